@@ -0,0 +1,277 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/dpt"
+	"github.com/vapourismo/knx-go/knx/registry"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// Topics below the prefix
+const (
+	topicStatus = "status"
+	topicRaw    = "raw"
+	topicValue  = "value"
+	topicSet    = "set"
+	topicSetRaw = "setraw"
+	topicRead   = "read"
+)
+
+// A publisher sends MQTT messages.
+type publisher interface {
+	Publish(msg mqttMessage) error
+}
+
+// A bridge relays group communication between KNX and MQTT.
+type bridge struct {
+	client knx.GroupClient
+	broker publisher
+	reg    *registry.Registry
+	prefix string
+	qos    byte
+	retain bool
+}
+
+// topic builds the topic of the given kind for the group address, e.g. "knx/value/1/2/3".
+func (b *bridge) topic(kind string, addr cemi.GroupAddr) string {
+	return b.prefix + "/" + kind + "/" + b.reg.Format(addr)
+}
+
+// jsonValue determines the JSON representation of a decoded value. Booleans and numbers keep
+// their type, everything else is given as text.
+func jsonValue(name string, value dpt.Datapoint) interface{} {
+	mainType := strings.SplitN(name, ".", 2)[0]
+
+	switch {
+	case mainType == "1":
+		return value.Float() != 0
+
+	case dpt.IsNumeric(name):
+		return value.Float()
+	}
+
+	return strings.TrimSpace(value.String())
+}
+
+// A state is the decoded value of a group address as published on the value topic.
+type state struct {
+	Value   interface{} `json:"value"`
+	Text    string      `json:"text"`
+	Unit    string      `json:"unit,omitempty"`
+	DPT     string      `json:"dpt"`
+	Name    string      `json:"name,omitempty"`
+	Source  string      `json:"source"`
+	Command string      `json:"command"`
+	Time    time.Time   `json:"time"`
+}
+
+// decode turns the payload into a state, if the datapoint type of the group address is known.
+func (b *bridge) decode(event knx.GroupEvent, now time.Time) (*state, error) {
+	entry, ok := b.reg.Lookup(event.Destination)
+	if !ok || entry.DPT == "" {
+		return nil, nil
+	}
+
+	value, ok := dpt.Produce(entry.DPT)
+	if !ok {
+		return nil, fmt.Errorf("unknown datapoint type %q", entry.DPT)
+	}
+
+	if err := value.Unpack(event.Data); err != nil {
+		return nil, err
+	}
+
+	text := strings.TrimSpace(value.String())
+	if unit := value.Unit(); unit != "" && !strings.HasSuffix(text, unit) {
+		text += " " + unit
+	}
+
+	return &state{
+		Value:   jsonValue(entry.DPT, value),
+		Text:    text,
+		Unit:    value.Unit(),
+		DPT:     entry.DPT,
+		Name:    entry.FullName(),
+		Source:  event.Source.String(),
+		Command: event.Command.String(),
+		Time:    now,
+	}, nil
+}
+
+// publishEvent publishes the payload of a write or response on the raw topic and, if the
+// datapoint type is known, its decoded value on the value topic.
+func (b *bridge) publishEvent(event knx.GroupEvent) error {
+	if event.Command == knx.GroupRead {
+		return nil
+	}
+
+	if err := b.broker.Publish(mqttMessage{
+		Topic:   b.topic(topicRaw, event.Destination),
+		Payload: event.Data,
+		QoS:     b.qos,
+		Retain:  b.retain,
+	}); err != nil {
+		return err
+	}
+
+	st, err := b.decode(event, time.Now())
+	if err != nil {
+		util.Log(b, "Cannot decode %v: %v", event.Destination, err)
+		return nil
+	} else if st == nil {
+		return nil
+	}
+
+	payload, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+
+	return b.broker.Publish(mqttMessage{
+		Topic:   b.topic(topicValue, event.Destination),
+		Payload: payload,
+		QoS:     b.qos,
+		Retain:  b.retain,
+	})
+}
+
+// commandValue is the JSON form of a value given on the set topic.
+type commandValue struct {
+	Value json.RawMessage `json:"value"`
+	DPT   string          `json:"dpt"`
+}
+
+// parseSetPayload extracts the value text and an optional datapoint type from the payload of a
+// set command. The payload is plain text, a JSON value or an object with value and dpt.
+func parseSetPayload(payload []byte) (string, string, error) {
+	text := strings.TrimSpace(string(payload))
+	if text == "" {
+		return "", "", errors.New("payload is empty")
+	}
+
+	var (
+		raw     = json.RawMessage(text)
+		dptName string
+	)
+
+	if strings.HasPrefix(text, "{") {
+		var cmd commandValue
+		if err := json.Unmarshal(raw, &cmd); err != nil {
+			return "", "", err
+		}
+
+		raw, dptName = cmd.Value, cmd.DPT
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		// Not JSON, take the text as is.
+		return text, dptName, nil
+	}
+
+	switch value := value.(type) {
+	case bool:
+		return strconv.FormatBool(value), dptName, nil
+
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), dptName, nil
+
+	case string:
+		return value, dptName, nil
+	}
+
+	return "", "", errors.New("value must be a boolean, number or string")
+}
+
+// handleCommand processes a message received on one of the command topics.
+func (b *bridge) handleCommand(msg mqttMessage) error {
+	rest := strings.TrimPrefix(msg.Topic, b.prefix+"/")
+	if rest == msg.Topic {
+		return fmt.Errorf("unexpected topic %q", msg.Topic)
+	}
+
+	kind, target, ok := strings.Cut(rest, "/")
+	if !ok {
+		return fmt.Errorf("topic %q has no group address", msg.Topic)
+	}
+
+	addr, err := b.reg.Resolve(target)
+	if err != nil {
+		return err
+	}
+
+	event := knx.GroupEvent{Command: knx.GroupWrite, Destination: addr}
+
+	switch kind {
+	case topicRead:
+		event.Command = knx.GroupRead
+
+	case topicSetRaw:
+		text := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(string(msg.Payload))), "0x")
+		if event.Data, err = hex.DecodeString(strings.ReplaceAll(text, " ", "")); err != nil {
+			return err
+		} else if len(event.Data) == 0 {
+			return errors.New("payload is empty")
+		}
+
+	case topicSet:
+		text, dptName, err := parseSetPayload(msg.Payload)
+		if err != nil {
+			return err
+		}
+
+		if dptName == "" {
+			if entry, ok := b.reg.Lookup(addr); ok {
+				dptName = entry.DPT
+			}
+		}
+
+		if dptName == "" {
+			return fmt.Errorf("datapoint type of %s is unknown", b.reg.Format(addr))
+		}
+
+		if event.Data, err = dpt.Encode(dptName, text); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("unknown command %q", kind)
+	}
+
+	return b.client.Send(event)
+}
+
+// commandFilters returns the topic filters of the command topics.
+func (b *bridge) commandFilters() []string {
+	return []string{
+		b.prefix + "/" + topicSet + "/#",
+		b.prefix + "/" + topicSetRaw + "/#",
+		b.prefix + "/" + topicRead + "/#",
+	}
+}
+
+// statusMessage is the retained message on the status topic.
+func (b *bridge) statusMessage(online bool) mqttMessage {
+	payload := "offline"
+	if online {
+		payload = "online"
+	}
+
+	return mqttMessage{
+		Topic:   b.prefix + "/" + topicStatus,
+		Payload: []byte(payload),
+		QoS:     1,
+		Retain:  true,
+	}
+}
@@ -0,0 +1,291 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testBroker is an in-process MQTT broker which supports what the bridge needs: QoS 0 and 1,
+// retained messages, wildcards and last wills. Subscriptions to topics below "forbidden/" are
+// refused.
+type testBroker struct {
+	listener net.Listener
+	mu       sync.Mutex
+	retained map[string]mqttMessage
+	sessions map[*brokerSession]struct{}
+	mute     bool
+}
+
+// brokerSession is the connection of a client to the test broker.
+type brokerSession struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+	filters []string
+	will    *mqttMessage
+}
+
+func (session *brokerSession) write(header byte, body []byte) {
+	packet, _ := encodePacket(header, body)
+
+	session.writeMu.Lock()
+	defer session.writeMu.Unlock()
+
+	session.conn.Write(packet)
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broker := &testBroker{
+		listener: listener,
+		retained: map[string]mqttMessage{},
+		sessions: map[*brokerSession]struct{}{},
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go broker.serve(conn)
+		}
+	}()
+
+	t.Cleanup(func() {
+		listener.Close()
+
+		broker.mu.Lock()
+		defer broker.mu.Unlock()
+
+		for session := range broker.sessions {
+			session.conn.Close()
+		}
+	})
+
+	return broker
+}
+
+// Addr returns the address on which the broker listens.
+func (broker *testBroker) Addr() string {
+	return broker.listener.Addr().String()
+}
+
+// Mute stops the broker from answering pings.
+func (broker *testBroker) Mute() {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	broker.mute = true
+}
+
+// Retained returns the retained message of the topic.
+func (broker *testBroker) Retained(topic string) (mqttMessage, bool) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	msg, ok := broker.retained[topic]
+	return msg, ok
+}
+
+// matchTopic checks whether the topic matches the filter.
+func matchTopic(filter, topic string) bool {
+	filterLevels, topicLevels := strings.Split(filter, "/"), strings.Split(topic, "/")
+
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+
+		if i >= len(topicLevels) || (level != "+" && level != topicLevels[i]) {
+			return false
+		}
+	}
+
+	return len(filterLevels) == len(topicLevels)
+}
+
+// route stores retained messages and forwards the message to the subscribers.
+func (broker *testBroker) route(msg mqttMessage) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	if msg.Retain {
+		if len(msg.Payload) == 0 {
+			delete(broker.retained, msg.Topic)
+		} else {
+			broker.retained[msg.Topic] = msg
+		}
+	}
+
+	body := append(appendString(nil, msg.Topic), msg.Payload...)
+
+	for session := range broker.sessions {
+		for _, filter := range session.filters {
+			if matchTopic(filter, msg.Topic) {
+				session.write(mqttPublish<<4, body)
+				break
+			}
+		}
+	}
+}
+
+// readString reads a length-prefixed string.
+func readString(body []byte) (string, []byte, error) {
+	if len(body) < 2 || len(body) < 2+int(binary.BigEndian.Uint16(body)) {
+		return "", nil, errors.New("string is truncated")
+	}
+
+	length := int(binary.BigEndian.Uint16(body))
+	return string(body[2 : 2+length]), body[2+length:], nil
+}
+
+// connect parses a CONNECT packet and remembers the will.
+func (session *brokerSession) connect(body []byte) error {
+	if len(body) < 10 {
+		return errors.New("connect packet is truncated")
+	}
+
+	flags := body[7]
+
+	// Skip the client identifier.
+	_, rest, err := readString(body[10:])
+	if err != nil {
+		return err
+	}
+
+	if flags&0x04 != 0 {
+		topic, rest2, err := readString(rest)
+		if err != nil {
+			return err
+		}
+
+		payload, _, err := readString(rest2)
+		if err != nil {
+			return err
+		}
+
+		session.will = &mqttMessage{Topic: topic, Payload: []byte(payload), Retain: flags&0x20 != 0}
+	}
+
+	return nil
+}
+
+func (broker *testBroker) serve(conn net.Conn) {
+	session := &brokerSession{conn: conn}
+	reader := bufio.NewReader(conn)
+
+	defer func() {
+		conn.Close()
+
+		broker.mu.Lock()
+		delete(broker.sessions, session)
+		broker.mu.Unlock()
+
+		if session.will != nil {
+			broker.route(*session.will)
+		}
+	}()
+
+	header, body, err := readPacket(reader)
+	if err != nil || header>>4 != mqttConnect || session.connect(body) != nil {
+		return
+	}
+
+	session.write(mqttConnAck<<4, []byte{0, 0})
+
+	broker.mu.Lock()
+	broker.sessions[session] = struct{}{}
+	broker.mu.Unlock()
+
+	for {
+		header, body, err := readPacket(reader)
+		if err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case mqttPublish:
+			msg, id, err := parsePublish(header, body)
+			if err != nil {
+				return
+			}
+
+			if msg.QoS == 1 {
+				session.write(mqttPubAck<<4, appendUint16(nil, id))
+			}
+
+			broker.route(msg)
+
+		case mqttSubscribe:
+			if len(body) < 2 {
+				return
+			}
+
+			id, rest := binary.BigEndian.Uint16(body), body[2:]
+
+			var filters []string
+			for len(rest) > 0 {
+				filter, rest2, err := readString(rest)
+				if err != nil || len(rest2) == 0 {
+					return
+				}
+
+				filters, rest = append(filters, filter), rest2[1:]
+			}
+
+			broker.mu.Lock()
+			session.filters = append(session.filters, filters...)
+
+			var retained []mqttMessage
+			for _, msg := range broker.retained {
+				for _, filter := range filters {
+					if matchTopic(filter, msg.Topic) {
+						retained = append(retained, msg)
+						break
+					}
+				}
+			}
+			broker.mu.Unlock()
+
+			ack := appendUint16(nil, id)
+			for _, filter := range filters {
+				if strings.HasPrefix(filter, "forbidden/") {
+					ack = append(ack, mqttSubscriptionRefused)
+				} else {
+					ack = append(ack, 0)
+				}
+			}
+
+			session.write(mqttSubAck<<4, ack)
+
+			for _, msg := range retained {
+				session.write(mqttPublish<<4|0x01, append(appendString(nil, msg.Topic), msg.Payload...))
+			}
+
+		case mqttPingReq:
+			broker.mu.Lock()
+			mute := broker.mute
+			broker.mu.Unlock()
+
+			if !mute {
+				session.write(0xD0, nil)
+			}
+
+		case mqttDisconnect:
+			session.will = nil
+			return
+		}
+	}
+}
@@ -0,0 +1,456 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/dpt"
+	"github.com/vapourismo/knx-go/knx/registry"
+)
+
+// An entityConfig groups several group addresses into one Home Assistant entity. Addresses are
+// given by role, as group address or full name:
+//
+//	switch         command, state
+//	light          command, state, brightness, brightness_state
+//	cover          move, position, position_state
+//	climate        temperature, setpoint, setpoint_state
+//	sensor         state
+//	binary_sensor  state
+//
+// State addresses default to their command counterpart.
+type entityConfig struct {
+	Component   string            `json:"component"`
+	Name        string            `json:"name"`
+	DeviceClass string            `json:"device_class,omitempty"`
+	Addresses   map[string]string `json:"addresses"`
+}
+
+// An entity is a Home Assistant entity backed by one or more group addresses.
+type entity struct {
+	component   string
+	name        string
+	deviceClass string
+	roles       map[string]cemi.GroupAddr
+}
+
+// entityRoles lists the roles each component understands. The first role is required.
+var entityRoles = map[string][]string{
+	"switch":        {"command", "state"},
+	"light":         {"command", "state", "brightness", "brightness_state"},
+	"cover":         {"move", "position", "position_state"},
+	"climate":       {"setpoint", "setpoint_state", "temperature"},
+	"sensor":        {"state"},
+	"binary_sensor": {"state"},
+}
+
+// stateRoles maps state roles to the command roles they default to.
+var stateRoles = map[string]string{
+	"state":            "command",
+	"brightness_state": "brightness",
+	"position_state":   "position",
+	"setpoint_state":   "setpoint",
+}
+
+// loadEntities reads the entity configuration from a JSON file.
+func loadEntities(name string, reg *registry.Registry) ([]*entity, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []entityConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, err
+	}
+
+	entities := make([]*entity, 0, len(configs))
+
+	for _, config := range configs {
+		e, err := config.resolve(reg)
+		if err != nil {
+			return nil, err
+		}
+
+		entities = append(entities, e)
+	}
+
+	return entities, nil
+}
+
+// resolve checks the configuration and resolves its addresses.
+func (config *entityConfig) resolve(reg *registry.Registry) (*entity, error) {
+	roles, ok := entityRoles[config.Component]
+	if !ok {
+		return nil, fmt.Errorf("entity %q: unknown component %q", config.Name, config.Component)
+	}
+
+	e := &entity{
+		component:   config.Component,
+		name:        config.Name,
+		deviceClass: config.DeviceClass,
+		roles:       map[string]cemi.GroupAddr{},
+	}
+
+	for role, target := range config.Addresses {
+		known := false
+		for _, r := range roles {
+			known = known || r == role
+		}
+
+		if !known {
+			return nil, fmt.Errorf("entity %q: %s does not have role %q", config.Name, config.Component, role)
+		}
+
+		addr, err := reg.Resolve(target)
+		if err != nil {
+			return nil, fmt.Errorf("entity %q: %w", config.Name, err)
+		}
+
+		e.roles[role] = addr
+	}
+
+	if _, ok := e.roles[roles[0]]; !ok {
+		return nil, fmt.Errorf("entity %q: %s requires role %q", config.Name, config.Component, roles[0])
+	}
+
+	for state, command := range stateRoles {
+		if _, ok := e.roles[state]; !ok {
+			if addr, ok := e.roles[command]; ok {
+				e.roles[state] = addr
+			}
+		}
+	}
+
+	if e.name == "" {
+		e.name = reg.Describe(e.roles[roles[0]])
+	}
+
+	return e, nil
+}
+
+// statusSuffixes mark group addresses which report the state of the address with the same name
+// without the suffix.
+var statusSuffixes = []string{" status", " state", " feedback"}
+
+// binaryDeviceClasses maps the datapoint types of DPT 1 to binary sensor device classes.
+var binaryDeviceClasses = map[string]string{
+	"1.002": "",
+	"1.005": "problem",
+	"1.009": "opening",
+	"1.011": "",
+	"1.018": "occupancy",
+	"1.019": "window",
+}
+
+// sensorDeviceClasses maps datapoint types to sensor device classes.
+var sensorDeviceClasses = map[string]string{
+	"7.013":  "illuminance",
+	"9.001":  "temperature",
+	"9.004":  "illuminance",
+	"9.005":  "wind_speed",
+	"9.006":  "pressure",
+	"9.007":  "humidity",
+	"9.008":  "carbon_dioxide",
+	"9.020":  "voltage",
+	"9.021":  "current",
+	"9.024":  "power",
+	"13.010": "energy",
+	"13.013": "energy",
+	"14.019": "current",
+	"14.027": "voltage",
+	"14.056": "power",
+	"14.068": "temperature",
+}
+
+// autoEntity derives an entity from the datapoint type of a single group address.
+func autoEntity(entry *registry.Entry) *entity {
+	e := &entity{name: entry.FullName(), roles: map[string]cemi.GroupAddr{}}
+
+	switch {
+	case entry.DPT == "1.001" || entry.DPT == "1.003":
+		e.component = "switch"
+		e.roles["command"] = entry.Address
+		e.roles["state"] = entry.Address
+
+	case entry.DPT == "1.008":
+		e.component = "cover"
+		e.roles["move"] = entry.Address
+
+	case entry.DPT == "5.001":
+		e.component = "light"
+		e.roles["brightness"] = entry.Address
+		e.roles["brightness_state"] = entry.Address
+
+	case strings.HasPrefix(entry.DPT, "1."):
+		deviceClass, ok := binaryDeviceClasses[entry.DPT]
+		if !ok {
+			return nil
+		}
+
+		e.component = "binary_sensor"
+		e.deviceClass = deviceClass
+		e.roles["state"] = entry.Address
+
+	default:
+		if _, ok := dpt.Produce(entry.DPT); !ok {
+			return nil
+		}
+
+		e.component = "sensor"
+		e.deviceClass = sensorDeviceClasses[entry.DPT]
+		e.roles["state"] = entry.Address
+	}
+
+	return e
+}
+
+// autoEntities derives entities from the group addresses in the registry that are not used by
+// the configured entities. Addresses named like another address plus a status suffix become the
+// state of that entity.
+func autoEntities(reg *registry.Registry, configured []*entity) []*entity {
+	used := map[cemi.GroupAddr]bool{}
+	for _, e := range configured {
+		for _, addr := range e.roles {
+			used[addr] = true
+		}
+	}
+
+	byName := map[string]*entity{}
+	var entities []*entity
+
+	all := reg.All()
+	var status []*registry.Entry
+
+	for i := range all {
+		entry := &all[i]
+		if used[entry.Address] || entry.DPT == "" {
+			continue
+		}
+
+		lower := strings.ToLower(entry.FullName())
+
+		isStatus := false
+		for _, suffix := range statusSuffixes {
+			isStatus = isStatus || strings.HasSuffix(lower, suffix)
+		}
+
+		if isStatus {
+			status = append(status, entry)
+			continue
+		}
+
+		if e := autoEntity(entry); e != nil {
+			entities = append(entities, e)
+			byName[lower] = e
+		}
+	}
+
+	for _, entry := range status {
+		lower := strings.ToLower(entry.FullName())
+
+		var base *entity
+		for _, suffix := range statusSuffixes {
+			if strings.HasSuffix(lower, suffix) {
+				base = byName[strings.TrimSuffix(lower, suffix)]
+				break
+			}
+		}
+
+		if base != nil {
+			switch {
+			case base.component == "switch" && strings.HasPrefix(entry.DPT, "1."):
+				base.roles["state"] = entry.Address
+				continue
+
+			case base.component == "light" && entry.DPT == "5.001":
+				base.roles["brightness_state"] = entry.Address
+				continue
+			}
+		}
+
+		if e := autoEntity(entry); e != nil {
+			entities = append(entities, e)
+		}
+	}
+
+	return entities
+}
+
+// haUnits maps units to the spelling Home Assistant expects.
+var haUnits = map[string]string{
+	"lux": "lx",
+}
+
+// unitOf returns the unit of the datapoint type of the group address.
+func unitOf(reg *registry.Registry, addr cemi.GroupAddr) string {
+	entry, ok := reg.Lookup(addr)
+	if !ok {
+		return ""
+	}
+
+	value, ok := dpt.Produce(entry.DPT)
+	if !ok {
+		return ""
+	}
+
+	if unit, ok := haUnits[value.Unit()]; ok {
+		return unit
+	}
+
+	return value.Unit()
+}
+
+// Value templates
+const (
+	numberTemplate = "{{ value_json.value }}"
+	switchTemplate = "{{ 'ON' if value_json.value else 'OFF' }}"
+)
+
+// discoveryConfig generates the discovery topic and payload of the entity.
+func (b *bridge) discoveryConfig(discoveryPrefix string, e *entity) (string, []byte, error) {
+	id := e.component
+	for _, role := range entityRoles[e.component] {
+		if addr, ok := e.roles[role]; ok {
+			id += "_" + strings.ReplaceAll(addr.String(), "/", "_")
+		}
+	}
+
+	payload := map[string]interface{}{
+		"name":                  e.name,
+		"unique_id":             b.prefix + "_" + id,
+		"availability_topic":    b.prefix + "/" + topicStatus,
+		"payload_available":     "online",
+		"payload_not_available": "offline",
+		"device": map[string]interface{}{
+			"identifiers": []string{b.prefix},
+			"name":        "KNX",
+		},
+	}
+
+	if e.deviceClass != "" {
+		payload["device_class"] = e.deviceClass
+	}
+
+	set := func(key, kind, role string) {
+		if addr, ok := e.roles[role]; ok {
+			payload[key] = b.topic(kind, addr)
+		}
+	}
+
+	switch e.component {
+	case "switch", "light":
+		set("command_topic", topicSet, "command")
+		set("state_topic", topicValue, "state")
+		payload["payload_on"] = "on"
+		payload["payload_off"] = "off"
+
+		if e.component == "switch" {
+			payload["value_template"] = switchTemplate
+			payload["state_on"] = "ON"
+			payload["state_off"] = "OFF"
+		} else {
+			payload["state_value_template"] = switchTemplate
+
+			if _, ok := e.roles["brightness"]; ok {
+				set("brightness_command_topic", topicSet, "brightness")
+				set("brightness_state_topic", topicValue, "brightness_state")
+				payload["brightness_scale"] = 100
+				payload["brightness_value_template"] = numberTemplate
+
+				// Dimmers without switching address are switched by their brightness.
+				if _, ok := e.roles["command"]; !ok {
+					payload["command_topic"] = payload["brightness_command_topic"]
+					payload["on_command_type"] = "brightness"
+				}
+			}
+		}
+
+	case "cover":
+		// DPT 1.008 is 0 for up and 1 for down. KNX positions count from open to closed.
+		set("command_topic", topicSet, "move")
+		payload["payload_open"] = "0"
+		payload["payload_close"] = "1"
+		payload["payload_stop"] = nil
+
+		if _, ok := e.roles["position"]; ok {
+			set("set_position_topic", topicSet, "position")
+			set("position_topic", topicValue, "position_state")
+			payload["position_template"] = numberTemplate
+			payload["position_open"] = 0
+			payload["position_closed"] = 100
+		}
+
+	case "climate":
+		set("temperature_command_topic", topicSet, "setpoint")
+		set("temperature_state_topic", topicValue, "setpoint_state")
+		set("current_temperature_topic", topicValue, "temperature")
+		payload["temperature_state_template"] = numberTemplate
+		payload["current_temperature_template"] = numberTemplate
+		payload["modes"] = []string{"heat"}
+		payload["temperature_unit"] = "C"
+
+	case "sensor":
+		set("state_topic", topicValue, "state")
+		payload["value_template"] = numberTemplate
+
+		addr := e.roles["state"]
+		if unit := unitOf(b.reg, addr); unit != "" {
+			payload["unit_of_measurement"] = unit
+		}
+
+		if entry, ok := b.reg.Lookup(addr); ok {
+			if dpt.IsNumeric(entry.DPT) {
+				payload["state_class"] = "measurement"
+
+				if e.deviceClass == "energy" {
+					payload["state_class"] = "total_increasing"
+				}
+			}
+		}
+
+	case "binary_sensor":
+		set("state_topic", topicValue, "state")
+		payload["value_template"] = switchTemplate
+		payload["payload_on"] = "ON"
+		payload["payload_off"] = "OFF"
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return fmt.Sprintf("%s/%s/%s/%s/config", discoveryPrefix, e.component, b.prefix, id), data, nil
+}
+
+// publishDiscovery publishes the retained discovery configuration of the entities.
+func (b *bridge) publishDiscovery(discoveryPrefix string, entities []*entity) error {
+	sort.Slice(entities, func(i, j int) bool {
+		return entities[i].name < entities[j].name
+	})
+
+	for _, e := range entities {
+		topic, payload, err := b.discoveryConfig(discoveryPrefix, e)
+		if err != nil {
+			return err
+		}
+
+		if err := b.broker.Publish(mqttMessage{
+			Topic:   topic,
+			Payload: payload,
+			QoS:     1,
+			Retain:  true,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/registry"
+)
+
+func discoveryRegistry() *registry.Registry {
+	reg := registry.New()
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 1, 1), Name: "Light", Path: []string{"Kitchen"}, DPT: "1.001"})
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 1, 2), Name: "Light status", Path: []string{"Kitchen"}, DPT: "1.001"})
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 1, 3), Name: "Dimmer", Path: []string{"Hall"}, DPT: "5.001"})
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 2, 1), Name: "Temperature", Path: []string{"Kitchen"}, DPT: "9.001"})
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 2, 2), Name: "Window", Path: []string{"Kitchen"}, DPT: "1.019"})
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 2, 3), Name: "Untyped"})
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(2, 1, 1), Name: "Switch", Path: []string{"Living"}, DPT: "1.001"})
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(2, 1, 2), Name: "Brightness", Path: []string{"Living"}, DPT: "5.001"})
+	return reg
+}
+
+func TestAutoEntities(t *testing.T) {
+	reg := discoveryRegistry()
+
+	configured := []*entity{{
+		component: "light",
+		name:      "Living",
+		roles:     map[string]cemi.GroupAddr{"command": cemi.NewGroupAddr3(2, 1, 1), "brightness": cemi.NewGroupAddr3(2, 1, 2)},
+	}}
+
+	entities := autoEntities(reg, configured)
+
+	byName := map[string]*entity{}
+	for _, e := range entities {
+		byName[e.name] = e
+	}
+
+	if len(entities) != 4 {
+		t.Fatalf("Expected 4 entities, got %d", len(entities))
+	}
+
+	if e := byName["Kitchen/Light"]; e == nil || e.component != "switch" ||
+		e.roles["command"] != cemi.NewGroupAddr3(1, 1, 1) || e.roles["state"] != cemi.NewGroupAddr3(1, 1, 2) {
+		t.Errorf("Unexpected switch %+v", e)
+	}
+
+	if e := byName["Hall/Dimmer"]; e == nil || e.component != "light" || e.roles["brightness"] != cemi.NewGroupAddr3(1, 1, 3) {
+		t.Errorf("Unexpected light %+v", e)
+	}
+
+	if e := byName["Kitchen/Temperature"]; e == nil || e.component != "sensor" || e.deviceClass != "temperature" {
+		t.Errorf("Unexpected sensor %+v", e)
+	}
+
+	if e := byName["Kitchen/Window"]; e == nil || e.component != "binary_sensor" || e.deviceClass != "window" {
+		t.Errorf("Unexpected binary sensor %+v", e)
+	}
+}
+
+func TestLoadEntities(t *testing.T) {
+	reg := discoveryRegistry()
+	dir := t.TempDir()
+
+	load := func(content string) ([]*entity, error) {
+		name := filepath.Join(dir, "entities.json")
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		return loadEntities(name, reg)
+	}
+
+	entities, err := load(`[{"component": "light", "addresses": {"command": "Living/Switch", "brightness": "2/1/2"}}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entities) != 1 {
+		t.Fatalf("Expected 1 entity, got %d", len(entities))
+	}
+
+	e := entities[0]
+	if e.name != "2/1/1 Living/Switch" || e.roles["state"] != cemi.NewGroupAddr3(2, 1, 1) ||
+		e.roles["brightness_state"] != cemi.NewGroupAddr3(2, 1, 2) {
+		t.Errorf("Unexpected entity %+v", e)
+	}
+
+	for _, content := range []string{
+		`[{"component": "fan", "addresses": {"command": "1/1/1"}}]`,
+		`[{"component": "switch", "addresses": {"command": "1/1/1", "speed": "1/1/2"}}]`,
+		`[{"component": "switch", "addresses": {"state": "1/1/1"}}]`,
+		`[{"component": "switch", "addresses": {"command": "Nowhere"}}]`,
+	} {
+		if _, err := load(content); err == nil {
+			t.Errorf("%s was accepted", content)
+		}
+	}
+}
+
+func TestPublishDiscovery(t *testing.T) {
+	broker := newTestBroker(t)
+
+	client, err := dialMQTT(broker.Addr(), mqttOptions{ClientID: "discovery", Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	reg := discoveryRegistry()
+	b := &bridge{broker: client, reg: reg, prefix: "knx"}
+
+	if err := b.publishDiscovery("homeassistant", autoEntities(reg, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	config := func(topic string) map[string]interface{} {
+		msg, ok := broker.Retained(topic)
+		if !ok {
+			t.Fatalf("No configuration on %s", topic)
+		}
+
+		var payload map[string]interface{}
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			t.Fatal(err)
+		}
+
+		return payload
+	}
+
+	sensor := config("homeassistant/sensor/knx/sensor_1_2_1/config")
+	if sensor["state_topic"] != "knx/value/1/2/1" || sensor["unit_of_measurement"] != "°C" ||
+		sensor["device_class"] != "temperature" || sensor["availability_topic"] != "knx/status" {
+		t.Errorf("Unexpected sensor configuration %v", sensor)
+	}
+
+	sw := config("homeassistant/switch/knx/switch_1_1_1_1_1_2/config")
+	if sw["command_topic"] != "knx/set/1/1/1" || sw["state_topic"] != "knx/value/1/1/2" {
+		t.Errorf("Unexpected switch configuration %v", sw)
+	}
+
+	light := config("homeassistant/light/knx/light_1_1_3_1_1_3/config")
+	if light["command_topic"] != "knx/set/1/1/3" || light["on_command_type"] != "brightness" ||
+		light["brightness_state_topic"] != "knx/value/1/1/3" {
+		t.Errorf("Unexpected light configuration %v", light)
+	}
+}
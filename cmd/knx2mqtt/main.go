@@ -0,0 +1,238 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Command knx2mqtt relays group communication between a KNX network and an MQTT broker.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/registry"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+const usage = `Usage: %s [flags]
+
+Group values are published below the prefix:
+
+  <prefix>/status            "online" or "offline" (retained, last will)
+  <prefix>/raw/<ga>          payload of writes and responses as sent on the bus
+  <prefix>/value/<ga>        decoded value as JSON, if the datapoint type is known
+
+Commands are accepted on:
+
+  <prefix>/set/<ga>          write a value, given as text, JSON or {"value": 21.5, "dpt": "9.001"}
+  <prefix>/setraw/<ga>       write a hexadecimal payload, e.g. 000C1A
+  <prefix>/read/<ga>         send a read request, the response appears on the value topic
+
+With -discovery, Home Assistant entities are announced for the group addresses with a known
+datapoint type. The file given by -entities groups several addresses into one entity:
+
+  [{"component": "light", "name": "Kitchen", "addresses": {"command": "1/1/1",
+    "state": "1/1/2", "brightness": "1/1/3", "brightness_state": "1/1/4"}}]
+
+Components are switch, light, cover, climate, sensor and binary_sensor.
+
+Group addresses in topics follow -style; commands also accept names from the project. The
+address is a gateway (10.0.0.2:3671), a multicast group (224.0.23.12:3671) or a URL as accepted
+by knx.Dial. It defaults to $KNX_ADDRESS.
+
+Flags:
+`
+
+// serve relays between both sides until one of them fails. Commands are handled on their own
+// goroutine, so that waiting for the broker to acknowledge a publication cannot hold up the
+// delivery of commands.
+func (b *bridge) serve(broker *mqttClient) error {
+	go func() {
+		for msg := range broker.Messages() {
+			if err := b.handleCommand(msg); err != nil {
+				util.Log(b, "Command on %s failed: %v", msg.Topic, err)
+			}
+		}
+	}()
+
+	for {
+		select {
+		case event, open := <-b.client.Inbound():
+			if !open {
+				return errors.New("KNX connection has been closed")
+			}
+
+			if err := b.publishEvent(event); err != nil {
+				return err
+			}
+
+		case <-broker.Done():
+			return broker.Err()
+		}
+	}
+}
+
+// connect establishes both connections and announces the bridge as online.
+func connect(config *config) (*bridge, *mqttClient, func(), error) {
+	client, err := knx.DialGroup(config.address)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	b := &bridge{
+		client: client,
+		reg:    config.reg,
+		prefix: config.prefix,
+		qos:    config.qos,
+		retain: config.retain,
+	}
+
+	will := b.statusMessage(false)
+	config.mqtt.Will = &will
+
+	broker, err := dialMQTT(config.broker, config.mqtt)
+	if err != nil {
+		client.Close()
+		return nil, nil, nil, err
+	}
+
+	b.broker = broker
+
+	closeAll := func() {
+		broker.Publish(b.statusMessage(false))
+		broker.Close()
+		client.Close()
+	}
+
+	if err := broker.Subscribe(b.commandFilters()...); err != nil {
+		closeAll()
+		return nil, nil, nil, err
+	}
+
+	if err := broker.Publish(b.statusMessage(true)); err != nil {
+		closeAll()
+		return nil, nil, nil, err
+	}
+
+	if config.discoveryPrefix != "" {
+		if err := b.publishDiscovery(config.discoveryPrefix, config.entities); err != nil {
+			closeAll()
+			return nil, nil, nil, err
+		}
+	}
+
+	return b, broker, closeAll, nil
+}
+
+// config is the configuration given on the command line.
+type config struct {
+	address string
+	broker  string
+	prefix  string
+	qos     byte
+	retain  bool
+	reg     *registry.Registry
+	mqtt    mqttOptions
+
+	// Home Assistant discovery is disabled if the prefix is empty.
+	discoveryPrefix string
+	entities        []*entity
+}
+
+func main() {
+	address := flag.String("address", os.Getenv("KNX_ADDRESS"), "gateway, multicast group or URL")
+	brokerAddr := flag.String("broker", "tcp://localhost:1883", "address of the MQTT broker")
+	clientID := flag.String("client-id", "knx2mqtt", "MQTT client identifier")
+	username := flag.String("username", "", "MQTT user name")
+	password := flag.String("password", os.Getenv("MQTT_PASSWORD"), "MQTT password, defaults to $MQTT_PASSWORD")
+	prefix := flag.String("prefix", "knx", "topic prefix")
+	qos := flag.Uint("qos", 0, "QoS of the published messages, either 0 or 1")
+	retain := flag.Bool("retain", true, "retain the published group values")
+	keepAlive := flag.Duration("keepalive", 30*time.Second, "MQTT keep alive interval")
+	project := flag.String("project", "", "ETS project, group address CSV or XML export")
+	projectPassword := flag.String("project-password", "", "password of the ETS project")
+	style := flag.String("style", "3", "group address style in topics, either 3, 2 or free")
+	discovery := flag.String("discovery", "", "Home Assistant discovery prefix, e.g. homeassistant")
+	entities := flag.String("entities", "", "JSON file which groups addresses into Home Assistant entities")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flag.NArg() != 0 || *address == "" || *qos > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+	util.Logger = logger
+
+	reg := registry.New()
+
+	if *project != "" {
+		var err error
+		if reg, err = registry.Load(*project, *projectPassword); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	addressStyle, err := registry.ParseAddressStyle(*style)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	reg.SetStyle(addressStyle)
+
+	var configured []*entity
+
+	if *entities != "" {
+		if configured, err = loadEntities(*entities, reg); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	cfg := &config{
+		address: *address,
+		broker:  *brokerAddr,
+		prefix:  strings.TrimSuffix(*prefix, "/"),
+		qos:     byte(*qos),
+		retain:  *retain,
+		reg:     reg,
+		mqtt: mqttOptions{
+			ClientID:  *clientID,
+			Username:  *username,
+			Password:  *password,
+			KeepAlive: *keepAlive,
+			Timeout:   10 * time.Second,
+		},
+		discoveryPrefix: strings.TrimSuffix(*discovery, "/"),
+		entities:        append(configured, autoEntities(reg, configured)...),
+	}
+
+	// Loop for ever. Failures don't matter, we'll always retry.
+	for {
+		b, broker, closeAll, err := connect(cfg)
+		if err != nil {
+			logger.Printf("Error while connecting: %v\n", err)
+
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		logger.Printf("Bridge is online\n")
+
+		if err := b.serve(broker); err != nil {
+			logger.Printf("Bridge terminated with error: %v\n", err)
+		}
+
+		closeAll()
+
+		time.Sleep(time.Second)
+	}
+}
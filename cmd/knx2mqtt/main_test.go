@@ -0,0 +1,192 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/registry"
+)
+
+func TestParseSetPayload(t *testing.T) {
+	cases := []struct {
+		payload string
+		text    string
+		dpt     string
+	}{
+		{"21.5", "21.5", ""},
+		{"on", "on", ""},
+		{"true", "true", ""},
+		{`"Hello"`, "Hello", ""},
+		{`{"value": 22, "dpt": "9.001"}`, "22", "9.001"},
+		{`{"value": false}`, "false", ""},
+	}
+
+	for _, c := range cases {
+		text, dptName, err := parseSetPayload([]byte(c.payload))
+		if err != nil || text != c.text || dptName != c.dpt {
+			t.Errorf("%s: got %q, %q, %v", c.payload, text, dptName, err)
+		}
+	}
+
+	for _, payload := range []string{"", "[1, 2]", `{"value": 1`} {
+		if _, _, err := parseSetPayload([]byte(payload)); err == nil {
+			t.Errorf("%q was accepted", payload)
+		}
+	}
+}
+
+func TestMatchTopic(t *testing.T) {
+	if !matchTopic("knx/set/#", "knx/set/1/2/3") || !matchTopic("knx/+/status", "knx/a/status") ||
+		matchTopic("knx/set/+", "knx/set/1/2") || matchTopic("knx/set", "knx/set/1") {
+		t.Error("Topics are not matched correctly")
+	}
+}
+
+// receive waits for a message on the topic.
+func receive(t *testing.T, client *mqttClient, topic string) mqttMessage {
+	timeout := time.After(2 * time.Second)
+
+	for {
+		select {
+		case msg := <-client.Messages():
+			if msg.Topic == topic {
+				return msg
+			}
+
+		case <-timeout:
+			t.Fatalf("No message on %s", topic)
+		}
+	}
+}
+
+// receiveEvent waits for a group event.
+func receiveEvent(t *testing.T, client knx.GroupClient) knx.GroupEvent {
+	select {
+	case event := <-client.Inbound():
+		return event
+
+	case <-time.After(2 * time.Second):
+		t.Fatal("No group event")
+	}
+
+	return knx.GroupEvent{}
+}
+
+func TestBridge(t *testing.T) {
+	broker := newTestBroker(t)
+
+	reg := registry.New()
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 2, 3), Name: "Temperature", Path: []string{"Kitchen"}, DPT: "9.001"})
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 2, 4), Name: "Light", DPT: "1.001"})
+
+	cfg := &config{
+		address: "bus://knx2mqtt-test",
+		broker:  "tcp://" + broker.Addr(),
+		prefix:  "knx",
+		retain:  true,
+		reg:     reg,
+		mqtt:    mqttOptions{ClientID: "knx2mqtt", Timeout: time.Second},
+	}
+
+	b, bridgeBroker, closeAll, err := connect(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeAll()
+
+	served := make(chan error, 1)
+	go func() { served <- b.serve(bridgeBroker) }()
+
+	device := knx.NewGroupTransport(knx.NamedBus("knx2mqtt-test").Connect())
+	defer device.Close()
+
+	observer, err := dialMQTT(broker.Addr(), mqttOptions{ClientID: "observer", Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer observer.Close()
+
+	if err := observer.Subscribe("knx/#"); err != nil {
+		t.Fatal(err)
+	}
+
+	if msg := receive(t, observer, "knx/status"); string(msg.Payload) != "online" || !msg.Retain {
+		t.Errorf("Unexpected status %+v", msg)
+	}
+
+	t.Run("Publish", func(t *testing.T) {
+		if err := device.Send(knx.GroupEvent{
+			Command:     knx.GroupWrite,
+			Destination: cemi.NewGroupAddr3(1, 2, 3),
+			Data:        []byte{0, 0x0C, 0x1A},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if msg := receive(t, observer, "knx/raw/1/2/3"); !bytes.Equal(msg.Payload, []byte{0, 0x0C, 0x1A}) {
+			t.Errorf("Unexpected raw payload %x", msg.Payload)
+		}
+
+		var st state
+		if err := json.Unmarshal(receive(t, observer, "knx/value/1/2/3").Payload, &st); err != nil {
+			t.Fatal(err)
+		}
+
+		if st.Value != 21.0 || st.Unit != "°C" || st.DPT != "9.001" || st.Name != "Kitchen/Temperature" ||
+			st.Command != "Write" {
+			t.Errorf("Unexpected state %+v", st)
+		}
+
+		if _, ok := broker.Retained("knx/value/1/2/3"); !ok {
+			t.Error("Value is not retained")
+		}
+	})
+
+	t.Run("Commands", func(t *testing.T) {
+		commands := []struct {
+			topic   string
+			payload string
+			event   knx.GroupEvent
+		}{
+			{"knx/set/1/2/4", "on", knx.GroupEvent{Command: knx.GroupWrite, Destination: cemi.NewGroupAddr3(1, 2, 4), Data: []byte{1}}},
+			{"knx/set/Kitchen/Temperature", `{"value": 21}`, knx.GroupEvent{Command: knx.GroupWrite, Destination: cemi.NewGroupAddr3(1, 2, 3), Data: []byte{0, 0x0C, 0x1A}}},
+			{"knx/setraw/1/2/5", "01", knx.GroupEvent{Command: knx.GroupWrite, Destination: cemi.NewGroupAddr3(1, 2, 5), Data: []byte{1}}},
+			{"knx/read/1/2/3", "", knx.GroupEvent{Command: knx.GroupRead, Destination: cemi.NewGroupAddr3(1, 2, 3), Data: []byte{0}}},
+		}
+
+		for _, c := range commands {
+			// Commands with errors are skipped.
+			observer.Publish(mqttMessage{Topic: "knx/set/1/2/5", Payload: []byte("1")})
+
+			if err := observer.Publish(mqttMessage{Topic: c.topic, Payload: []byte(c.payload), QoS: 1}); err != nil {
+				t.Fatal(err)
+			}
+
+			event := receiveEvent(t, device)
+			if event.Command != c.event.Command || event.Destination != c.event.Destination ||
+				(event.Command != knx.GroupRead && !bytes.Equal(event.Data, c.event.Data)) {
+				t.Errorf("%s: unexpected event %+v", c.topic, event)
+			}
+		}
+	})
+
+	t.Run("Will", func(t *testing.T) {
+		// Losing the connection makes the broker publish the will.
+		bridgeBroker.shutdown(errors.New("connection lost"))
+
+		if msg := receive(t, observer, "knx/status"); string(msg.Payload) != "offline" {
+			t.Errorf("Unexpected status %+v", msg)
+		}
+
+		if err := <-served; err == nil {
+			t.Error("Bridge did not notice the lost connection")
+		}
+	})
+}
@@ -0,0 +1,543 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MQTT 3.1.1 packet types
+const (
+	mqttConnect    = 1
+	mqttConnAck    = 2
+	mqttPublish    = 3
+	mqttPubAck     = 4
+	mqttSubscribe  = 8
+	mqttSubAck     = 9
+	mqttPingReq    = 12
+	mqttDisconnect = 14
+)
+
+// mqttSubscriptionRefused is the SUBACK return code of a refused subscription.
+const mqttSubscriptionRefused = 0x80
+
+// mqttMaxRemaining is the largest remaining length that can be encoded.
+const mqttMaxRemaining = 268435455
+
+// An mqttMessage is a message published to or received from the broker.
+type mqttMessage struct {
+	Topic   string
+	Payload []byte
+	QoS     byte
+	Retain  bool
+}
+
+// mqttOptions configures the connection to the broker.
+type mqttOptions struct {
+	ClientID  string
+	Username  string
+	Password  string
+	KeepAlive time.Duration
+	Timeout   time.Duration
+
+	// Will is published by the broker when the connection is lost.
+	Will *mqttMessage
+}
+
+// An mqttClient is a minimal MQTT 3.1.1 client. It publishes with QoS 0 or 1 and subscribes with
+// QoS 0.
+type mqttClient struct {
+	conn      net.Conn
+	timeout   time.Duration
+	keepAlive time.Duration
+	writeMu   sync.Mutex
+	mu        sync.Mutex
+	nextID    uint16
+	pending   map[uint16]chan []byte
+	messages  chan mqttMessage
+	done      chan struct{}
+	err       error
+	once      sync.Once
+}
+
+var errMQTTClosed = errors.New("mqtt connection is closed")
+
+// brokerAddress turns "tcp://host:port", "mqtt://host" or "host" into a host and port.
+func brokerAddress(address string) (string, error) {
+	if strings.Contains(address, "://") {
+		u, err := url.Parse(address)
+		if err != nil {
+			return "", err
+		}
+
+		if u.Scheme != "tcp" && u.Scheme != "mqtt" {
+			return "", fmt.Errorf("unsupported broker scheme %q", u.Scheme)
+		}
+
+		address = u.Host
+	}
+
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "1883")
+	}
+
+	return address, nil
+}
+
+// dialMQTT connects to the broker and waits for it to accept the connection.
+func dialMQTT(address string, opts mqttOptions) (*mqttClient, error) {
+	address, err := brokerAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("tcp", address, opts.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &mqttClient{
+		conn:      conn,
+		timeout:   opts.Timeout,
+		keepAlive: opts.KeepAlive,
+		pending:   map[uint16]chan []byte{},
+		messages:  make(chan mqttMessage, 64),
+		done:      make(chan struct{}),
+	}
+
+	reader := bufio.NewReader(conn)
+
+	conn.SetDeadline(time.Now().Add(opts.Timeout))
+
+	if err := client.write(mqttConnect<<4, connectPayload(opts)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	header, body, err := readPacket(reader)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if header>>4 != mqttConnAck || len(body) != 2 {
+		conn.Close()
+		return nil, errors.New("broker did not acknowledge the connection")
+	}
+
+	if body[1] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("broker refused the connection with code %d", body[1])
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	go client.serveInbound(reader)
+
+	if opts.KeepAlive > 0 {
+		go client.ping(opts.KeepAlive)
+	}
+
+	return client, nil
+}
+
+// appendUint16 appends a big-endian 16-bit integer.
+func appendUint16(buffer []byte, value uint16) []byte {
+	return append(buffer, byte(value>>8), byte(value))
+}
+
+// appendString appends a length-prefixed string.
+func appendString(buffer []byte, s string) []byte {
+	buffer = appendUint16(buffer, uint16(len(s)))
+	return append(buffer, s...)
+}
+
+// connectPayload assembles the variable header and payload of a CONNECT packet.
+func connectPayload(opts mqttOptions) []byte {
+	buffer := appendString(nil, "MQTT")
+	buffer = append(buffer, 4)
+
+	// Clean session
+	flags := byte(0x02)
+
+	if opts.Will != nil {
+		flags |= 0x04 | opts.Will.QoS<<3
+		if opts.Will.Retain {
+			flags |= 0x20
+		}
+	}
+
+	if opts.Username != "" {
+		flags |= 0x80
+
+		if opts.Password != "" {
+			flags |= 0x40
+		}
+	}
+
+	buffer = append(buffer, flags)
+	buffer = appendUint16(buffer, uint16(opts.KeepAlive/time.Second))
+	buffer = appendString(buffer, opts.ClientID)
+
+	if opts.Will != nil {
+		buffer = appendString(buffer, opts.Will.Topic)
+		buffer = appendString(buffer, string(opts.Will.Payload))
+	}
+
+	if opts.Username != "" {
+		buffer = appendString(buffer, opts.Username)
+
+		if opts.Password != "" {
+			buffer = appendString(buffer, opts.Password)
+		}
+	}
+
+	return buffer
+}
+
+// readPacket reads the fixed header and the remainder of a packet.
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length, shift := 0, 0
+
+	for {
+		octet, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		length |= int(octet&0x7F) << shift
+		shift += 7
+
+		if octet&0x80 == 0 {
+			break
+		} else if shift > 21 {
+			return 0, nil, errors.New("remaining length is invalid")
+		}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+
+	return header, body, nil
+}
+
+// encodePacket assembles a packet with the given fixed header octet and remainder.
+func encodePacket(header byte, body []byte) ([]byte, error) {
+	if len(body) > mqttMaxRemaining {
+		return nil, errors.New("packet is too large")
+	}
+
+	packet := []byte{header}
+
+	length := len(body)
+	for {
+		octet := byte(length & 0x7F)
+		length >>= 7
+
+		if length > 0 {
+			octet |= 0x80
+		}
+
+		packet = append(packet, octet)
+
+		if length == 0 {
+			break
+		}
+	}
+
+	return append(packet, body...), nil
+}
+
+// write sends a packet with the given fixed header octet and remainder.
+func (client *mqttClient) write(header byte, body []byte) error {
+	packet, err := encodePacket(header, body)
+	if err != nil {
+		return err
+	}
+
+	client.writeMu.Lock()
+	defer client.writeMu.Unlock()
+
+	_, err = client.conn.Write(packet)
+	return err
+}
+
+// packetID allocates a packet identifier and registers a channel for its acknowledgement.
+func (client *mqttClient) packetID() (uint16, chan []byte) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	client.nextID++
+	if client.nextID == 0 {
+		client.nextID = 1
+	}
+
+	ack := make(chan []byte, 1)
+	client.pending[client.nextID] = ack
+
+	return client.nextID, ack
+}
+
+// await waits for the acknowledgement of the packet and returns the remainder of it after the
+// packet identifier.
+func (client *mqttClient) await(id uint16, ack chan []byte) ([]byte, error) {
+	timer := time.NewTimer(client.timeout)
+	defer timer.Stop()
+
+	select {
+	case payload := <-ack:
+		return payload, nil
+
+	case <-client.done:
+		return nil, client.closeErr()
+
+	case <-timer.C:
+		client.mu.Lock()
+		delete(client.pending, id)
+		client.mu.Unlock()
+
+		return nil, errors.New("broker did not acknowledge in time")
+	}
+}
+
+// acknowledge resolves the packet identifier.
+func (client *mqttClient) acknowledge(body []byte) {
+	if len(body) < 2 {
+		return
+	}
+
+	id := binary.BigEndian.Uint16(body)
+
+	client.mu.Lock()
+	ack, ok := client.pending[id]
+	delete(client.pending, id)
+	client.mu.Unlock()
+
+	if ok {
+		ack <- body[2:]
+	}
+}
+
+// Publish sends a message. Messages with QoS 1 are acknowledged by the broker before Publish
+// returns. Higher levels are not supported.
+func (client *mqttClient) Publish(msg mqttMessage) error {
+	if msg.QoS > 1 {
+		return errors.New("QoS 2 is not supported")
+	}
+
+	header := byte(mqttPublish<<4) | msg.QoS<<1
+	if msg.Retain {
+		header |= 0x01
+	}
+
+	body := appendString(nil, msg.Topic)
+
+	var (
+		id  uint16
+		ack chan []byte
+	)
+
+	if msg.QoS == 1 {
+		id, ack = client.packetID()
+		body = appendUint16(body, id)
+	}
+
+	body = append(body, msg.Payload...)
+
+	if err := client.write(header, body); err != nil {
+		return err
+	}
+
+	if ack == nil {
+		return nil
+	}
+
+	_, err := client.await(id, ack)
+	return err
+}
+
+// Subscribe subscribes to the topic filters and waits for the broker to acknowledge. It fails if
+// the broker refuses any of them.
+func (client *mqttClient) Subscribe(filters ...string) error {
+	id, ack := client.packetID()
+
+	body := appendUint16(nil, id)
+	for _, filter := range filters {
+		body = appendString(body, filter)
+		body = append(body, 0)
+	}
+
+	if err := client.write(mqttSubscribe<<4|0x02, body); err != nil {
+		return err
+	}
+
+	codes, err := client.await(id, ack)
+	if err != nil {
+		return err
+	}
+
+	if len(codes) != len(filters) {
+		return errors.New("broker acknowledged a different number of subscriptions")
+	}
+
+	for i, code := range codes {
+		if code == mqttSubscriptionRefused {
+			return fmt.Errorf("broker refused the subscription to %s", filters[i])
+		}
+	}
+
+	return nil
+}
+
+// Messages returns the channel on which messages for the subscriptions arrive. It is closed when
+// the connection is lost.
+func (client *mqttClient) Messages() <-chan mqttMessage {
+	return client.messages
+}
+
+// Done returns a channel which is closed when the connection has been lost or closed.
+func (client *mqttClient) Done() <-chan struct{} {
+	return client.done
+}
+
+// closeErr returns the reason for which the connection was closed.
+func (client *mqttClient) closeErr() error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.err == nil {
+		return errMQTTClosed
+	}
+
+	return client.err
+}
+
+// shutdown closes the connection and records the reason.
+func (client *mqttClient) shutdown(err error) {
+	client.once.Do(func() {
+		client.mu.Lock()
+		client.err = err
+		client.mu.Unlock()
+
+		client.conn.Close()
+		close(client.done)
+	})
+}
+
+// Close disconnects gracefully, which means that the broker does not publish the will.
+func (client *mqttClient) Close() {
+	client.write(mqttDisconnect<<4, nil)
+	client.shutdown(errMQTTClosed)
+}
+
+// Err returns the reason for which the connection was lost.
+func (client *mqttClient) Err() error {
+	return client.closeErr()
+}
+
+// ping pings the broker in regular intervals.
+func (client *mqttClient) ping(interval time.Duration) {
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := client.write(mqttPingReq<<4, nil); err != nil {
+				client.shutdown(err)
+				return
+			}
+
+		case <-client.done:
+			return
+		}
+	}
+}
+
+// serveInbound processes the packets from the broker. With a keep alive, the connection is
+// considered lost if nothing, not even a PINGRESP, arrives within one and a half times of it.
+func (client *mqttClient) serveInbound(r *bufio.Reader) {
+	defer close(client.messages)
+
+	for {
+		if client.keepAlive > 0 {
+			client.conn.SetReadDeadline(time.Now().Add(client.keepAlive * 3 / 2))
+		}
+
+		header, body, err := readPacket(r)
+		if err != nil {
+			client.shutdown(err)
+			return
+		}
+
+		switch header >> 4 {
+		case mqttPublish:
+			msg, id, err := parsePublish(header, body)
+			if err != nil {
+				client.shutdown(err)
+				return
+			}
+
+			if msg.QoS == 1 {
+				client.write(mqttPubAck<<4, appendUint16(nil, id))
+			}
+
+			select {
+			case client.messages <- msg:
+			case <-client.done:
+				return
+			}
+
+		case mqttPubAck, mqttSubAck:
+			client.acknowledge(body)
+		}
+	}
+}
+
+// parsePublish decodes a PUBLISH packet.
+func parsePublish(header byte, body []byte) (mqttMessage, uint16, error) {
+	msg := mqttMessage{QoS: header >> 1 & 0x03, Retain: header&0x01 != 0}
+
+	if len(body) < 2 {
+		return msg, 0, io.ErrUnexpectedEOF
+	}
+
+	length := int(binary.BigEndian.Uint16(body))
+	if len(body) < 2+length {
+		return msg, 0, io.ErrUnexpectedEOF
+	}
+
+	msg.Topic = string(body[2 : 2+length])
+	body = body[2+length:]
+
+	var id uint16
+
+	if msg.QoS > 0 {
+		if len(body) < 2 {
+			return msg, 0, io.ErrUnexpectedEOF
+		}
+
+		id = binary.BigEndian.Uint16(body)
+		body = body[2:]
+	}
+
+	msg.Payload = body
+
+	return msg, id, nil
+}
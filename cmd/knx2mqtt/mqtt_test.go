@@ -0,0 +1,56 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMQTTClient_subscribe(t *testing.T) {
+	broker := newTestBroker(t)
+
+	client, err := dialMQTT(broker.Addr(), mqttOptions{ClientID: "subscriber", Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if err := client.Subscribe("knx/#", "status"); err != nil {
+		t.Error(err)
+	}
+
+	if err := client.Subscribe("knx/#", "forbidden/#"); err == nil {
+		t.Error("Refused subscription was reported as successful")
+	}
+}
+
+func TestMQTTClient_keepAlive(t *testing.T) {
+	broker := newTestBroker(t)
+
+	client, err := dialMQTT(broker.Addr(), mqttOptions{
+		ClientID:  "pinger",
+		KeepAlive: 100 * time.Millisecond,
+		Timeout:   time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// The broker answers the pings.
+	select {
+	case <-client.Done():
+		t.Fatalf("Connection was lost: %v", client.Err())
+
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	broker.Mute()
+
+	select {
+	case <-client.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Unresponsive broker was not detected")
+	}
+}
@@ -0,0 +1,416 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// peerQueueSize is the number of frames that may wait to be sent to a peer. Frames are dropped
+// while the queue is full, so that a slow peer does not hold up the others.
+const peerQueueSize = 64
+
+// unlimitedHops is the hop count of frames which are never discarded by routers.
+const unlimitedHops = 7
+
+// peerStats counts what happened to the frames of a peer.
+type peerStats struct {
+	Received uint64 `json:"received"`
+	Sent     uint64 `json:"sent"`
+
+	// Filtered counts the frames rejected by the inbound or outbound filter.
+	Filtered uint64 `json:"filtered"`
+
+	// HopLimit counts the received frames whose hop count was exhausted.
+	HopLimit uint64 `json:"hop_limit"`
+
+	// Looped counts the received frames whose source belongs to another peer.
+	Looped uint64 `json:"looped"`
+
+	// Dropped counts the frames which could not be queued for sending.
+	Dropped uint64 `json:"dropped"`
+
+	Errors uint64 `json:"errors"`
+}
+
+// A peer is one of the connections of the bridge.
+type peer struct {
+	name     string
+	address  string
+	inbound  *filter
+	outbound *filter
+	queue    chan cemi.LData
+
+	mu        sync.Mutex
+	transport knx.Transport
+	since     time.Time
+	lastError string
+	stats     peerStats
+}
+
+// newPeer parses the peer configuration.
+func newPeer(config peerConfig) (*peer, error) {
+	p := &peer{
+		name:    config.Name,
+		address: config.Address,
+		queue:   make(chan cemi.LData, peerQueueSize),
+	}
+
+	if p.name == "" {
+		p.name = config.Address
+	}
+
+	var err error
+
+	if p.inbound, err = newFilter(config.Inbound); err != nil {
+		return nil, fmt.Errorf("peer %s: inbound filter: %w", p.name, err)
+	}
+
+	if p.outbound, err = newFilter(config.Outbound); err != nil {
+		return nil, fmt.Errorf("peer %s: outbound filter: %w", p.name, err)
+	}
+
+	return p, nil
+}
+
+// count updates the statistics.
+func (p *peer) count(update func(stats *peerStats)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	update(&p.stats)
+}
+
+// fail records the error.
+func (p *peer) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stats.Errors++
+	p.lastError = err.Error()
+}
+
+// enqueue queues the frame for sending.
+func (p *peer) enqueue(ldata cemi.LData) {
+	select {
+	case p.queue <- ldata:
+	default:
+		p.count(func(stats *peerStats) { stats.Dropped++ })
+	}
+}
+
+// current returns the transport of the peer, or nil if it is not connected.
+func (p *peer) current() knx.Transport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.transport
+}
+
+// send transmits the queued frames. Frames queued while the peer is disconnected are dropped.
+func (p *peer) send(done <-chan struct{}) {
+	for {
+		var ldata cemi.LData
+
+		select {
+		case <-done:
+			return
+		case ldata = <-p.queue:
+		}
+
+		transport := p.current()
+		if transport == nil {
+			p.count(func(stats *peerStats) { stats.Dropped++ })
+			continue
+		}
+
+		if err := transport.Send(knx.OutboundFrame(transport, ldata)); err != nil {
+			p.fail(err)
+			continue
+		}
+
+		p.count(func(stats *peerStats) { stats.Sent++ })
+	}
+}
+
+// A frame is an L_Data frame received from a peer.
+type frame struct {
+	from  int
+	ldata cemi.LData
+}
+
+// A sighting is the peer a source address was last seen on.
+type sighting struct {
+	peer int
+	time time.Time
+}
+
+// A bridge relays frames between its peers.
+type bridge struct {
+	peers      []*peer
+	loopWindow time.Duration
+	retry      time.Duration
+	dial       func(address string) (knx.Transport, error)
+
+	frames  chan frame
+	sources map[cemi.IndividualAddr]sighting
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// newBridge creates a bridge for the configuration. It does not connect yet.
+func newBridge(cfg *config) (*bridge, error) {
+	loopWindow, err := cfg.check()
+	if err != nil {
+		return nil, err
+	}
+
+	br := &bridge{
+		loopWindow: loopWindow,
+		retry:      5 * time.Second,
+		dial:       knx.Dial,
+		frames:     make(chan frame, peerQueueSize),
+		sources:    map[cemi.IndividualAddr]sighting{},
+		done:       make(chan struct{}),
+	}
+
+	for _, config := range cfg.Peers {
+		p, err := newPeer(config)
+		if err != nil {
+			return nil, err
+		}
+
+		br.peers = append(br.peers, p)
+	}
+
+	return br, nil
+}
+
+// start connects to the peers and relays frames until the bridge is closed. Each peer reconnects
+// on its own when its connection is lost.
+func (br *bridge) start() {
+	for i, p := range br.peers {
+		br.wg.Add(2)
+
+		go func(i int, p *peer) {
+			defer br.wg.Done()
+			br.connect(i, p)
+		}(i, p)
+
+		go func(p *peer) {
+			defer br.wg.Done()
+			p.send(br.done)
+		}(p)
+	}
+
+	br.wg.Add(1)
+	go func() {
+		defer br.wg.Done()
+
+		for {
+			select {
+			case <-br.done:
+				return
+			case f := <-br.frames:
+				br.route(f, time.Now())
+			}
+		}
+	}()
+}
+
+// connect keeps the peer connected and passes its frames to the bridge.
+func (br *bridge) connect(i int, p *peer) {
+	for {
+		transport, err := br.dial(p.address)
+		if err != nil {
+			util.Log(br, "Error while connecting to %s: %v", p.name, err)
+			p.fail(err)
+		} else {
+			util.Log(br, "Connected to %s", p.name)
+
+			p.mu.Lock()
+			p.transport = transport
+			p.since = time.Now()
+			p.mu.Unlock()
+
+			br.receive(i, p, transport)
+
+			p.mu.Lock()
+			p.transport = nil
+			p.mu.Unlock()
+
+			transport.Close()
+			util.Log(br, "Connection to %s has been lost", p.name)
+		}
+
+		select {
+		case <-br.done:
+			return
+		case <-time.After(br.retry):
+		}
+	}
+}
+
+// receive passes the frames of the transport to the bridge until it is closed.
+func (br *bridge) receive(i int, p *peer, transport knx.Transport) {
+	for {
+		select {
+		case <-br.done:
+			return
+
+		case msg, open := <-transport.Inbound():
+			if !open {
+				return
+			}
+
+			// Confirmations of our own requests are not relayed.
+			ind, ok := msg.(*cemi.LDataInd)
+			if !ok {
+				continue
+			}
+
+			p.count(func(stats *peerStats) { stats.Received++ })
+
+			select {
+			case br.frames <- frame{from: i, ldata: ind.LData}:
+			case <-br.done:
+				return
+			}
+		}
+	}
+}
+
+// route relays the frame to the other peers. Frames are dropped if the inbound filter rejects
+// them, if their hop count is exhausted, or if their source was recently seen on another peer,
+// which means that they went round in a loop. Hop counts other than 7 are decremented.
+func (br *bridge) route(f frame, now time.Time) {
+	from := br.peers[f.from]
+	ldata := f.ldata
+
+	if !from.inbound.allows(&ldata) {
+		from.count(func(stats *peerStats) { stats.Filtered++ })
+		return
+	}
+
+	if ldata.Source != 0 {
+		if seen, ok := br.sources[ldata.Source]; ok && seen.peer != f.from && now.Sub(seen.time) < br.loopWindow {
+			from.count(func(stats *peerStats) { stats.Looped++ })
+			return
+		}
+
+		br.sources[ldata.Source] = sighting{peer: f.from, time: now}
+	}
+
+	hops := ldata.Control2.Hops()
+	if hops == 0 {
+		from.count(func(stats *peerStats) { stats.HopLimit++ })
+		return
+	}
+
+	if hops < unlimitedHops {
+		ldata.Control2 = ldata.Control2&^cemi.Control2Hops(unlimitedHops) | cemi.Control2Hops(hops-1)
+	}
+
+	for i, to := range br.peers {
+		if i == f.from {
+			continue
+		}
+
+		if !to.outbound.allows(&ldata) {
+			to.count(func(stats *peerStats) { stats.Filtered++ })
+			continue
+		}
+
+		to.enqueue(ldata)
+	}
+}
+
+// close disconnects from all peers.
+func (br *bridge) close() {
+	close(br.done)
+	br.wg.Wait()
+}
+
+// peerStatus is the health of a peer.
+type peerStatus struct {
+	Name      string     `json:"name"`
+	Address   string     `json:"address"`
+	State     string     `json:"state"`
+	Since     *time.Time `json:"since,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+	peerStats
+}
+
+// bridgeStatus is the health of the bridge. Its status is "ok" if all peers are connected,
+// "degraded" if some are and "down" otherwise.
+type bridgeStatus struct {
+	Status string       `json:"status"`
+	Peers  []peerStatus `json:"peers"`
+}
+
+// status collects the health of the peers.
+func (br *bridge) status() bridgeStatus {
+	status := bridgeStatus{Peers: make([]peerStatus, 0, len(br.peers))}
+	connected := 0
+
+	for _, p := range br.peers {
+		p.mu.Lock()
+
+		ps := peerStatus{
+			Name:      p.name,
+			Address:   p.address,
+			State:     "Disconnected",
+			LastError: p.lastError,
+			peerStats: p.stats,
+		}
+
+		if p.transport != nil {
+			state := p.transport.State()
+			ps.State = state.String()
+
+			since := p.since
+			ps.Since = &since
+
+			if state == knx.StateConnected {
+				connected++
+			}
+		}
+
+		p.mu.Unlock()
+
+		status.Peers = append(status.Peers, ps)
+	}
+
+	switch connected {
+	case len(br.peers):
+		status.Status = "ok"
+	case 0:
+		status.Status = "down"
+	default:
+		status.Status = "degraded"
+	}
+
+	return status
+}
+
+// ServeHTTP serves the health status as JSON. The response status is 503 unless all peers are
+// connected.
+func (br *bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status := br.status()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if status.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.NewEncoder(w).Encode(status)
+}
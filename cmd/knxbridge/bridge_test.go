@@ -0,0 +1,169 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// expectFrame waits for a frame on the connection.
+func expectFrame(t *testing.T, conn *knx.BusConn) cemi.LData {
+	t.Helper()
+
+	select {
+	case msg := <-conn.Inbound():
+		ind, ok := msg.(*cemi.LDataInd)
+		require.True(t, ok, "Unexpected message %v", msg)
+		return ind.LData
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("No frame received")
+		return cemi.LData{}
+	}
+}
+
+// expectNoFrame makes sure that no frame arrives on the connection.
+func expectNoFrame(t *testing.T, conn *knx.BusConn) {
+	t.Helper()
+
+	select {
+	case msg := <-conn.Inbound():
+		t.Errorf("Unexpected message %v", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestBridge(t *testing.T) {
+	br, err := newBridge(&config{
+		Peers: []peerConfig{
+			{Name: "a", Address: "bus://bridge-test-a"},
+			{Name: "b", Address: "bus://bridge-test-b"},
+			{
+				Name:     "c",
+				Address:  "bus://bridge-test-c",
+				Outbound: filterConfig{DenyGroups: []string{"2/0/0-2/7/255"}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	br.retry = 10 * time.Millisecond
+
+	a := knx.NamedBus("bridge-test-a").Connect()
+	defer a.Close()
+
+	b := knx.NamedBus("bridge-test-b").Connect()
+	defer b.Close()
+
+	c := knx.NamedBus("bridge-test-c").Connect()
+	defer c.Close()
+
+	br.start()
+	defer br.close()
+
+	require.Eventually(t, func() bool { return br.status().Status == "ok" }, 5*time.Second, 10*time.Millisecond)
+
+	frame := func(source cemi.IndividualAddr, dest cemi.GroupAddr, hops uint8) *cemi.LDataInd {
+		return &cemi.LDataInd{LData: cemi.LData{
+			Control1:    cemi.Control1StdFrame,
+			Control2:    cemi.Control2GroupAddr | cemi.Control2Hops(hops),
+			Source:      source,
+			Destination: uint16(dest),
+			Data:        &cemi.AppData{Command: cemi.GroupValueWrite, Data: []byte{1}},
+		}}
+	}
+
+	t.Run("Relay", func(t *testing.T) {
+		require.NoError(t, a.Send(frame(cemi.NewIndividualAddr3(1, 1, 5), cemi.NewGroupAddr3(1, 2, 3), 6)))
+
+		for _, conn := range []*knx.BusConn{b, c} {
+			ldata := expectFrame(t, conn)
+			assert.Equal(t, cemi.NewIndividualAddr3(1, 1, 5), ldata.Source)
+			assert.Equal(t, uint8(5), ldata.Control2.Hops())
+			assert.True(t, ldata.Control2.IsGroupAddr())
+		}
+
+		require.NoError(t, b.Send(frame(cemi.NewIndividualAddr3(2, 1, 5), cemi.NewGroupAddr3(1, 2, 3), 7)))
+
+		for _, conn := range []*knx.BusConn{a, c} {
+			assert.Equal(t, uint8(7), expectFrame(t, conn).Control2.Hops())
+		}
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		require.NoError(t, a.Send(frame(cemi.NewIndividualAddr3(1, 1, 5), cemi.NewGroupAddr3(2, 0, 1), 6)))
+
+		expectFrame(t, b)
+		expectNoFrame(t, c)
+	})
+
+	t.Run("HopLimit", func(t *testing.T) {
+		require.NoError(t, a.Send(frame(cemi.NewIndividualAddr3(1, 1, 5), cemi.NewGroupAddr3(1, 2, 3), 0)))
+
+		expectNoFrame(t, b)
+		expectNoFrame(t, c)
+	})
+
+	t.Run("Loop", func(t *testing.T) {
+		// 1.1.5 lives behind a, so its frames must not come back through c.
+		require.NoError(t, c.Send(frame(cemi.NewIndividualAddr3(1, 1, 5), cemi.NewGroupAddr3(1, 2, 3), 5)))
+
+		expectNoFrame(t, a)
+		expectNoFrame(t, b)
+	})
+
+	t.Run("Health", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		br.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var status bridgeStatus
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+
+		assert.Equal(t, "ok", status.Status)
+		require.Len(t, status.Peers, 3)
+
+		assert.Equal(t, "a", status.Peers[0].Name)
+		assert.Equal(t, "Connected", status.Peers[0].State)
+		assert.Equal(t, uint64(3), status.Peers[0].Received)
+		assert.Equal(t, uint64(1), status.Peers[0].HopLimit)
+		assert.Equal(t, uint64(1), status.Peers[0].Sent)
+		assert.Equal(t, uint64(1), status.Peers[2].Filtered)
+		assert.Equal(t, uint64(1), status.Peers[2].Looped)
+	})
+}
+
+func TestBridgeReconnect(t *testing.T) {
+	br, err := newBridge(&config{
+		Peers: []peerConfig{
+			{Name: "a", Address: "bus://reconnect-test-a"},
+			{Name: "b", Address: "bus://"},
+		},
+	})
+	require.NoError(t, err)
+
+	br.retry = 10 * time.Millisecond
+
+	br.start()
+	defer br.close()
+
+	require.Eventually(t, func() bool {
+		status := br.status()
+		return status.Status == "degraded" && status.Peers[1].Errors > 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	br.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
@@ -0,0 +1,194 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// A config describes the peers of a bridge.
+type config struct {
+	// Health is the address on which the health status is served, if not empty.
+	Health string `json:"health,omitempty"`
+
+	// LoopWindow is the time for which the peer a source address was seen on is remembered.
+	// Frames from that source arriving through a different peer meanwhile are considered looped.
+	LoopWindow string `json:"loop_window,omitempty"`
+
+	Peers []peerConfig `json:"peers"`
+}
+
+// A peerConfig describes a connection of the bridge. Its address is a URL as accepted by
+// knx.Dial, e.g. "udp://10.0.0.2", "tcp://10.0.0.2" or "multicast://224.0.23.12?interface=eth0".
+type peerConfig struct {
+	Name    string `json:"name,omitempty"`
+	Address string `json:"address"`
+
+	// Inbound filters the frames received from the peer, outbound those sent to it.
+	Inbound  filterConfig `json:"inbound,omitempty"`
+	Outbound filterConfig `json:"outbound,omitempty"`
+}
+
+// A filterConfig lists the destinations which may pass. Entries are single addresses, ranges such
+// as "1/2/0-1/2/255" or "1.1.0-1.1.255", or "*". Empty allow lists allow everything. Group filters
+// apply to frames sent to group addresses, individual filters to frames sent to devices.
+type filterConfig struct {
+	AllowGroups      []string `json:"allow_groups,omitempty"`
+	DenyGroups       []string `json:"deny_groups,omitempty"`
+	AllowIndividuals []string `json:"allow_individuals,omitempty"`
+	DenyIndividuals  []string `json:"deny_individuals,omitempty"`
+}
+
+// defaultLoopWindow is used if the configuration does not give a loop window.
+const defaultLoopWindow = 10 * time.Second
+
+// loadConfig reads the configuration from a JSON file.
+func loadConfig(name string) (*config, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// An addrRange is an inclusive range of raw addresses.
+type addrRange struct {
+	first, last uint16
+}
+
+// A rangeList matches addresses against ranges.
+type rangeList []addrRange
+
+func (list rangeList) contains(addr uint16) bool {
+	for _, r := range list {
+		if r.first <= addr && addr <= r.last {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseRanges parses the entries of a filter using the given address parser.
+func parseRanges(entries []string, parse func(string) (uint16, error)) (rangeList, error) {
+	list := make(rangeList, 0, len(entries))
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+
+		if entry == "*" {
+			list = append(list, addrRange{0, 0xFFFF})
+			continue
+		}
+
+		firstText, lastText, isRange := strings.Cut(entry, "-")
+
+		first, err := parse(strings.TrimSpace(firstText))
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", entry, err)
+		}
+
+		last := first
+
+		if isRange {
+			if last, err = parse(strings.TrimSpace(lastText)); err != nil {
+				return nil, fmt.Errorf("%q: %w", entry, err)
+			}
+
+			if last < first {
+				return nil, fmt.Errorf("%q: range is empty", entry)
+			}
+		}
+
+		list = append(list, addrRange{first, last})
+	}
+
+	return list, nil
+}
+
+func parseGroupAddr(text string) (uint16, error) {
+	addr, err := cemi.NewGroupAddrString(text)
+	return uint16(addr), err
+}
+
+func parseIndividualAddr(text string) (uint16, error) {
+	addr, err := cemi.NewIndividualAddrString(text)
+	return uint16(addr), err
+}
+
+// A filter decides which frames pass based on their destination.
+type filter struct {
+	allowGroups, denyGroups           rangeList
+	allowIndividuals, denyIndividuals rangeList
+}
+
+// newFilter parses the filter configuration.
+func newFilter(config filterConfig) (*filter, error) {
+	f := &filter{}
+
+	for _, list := range []struct {
+		target  *rangeList
+		entries []string
+		parse   func(string) (uint16, error)
+	}{
+		{&f.allowGroups, config.AllowGroups, parseGroupAddr},
+		{&f.denyGroups, config.DenyGroups, parseGroupAddr},
+		{&f.allowIndividuals, config.AllowIndividuals, parseIndividualAddr},
+		{&f.denyIndividuals, config.DenyIndividuals, parseIndividualAddr},
+	} {
+		var err error
+		if *list.target, err = parseRanges(list.entries, list.parse); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+// allows determines whether the frame passes the filter.
+func (f *filter) allows(ldata *cemi.LData) bool {
+	allow, deny := f.allowIndividuals, f.denyIndividuals
+	if ldata.Control2.IsGroupAddr() {
+		allow, deny = f.allowGroups, f.denyGroups
+	}
+
+	if len(allow) > 0 && !allow.contains(ldata.Destination) {
+		return false
+	}
+
+	return !deny.contains(ldata.Destination)
+}
+
+var errNoPeers = errors.New("at least two peers are required")
+
+// check validates the configuration and returns the loop window.
+func (cfg *config) check() (time.Duration, error) {
+	if len(cfg.Peers) < 2 {
+		return 0, errNoPeers
+	}
+
+	for i, peer := range cfg.Peers {
+		if peer.Address == "" {
+			return 0, fmt.Errorf("peer %d has no address", i+1)
+		}
+	}
+
+	if cfg.LoopWindow == "" {
+		return defaultLoopWindow, nil
+	}
+
+	return time.ParseDuration(cfg.LoopWindow)
+}
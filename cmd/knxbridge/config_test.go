@@ -0,0 +1,77 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+func groupFrame(dest cemi.GroupAddr) *cemi.LData {
+	return &cemi.LData{Control2: cemi.Control2GroupAddr, Destination: uint16(dest)}
+}
+
+func individualFrame(dest cemi.IndividualAddr) *cemi.LData {
+	return &cemi.LData{Destination: uint16(dest)}
+}
+
+func TestFilter(t *testing.T) {
+	f, err := newFilter(filterConfig{
+		AllowGroups:     []string{"1/0/0-1/7/255", "3/1/1"},
+		DenyGroups:      []string{"1/2/3"},
+		DenyIndividuals: []string{"1.1.0 - 1.1.255"},
+	})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		ldata  *cemi.LData
+		allows bool
+	}{
+		{groupFrame(cemi.NewGroupAddr3(1, 0, 0)), true},
+		{groupFrame(cemi.NewGroupAddr3(1, 7, 255)), true},
+		{groupFrame(cemi.NewGroupAddr3(3, 1, 1)), true},
+		{groupFrame(cemi.NewGroupAddr3(1, 2, 3)), false},
+		{groupFrame(cemi.NewGroupAddr3(2, 0, 0)), false},
+		{individualFrame(cemi.NewIndividualAddr3(1, 1, 5)), false},
+		{individualFrame(cemi.NewIndividualAddr3(1, 2, 5)), true},
+	} {
+		assert.Equal(t, tt.allows, f.allows(tt.ldata), "%+v", tt.ldata)
+	}
+
+	empty, err := newFilter(filterConfig{})
+	require.NoError(t, err)
+	assert.True(t, empty.allows(groupFrame(cemi.NewGroupAddr3(31, 7, 255))))
+
+	all, err := newFilter(filterConfig{DenyGroups: []string{"*"}})
+	require.NoError(t, err)
+	assert.False(t, all.allows(groupFrame(cemi.NewGroupAddr3(0, 0, 1))))
+	assert.True(t, all.allows(individualFrame(cemi.NewIndividualAddr3(1, 1, 1))))
+
+	for _, config := range []filterConfig{
+		{AllowGroups: []string{"1/2/x"}},
+		{AllowGroups: []string{"1/2/3-1/2/1"}},
+		{DenyIndividuals: []string{"1/2/3"}},
+	} {
+		_, err := newFilter(config)
+		assert.Error(t, err, "%+v", config)
+	}
+}
+
+func TestConfigCheck(t *testing.T) {
+	_, err := (&config{Peers: []peerConfig{{Address: "udp://10.0.0.2"}}}).check()
+	assert.Equal(t, errNoPeers, err)
+
+	_, err = (&config{Peers: []peerConfig{{Address: "udp://10.0.0.2"}, {}}}).check()
+	assert.Error(t, err)
+
+	window, err := (&config{Peers: []peerConfig{{Address: "a"}, {Address: "b"}}}).check()
+	require.NoError(t, err)
+	assert.Equal(t, defaultLoopWindow, window)
+
+	_, err = (&config{LoopWindow: "soon", Peers: []peerConfig{{Address: "a"}, {Address: "b"}}}).check()
+	assert.Error(t, err)
+}
@@ -4,155 +4,104 @@
 package main
 
 import (
-	"errors"
+	"flag"
 	"fmt"
 	"log"
-	"net"
+	"net/http"
 	"os"
-	"time"
+	"os/signal"
 
-	"github.com/vapourismo/knx-go/knx"
-	"github.com/vapourismo/knx-go/knx/cemi"
-	"github.com/vapourismo/knx-go/knx/knxnet"
 	"github.com/vapourismo/knx-go/knx/util"
 )
 
-type relay interface {
-	relay(data cemi.LData) error
+const usage = `Usage: %s [flags] [address...]
 
-	Inbound() <-chan cemi.Message
-	Close()
-}
+Relays the frames of each peer to all other peers. Peers are given either as addresses or in the
+JSON file given by -config:
 
-type reqRelay struct {
-	*knx.Tunnel
-}
+	{
+		"health": ":8080",
+		"loop_window": "10s",
+		"peers": [
+			{"name": "office", "address": "udp://10.0.0.2:3671"},
+			{
+				"name": "site-b",
+				"address": "multicast://224.0.23.12?interface=tun0",
+				"outbound": {"allow_groups": ["1/0/0-1/7/255"], "deny_individuals": ["*"]}
+			}
+		]
+	}
 
-func (relay reqRelay) relay(data cemi.LData) error {
-	return relay.Send(&cemi.LDataReq{LData: data})
-}
+Addresses are gateways (10.0.0.2:3671), multicast groups (224.0.23.12:3671) or URLs as accepted by
+knx.Dial, e.g. tcp://10.0.0.2, multicast://224.0.23.12?interface=eth0 or, to join routers over a
+network without multicast, unicast://10.8.0.5:3671?peer=10.1.0.2. Bind unicast peers to a specific
+local address when a multicast peer uses the same port.
 
-type indRelay struct {
-	*knx.Router
-}
+Inbound filters apply to the frames received from a peer, outbound filters to the frames sent to
+it. They list single addresses, ranges or "*". Frames pass if an allow list is empty or contains
+their destination, and no deny list contains it.
 
-func (relay indRelay) relay(data cemi.LData) error {
-	return relay.Send(&cemi.LDataInd{LData: data})
-}
+The hop count of relayed frames is decremented, frames whose hop count is exhausted are dropped.
+Frames from a source address that was seen on a different peer within the loop window are dropped
+as well, which prevents frames from going round in circles between bridged networks.
 
-func printUsage() {
-	fmt.Fprintf(os.Stderr, "Usage: %s <gateway addr> <other addr>\n", os.Args[0])
-}
+The health status of the peers is served as JSON on the health address.
+
+Flags:
+`
 
 func main() {
-	if len(os.Args) < 3 {
-		printUsage()
-		return
+	configFile := flag.String("config", "", "JSON file describing the peers")
+	health := flag.String("health", "", "address on which to serve the health status")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
+		flag.PrintDefaults()
 	}
 
+	flag.Parse()
+
 	logger := log.New(os.Stdout, "", log.LstdFlags)
 	util.Logger = logger
 
-	gatewayAddr := os.Args[1]
-	otherAddr := os.Args[2]
-
-	// Loop for ever. Failures don't matter, we'll always retry.
-	for {
-		br, err := newBridge(gatewayAddr, otherAddr)
-		if err != nil {
-			logger.Printf("Error while creating: %v\n", err)
-
-			time.Sleep(time.Second)
-			continue
-		}
+	cfg := &config{}
 
-		err = br.serve()
-		if err != nil {
-			logger.Printf("Server terminated with error: %v\n", err)
+	if *configFile != "" {
+		var err error
+		if cfg, err = loadConfig(*configFile); err != nil {
+			logger.Fatal(err)
 		}
-
-		br.close()
-
-		time.Sleep(time.Second)
 	}
-}
 
-type bridge struct {
-	tunnel *knx.Tunnel
-	other  relay
-}
+	for _, address := range flag.Args() {
+		cfg.Peers = append(cfg.Peers, peerConfig{Address: address})
+	}
 
-func newBridge(gatewayAddr, otherAddr string) (*bridge, error) {
-	// Instantiate tunnel connection.
-	tunnel, err := knx.NewTunnel(gatewayAddr, knxnet.TunnelLayerData, knx.DefaultTunnelConfig)
-	if err != nil {
-		return nil, err
+	if len(cfg.Peers) < 2 {
+		flag.Usage()
+		os.Exit(2)
 	}
 
-	var other relay
+	if *health != "" {
+		cfg.Health = *health
+	}
 
-	addr, err := net.ResolveUDPAddr("udp4", otherAddr)
+	br, err := newBridge(cfg)
 	if err != nil {
-		tunnel.Close()
-		return nil, err
+		logger.Fatal(err)
 	}
 
-	if addr.IP.IsMulticast() {
-		// Instantiate routing facilities. MulticastLoopback is disabled by default.
-		router, err := knx.NewRouter(otherAddr, knx.DefaultRouterConfig)
-		if err != nil {
-			tunnel.Close()
-			return nil, err
-		}
-
-		other = indRelay{router}
-	} else {
-		// Instantiate tunnel connection.
-		otherTunnel, err := knx.NewTunnel(otherAddr, knxnet.TunnelLayerData, knx.DefaultTunnelConfig)
-		if err != nil {
-			tunnel.Close()
-			return nil, err
-		}
-
-		other = reqRelay{otherTunnel}
+	if cfg.Health != "" {
+		go func() {
+			logger.Fatal(http.ListenAndServe(cfg.Health, br))
+		}()
 	}
 
-	return &bridge{tunnel, other}, nil
-}
-
-func (br *bridge) serve() error {
-	for {
-		select {
-		// Receive message from gateway.
-		case msg, open := <-br.tunnel.Inbound():
-			if !open {
-				return errors.New("tunnel channel closed")
-			}
-
-			if ind, ok := msg.(*cemi.LDataInd); ok {
-				util.Log(br, "%+v", ind)
-				if err := br.other.relay(ind.LData); err != nil {
-					return err
-				}
-			}
-
-		// Receive message from router.
-		case msg, open := <-br.other.Inbound():
-			if !open {
-				return errors.New("router channel closed")
-			}
+	br.start()
 
-			if ind, ok := msg.(*cemi.LDataInd); ok {
-				util.Log(br, "%+v", ind)
-				if err := br.tunnel.Send(&cemi.LDataReq{LData: ind.LData}); err != nil {
-					return err
-				}
-			}
-		}
-	}
-}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	<-signals
 
-func (br *bridge) close() {
-	br.tunnel.Close()
-	br.other.Close()
+	br.close()
 }
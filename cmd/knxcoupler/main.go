@@ -0,0 +1,137 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/ets"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+const usage = `Usage: %s [flags] line...
+
+Couples the main line with the given sub lines like KNX IP routers do. Each line is given as the
+individual address of its coupler and the address of its gateway, e.g. 1.1.0=10.0.0.3:3671.
+Addresses are gateways, multicast groups or URLs as accepted by knx.Dial.
+
+Group telegrams pass according to the filter table of a line, which is taken from the ETS project
+given by -project. Without a project, all group telegrams pass. Individual telegrams pass according
+to the line of their destination.
+
+Flags:
+`
+
+// A lineSpec is a sub line given on the command line.
+type lineSpec struct {
+	address cemi.IndividualAddr
+	gateway string
+}
+
+var errInvalidLine = errors.New("line must be given as coupler address and gateway")
+
+// parseLine parses a line such as "1.1.0=10.0.0.3:3671".
+func parseLine(arg string) (lineSpec, error) {
+	addr, gateway, ok := strings.Cut(arg, "=")
+	if !ok || gateway == "" {
+		return lineSpec{}, errInvalidLine
+	}
+
+	address, err := cemi.NewIndividualAddrString(addr)
+	if err != nil {
+		return lineSpec{}, err
+	}
+
+	return lineSpec{address, gateway}, nil
+}
+
+// filterTable builds the filter table of the line from the project.
+func filterTable(project *ets.Project, address cemi.IndividualAddr) *knx.GroupFilterTable {
+	return knx.NewGroupFilterTable(project.FilterTable(uint8(address>>12), uint8(address>>8)&0xF)...)
+}
+
+func main() {
+	mainLine := flag.String("main", "multicast://224.0.23.12", "address of the main line")
+	projectFile := flag.String("project", "", "ETS project (.knxproj) providing the filter tables")
+	projectPassword := flag.String("project-password", "", "password of the ETS project")
+	repetitions := flag.Int("repetitions", knx.DefaultCouplerConfig.Repetitions, "number of repetitions of unconfirmed frames")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+	util.Logger = logger
+
+	var project *ets.Project
+
+	if *projectFile != "" {
+		var err error
+		if project, err = ets.Open(*projectFile, *projectPassword); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	var lines []knx.CouplerLine
+
+	for _, arg := range flag.Args() {
+		spec, err := parseLine(arg)
+		if err != nil {
+			logger.Fatalf("%s: %v", arg, err)
+		}
+
+		transport, err := knx.Dial(spec.gateway)
+		if err != nil {
+			logger.Fatalf("%s: %v", arg, err)
+		}
+
+		line := knx.CouplerLine{Address: spec.address, Transport: transport}
+
+		if project != nil {
+			line.Filter = filterTable(project, spec.address)
+			logger.Printf("Filter table of line %v contains %d group addresses",
+				spec.address, line.Filter.Len())
+		}
+
+		lines = append(lines, line)
+	}
+
+	main, err := knx.Dial(*mainLine)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	config := knx.DefaultCouplerConfig
+	config.Repetitions = *repetitions
+
+	coupler, err := knx.NewCoupler(main, lines, config)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	<-signals
+
+	coupler.Close()
+
+	for _, stats := range coupler.Stats() {
+		logger.Printf("%+v", stats)
+	}
+}
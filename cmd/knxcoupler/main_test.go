@@ -0,0 +1,50 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/ets"
+)
+
+func TestParseLine(t *testing.T) {
+	spec, err := parseLine("1.1.0=10.0.0.3:3671")
+	require.NoError(t, err)
+	assert.Equal(t, cemi.NewIndividualAddr3(1, 1, 0), spec.address)
+	assert.Equal(t, "10.0.0.3:3671", spec.gateway)
+
+	spec, err = parseLine("1.2.0=tcp://10.0.0.4")
+	require.NoError(t, err)
+	assert.Equal(t, "tcp://10.0.0.4", spec.gateway)
+
+	for _, arg := range []string{"1.1.0", "1.1.0=", "1/1/0=10.0.0.3"} {
+		_, err := parseLine(arg)
+		assert.Error(t, err, arg)
+	}
+}
+
+func TestFilterTable(t *testing.T) {
+	project := &ets.Project{Areas: []*ets.Area{{
+		Address: 1,
+		Lines: []*ets.Line{{
+			Address: 2,
+			Devices: []*ets.Device{{
+				GroupObjects: []ets.GroupObject{
+					{GroupAddresses: []cemi.GroupAddr{cemi.NewGroupAddr3(1, 0, 1), cemi.NewGroupAddr3(1, 0, 0)}},
+				},
+			}},
+		}},
+	}}}
+
+	table := filterTable(project, cemi.NewIndividualAddr3(1, 2, 0))
+	assert.Equal(t, 2, table.Len())
+	assert.True(t, table.Contains(cemi.NewGroupAddr3(1, 0, 1)))
+	assert.False(t, table.Contains(cemi.NewGroupAddr3(1, 0, 2)))
+
+	assert.Equal(t, 0, filterTable(project, cemi.NewIndividualAddr3(1, 1, 0)).Len())
+}
@@ -0,0 +1,295 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Command knxctl reads and writes group addresses from the command line.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/registry"
+)
+
+const usage = `Usage: %s [flags] <command> [arguments]
+
+Commands:
+  read <ga> [-dpt 9.001]                  read a group address and print its value
+  write <ga> <value> [-dpt 1.001] [-raw]  write a value to a group address
+  response <ga> <value> [-dpt] [-raw]     answer a read request with a value
+
+Group addresses are given as 1/2/3, 1/515 or by full name if a project is loaded. Values are parsed
+according to the datapoint type, or as hexadecimal payload if there is none or -raw is set. The
+payload includes the leading octet, which carries values of up to 6 bits (01) and is zero
+otherwise (000C1A).
+
+The address is a gateway (10.0.0.2:3671), a multicast group (224.0.23.12:3671) or a URL as
+accepted by knx.Dial (tcp://10.0.0.2?heartbeat=5s). It defaults to $KNX_ADDRESS.
+
+Flags:
+`
+
+// isNegativeNumber determines whether the argument is a negative number rather than a flag.
+func isNegativeNumber(arg string) bool {
+	return len(arg) > 1 && arg[0] == '-' && arg[1] >= '0' && arg[1] <= '9'
+}
+
+// parseArgs parses the flags of a command, which may appear between its positional arguments.
+// Negative numbers are positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		for len(args) > 0 && isNegativeNumber(args[0]) {
+			positional = append(positional, args[0])
+			args = args[1:]
+		}
+
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// closingGroupClient is a group client which can be closed.
+type closingGroupClient interface {
+	knx.GroupClient
+	Close()
+}
+
+// A command carries the state shared by all commands.
+type command struct {
+	client  closingGroupClient
+	reg     *registry.Registry
+	timeout time.Duration
+	json    bool
+	out     io.Writer
+}
+
+// target resolves the group address and determines its datapoint type. A datapoint type given on
+// the command line takes precedence over the one from the registry.
+func (cmd *command) target(nameOrAddr, dptName string) (cemi.GroupAddr, result, string, error) {
+	addr, err := cmd.reg.Resolve(nameOrAddr)
+	if err != nil {
+		return 0, result{}, "", err
+	}
+
+	res := result{Address: cmd.reg.Format(addr)}
+
+	if entry, ok := cmd.reg.Lookup(addr); ok {
+		res.Name = entry.FullName()
+
+		if dptName == "" {
+			dptName = entry.DPT
+		}
+	}
+
+	return addr, res, dptName, nil
+}
+
+// print writes the result as plain value or as JSON.
+func (cmd *command) print(res *result) error {
+	if cmd.json {
+		return json.NewEncoder(cmd.out).Encode(res)
+	}
+
+	_, err := fmt.Fprintln(cmd.out, res)
+	return err
+}
+
+var errTimeout = errors.New("timed out waiting for a response")
+
+// read sends a read request and waits for the response.
+func (cmd *command) read(args []string) error {
+	fs := flag.NewFlagSet("read", flag.ContinueOnError)
+	dptName := fs.String("dpt", "", "datapoint type of the group address")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	} else if len(positional) != 1 {
+		return errors.New("read expects a group address")
+	}
+
+	addr, res, name, err := cmd.target(positional[0], *dptName)
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.client.Send(knx.GroupEvent{
+		Command:     knx.GroupRead,
+		Destination: addr,
+	}); err != nil {
+		return err
+	}
+
+	timeout := time.After(cmd.timeout)
+
+	for {
+		select {
+		case event, open := <-cmd.client.Inbound():
+			if !open {
+				return errors.New("connection closed")
+			}
+
+			if event.Command != knx.GroupResponse || event.Destination != addr {
+				continue
+			}
+
+			res.Source = event.Source.String()
+			res.Command = event.Command.String()
+
+			if err := res.decode(name, event.Data); err != nil {
+				return err
+			}
+
+			return cmd.print(&res)
+
+		case <-timeout:
+			return errTimeout
+		}
+	}
+}
+
+// send transmits a value using the given group command.
+func (cmd *command) send(groupCmd knx.GroupCommand, args []string) error {
+	fs := flag.NewFlagSet(strings.ToLower(groupCmd.String()), flag.ContinueOnError)
+	dptName := fs.String("dpt", "", "datapoint type of the group address")
+	raw := fs.Bool("raw", false, "interpret the value as hexadecimal payload")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	} else if len(positional) != 2 {
+		return fmt.Errorf("%s expects a group address and a value", fs.Name())
+	}
+
+	addr, res, name, err := cmd.target(positional[0], *dptName)
+	if err != nil {
+		return err
+	}
+
+	data, err := parseValue(name, positional[1], *raw)
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.client.Send(knx.GroupEvent{
+		Command:     groupCmd,
+		Destination: addr,
+		Data:        data,
+	}); err != nil {
+		return err
+	}
+
+	if !cmd.json {
+		return nil
+	}
+
+	res.Command = groupCmd.String()
+
+	if *raw {
+		name = ""
+	}
+
+	if err := res.decode(name, data); err != nil {
+		return err
+	}
+
+	return cmd.print(&res)
+}
+
+// run executes the command given by the arguments.
+func (cmd *command) run(args []string) error {
+	switch args[0] {
+	case "read":
+		return cmd.read(args[1:])
+
+	case "write":
+		return cmd.send(knx.GroupWrite, args[1:])
+
+	case "response":
+		return cmd.send(knx.GroupResponse, args[1:])
+	}
+
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func main() {
+	address := flag.String("address", os.Getenv("KNX_ADDRESS"), "gateway, multicast group or URL")
+	project := flag.String("project", "", "ETS project, group address CSV or XML export")
+	password := flag.String("password", "", "password of the ETS project")
+	timeout := flag.Duration("timeout", 3*time.Second, "how long to wait for a response")
+	style := flag.String("style", "3", "group address style, either 3, 2 or free")
+	jsonOutput := flag.Bool("json", false, "print results as JSON")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flag.NArg() == 0 || *address == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cmd := &command{timeout: *timeout, json: *jsonOutput, out: os.Stdout}
+
+	if err := cmd.setup(*address, *project, *password, *style); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+		os.Exit(1)
+	}
+
+	err := cmd.run(flag.Args())
+	cmd.client.Close()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+		os.Exit(1)
+	}
+}
+
+// setup loads the registry and connects to the network.
+func (cmd *command) setup(address, project, password, style string) error {
+	reg := registry.New()
+
+	if project != "" {
+		var err error
+		if reg, err = registry.Load(project, password); err != nil {
+			return err
+		}
+	}
+
+	addressStyle, err := registry.ParseAddressStyle(style)
+	if err != nil {
+		return err
+	}
+
+	reg.SetStyle(addressStyle)
+
+	client, err := knx.DialGroup(address)
+	if err != nil {
+		return err
+	}
+
+	cmd.client = client
+	cmd.reg = reg
+
+	return nil
+}
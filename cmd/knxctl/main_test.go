@@ -0,0 +1,193 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/registry"
+)
+
+// fakeClient answers read requests with a fixed payload.
+type fakeClient struct {
+	inbound chan knx.GroupEvent
+	sent    []knx.GroupEvent
+	answer  []byte
+}
+
+func (client *fakeClient) Send(event knx.GroupEvent) error {
+	client.sent = append(client.sent, event)
+
+	if event.Command == knx.GroupRead && client.answer != nil {
+		go func() {
+			// Unrelated traffic must be ignored.
+			client.inbound <- knx.GroupEvent{Command: knx.GroupWrite, Destination: event.Destination}
+			client.inbound <- knx.GroupEvent{
+				Command:     knx.GroupResponse,
+				Source:      cemi.NewIndividualAddr3(1, 1, 5),
+				Destination: event.Destination,
+				Data:        client.answer,
+			}
+		}()
+	}
+
+	return nil
+}
+
+func (client *fakeClient) Inbound() <-chan knx.GroupEvent {
+	return client.inbound
+}
+
+func (client *fakeClient) Close() {}
+
+func makeTestCommand(answer []byte) (*command, *fakeClient, *bytes.Buffer) {
+	reg := registry.New()
+	reg.Add(registry.Entry{
+		Address: cemi.NewGroupAddr3(1, 2, 3),
+		Name:    "Temperature",
+		Path:    []string{"Kitchen"},
+		DPT:     "9.001",
+	})
+
+	client := &fakeClient{inbound: make(chan knx.GroupEvent), answer: answer}
+	out := &bytes.Buffer{}
+
+	return &command{client: client, reg: reg, timeout: time.Second, out: out}, client, out
+}
+
+func TestParseValue(t *testing.T) {
+	cases := []struct {
+		dpt   string
+		text  string
+		raw   bool
+		bytes []byte
+	}{
+		{"1.001", "on", false, []byte{1}},
+		{"1.001", "False", false, []byte{0}},
+		{"9.001", "21", false, []byte{0, 0x0C, 0x1A}},
+		{"5.001", "100", false, []byte{0, 0xFF}},
+		{"9.001", "0x000C1A", true, []byte{0, 0x0C, 0x1A}},
+		{"", "0c 1a", false, []byte{0x0C, 0x1A}},
+	}
+
+	for _, c := range cases {
+		data, err := parseValue(c.dpt, c.text, c.raw)
+		if err != nil || !bytes.Equal(data, c.bytes) {
+			t.Errorf("%s %q: got %v, %v", c.dpt, c.text, data, err)
+		}
+	}
+
+	for _, c := range [][2]string{{"9.001", "warm"}, {"0.000", "1"}, {"", "xyz"}, {"", ""}} {
+		if _, err := parseValue(c[0], c[1], false); err == nil {
+			t.Errorf("%s %q: value was accepted", c[0], c[1])
+		}
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	fs := flag.NewFlagSet("write", flag.ContinueOnError)
+	dptName := fs.String("dpt", "", "")
+
+	positional, err := parseArgs(fs, []string{"1/2/3", "--dpt", "9.001", "21.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(positional, []string{"1/2/3", "21.5"}) || *dptName != "9.001" {
+		t.Errorf("Unexpected result %v, %q", positional, *dptName)
+	}
+
+	positional, err = parseArgs(fs, []string{"1/2/3", "-5", "-dpt", "9.002"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(positional, []string{"1/2/3", "-5"}) || *dptName != "9.002" {
+		t.Errorf("Unexpected result %v, %q", positional, *dptName)
+	}
+}
+
+func TestCommand_read(t *testing.T) {
+	cmd, client, out := makeTestCommand([]byte{0, 0x0C, 0x1A})
+
+	if err := cmd.run([]string{"read", "Kitchen/Temperature"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != "21.0 °C\n" {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	if len(client.sent) != 1 || client.sent[0].Destination != cemi.NewGroupAddr3(1, 2, 3) {
+		t.Errorf("Unexpected requests %+v", client.sent)
+	}
+
+	out.Reset()
+	cmd.json = true
+
+	if err := cmd.run([]string{"read", "1/2/3", "-dpt", "7.001"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var res result
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Source != "1.1.5" || res.Data != "000c1a" || res.DPT != "7.001" || res.Value != "3098" ||
+		res.Name != "Kitchen/Temperature" || res.Numeric == nil || *res.Numeric != 3098 {
+		t.Errorf("Unexpected result %+v", res)
+	}
+}
+
+func TestCommand_readTimeout(t *testing.T) {
+	cmd, _, _ := makeTestCommand(nil)
+	cmd.timeout = 10 * time.Millisecond
+
+	if err := cmd.run([]string{"read", "1/2/3"}); err != errTimeout {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestCommand_write(t *testing.T) {
+	cmd, client, out := makeTestCommand(nil)
+
+	if err := cmd.run([]string{"write", "1/2/3", "21"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cmd.run([]string{"response", "1/2/4", "-raw", "01"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cmd.run([]string{"write", "1/2/5", "-5", "-dpt", "9.001"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []knx.GroupEvent{
+		{Command: knx.GroupWrite, Destination: cemi.NewGroupAddr3(1, 2, 3), Data: []byte{0, 0x0C, 0x1A}},
+		{Command: knx.GroupResponse, Destination: cemi.NewGroupAddr3(1, 2, 4), Data: []byte{1}},
+		{Command: knx.GroupWrite, Destination: cemi.NewGroupAddr3(1, 2, 5), Data: []byte{0, 0x86, 0x0C}},
+	}
+
+	if !reflect.DeepEqual(client.sent, expected) {
+		t.Errorf("Unexpected events %+v", client.sent)
+	}
+
+	if out.Len() != 0 {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	for _, args := range [][]string{{"write", "1/2/3"}, {"write", "Nowhere", "1"}, {"toggle", "1/2/3"}} {
+		if err := cmd.run(args); err == nil {
+			t.Errorf("%v: command was accepted", args)
+		}
+	}
+}
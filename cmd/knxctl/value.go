@@ -0,0 +1,88 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/vapourismo/knx-go/knx/dpt"
+)
+
+// parseHex parses a raw payload such as "000C1A", "0x000c1a" or "00 0c 1a".
+func parseHex(text string) ([]byte, error) {
+	text = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(text)), "0x")
+	text = strings.NewReplacer(" ", "", ":", "").Replace(text)
+
+	if text == "" {
+		return nil, errors.New("payload is empty")
+	}
+
+	return hex.DecodeString(text)
+}
+
+// parseValue encodes the value text using the datapoint type. Without datapoint type, or if raw is
+// set, the text is taken to be a hexadecimal payload.
+func parseValue(name, text string, raw bool) ([]byte, error) {
+	if raw || name == "" {
+		return parseHex(text)
+	}
+
+	return dpt.Encode(name, text)
+}
+
+// A result describes a value which was sent or received.
+type result struct {
+	Address string   `json:"address"`
+	Name    string   `json:"name,omitempty"`
+	Source  string   `json:"source,omitempty"`
+	Command string   `json:"command"`
+	Data    string   `json:"data"`
+	DPT     string   `json:"dpt,omitempty"`
+	Value   string   `json:"value,omitempty"`
+	Unit    string   `json:"unit,omitempty"`
+	Numeric *float64 `json:"numeric,omitempty"`
+}
+
+// decode fills in the value of the result using the datapoint type, if it is known.
+func (res *result) decode(name string, data []byte) error {
+	res.Data = hex.EncodeToString(data)
+
+	if name == "" {
+		return nil
+	}
+
+	value, ok := dpt.Produce(name)
+	if !ok {
+		return fmt.Errorf("unknown datapoint type %q", name)
+	}
+
+	if err := value.Unpack(data); err != nil {
+		return err
+	}
+
+	numeric := value.Float()
+
+	res.DPT = name
+	res.Value = strings.TrimSpace(value.String())
+	res.Unit = value.Unit()
+	res.Numeric = &numeric
+
+	return nil
+}
+
+// String formats the value for humans. Payloads without datapoint type are shown in hexadecimal.
+func (res *result) String() string {
+	if res.DPT == "" {
+		return res.Data
+	}
+
+	// Some datapoint types include the unit in their string representation.
+	if res.Unit == "" || strings.HasSuffix(res.Value, res.Unit) {
+		return res.Value
+	}
+
+	return res.Value + " " + res.Unit
+}
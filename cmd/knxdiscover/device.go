@@ -0,0 +1,165 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+)
+
+// A slot is a tunnelling slot of a device.
+type slot struct {
+	Address    string `json:"address"`
+	Free       bool   `json:"free"`
+	Authorised bool   `json:"authorised"`
+	Usable     bool   `json:"usable"`
+}
+
+// String formats the slot, e.g. "1.1.250 free".
+func (s slot) String() string {
+	var states []string
+
+	if s.Free {
+		states = append(states, "free")
+	} else {
+		states = append(states, "busy")
+	}
+
+	if s.Authorised {
+		states = append(states, "authorised")
+	}
+
+	if !s.Usable {
+		states = append(states, "unusable")
+	}
+
+	return s.Address + " " + strings.Join(states, ",")
+}
+
+// A device is a KNXnet/IP server, possibly found on several interfaces.
+type device struct {
+	Endpoint   string   `json:"endpoint"`
+	Interfaces []string `json:"interfaces"`
+	Name       string   `json:"name"`
+	Address    string   `json:"address"`
+	MAC        string   `json:"mac"`
+	Serial     string   `json:"serial"`
+	Medium     string   `json:"medium"`
+	Multicast  string   `json:"multicast"`
+	Services   []string `json:"services"`
+	Described  bool     `json:"described"`
+	Slots      []slot   `json:"slots,omitempty"`
+
+	control knxnet.HostInfo
+}
+
+// update fills in the device information from the description block.
+func (dev *device) update(block *knxnet.DescriptionBlock) {
+	hw := &block.DeviceHardware
+
+	dev.Name = strings.TrimRight(hw.FriendlyName, "\x00 ")
+	dev.Address = hw.Source.String()
+	dev.MAC = hw.HardwareAddr.String()
+	dev.Serial = hw.SerialNumber.String()
+	dev.Medium = hw.Medium.String()
+	dev.Multicast = hw.RoutingMulticastAddress.String()
+
+	dev.Services = dev.Services[:0]
+	for _, family := range block.SupportedServices.Families {
+		dev.Services = append(dev.Services, family.String())
+	}
+
+	if block.TunnellingInfo != nil {
+		dev.Slots = dev.Slots[:0]
+
+		for _, s := range block.TunnellingInfo.Slots {
+			dev.Slots = append(dev.Slots, slot{
+				Address:    s.Address.String(),
+				Free:       s.Status&knxnet.TunnellingSlotFree != 0,
+				Authorised: s.Status&knxnet.TunnellingSlotAuthorised != 0,
+				Usable:     s.Status&knxnet.TunnellingSlotUsable != 0,
+			})
+		}
+	}
+}
+
+// SlotSummary summarizes the tunnelling slots, e.g. "1/2 free". It is empty if the device did
+// not report its slots.
+func (dev *device) SlotSummary() string {
+	if len(dev.Slots) == 0 {
+		return ""
+	}
+
+	free := 0
+	for _, s := range dev.Slots {
+		if s.Free && s.Usable {
+			free++
+		}
+	}
+
+	return fmt.Sprintf("%d/%d free", free, len(dev.Slots))
+}
+
+// endpoint formats the control endpoint of a search response.
+func endpoint(res *knxnet.SearchRes) string {
+	return fmt.Sprintf("%v:%d", res.Control.Address, res.Control.Port)
+}
+
+// addInterface adds the interface name, keeping the names sorted and unique.
+func (dev *device) addInterface(name string) {
+	idx := sort.SearchStrings(dev.Interfaces, name)
+	if idx == len(dev.Interfaces) || dev.Interfaces[idx] != name {
+		dev.Interfaces = append(dev.Interfaces, "")
+		copy(dev.Interfaces[idx+1:], dev.Interfaces[idx:])
+		dev.Interfaces[idx] = name
+	}
+}
+
+// merge combines the search results into one device per control endpoint, sorted by endpoint.
+func merge(results []knx.DiscoveryResult) []*device {
+	devices := map[string]*device{}
+
+	for _, result := range results {
+		key := endpoint(result.Response)
+
+		dev, ok := devices[key]
+		if !ok {
+			dev = &device{Endpoint: key, control: result.Response.Control}
+			dev.update(&result.Response.DescriptionB)
+			devices[key] = dev
+		}
+
+		ifis := result.Interfaces
+		if len(ifis) == 0 {
+			ifis = []*net.Interface{result.Interface}
+		}
+
+		for _, ifi := range ifis {
+			if ifi != nil {
+				dev.addInterface(ifi.Name)
+			}
+		}
+	}
+
+	sorted := make([]*device, 0, len(devices))
+	for _, dev := range devices {
+		sorted = append(sorted, dev)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i].control, sorted[j].control
+		if c := bytes.Compare(a.Address[:], b.Address[:]); c != 0 {
+			return c < 0
+		}
+
+		return a.Port < b.Port
+	})
+
+	return sorted
+}
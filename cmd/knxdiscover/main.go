@@ -0,0 +1,100 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Command knxdiscover lists the KNXnet/IP devices on the local networks.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+)
+
+// describeAll describes the devices in parallel. Devices which answer are updated with their
+// description, which also contains the tunnelling slots on devices supporting KNXnet/IP Core v2.
+func describeAll(devices []*device, describe func(endpoint string) (*knxnet.DescriptionRes, error)) {
+	var wait sync.WaitGroup
+
+	for _, dev := range devices {
+		wait.Add(1)
+		go func(dev *device) {
+			defer wait.Done()
+
+			res, err := describe(dev.Endpoint)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: describing %s failed: %v\n", os.Args[0], dev.Endpoint, err)
+				return
+			}
+
+			if res != nil {
+				dev.update((*knxnet.DescriptionBlock)(res))
+				dev.Described = true
+			}
+		}(dev)
+	}
+
+	wait.Wait()
+}
+
+// search discovers the devices on the named interface, or on all interfaces if the name is empty.
+func search(ifName string, timeout time.Duration) ([]knx.DiscoveryResult, error) {
+	if ifName == "" {
+		return knx.DiscoverAll("224.0.23.12:3671", timeout)
+	}
+
+	ifi, err := net.InterfaceByName(ifName)
+	if err != nil {
+		return nil, err
+	}
+
+	responses, err := knx.DiscoverOnInterface(ifi, "224.0.23.12:3671", timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]knx.DiscoveryResult, len(responses))
+	for i, res := range responses {
+		results[i] = knx.DiscoveryResult{Interface: ifi, Response: res}
+	}
+
+	return results, nil
+}
+
+func main() {
+	timeout := flag.Duration("timeout", 3*time.Second, "how long to wait for search and description responses")
+	format := flag.String("format", "table", "output format, either table, json or csv")
+	ifName := flag.String("interface", "", "only search on this interface")
+	describe := flag.Bool("describe", true, "describe each device to learn its tunnelling slots")
+
+	flag.Parse()
+
+	printDevices, ok := printers[*format]
+	if !ok || flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	results, err := search(*ifName, *timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+		os.Exit(1)
+	}
+
+	devices := merge(results)
+
+	if *describe {
+		describeAll(devices, func(endpoint string) (*knxnet.DescriptionRes, error) {
+			return knx.DescribeTunnel(endpoint, *timeout)
+		})
+	}
+
+	if err := printDevices(os.Stdout, devices); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,140 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+)
+
+func makeSearchRes(ip knxnet.Address, name string) *knxnet.SearchRes {
+	return &knxnet.SearchRes{
+		Control: knxnet.HostInfo{Protocol: knxnet.UDP4, Address: ip, Port: 3671},
+		DescriptionB: knxnet.DescriptionBlock{
+			DeviceHardware: knxnet.DeviceInformationBlock{
+				Medium:                  knxnet.KNXMediumTP1,
+				Source:                  cemi.NewIndividualAddr3(1, 1, 0),
+				SerialNumber:            knxnet.DeviceSerialNumber{0x00, 0xFA, 0x12, 0x34, 0x56, 0x78},
+				RoutingMulticastAddress: knxnet.Address{224, 0, 23, 12},
+				HardwareAddr:            net.HardwareAddr{0, 0x24, 0x6d, 1, 2, 3},
+				FriendlyName:            name,
+			},
+			SupportedServices: knxnet.SupportedServicesDIB{
+				Families: []knxnet.ServiceFamily{
+					{Type: knxnet.ServiceFamilyTypeIPCore, Version: 2},
+					{Type: knxnet.ServiceFamilyTypeIPTunnelling, Version: 2},
+				},
+			},
+		},
+	}
+}
+
+func makeTestDevices() []*device {
+	eth0 := &net.Interface{Name: "eth0"}
+	wlan0 := &net.Interface{Name: "wlan0"}
+	vlan10 := &net.Interface{Name: "vlan10"}
+
+	return merge([]knx.DiscoveryResult{
+		{Interface: wlan0, Response: makeSearchRes(knxnet.Address{10, 0, 0, 10}, "Router")},
+		{
+			Interface:  eth0,
+			Interfaces: []*net.Interface{eth0, vlan10},
+			Response:   makeSearchRes(knxnet.Address{10, 0, 0, 2}, "Interface"),
+		},
+		{Interface: eth0, Response: makeSearchRes(knxnet.Address{10, 0, 0, 10}, "Router")},
+	})
+}
+
+func TestMerge(t *testing.T) {
+	devices := makeTestDevices()
+
+	if len(devices) != 2 {
+		t.Fatalf("Unexpected devices %+v", devices)
+	}
+
+	dev := devices[1]
+	if dev.Endpoint != "10.0.0.10:3671" || dev.Name != "Router" || dev.Address != "1.1.0" ||
+		dev.MAC != "00:24:6d:01:02:03" || dev.Serial != "00FA:12345678" || dev.Medium != "TP1" ||
+		!reflect.DeepEqual(dev.Interfaces, []string{"eth0", "wlan0"}) ||
+		!reflect.DeepEqual(dev.Services, []string{"Core v2", "Tunnelling v2"}) {
+		t.Errorf("Unexpected device %+v", dev)
+	}
+
+	if devices[0].Endpoint != "10.0.0.2:3671" {
+		t.Errorf("Devices are not sorted: %+v", devices)
+	}
+
+	if !reflect.DeepEqual(devices[0].Interfaces, []string{"eth0", "vlan10"}) {
+		t.Errorf("Unexpected interfaces %v", devices[0].Interfaces)
+	}
+}
+
+func TestDescribeAll(t *testing.T) {
+	devices := makeTestDevices()
+
+	describeAll(devices, func(endpoint string) (*knxnet.DescriptionRes, error) {
+		if endpoint != "10.0.0.10:3671" {
+			return nil, nil
+		}
+
+		res := knxnet.DescriptionRes(makeSearchRes(knxnet.Address{10, 0, 0, 10}, "Router").DescriptionB)
+		res.TunnellingInfo = &knxnet.TunnellingInfoDIB{
+			MaxAPDULength: 254,
+			Slots: []knxnet.TunnellingSlot{
+				{Address: cemi.NewIndividualAddr3(1, 1, 250), Status: knxnet.TunnellingSlotFree | knxnet.TunnellingSlotUsable},
+				{Address: cemi.NewIndividualAddr3(1, 1, 251), Status: knxnet.TunnellingSlotUsable},
+			},
+		}
+
+		return &res, nil
+	})
+
+	if devices[0].Described || devices[0].SlotSummary() != "" {
+		t.Errorf("Unexpected device %+v", devices[0])
+	}
+
+	if !devices[1].Described || devices[1].SlotSummary() != "1/2 free" {
+		t.Errorf("Unexpected device %+v", devices[1])
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := printCSV(buffer, devices); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[2], ",1/2 free,1.1.250:free 1.1.251:busy,eth0 wlan0") {
+		t.Errorf("Unexpected CSV %q", buffer.String())
+	}
+
+	buffer.Reset()
+	if err := printJSON(buffer, devices); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded []device
+	if err := json.Unmarshal(buffer.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(decoded) != 2 || len(decoded[1].Slots) != 2 || !decoded[1].Slots[0].Free || decoded[1].Slots[1].Free {
+		t.Errorf("Unexpected JSON %s", buffer.String())
+	}
+
+	buffer.Reset()
+	if err := printTable(buffer, devices); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buffer.String(), "Core v2, Tunnelling v2  1/2 free") {
+		t.Errorf("Unexpected table %q", buffer.String())
+	}
+}
@@ -0,0 +1,71 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// printTable writes the devices as a table meant for humans.
+func printTable(w io.Writer, devices []*device) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "Name\tAddress\tEndpoint\tMAC\tSerial\tMedium\tServices\tTunnels\tInterfaces")
+
+	for _, dev := range devices {
+		fmt.Fprintf(
+			tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			dev.Name, dev.Address, dev.Endpoint, dev.MAC, dev.Serial, dev.Medium,
+			strings.Join(dev.Services, ", "), dev.SlotSummary(), strings.Join(dev.Interfaces, ","),
+		)
+	}
+
+	return tw.Flush()
+}
+
+// printJSON writes the devices as a JSON array.
+func printJSON(w io.Writer, devices []*device) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(devices)
+}
+
+// printCSV writes the devices as CSV with a header row. Lists are separated by spaces, the
+// tunnelling slots are given as "address:state" pairs.
+func printCSV(w io.Writer, devices []*device) error {
+	writer := csv.NewWriter(w)
+
+	writer.Write([]string{
+		"Name", "Address", "Endpoint", "MAC", "Serial", "Medium", "Multicast", "Services",
+		"Tunnels", "Slots", "Interfaces",
+	})
+
+	for _, dev := range devices {
+		slots := make([]string, len(dev.Slots))
+		for i, s := range dev.Slots {
+			slots[i] = strings.Replace(s.String(), " ", ":", 1)
+		}
+
+		writer.Write([]string{
+			dev.Name, dev.Address, dev.Endpoint, dev.MAC, dev.Serial, dev.Medium, dev.Multicast,
+			strings.Join(dev.Services, " "), dev.SlotSummary(), strings.Join(slots, " "),
+			strings.Join(dev.Interfaces, " "),
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// printers maps the output formats to their printers.
+var printers = map[string]func(io.Writer, []*device) error{
+	"table": printTable,
+	"json":  printJSON,
+	"csv":   printCSV,
+}
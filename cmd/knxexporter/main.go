@@ -0,0 +1,138 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Command knxexporter serves the health of a KNXnet/IP connection and the values of group
+// addresses as Prometheus metrics.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/registry"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+const usage = `Usage: %s [flags]
+
+Metrics are served on /metrics in the Prometheus text format. Besides counters of packets, frames,
+resends, heartbeat failures, reconnects and routing flow control, the values of group addresses
+with a numeric datapoint type are exported as knx_group_value, labelled with address, name, dpt
+and unit. Datapoint types are taken from the project.
+
+The address is a gateway (10.0.0.2:3671), a multicast group (224.0.23.12:3671) or a URL as
+accepted by knx.Dial. It defaults to $KNX_ADDRESS.
+
+Flags:
+`
+
+// exporter serves the metrics of the current connection.
+type exporter struct {
+	metrics *metrics
+
+	mu        sync.Mutex
+	transport knx.Transport
+}
+
+func (exp *exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	exp.mu.Lock()
+	transport := exp.transport
+	exp.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	exp.metrics.writeTo(w, transport)
+}
+
+// serve records the group values until the connection is closed.
+func (exp *exporter) serve(transport knx.Transport) {
+	exp.mu.Lock()
+	exp.transport = transport
+	exp.mu.Unlock()
+
+	client := knx.NewGroupTransport(transport)
+
+	for event := range client.Inbound() {
+		exp.metrics.update(event, time.Now())
+	}
+
+	exp.mu.Lock()
+	exp.transport = nil
+	exp.mu.Unlock()
+}
+
+func main() {
+	address := flag.String("address", os.Getenv("KNX_ADDRESS"), "gateway, multicast group or URL")
+	listen := flag.String("listen", ":9671", "address on which to serve the metrics")
+	project := flag.String("project", "", "ETS project, group address CSV or XML export")
+	projectPassword := flag.String("project-password", "", "password of the ETS project")
+	style := flag.String("style", "3", "group address style in labels, either 3, 2 or free")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flag.NArg() != 0 || *address == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+	util.Logger = logger
+
+	reg := registry.New()
+
+	if *project != "" {
+		var err error
+		if reg, err = registry.Load(*project, *projectPassword); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	addressStyle, err := registry.ParseAddressStyle(*style)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	reg.SetStyle(addressStyle)
+
+	exp := &exporter{metrics: newMetrics(reg)}
+
+	config := knx.DialConfig{Tunnel: knx.DefaultTunnelConfig, Router: knx.DefaultRouterConfig}
+	config.Tunnel.Hooks = exp.metrics.hooks()
+	config.Router.Hooks = exp.metrics.hooks()
+
+	http.Handle("/metrics", exp)
+
+	go func() {
+		logger.Fatal(http.ListenAndServe(*listen, nil))
+	}()
+
+	// Loop for ever. Failures don't matter, we'll always retry.
+	for {
+		transport, err := knx.DialConfigured(*address, config)
+		if err != nil {
+			logger.Printf("Error while connecting: %v\n", err)
+			exp.metrics.add("knx_reconnect_attempts_total", "error", 1)
+
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		logger.Printf("Connected to %s\n", *address)
+
+		exp.serve(transport)
+		transport.Close()
+
+		logger.Printf("Connection has been lost\n")
+
+		time.Sleep(time.Second)
+	}
+}
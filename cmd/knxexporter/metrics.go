@@ -0,0 +1,302 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/dpt"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+	"github.com/vapourismo/knx-go/knx/registry"
+)
+
+// latencyBuckets are the upper bounds of the round-trip histogram in seconds.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// A histogram counts observations in cumulative buckets.
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// observe adds the value to the histogram.
+func (h *histogram) observe(value float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(latencyBuckets))
+	}
+
+	for i, bound := range latencyBuckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+
+	h.count++
+	h.sum += value
+}
+
+// A groupValue is the last numeric value of a group address.
+type groupValue struct {
+	value float64
+	time  time.Time
+}
+
+// metrics collects the statistics of a connection and the values of the group addresses.
+type metrics struct {
+	reg *registry.Registry
+
+	mu        sync.Mutex
+	counters  map[string]map[string]uint64
+	roundTrip histogram
+	values    map[cemi.GroupAddr]groupValue
+}
+
+// newMetrics creates an empty collection.
+func newMetrics(reg *registry.Registry) *metrics {
+	return &metrics{
+		reg:      reg,
+		counters: map[string]map[string]uint64{},
+		values:   map[cemi.GroupAddr]groupValue{},
+	}
+}
+
+// add increases the counter with the given label value.
+func (m *metrics) add(name, label string, delta uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counter, ok := m.counters[name]
+	if !ok {
+		counter = map[string]uint64{}
+		m.counters[name] = counter
+	}
+
+	counter[label] += delta
+}
+
+// serviceName returns the name of the KNXnet/IP service, e.g. "TunnelReq".
+func serviceName(service knxnet.Service) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", service), "*knxnet.")
+}
+
+// result labels the outcome of an operation.
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+
+	return "ok"
+}
+
+// hooks returns the hooks which feed the counters.
+func (m *metrics) hooks() knx.Hooks {
+	return knx.Hooks{
+		PacketIn: func(service knxnet.Service) {
+			m.add("knx_packets_received_total", serviceName(service), 1)
+		},
+		PacketOut: func(service knxnet.ServicePackable, err error) {
+			m.add("knx_packets_sent_total", serviceName(service), 1)
+		},
+		FrameIn: func(cemi.Message) {
+			m.add("knx_frames_received_total", "", 1)
+		},
+		FrameOut: func(_ cemi.Message, err error) {
+			m.add("knx_frames_sent_total", result(err), 1)
+		},
+		Resend: func() {
+			m.add("knx_tunnel_resends_total", "", 1)
+		},
+		RoundTrip: func(latency time.Duration) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			m.roundTrip.observe(latency.Seconds())
+		},
+		HeartbeatFailed: func(error) {
+			m.add("knx_heartbeat_failures_total", "", 1)
+		},
+		Reconnect: func(_ int, err error) {
+			m.add("knx_reconnect_attempts_total", result(err), 1)
+		},
+		RoutingBusy: func(*knxnet.RoutingBusy) {
+			m.add("knx_routing_busy_total", "", 1)
+		},
+		RoutingLost: func(msg *knxnet.RoutingLost) {
+			m.add("knx_routing_lost_frames_total", "", uint64(msg.Count))
+		},
+	}
+}
+
+// update records the value carried by the event, if the group address has a numeric datapoint
+// type. Booleans of DPT 1 are exported as 0 and 1.
+func (m *metrics) update(event knx.GroupEvent, now time.Time) {
+	if event.Command == knx.GroupRead {
+		return
+	}
+
+	entry, ok := m.reg.Lookup(event.Destination)
+	if !ok || (!strings.HasPrefix(entry.DPT, "1.") && !dpt.IsNumeric(entry.DPT)) {
+		return
+	}
+
+	value, ok := dpt.Produce(entry.DPT)
+	if !ok || value.Unpack(event.Data) != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.values[event.Destination] = groupValue{value: value.Float(), time: now}
+}
+
+// labelEscaper escapes label values as demanded by the text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatFloat formats the value as the text format expects it.
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// A connection is the part of a transport whose state is exported.
+type connection interface {
+	State() knx.ConnState
+}
+
+// counterHelp describes the counters. The label of each counter is given after the text.
+var counterHelp = map[string][2]string{
+	"knx_packets_received_total":    {"KNXnet/IP packets received by the socket.", "service"},
+	"knx_packets_sent_total":        {"KNXnet/IP packets sent through the socket.", "service"},
+	"knx_frames_received_total":     {"Frames received from the network.", ""},
+	"knx_frames_sent_total":         {"Frames sent to the network.", "result"},
+	"knx_tunnel_resends_total":      {"Tunnel requests which had to be repeated.", ""},
+	"knx_heartbeat_failures_total":  {"Failed connection state requests.", ""},
+	"knx_reconnect_attempts_total":  {"Attempts to re-establish the connection.", "result"},
+	"knx_routing_busy_total":        {"RoutingBusy indications received from other routers.", ""},
+	"knx_routing_lost_frames_total": {"Frames other routers have reported as lost.", ""},
+}
+
+// writeTo writes the metrics in the Prometheus text format. The transport, if not nil, provides
+// its state and queue depths.
+func (m *metrics) writeTo(w io.Writer, transport knx.Transport) {
+	if conn, ok := transport.(connection); ok {
+		connected := 0
+		if conn.State() == knx.StateConnected {
+			connected = 1
+		}
+
+		fmt.Fprintf(w, "# HELP knx_connected Whether the connection is established.\n")
+		fmt.Fprintf(w, "# TYPE knx_connected gauge\nknx_connected %d\n", connected)
+	}
+
+	if queue, ok := transport.(interface{ QueueDepth() int }); ok {
+		fmt.Fprintf(w, "# HELP knx_send_queue_depth Frames waiting to be sent.\n")
+		fmt.Fprintf(w, "# TYPE knx_send_queue_depth gauge\nknx_send_queue_depth %d\n", queue.QueueDepth())
+	}
+
+	if router, ok := transport.(*knx.Router); ok {
+		state := router.FlowState()
+
+		fmt.Fprintf(w, "# HELP knx_inbound_queue_depth Received frames which have not been processed yet.\n")
+		fmt.Fprintf(w, "# TYPE knx_inbound_queue_depth gauge\nknx_inbound_queue_depth %d\n", state.Queued)
+		fmt.Fprintf(w, "# HELP knx_routing_dropped_frames_total Received frames dropped because the queue was full.\n")
+		fmt.Fprintf(w, "# TYPE knx_routing_dropped_frames_total counter\nknx_routing_dropped_frames_total %d\n", state.Lost)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(counterHelp))
+	for name := range counterHelp {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		help := counterHelp[name]
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help[0], name)
+
+		counter := m.counters[name]
+
+		labels := make([]string, 0, len(counter))
+		for label := range counter {
+			labels = append(labels, label)
+		}
+
+		sort.Strings(labels)
+
+		if help[1] == "" {
+			fmt.Fprintf(w, "%s %d\n", name, counter[""])
+			continue
+		}
+
+		for _, label := range labels {
+			fmt.Fprintf(w, "%s{%s=\"%s\"} %d\n", name, help[1], labelEscaper.Replace(label), counter[label])
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP knx_tunnel_round_trip_seconds Time until the gateway acknowledges a tunnel request.\n")
+	fmt.Fprintf(w, "# TYPE knx_tunnel_round_trip_seconds histogram\n")
+
+	for i, bound := range latencyBuckets {
+		var count uint64
+		if m.roundTrip.counts != nil {
+			count = m.roundTrip.counts[i]
+		}
+
+		fmt.Fprintf(w, "knx_tunnel_round_trip_seconds_bucket{le=\"%s\"} %d\n", formatFloat(bound), count)
+	}
+
+	fmt.Fprintf(w, "knx_tunnel_round_trip_seconds_bucket{le=\"+Inf\"} %d\n", m.roundTrip.count)
+	fmt.Fprintf(w, "knx_tunnel_round_trip_seconds_sum %s\n", formatFloat(m.roundTrip.sum))
+	fmt.Fprintf(w, "knx_tunnel_round_trip_seconds_count %d\n", m.roundTrip.count)
+
+	addrs := make([]cemi.GroupAddr, 0, len(m.values))
+	for addr := range m.values {
+		addrs = append(addrs, addr)
+	}
+
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+
+	fmt.Fprintf(w, "# HELP knx_group_value Last value of a group address.\n# TYPE knx_group_value gauge\n")
+
+	for _, addr := range addrs {
+		fmt.Fprintf(w, "knx_group_value{%s} %s\n", m.groupLabels(addr), formatFloat(m.values[addr].value))
+	}
+
+	fmt.Fprintf(w, "# HELP knx_group_value_timestamp_seconds Time at which the value of a group address was received.\n")
+	fmt.Fprintf(w, "# TYPE knx_group_value_timestamp_seconds gauge\n")
+
+	for _, addr := range addrs {
+		at := float64(m.values[addr].time.UnixNano()) / 1e9
+		fmt.Fprintf(w, "knx_group_value_timestamp_seconds{%s} %s\n", m.groupLabels(addr), formatFloat(at))
+	}
+}
+
+// groupLabels generates the labels of a group address.
+func (m *metrics) groupLabels(addr cemi.GroupAddr) string {
+	entry, _ := m.reg.Lookup(addr)
+
+	unit := ""
+	if value, ok := dpt.Produce(entry.DPT); ok {
+		unit = value.Unit()
+	}
+
+	return fmt.Sprintf(
+		`address="%s",name="%s",dpt="%s",unit="%s"`,
+		labelEscaper.Replace(m.reg.Format(addr)),
+		labelEscaper.Replace(entry.FullName()),
+		labelEscaper.Replace(entry.DPT),
+		labelEscaper.Replace(unit),
+	)
+}
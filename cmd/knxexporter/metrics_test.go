@@ -0,0 +1,71 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+	"github.com/vapourismo/knx-go/knx/registry"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := registry.New()
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 2, 3), Name: `Temperature "south"`, Path: []string{"Kitchen"}, DPT: "9.001"})
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 2, 4), Name: "Light", DPT: "1.001"})
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 2, 5), Name: "Text", DPT: "16.000"})
+
+	m := newMetrics(reg)
+	hooks := m.hooks()
+
+	hooks.PacketIn(&knxnet.TunnelReq{})
+	hooks.PacketIn(&knxnet.TunnelReq{})
+	hooks.PacketOut(&knxnet.TunnelRes{}, nil)
+	hooks.FrameOut(nil, errors.New("failed"))
+	hooks.Resend()
+	hooks.RoundTrip(20 * time.Millisecond)
+	hooks.Reconnect(1, nil)
+	hooks.RoutingLost(&knxnet.RoutingLost{Count: 3})
+
+	now := time.Unix(1700000000, 0)
+	m.update(knx.GroupEvent{Command: knx.GroupWrite, Destination: cemi.NewGroupAddr3(1, 2, 3), Data: []byte{0, 0x0C, 0x1A}}, now)
+	m.update(knx.GroupEvent{Command: knx.GroupResponse, Destination: cemi.NewGroupAddr3(1, 2, 4), Data: []byte{1}}, now)
+	m.update(knx.GroupEvent{Command: knx.GroupWrite, Destination: cemi.NewGroupAddr3(1, 2, 5), Data: make([]byte, 15)}, now)
+	m.update(knx.GroupEvent{Command: knx.GroupWrite, Destination: cemi.NewGroupAddr3(1, 2, 6), Data: []byte{1}}, now)
+
+	var buf bytes.Buffer
+	m.writeTo(&buf, nil)
+	output := buf.String()
+
+	for _, line := range []string{
+		`knx_packets_received_total{service="TunnelReq"} 2`,
+		`knx_packets_sent_total{service="TunnelRes"} 1`,
+		`knx_frames_sent_total{result="error"} 1`,
+		`knx_frames_received_total 0`,
+		`knx_tunnel_resends_total 1`,
+		`knx_reconnect_attempts_total{result="ok"} 1`,
+		`knx_routing_lost_frames_total 3`,
+		`knx_tunnel_round_trip_seconds_bucket{le="0.01"} 0`,
+		`knx_tunnel_round_trip_seconds_bucket{le="0.025"} 1`,
+		`knx_tunnel_round_trip_seconds_count 1`,
+		`knx_group_value{address="1/2/3",name="Kitchen/Temperature \"south\"",dpt="9.001",unit="°C"} 21`,
+		`knx_group_value{address="1/2/4",name="Light",dpt="1.001",unit=""} 1`,
+		`knx_group_value_timestamp_seconds{address="1/2/4",name="Light",dpt="1.001",unit=""} 1.7e+09`,
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Output lacks %s", line)
+		}
+	}
+
+	for _, addr := range []string{"1/2/5", "1/2/6"} {
+		if strings.Contains(output, `address="`+addr+`"`) {
+			t.Errorf("Output contains %s", addr)
+		}
+	}
+}
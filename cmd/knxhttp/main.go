@@ -0,0 +1,104 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Command knxhttp exposes group communication on a KNX network as a JSON API.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/registry"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+const usage = `Usage: %s [flags]
+
+Endpoints:
+
+  GET /ga                    list group addresses with metadata and last value
+  GET /ga/<ga>               value of a group address, from the cache or read from the bus
+  PUT /ga/<ga>               write {"value": 21.5}, {"value": 21.5, "dpt": "9.001"} or {"raw": "000c1a"}
+  GET /events                server-sent events, filtered by ga, path, dpt and command parameters
+  GET /openapi.json          OpenAPI description
+
+Group addresses are given as address or as name from the project. Failures are reported with 404
+for unknown addresses, 502 for negative confirmations, 503 while disconnected and 504 if the bus
+does not answer in time.
+
+The address is a gateway (10.0.0.2:3671), a multicast group (224.0.23.12:3671) or a URL as
+accepted by knx.Dial. It defaults to $KNX_ADDRESS.
+
+Flags:
+`
+
+func main() {
+	address := flag.String("address", os.Getenv("KNX_ADDRESS"), "gateway, multicast group or URL")
+	listen := flag.String("listen", ":8080", "address on which to serve HTTP")
+	project := flag.String("project", "", "ETS project, group address CSV or XML export")
+	projectPassword := flag.String("project-password", "", "password of the ETS project")
+	style := flag.String("style", "3", "group address style, either 3, 2 or free")
+	timeout := flag.Duration("timeout", 3*time.Second, "time to wait for responses and confirmations")
+	maxAge := flag.Duration("max-age", 0, "age after which cached values are read again, 0 keeps them")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flag.NArg() != 0 || *address == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+	util.Logger = logger
+
+	reg := registry.New()
+
+	if *project != "" {
+		var err error
+		if reg, err = registry.Load(*project, *projectPassword); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	addressStyle, err := registry.ParseAddressStyle(*style)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	reg.SetStyle(addressStyle)
+
+	srv := newServer(reg, *timeout, *maxAge)
+
+	go func() {
+		logger.Fatal(http.ListenAndServe(*listen, srv.handler()))
+	}()
+
+	// Loop for ever. Failures don't matter, we'll always retry. The API reports 503 meanwhile.
+	for {
+		client, err := knx.DialGroup(*address)
+		if err != nil {
+			logger.Printf("Error while connecting: %v\n", err)
+
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		logger.Printf("Connected to %s\n", *address)
+
+		<-srv.attach(client)
+		client.Close()
+
+		logger.Printf("Connection has been lost\n")
+
+		time.Sleep(time.Second)
+	}
+}
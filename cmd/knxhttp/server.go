@@ -0,0 +1,495 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/registry"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+//go:embed openapi.json
+var openAPI []byte
+
+// keepAliveInterval is the interval at which event streams send a comment, so that proxies don't
+// close idle connections.
+const keepAliveInterval = 30 * time.Second
+
+var (
+	errNotConnected = errors.New("not connected to the KNX network")
+	errNoResponse   = errors.New("no response to the read request")
+)
+
+// A statusError is an error which is reported with the given HTTP status.
+type statusError struct {
+	status int
+	err    error
+}
+
+func (err *statusError) Error() string {
+	return err.err.Error()
+}
+
+func (err *statusError) Unwrap() error {
+	return err.err
+}
+
+// withStatus attaches the HTTP status to the error.
+func withStatus(status int, err error) error {
+	return &statusError{status: status, err: err}
+}
+
+// statusOf determines the HTTP status with which the error is reported.
+func statusOf(err error) int {
+	var se *statusError
+
+	switch {
+	case errors.As(err, &se):
+		return se.status
+
+	case errors.Is(err, knx.ErrNegativeConfirmation):
+		return http.StatusBadGateway
+
+	case errors.Is(err, errNoResponse), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+
+	case errors.Is(err, errNotConnected):
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusInternalServerError
+}
+
+// A server exposes group communication through HTTP.
+type server struct {
+	reg     *registry.Registry
+	timeout time.Duration
+	maxAge  time.Duration
+	cache   cache
+
+	mu     sync.RWMutex
+	client knx.GroupClient
+	mux    *knx.GroupMux
+}
+
+// newServer creates a server which is not yet connected to a KNX network.
+func newServer(reg *registry.Registry, timeout, maxAge time.Duration) *server {
+	return &server{reg: reg, timeout: timeout, maxAge: maxAge}
+}
+
+// attach connects the server to the client. The returned channel is closed once the client has
+// closed its inbound channel, after which the server is detached again.
+func (srv *server) attach(client knx.GroupClient) <-chan struct{} {
+	mux := knx.NewGroupMux(client)
+
+	srv.mu.Lock()
+	srv.client, srv.mux = client, mux
+	srv.mu.Unlock()
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for event := range mux.Inbound() {
+			srv.cache.update(event, time.Now())
+		}
+
+		srv.mu.Lock()
+		srv.client, srv.mux = nil, nil
+		srv.mu.Unlock()
+	}()
+
+	return done
+}
+
+// connection returns the client and its multiplexer, if the server is connected.
+func (srv *server) connection() (knx.GroupClient, *knx.GroupMux, error) {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	if srv.client == nil {
+		return nil, nil, errNotConnected
+	}
+
+	return srv.client, srv.mux, nil
+}
+
+// send transmits the event and waits for its confirmation, if the client supports it.
+func (srv *server) send(ctx context.Context, event knx.GroupEvent) error {
+	client, _, err := srv.connection()
+	if err != nil {
+		return err
+	}
+
+	if confirmer, ok := client.(knx.GroupConfirmer); ok {
+		return confirmer.SendConfirmed(ctx, event)
+	}
+
+	return client.Send(event)
+}
+
+// handler returns the HTTP handler of the API.
+func (srv *server) handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/ga", srv.methods(map[string]func(*http.Request) (interface{}, error){
+		http.MethodGet: srv.list,
+	}))
+
+	mux.HandleFunc("/ga/", srv.methods(map[string]func(*http.Request) (interface{}, error){
+		http.MethodGet: srv.read,
+		http.MethodPut: srv.write,
+	}))
+
+	mux.HandleFunc("/events", srv.events)
+
+	mux.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPI)
+	})
+
+	return mux
+}
+
+// writeJSON sends the value as JSON.
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// An errorBody is the body of a failed request.
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// methods dispatches the request by method and sends the result or error as JSON.
+func (srv *server) methods(
+	handlers map[string]func(*http.Request) (interface{}, error),
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handle, ok := handlers[r.Method]
+		if !ok {
+			writeJSON(w, http.StatusMethodNotAllowed, errorBody{Error: "method not allowed"})
+			return
+		}
+
+		result, err := handle(r)
+		if err != nil {
+			writeJSON(w, statusOf(err), errorBody{Error: err.Error()})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// target resolves the group address, given as address or name, in the path of the request.
+func (srv *server) target(r *http.Request) (cemi.GroupAddr, error) {
+	addr, err := srv.reg.Resolve(strings.TrimPrefix(r.URL.Path, "/ga/"))
+	if err != nil {
+		return 0, withStatus(http.StatusNotFound, err)
+	}
+
+	return addr, nil
+}
+
+// list describes the group addresses of the registry and those which have been seen on the bus.
+// The path and dpt parameters restrict the list to names with the given prefix and to the given
+// datapoint type.
+func (srv *server) list(r *http.Request) (interface{}, error) {
+	path, dptName := r.URL.Query().Get("path"), r.URL.Query().Get("dpt")
+
+	seen := map[cemi.GroupAddr]bool{}
+	var addrs []cemi.GroupAddr
+
+	for _, entry := range srv.reg.All() {
+		seen[entry.Address] = true
+		addrs = append(addrs, entry.Address)
+	}
+
+	for _, addr := range srv.cache.addrs() {
+		if !seen[addr] {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+
+	values := []*groupValue{}
+
+	for _, addr := range addrs {
+		gv := describe(srv.reg, addr)
+		if !strings.HasPrefix(gv.Name, path) || !matchDPT(gv.DPT, dptName) {
+			continue
+		}
+
+		if cached, ok := srv.cache.get(addr); ok {
+			if err := gv.setEvent(cached.event, cached.time); err != nil {
+				util.Log(srv, "Cannot decode %v: %v", gv.Address, err)
+			}
+
+			gv.Cached = true
+		}
+
+		values = append(values, gv)
+	}
+
+	return values, nil
+}
+
+// read returns the value of the group address. Cached values are used unless they are older than
+// the maximum age or the live parameter is set, otherwise the value is read from the bus.
+func (srv *server) read(r *http.Request) (interface{}, error) {
+	addr, err := srv.target(r)
+	if err != nil {
+		return nil, err
+	}
+
+	gv := describe(srv.reg, addr)
+
+	cached, ok := srv.cache.get(addr)
+	fresh := ok && (srv.maxAge <= 0 || time.Since(cached.time) <= srv.maxAge)
+
+	if fresh && r.URL.Query().Get("live") != "true" {
+		gv.Cached = true
+		return gv, gv.setEvent(cached.event, cached.time)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), srv.timeout)
+	defer cancel()
+
+	event, err := srv.readBus(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// The cache is also updated by the multiplexer, but asynchronously.
+	now := time.Now()
+	srv.cache.update(event, now)
+
+	if err := gv.setEvent(event, now); err != nil {
+		return nil, withStatus(http.StatusBadGateway, err)
+	}
+
+	return gv, nil
+}
+
+// readBus sends a read request and waits for the response.
+func (srv *server) readBus(ctx context.Context, addr cemi.GroupAddr) (knx.GroupEvent, error) {
+	_, mux, err := srv.connection()
+	if err != nil {
+		return knx.GroupEvent{}, err
+	}
+
+	// Subscribe before asking, so that the response cannot be missed.
+	events, stop := mux.Subscribe(addr)
+	defer stop()
+
+	if err := srv.send(ctx, knx.GroupEvent{Command: knx.GroupRead, Destination: addr}); err != nil {
+		return knx.GroupEvent{}, err
+	}
+
+	for {
+		select {
+		case event, open := <-events:
+			if !open {
+				return knx.GroupEvent{}, errNotConnected
+			}
+
+			if event.Command == knx.GroupResponse {
+				return event, nil
+			}
+
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return knx.GroupEvent{}, errNoResponse
+			}
+
+			return knx.GroupEvent{}, ctx.Err()
+		}
+	}
+}
+
+// write encodes the value in the body and writes it to the group address.
+func (srv *server) write(r *http.Request) (interface{}, error) {
+	addr, err := srv.target(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var req writeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, withStatus(http.StatusBadRequest, err)
+	}
+
+	gv := describe(srv.reg, addr)
+
+	data, err := req.encode(gv.DPT)
+	if err != nil {
+		return nil, withStatus(http.StatusUnprocessableEntity, err)
+	}
+
+	if req.DPT != "" {
+		gv.DPT = req.DPT
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), srv.timeout)
+	defer cancel()
+
+	event := knx.GroupEvent{Command: knx.GroupWrite, Destination: addr, Data: data}
+	if err := srv.send(ctx, event); err != nil {
+		return nil, err
+	}
+
+	// Our own writes are not echoed by the gateway.
+	now := time.Now()
+	srv.cache.update(event, now)
+
+	if req.Raw != "" {
+		gv.DPT = ""
+	}
+
+	return gv, gv.setEvent(event, now)
+}
+
+// matchDPT checks whether the datapoint type is the given one or belongs to the given main type.
+// An empty filter matches everything.
+func matchDPT(name, filter string) bool {
+	return filter == "" || name == filter || strings.HasPrefix(name, filter+".")
+}
+
+// An eventFilter selects the events of an event stream.
+type eventFilter struct {
+	addrs    map[cemi.GroupAddr]bool
+	path     string
+	dpt      string
+	commands map[string]bool
+}
+
+// parseEventFilter reads the filter from the query parameters ga, path, dpt and command.
+func (srv *server) parseEventFilter(r *http.Request) (*eventFilter, error) {
+	query := r.URL.Query()
+	filter := &eventFilter{path: query.Get("path"), dpt: query.Get("dpt")}
+
+	for _, target := range query["ga"] {
+		addr, err := srv.reg.Resolve(target)
+		if err != nil {
+			return nil, err
+		}
+
+		if filter.addrs == nil {
+			filter.addrs = map[cemi.GroupAddr]bool{}
+		}
+
+		filter.addrs[addr] = true
+	}
+
+	for _, command := range query["command"] {
+		if filter.commands == nil {
+			filter.commands = map[string]bool{}
+		}
+
+		filter.commands[strings.ToLower(command)] = true
+	}
+
+	return filter, nil
+}
+
+// matches checks whether the described event passes the filter.
+func (filter *eventFilter) matches(addr cemi.GroupAddr, gv *groupValue) bool {
+	return (filter.addrs == nil || filter.addrs[addr]) &&
+		strings.HasPrefix(gv.Name, filter.path) &&
+		matchDPT(gv.DPT, filter.dpt) &&
+		(filter.commands == nil || filter.commands[strings.ToLower(gv.Command)])
+}
+
+// events streams the decoded group events as server-sent events. The stream ends when the
+// connection to the KNX network is lost; clients are expected to reconnect.
+func (srv *server) events(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, errorBody{Error: "method not allowed"})
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, errorBody{Error: "streaming is not supported"})
+		return
+	}
+
+	filter, err := srv.parseEventFilter(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: err.Error()})
+		return
+	}
+
+	_, mux, err := srv.connection()
+	if err != nil {
+		writeJSON(w, statusOf(err), errorBody{Error: err.Error()})
+		return
+	}
+
+	events, stop := mux.Subscribe()
+	defer stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, open := <-events:
+			if !open {
+				return
+			}
+
+			gv := describe(srv.reg, event.Destination)
+			if err := gv.setEvent(event, time.Now()); err != nil {
+				util.Log(srv, "Cannot decode %v: %v", gv.Address, err)
+			}
+
+			if !filter.matches(event.Destination, gv) {
+				continue
+			}
+
+			data, err := json.Marshal(gv)
+			if err != nil {
+				return
+			}
+
+			if _, err := fmt.Fprintf(w, "event: group\ndata: %s\n\n", data); err != nil {
+				return
+			}
+
+			flusher.Flush()
+
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
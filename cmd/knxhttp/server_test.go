@@ -0,0 +1,230 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/registry"
+)
+
+func testRegistry() *registry.Registry {
+	reg := registry.New()
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 2, 3), Name: "Temperature", Path: []string{"Kitchen"}, DPT: "9.001"})
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 2, 4), Name: "Light", Path: []string{"Kitchen"}, DPT: "1.001"})
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(2, 0, 1), Name: "Scene", Path: []string{"Hall"}, DPT: "17.001"})
+	return reg
+}
+
+// request performs the request and decodes the JSON response.
+func request(t *testing.T, handler http.Handler, method, target, body string, result interface{}) int {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+
+	if result != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), result); err != nil {
+			t.Fatalf("%s %s: %v: %s", method, target, err, rec.Body.String())
+		}
+	}
+
+	return rec.Code
+}
+
+// answer responds to read requests of the address with the payload.
+func answer(device *knx.GroupTransport, addr cemi.GroupAddr, data []byte) {
+	for event := range device.Inbound() {
+		if event.Command == knx.GroupRead && event.Destination == addr {
+			device.Send(knx.GroupEvent{Command: knx.GroupResponse, Destination: addr, Data: data})
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	bus := knx.NewBus()
+
+	srv := newServer(testRegistry(), 200*time.Millisecond, 0)
+	client := knx.NewGroupTransport(bus.Connect())
+	defer client.Close()
+
+	done := srv.attach(client)
+	handler := srv.handler()
+
+	device := knx.NewGroupTransport(bus.Connect())
+	defer device.Close()
+
+	go answer(device, cemi.NewGroupAddr3(1, 2, 3), []byte{0, 0x0C, 0x1A})
+
+	t.Run("Read", func(t *testing.T) {
+		var gv groupValue
+		if code := request(t, handler, http.MethodGet, "/ga/Kitchen/Temperature", "", &gv); code != http.StatusOK {
+			t.Fatalf("Unexpected status %d", code)
+		}
+
+		if gv.Address != "1/2/3" || gv.Value != 21.0 || gv.Unit != "°C" || gv.Raw != "000c1a" || gv.Cached {
+			t.Errorf("Unexpected value %+v", gv)
+		}
+
+		// The response has been cached.
+		if code := request(t, handler, http.MethodGet, "/ga/1/2/3", "", &gv); code != http.StatusOK || !gv.Cached {
+			t.Errorf("Value is not cached: %d %+v", code, gv)
+		}
+	})
+
+	t.Run("ReadTimeout", func(t *testing.T) {
+		var body errorBody
+		if code := request(t, handler, http.MethodGet, "/ga/1/2/4", "", &body); code != http.StatusGatewayTimeout {
+			t.Errorf("Unexpected status %d: %s", code, body.Error)
+		}
+	})
+
+	t.Run("Write", func(t *testing.T) {
+		observer := knx.NewGroupTransport(bus.Connect())
+		defer observer.Close()
+
+		var gv groupValue
+		if code := request(t, handler, http.MethodPut, "/ga/Kitchen/Light", `{"value": "on"}`, &gv); code != http.StatusOK {
+			t.Fatalf("Unexpected status %d", code)
+		}
+
+		if gv.Value != true || gv.Command != "Write" {
+			t.Errorf("Unexpected value %+v", gv)
+		}
+
+		select {
+		case event := <-observer.Inbound():
+			if event.Destination != cemi.NewGroupAddr3(1, 2, 4) || len(event.Data) != 1 || event.Data[0] != 1 {
+				t.Errorf("Unexpected event %+v", event)
+			}
+
+		case <-time.After(time.Second):
+			t.Error("Value has not been written")
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		cases := []struct {
+			method string
+			target string
+			body   string
+			status int
+		}{
+			{http.MethodGet, "/ga/Nowhere", "", http.StatusNotFound},
+			{http.MethodDelete, "/ga/1/2/3", "", http.StatusMethodNotAllowed},
+			{http.MethodPut, "/ga/1/2/3", "{", http.StatusBadRequest},
+			{http.MethodPut, "/ga/1/2/3", `{}`, http.StatusUnprocessableEntity},
+			{http.MethodPut, "/ga/1/2/3", `{"value": "warm"}`, http.StatusUnprocessableEntity},
+			{http.MethodPut, "/ga/3/3/3", `{"value": 1}`, http.StatusUnprocessableEntity},
+		}
+
+		for _, c := range cases {
+			var body errorBody
+			if code := request(t, handler, c.method, c.target, c.body, &body); code != c.status || body.Error == "" {
+				t.Errorf("%s %s: unexpected status %d: %s", c.method, c.target, code, body.Error)
+			}
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		var values []groupValue
+		request(t, handler, http.MethodGet, "/ga?path=Kitchen", "", &values)
+
+		if len(values) != 2 || values[0].Address != "1/2/3" || !values[0].Cached || values[1].Name != "Kitchen/Light" {
+			t.Errorf("Unexpected list %+v", values)
+		}
+
+		request(t, handler, http.MethodGet, "/ga?dpt=17", "", &values)
+		if len(values) != 1 || values[0].Address != "2/0/1" {
+			t.Errorf("Unexpected list %+v", values)
+		}
+	})
+
+	t.Run("Events", func(t *testing.T) {
+		httpServer := httptest.NewServer(handler)
+		defer httpServer.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, httpServer.URL+"/events?ga=1/2/3&command=write", nil)
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		// Neither of the first two events passes the filter.
+		device.Send(knx.GroupEvent{Command: knx.GroupWrite, Destination: cemi.NewGroupAddr3(1, 2, 4), Data: []byte{1}})
+		device.Send(knx.GroupEvent{Command: knx.GroupResponse, Destination: cemi.NewGroupAddr3(1, 2, 3), Data: []byte{0, 0, 0}})
+		device.Send(knx.GroupEvent{Command: knx.GroupWrite, Destination: cemi.NewGroupAddr3(1, 2, 3), Data: []byte{0, 0x0C, 0x1A}})
+
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			data := strings.TrimPrefix(scanner.Text(), "data: ")
+			if data == scanner.Text() {
+				continue
+			}
+
+			var gv groupValue
+			if err := json.Unmarshal([]byte(data), &gv); err != nil {
+				t.Fatal(err)
+			}
+
+			if gv.Address != "1/2/3" || gv.Command != "Write" || gv.Value != 21.0 {
+				t.Errorf("Unexpected event %+v", gv)
+			}
+
+			return
+		}
+
+		t.Errorf("Stream ended: %v", scanner.Err())
+	})
+
+	client.Close()
+	<-done
+
+	var body errorBody
+	if code := request(t, handler, http.MethodGet, "/ga/1/2/3?live=true", "", &body); code != http.StatusServiceUnavailable {
+		t.Errorf("Unexpected status %d after disconnecting", code)
+	}
+}
+
+// rejectingClient confirms every group communication negatively.
+type rejectingClient struct {
+	inbound chan knx.GroupEvent
+}
+
+func (client *rejectingClient) Send(knx.GroupEvent) error {
+	return nil
+}
+
+func (client *rejectingClient) SendConfirmed(context.Context, knx.GroupEvent) error {
+	return knx.ErrNegativeConfirmation
+}
+
+func (client *rejectingClient) Inbound() <-chan knx.GroupEvent {
+	return client.inbound
+}
+
+func TestServer_negativeConfirmation(t *testing.T) {
+	srv := newServer(testRegistry(), time.Second, 0)
+
+	client := &rejectingClient{inbound: make(chan knx.GroupEvent)}
+	defer close(client.inbound)
+
+	srv.attach(client)
+
+	var body errorBody
+	if code := request(t, srv.handler(), http.MethodPut, "/ga/1/2/4", `{"value": true}`, &body); code != http.StatusBadGateway {
+		t.Errorf("Unexpected status %d: %s", code, body.Error)
+	}
+}
@@ -0,0 +1,202 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/dpt"
+	"github.com/vapourismo/knx-go/knx/registry"
+)
+
+// A groupValue describes a group address and, if known, its value.
+type groupValue struct {
+	Address string      `json:"address"`
+	Name    string      `json:"name,omitempty"`
+	DPT     string      `json:"dpt,omitempty"`
+	Flags   string      `json:"flags,omitempty"`
+	Comment string      `json:"comment,omitempty"`
+	Command string      `json:"command,omitempty"`
+	Source  string      `json:"source,omitempty"`
+	Raw     string      `json:"raw,omitempty"`
+	Value   interface{} `json:"value,omitempty"`
+	Text    string      `json:"text,omitempty"`
+	Unit    string      `json:"unit,omitempty"`
+	Time    *time.Time  `json:"time,omitempty"`
+	Cached  bool        `json:"cached,omitempty"`
+}
+
+// describe fills in the metadata of the group address from the registry.
+func describe(reg *registry.Registry, addr cemi.GroupAddr) *groupValue {
+	gv := &groupValue{Address: reg.Format(addr)}
+
+	if entry, ok := reg.Lookup(addr); ok {
+		gv.Name = entry.FullName()
+		gv.DPT = entry.DPT
+		gv.Flags = entry.Flags.String()
+		gv.Comment = entry.Comment
+	}
+
+	return gv
+}
+
+// setEvent fills in the payload of the event and decodes it, if the datapoint type is known.
+func (gv *groupValue) setEvent(event knx.GroupEvent, at time.Time) error {
+	gv.Command = event.Command.String()
+	gv.Raw = hex.EncodeToString(event.Data)
+	gv.Time = &at
+
+	if event.Source != 0 {
+		gv.Source = event.Source.String()
+	}
+
+	if gv.DPT == "" || event.Command == knx.GroupRead {
+		return nil
+	}
+
+	value, ok := dpt.Produce(gv.DPT)
+	if !ok {
+		return fmt.Errorf("unknown datapoint type %q", gv.DPT)
+	}
+
+	if err := value.Unpack(event.Data); err != nil {
+		return err
+	}
+
+	gv.Text = strings.TrimSpace(value.String())
+	gv.Unit = value.Unit()
+
+	switch mainType := strings.SplitN(gv.DPT, ".", 2)[0]; {
+	case mainType == "1":
+		gv.Value = value.Float() != 0
+
+	case dpt.IsNumeric(gv.DPT):
+		gv.Value = value.Float()
+
+	default:
+		gv.Value = gv.Text
+	}
+
+	return nil
+}
+
+// A writeRequest is the body of a PUT request. Either value or raw must be given.
+type writeRequest struct {
+	Value json.RawMessage `json:"value"`
+	Raw   string          `json:"raw"`
+	DPT   string          `json:"dpt"`
+}
+
+var (
+	errNoValue     = errors.New("either value or raw must be given")
+	errUnknownType = errors.New("datapoint type is unknown, give dpt or raw")
+)
+
+// encode determines the payload of the write request. The datapoint type of the request takes
+// precedence over the given one.
+func (req *writeRequest) encode(dptName string) ([]byte, error) {
+	if (req.Raw == "") == (len(req.Value) == 0) {
+		return nil, errNoValue
+	}
+
+	if req.Raw != "" {
+		data, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(req.Raw), "0x"))
+		if err != nil {
+			return nil, err
+		} else if len(data) == 0 {
+			return nil, errors.New("raw payload is empty")
+		}
+
+		return data, nil
+	}
+
+	if req.DPT != "" {
+		dptName = req.DPT
+	}
+
+	if dptName == "" {
+		return nil, errUnknownType
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(req.Value, &decoded); err != nil {
+		return nil, err
+	}
+
+	var text string
+
+	switch decoded := decoded.(type) {
+	case bool:
+		text = strconv.FormatBool(decoded)
+
+	case float64:
+		text = strconv.FormatFloat(decoded, 'f', -1, 64)
+
+	case string:
+		text = strings.TrimSpace(decoded)
+
+	default:
+		return nil, errors.New("value must be a boolean, number or string")
+	}
+
+	return dpt.Encode(dptName, text)
+}
+
+// A cachedEvent is the last value seen for a group address.
+type cachedEvent struct {
+	event knx.GroupEvent
+	time  time.Time
+}
+
+// A cache remembers the last value of every group address.
+type cache struct {
+	mu     sync.RWMutex
+	events map[cemi.GroupAddr]cachedEvent
+}
+
+// update stores the value carried by the event. Read requests carry no value and are ignored.
+func (c *cache) update(event knx.GroupEvent, at time.Time) {
+	if event.Command == knx.GroupRead {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.events == nil {
+		c.events = make(map[cemi.GroupAddr]cachedEvent)
+	}
+
+	c.events[event.Destination] = cachedEvent{event: event, time: at}
+}
+
+// get returns the last value of the group address.
+func (c *cache) get(addr cemi.GroupAddr) (cachedEvent, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cached, ok := c.events[addr]
+	return cached, ok
+}
+
+// addrs returns the group addresses that have a value.
+func (c *cache) addrs() []cemi.GroupAddr {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	addrs := make([]cemi.GroupAddr, 0, len(c.events))
+	for addr := range c.events {
+		addrs = append(addrs, addr)
+	}
+
+	return addrs
+}
@@ -0,0 +1,202 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Command knxlogger writes the numeric and boolean values of group addresses as InfluxDB line
+// protocol or CSV.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/registry"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+const usage = `Usage: %s [flags]
+
+Values of group addresses with a boolean (1.x) or numeric datapoint type are decoded and written
+as InfluxDB line protocol or as CSV. Datapoint types and the metadata used by the mapping are
+taken from the project.
+
+The output is a file, "-" for stdout, or an http:// or https:// URL to which line protocol is
+posted in batches, e.g. http://localhost:8086/api/v2/write?org=home&bucket=knx.
+
+Measurement, tag values and field are templates. They may contain the placeholders {address},
+{name} (the full name), {group} (the name without path), {path} (the path joined by slashes),
+{path0} to {path2}, {dpt}, {maintype} and {unit}. Tags are given as key=template pairs separated
+by commas. Tags with an empty value are left out of the line protocol.
+
+The address is a gateway (10.0.0.2:3671), a multicast group (224.0.23.12:3671) or a URL as
+accepted by knx.Dial. It defaults to $KNX_ADDRESS.
+
+Flags:
+`
+
+// openSink creates the sink for the output in the given format.
+func openSink(output, format, token string, tags []tagTemplate) (sink, error) {
+	if format != "influx" && format != "csv" {
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+
+	if strings.HasPrefix(output, "http://") || strings.HasPrefix(output, "https://") {
+		if format != "influx" {
+			return nil, fmt.Errorf("only line protocol can be posted to %s", output)
+		}
+
+		return &httpSink{client: &http.Client{Timeout: 10 * time.Second}, url: output, token: token}, nil
+	}
+
+	var w io.Writer = os.Stdout
+
+	if output != "-" {
+		file, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		w = file
+	}
+
+	if format == "csv" {
+		return newCSVSink(w, tags), nil
+	}
+
+	return &lineSink{w: w}, nil
+}
+
+// logger writes the values received through a connection.
+type logger struct {
+	reg     *registry.Registry
+	mapping mapping
+	filter  filter
+	sink    sink
+	flush   time.Duration
+}
+
+// serve logs the group values until the connection is closed.
+func (l *logger) serve(transport knx.Transport) {
+	client := knx.NewGroupTransport(transport)
+
+	ticker := time.NewTicker(l.flush)
+	defer ticker.Stop()
+
+	inbound := client.Inbound()
+
+	for {
+		select {
+		case event, open := <-inbound:
+			if !open {
+				return
+			}
+
+			p, err := l.mapping.point(l.reg, event, time.Now())
+			if err != nil {
+				util.Log(l, "Could not decode value of %v: %v", event.Destination, err)
+				continue
+			}
+
+			if p == nil || !l.filter.pass(event.Destination, p) {
+				continue
+			}
+
+			if err := l.sink.write(p); err != nil {
+				util.Log(l, "Could not write value: %v", err)
+			}
+
+		case <-ticker.C:
+			if err := l.sink.flush(); err != nil {
+				util.Log(l, "Could not flush values: %v", err)
+			}
+		}
+	}
+}
+
+func main() {
+	address := flag.String("address", os.Getenv("KNX_ADDRESS"), "gateway, multicast group or URL")
+	project := flag.String("project", "", "ETS project, group address CSV or XML export")
+	projectPassword := flag.String("project-password", "", "password of the ETS project")
+	style := flag.String("style", "3", "group address style in templates, either 3, 2 or free")
+	output := flag.String("output", "-", "file, - for stdout, or URL of an InfluxDB write endpoint")
+	format := flag.String("format", "influx", "output format, either influx or csv")
+	token := flag.String("token", os.Getenv("INFLUX_TOKEN"), "API token for the InfluxDB endpoint")
+	measurement := flag.String("measurement", "knx", "template of the measurement")
+	tags := flag.String("tags", "address={address},name={name},unit={unit}", "templates of the tags")
+	field := flag.String("field", "value", "template of the field")
+	deadband := flag.Float64("deadband", 0, "change a numeric value must exceed to be written again")
+	minInterval := flag.Duration("min-interval", 0, "minimum time between two values of a group address")
+	maxInterval := flag.Duration("max-interval", 0, "time after which an unchanged value is written again, 0 for never")
+	flush := flag.Duration("flush", time.Second, "interval at which values are posted to the endpoint")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flag.NArg() != 0 || *address == "" || *project == "" || *flush <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Log to stderr, stdout may carry the values.
+	logs := log.New(os.Stderr, "", log.LstdFlags)
+	util.Logger = logs
+
+	reg, err := registry.Load(*project, *projectPassword)
+	if err != nil {
+		logs.Fatal(err)
+	}
+
+	addressStyle, err := registry.ParseAddressStyle(*style)
+	if err != nil {
+		logs.Fatal(err)
+	}
+
+	reg.SetStyle(addressStyle)
+
+	tagTemplates, err := parseTags(*tags)
+	if err != nil {
+		logs.Fatal(err)
+	}
+
+	out, err := openSink(*output, *format, *token, tagTemplates)
+	if err != nil {
+		logs.Fatal(err)
+	}
+
+	l := &logger{
+		reg:     reg,
+		mapping: mapping{measurement: *measurement, tags: tagTemplates, field: *field},
+		filter:  filter{deadband: *deadband, minInterval: *minInterval, maxInterval: *maxInterval},
+		sink:    out,
+		flush:   *flush,
+	}
+
+	// Loop for ever. Failures don't matter, we'll always retry.
+	for {
+		transport, err := knx.Dial(*address)
+		if err != nil {
+			logs.Printf("Error while connecting: %v\n", err)
+
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		logs.Printf("Connected to %s\n", *address)
+
+		l.serve(transport)
+		transport.Close()
+
+		logs.Printf("Connection has been lost\n")
+
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,197 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/dpt"
+	"github.com/vapourismo/knx-go/knx/registry"
+)
+
+// A tag is a key and value describing a point.
+type tag struct {
+	key   string
+	value string
+}
+
+// A point is a value to be logged. Its value is either a float64 or a bool.
+type point struct {
+	measurement string
+	tags        []tag
+	field       string
+	value       interface{}
+	time        time.Time
+}
+
+// A tagTemplate is the key and value template of a tag.
+type tagTemplate struct {
+	key      string
+	template string
+}
+
+// parseTags parses a list of tags such as "room={path1},name={name}".
+func parseTags(text string) ([]tagTemplate, error) {
+	var tags []tagTemplate
+
+	for _, item := range strings.Split(text, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+
+		key, template, ok := strings.Cut(item, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("tag %q must be given as key=template", item)
+		}
+
+		tags = append(tags, tagTemplate{key: key, template: template})
+	}
+
+	return tags, nil
+}
+
+// A mapping determines the measurement, tags and field of a group address from its metadata.
+// Templates may contain the placeholders {address}, {name} (the full name), {group} (the name
+// without path), {path} (the path joined by slashes), {path0} to {path2}, {dpt}, {maintype}
+// and {unit}. Measurement and field fall back to "knx" and "value" if they expand to nothing.
+type mapping struct {
+	measurement string
+	tags        []tagTemplate
+	field       string
+}
+
+// expander replaces the placeholders by the metadata of the group address.
+func expander(reg *registry.Registry, entry *registry.Entry, unit string) *strings.Replacer {
+	pathLevel := func(i int) string {
+		if i < len(entry.Path) {
+			return entry.Path[i]
+		}
+
+		return ""
+	}
+
+	return strings.NewReplacer(
+		"{address}", reg.Format(entry.Address),
+		"{name}", entry.FullName(),
+		"{group}", entry.Name,
+		"{path}", strings.Join(entry.Path, "/"),
+		"{path0}", pathLevel(0),
+		"{path1}", pathLevel(1),
+		"{path2}", pathLevel(2),
+		"{dpt}", entry.DPT,
+		"{maintype}", strings.SplitN(entry.DPT, ".", 2)[0],
+		"{unit}", unit,
+	)
+}
+
+// point turns the event into a point, if the group address has a numeric or boolean datapoint
+// type.
+func (m *mapping) point(reg *registry.Registry, event knx.GroupEvent, now time.Time) (*point, error) {
+	if event.Command == knx.GroupRead {
+		return nil, nil
+	}
+
+	entry, ok := reg.Lookup(event.Destination)
+	if !ok {
+		return nil, nil
+	}
+
+	mainType := strings.SplitN(entry.DPT, ".", 2)[0]
+	if mainType != "1" && !dpt.IsNumeric(entry.DPT) {
+		return nil, nil
+	}
+
+	value, ok := dpt.Produce(entry.DPT)
+	if !ok {
+		return nil, fmt.Errorf("unknown datapoint type %q", entry.DPT)
+	}
+
+	if err := value.Unpack(event.Data); err != nil {
+		return nil, err
+	}
+
+	expand := expander(reg, &entry, value.Unit())
+
+	p := &point{
+		measurement: expand.Replace(m.measurement),
+		field:       expand.Replace(m.field),
+		value:       value.Float(),
+		time:        now,
+	}
+
+	if mainType == "1" {
+		p.value = value.Float() != 0
+	}
+
+	// Line protocol demands a measurement and a field.
+	if p.measurement == "" {
+		p.measurement = "knx"
+	}
+
+	if p.field == "" {
+		p.field = "value"
+	}
+
+	for _, tt := range m.tags {
+		p.tags = append(p.tags, tag{key: tt.key, value: expand.Replace(tt.template)})
+	}
+
+	return p, nil
+}
+
+// A sample is the last value written for a group address.
+type sample struct {
+	value interface{}
+	time  time.Time
+}
+
+// A filter suppresses values which have not changed by more than the deadband and values which
+// arrive sooner than the minimum interval after the previous one. Unchanged values are written
+// again once the maximum interval has passed, if it is positive.
+type filter struct {
+	deadband    float64
+	minInterval time.Duration
+	maxInterval time.Duration
+
+	last map[cemi.GroupAddr]sample
+}
+
+// pass determines whether the point for the group address should be written and remembers it if
+// so.
+func (f *filter) pass(addr cemi.GroupAddr, p *point) bool {
+	if f.last == nil {
+		f.last = map[cemi.GroupAddr]sample{}
+	}
+
+	prev, ok := f.last[addr]
+
+	if ok {
+		elapsed := p.time.Sub(prev.time)
+		if elapsed < f.minInterval {
+			return false
+		}
+
+		if f.maxInterval <= 0 || elapsed < f.maxInterval {
+			switch value := p.value.(type) {
+			case float64:
+				if prevValue, ok := prev.value.(float64); ok && math.Abs(value-prevValue) <= f.deadband {
+					return false
+				}
+
+			case bool:
+				if value == prev.value {
+					return false
+				}
+			}
+		}
+	}
+
+	f.last[addr] = sample{value: p.value, time: p.time}
+
+	return true
+}
@@ -0,0 +1,91 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/registry"
+)
+
+func TestMapping(t *testing.T) {
+	reg := registry.New()
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 2, 3), Name: "Temperature", Path: []string{"Climate", "Kitchen"}, DPT: "9.001"})
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 2, 4), Name: "Light", DPT: "1.001"})
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 2, 5), Name: "Text", DPT: "16.000"})
+
+	tags, err := parseTags("room={path1}, unit={unit},address={address}")
+	require.NoError(t, err)
+
+	m := mapping{measurement: "{path0}", tags: tags, field: "{group}"}
+	now := time.Unix(1700000000, 0)
+
+	t.Run("Numeric", func(t *testing.T) {
+		p, err := m.point(reg, knx.GroupEvent{Command: knx.GroupWrite, Destination: cemi.NewGroupAddr3(1, 2, 3), Data: []byte{0, 0x0C, 0x1A}}, now)
+		require.NoError(t, err)
+		require.NotNil(t, p)
+
+		assert.Equal(t, "Climate", p.measurement)
+		assert.Equal(t, []tag{{"room", "Kitchen"}, {"unit", "°C"}, {"address", "1/2/3"}}, p.tags)
+		assert.Equal(t, "Temperature", p.field)
+		assert.Equal(t, 21.0, p.value)
+		assert.Equal(t, now, p.time)
+	})
+
+	t.Run("Boolean", func(t *testing.T) {
+		p, err := m.point(reg, knx.GroupEvent{Command: knx.GroupResponse, Destination: cemi.NewGroupAddr3(1, 2, 4), Data: []byte{1}}, now)
+		require.NoError(t, err)
+		require.NotNil(t, p)
+
+		assert.Equal(t, true, p.value)
+		assert.Equal(t, "knx", p.measurement)
+	})
+
+	t.Run("Ignored", func(t *testing.T) {
+		for _, event := range []knx.GroupEvent{
+			{Command: knx.GroupRead, Destination: cemi.NewGroupAddr3(1, 2, 3)},
+			{Command: knx.GroupWrite, Destination: cemi.NewGroupAddr3(1, 2, 5), Data: make([]byte, 15)},
+			{Command: knx.GroupWrite, Destination: cemi.NewGroupAddr3(1, 2, 6), Data: []byte{1}},
+		} {
+			p, err := m.point(reg, event, now)
+			assert.NoError(t, err)
+			assert.Nil(t, p)
+		}
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		_, err := m.point(reg, knx.GroupEvent{Command: knx.GroupWrite, Destination: cemi.NewGroupAddr3(1, 2, 3), Data: []byte{0}}, now)
+		assert.Error(t, err)
+	})
+
+	_, err = parseTags("room")
+	assert.Error(t, err)
+}
+
+func TestFilter(t *testing.T) {
+	addr := cemi.NewGroupAddr3(1, 2, 3)
+	start := time.Unix(1700000000, 0)
+
+	at := func(seconds int, value interface{}) *point {
+		return &point{value: value, time: start.Add(time.Duration(seconds) * time.Second)}
+	}
+
+	f := filter{deadband: 0.5, minInterval: 10 * time.Second, maxInterval: time.Minute}
+
+	assert.True(t, f.pass(addr, at(0, 20.0)))
+	assert.False(t, f.pass(addr, at(5, 25.0)), "min interval")
+	assert.False(t, f.pass(addr, at(15, 20.5)), "deadband")
+	assert.True(t, f.pass(addr, at(20, 20.6)))
+	assert.True(t, f.pass(addr, at(80, 20.6)), "max interval")
+
+	other := cemi.NewGroupAddr3(1, 2, 4)
+	assert.True(t, f.pass(other, at(0, false)))
+	assert.False(t, f.pass(other, at(20, false)))
+	assert.True(t, f.pass(other, at(30, true)))
+}
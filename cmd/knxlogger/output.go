@@ -0,0 +1,190 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A sink receives the points to be logged.
+type sink interface {
+	write(p *point) error
+	flush() error
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `, "\n", `\n`)
+	keyEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", `\n`)
+)
+
+// formatValue formats the value of the point.
+func formatValue(value interface{}) string {
+	switch value := value.(type) {
+	case bool:
+		return strconv.FormatBool(value)
+
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// appendLine appends the point in InfluxDB line protocol to the buffer. Tags with empty values are
+// omitted, because InfluxDB rejects them.
+func appendLine(buf *bytes.Buffer, p *point) {
+	buf.WriteString(measurementEscaper.Replace(p.measurement))
+
+	for _, t := range p.tags {
+		if t.key == "" || t.value == "" {
+			continue
+		}
+
+		buf.WriteByte(',')
+		buf.WriteString(keyEscaper.Replace(t.key))
+		buf.WriteByte('=')
+		buf.WriteString(keyEscaper.Replace(t.value))
+	}
+
+	buf.WriteByte(' ')
+	buf.WriteString(keyEscaper.Replace(p.field))
+	buf.WriteByte('=')
+	buf.WriteString(formatValue(p.value))
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.FormatInt(p.time.UnixNano(), 10))
+	buf.WriteByte('\n')
+}
+
+// lineSink writes InfluxDB line protocol to a writer.
+type lineSink struct {
+	w io.Writer
+}
+
+func (s *lineSink) write(p *point) error {
+	var buf bytes.Buffer
+	appendLine(&buf, p)
+
+	_, err := s.w.Write(buf.Bytes())
+	return err
+}
+
+func (s *lineSink) flush() error {
+	return nil
+}
+
+// csvSink writes CSV records to a writer. The header is written before the first record. Its
+// columns are time, measurement, the keys of the tags, field and value.
+type csvSink struct {
+	w      *csv.Writer
+	tags   []tagTemplate
+	header bool
+}
+
+// newCSVSink creates a sink whose columns contain the given tags.
+func newCSVSink(w io.Writer, tags []tagTemplate) *csvSink {
+	return &csvSink{w: csv.NewWriter(w), tags: tags}
+}
+
+func (s *csvSink) write(p *point) error {
+	if !s.header {
+		record := []string{"time", "measurement"}
+		for _, t := range s.tags {
+			record = append(record, t.key)
+		}
+
+		if err := s.w.Write(append(record, "field", "value")); err != nil {
+			return err
+		}
+
+		s.header = true
+	}
+
+	record := []string{p.time.UTC().Format(time.RFC3339Nano), p.measurement}
+	for _, t := range p.tags {
+		record = append(record, t.value)
+	}
+
+	if err := s.w.Write(append(record, p.field, formatValue(p.value))); err != nil {
+		return err
+	}
+
+	// Flush every record so that the file can be followed while logging.
+	s.w.Flush()
+	return s.w.Error()
+}
+
+func (s *csvSink) flush() error {
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// maxPendingLines limits the number of lines kept while the HTTP endpoint is unavailable. The
+// oldest lines are dropped first.
+const maxPendingLines = 10000
+
+// httpSink posts batches of InfluxDB line protocol to an HTTP endpoint, such as the /write
+// endpoint of InfluxDB 1.x or /api/v2/write of InfluxDB 2.x.
+type httpSink struct {
+	client *http.Client
+	url    string
+	token  string
+
+	lines [][]byte
+}
+
+func (s *httpSink) write(p *point) error {
+	var buf bytes.Buffer
+	appendLine(&buf, p)
+
+	s.lines = append(s.lines, buf.Bytes())
+	if len(s.lines) > maxPendingLines {
+		s.lines = s.lines[len(s.lines)-maxPendingLines:]
+	}
+
+	return nil
+}
+
+// flush posts the pending lines. They are kept for the next attempt, if posting fails.
+func (s *httpSink) flush() error {
+	if len(s.lines) == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(bytes.Join(s.lines, nil)))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		// Points the server can't parse will never be accepted, so drop them.
+		if res.StatusCode == http.StatusBadRequest {
+			s.lines = nil
+		}
+
+		return fmt.Errorf("endpoint responded with %s", res.Status)
+	}
+
+	s.lines = nil
+	return nil
+}
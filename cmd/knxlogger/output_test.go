@@ -0,0 +1,95 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testPoints = []*point{
+	{
+		measurement: "Climate room",
+		tags:        []tag{{"name", "Kitchen/Temperature, south"}, {"unit", "°C"}},
+		field:       "value",
+		value:       21.5,
+		time:        time.Unix(1700000000, 0),
+	},
+	{
+		measurement: "knx",
+		tags:        []tag{{"name", "Light"}, {"unit", ""}},
+		field:       "a=b",
+		value:       true,
+		time:        time.Unix(1700000001, 500),
+	},
+}
+
+func TestLineSink(t *testing.T) {
+	var buf bytes.Buffer
+	s := &lineSink{w: &buf}
+
+	for _, p := range testPoints {
+		require.NoError(t, s.write(p))
+	}
+
+	assert.Equal(t,
+		`Climate\ room,name=Kitchen/Temperature\,\ south,unit=°C value=21.5 1700000000000000000`+"\n"+
+			`knx,name=Light a\=b=true 1700000001000000500`+"\n",
+		buf.String())
+}
+
+func TestCSVSink(t *testing.T) {
+	var buf bytes.Buffer
+	s := newCSVSink(&buf, []tagTemplate{{"name", "{name}"}, {"unit", "{unit}"}})
+
+	for _, p := range testPoints {
+		require.NoError(t, s.write(p))
+	}
+
+	assert.Equal(t,
+		"time,measurement,name,unit,field,value\n"+
+			"2023-11-14T22:13:20Z,Climate room,\"Kitchen/Temperature, south\",°C,value,21.5\n"+
+			"2023-11-14T22:13:21.0000005Z,knx,Light,,a=b,true\n",
+		buf.String())
+}
+
+func TestHTTPSink(t *testing.T) {
+	var (
+		bodies []string
+		status = http.StatusServiceUnavailable
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Token secret", r.Header.Get("Authorization"))
+
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	s := &httpSink{client: server.Client(), url: server.URL, token: "secret"}
+
+	require.NoError(t, s.flush())
+	assert.Empty(t, bodies)
+
+	require.NoError(t, s.write(testPoints[0]))
+	assert.Error(t, s.flush())
+
+	status = http.StatusNoContent
+
+	require.NoError(t, s.write(testPoints[1]))
+	assert.NoError(t, s.flush())
+	assert.NoError(t, s.flush())
+
+	require.Len(t, bodies, 2)
+	assert.Equal(t, bodies[0]+`knx,name=Light a\=b=true 1700000001000000500`+"\n", bodies[1])
+}
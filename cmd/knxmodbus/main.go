@@ -0,0 +1,132 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Command knxmodbus serves the values of group addresses as coils and registers of a Modbus TCP
+// server.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/registry"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+const usage = `Usage: %s [flags]
+
+Group addresses are mapped to coils, discrete inputs, holding registers and input registers by a
+JSON file:
+
+	[
+		{"address": "1/2/3", "register": 0},
+		{"address": "Kitchen/Temperature", "table": "input", "register": 10, "scale": 10},
+		{"address": "1/2/4", "dpt": "1.001", "register": 0}
+	]
+
+The datapoint type is taken from the project unless given. It determines the default table and
+encoding: DPT 1 is a coil, DPTs 5, 7, 17 and 20 are uint16, DPTs 6 and 8 are int16, DPT 12 is
+uint32, DPT 13 is int32, DPTs 9 and 14 are IEEE floats in two registers. Given a scale, DPT 9 is
+an int16 holding the value multiplied by the scale. The encoding may be set explicitly to bit,
+uint16, int16, uint32, int32 or float32. 32-bit values store the high word first.
+
+Reads are served from the last values seen on the bus. Writes to coils and holding registers are
+sent as group writes.
+
+The address is a gateway (10.0.0.2:3671), a multicast group (224.0.23.12:3671) or a URL as
+accepted by knx.Dial. It defaults to $KNX_ADDRESS.
+
+Flags:
+`
+
+// serve keeps the registers up to date until the client has closed its inbound channel. If read
+// is set, the values of all group addresses are requested first.
+func (m *registerMap) serve(client knx.GroupClient, read bool) {
+	m.setSender(client.Send)
+	defer m.setSender(nil)
+
+	if read {
+		for _, addr := range m.addrs() {
+			if err := client.Send(knx.GroupEvent{Command: knx.GroupRead, Destination: addr}); err != nil {
+				util.Log(m, "Could not request value of %v: %v", addr, err)
+			}
+		}
+	}
+
+	for event := range client.Inbound() {
+		if err := m.update(event); err != nil {
+			util.Log(m, "Could not decode value of %v: %v", event.Destination, err)
+		}
+	}
+}
+
+func main() {
+	address := flag.String("address", os.Getenv("KNX_ADDRESS"), "gateway, multicast group or URL")
+	listen := flag.String("listen", ":502", "address on which to serve Modbus TCP")
+	project := flag.String("project", "", "ETS project, group address CSV or XML export")
+	projectPassword := flag.String("project-password", "", "password of the ETS project")
+	config := flag.String("config", "", "JSON file mapping group addresses to registers")
+	read := flag.Bool("read", true, "request the values of the group addresses after connecting")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flag.NArg() != 0 || *address == "" || *config == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+	util.Logger = logger
+
+	reg := registry.New()
+
+	if *project != "" {
+		var err error
+		if reg, err = registry.Load(*project, *projectPassword); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	regs, err := loadRegisterMap(*config, reg)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	go func() {
+		logger.Fatal(serveModbus(listener, regs))
+	}()
+
+	// Loop for ever. Failures don't matter, we'll always retry. Modbus writes fail meanwhile.
+	for {
+		client, err := knx.DialGroup(*address)
+		if err != nil {
+			logger.Printf("Error while connecting: %v\n", err)
+
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		logger.Printf("Connected to %s\n", *address)
+
+		regs.serve(client, *read)
+		client.Close()
+
+		logger.Printf("Connection has been lost\n")
+
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,336 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// Modbus function codes
+const (
+	modbusReadCoils              = 1
+	modbusReadDiscreteInputs     = 2
+	modbusReadHoldingRegisters   = 3
+	modbusReadInputRegisters     = 4
+	modbusWriteSingleCoil        = 5
+	modbusWriteSingleRegister    = 6
+	modbusWriteMultipleCoils     = 15
+	modbusWriteMultipleRegisters = 16
+)
+
+// A modbusException is a Modbus exception code returned to the client instead of a response.
+type modbusException byte
+
+// Modbus exception codes
+const (
+	modbusIllegalFunction modbusException = 1
+	modbusIllegalAddress  modbusException = 2
+	modbusIllegalValue    modbusException = 3
+	modbusDeviceFailure   modbusException = 4
+)
+
+// Limits of the Modbus TCP protocol
+const (
+	modbusMaxReadBits       = 2000
+	modbusMaxReadRegisters  = 125
+	modbusMaxWriteBits      = 1968
+	modbusMaxWriteRegisters = 123
+	modbusMaxADU            = 260
+	modbusHeaderLength      = 7
+)
+
+func (e modbusException) Error() string {
+	switch e {
+	case modbusIllegalFunction:
+		return "illegal function"
+	case modbusIllegalAddress:
+		return "illegal data address"
+	case modbusIllegalValue:
+		return "illegal data value"
+	default:
+		return "server device failure"
+	}
+}
+
+// A modbusTable is one of the four Modbus data tables.
+type modbusTable byte
+
+// Modbus data tables
+const (
+	tableCoils modbusTable = iota
+	tableDiscreteInputs
+	tableHoldingRegisters
+	tableInputRegisters
+)
+
+// A modbusHandler serves the requests of Modbus clients. Errors which are not a modbusException
+// are reported as server device failure.
+type modbusHandler interface {
+	readBits(table modbusTable, addr, count uint16) ([]bool, error)
+	readRegisters(table modbusTable, addr, count uint16) ([]uint16, error)
+	writeBits(addr uint16, values []bool) error
+	writeRegisters(addr uint16, values []uint16) error
+}
+
+// maxAcceptDelay limits the back-off after temporary Accept errors.
+const maxAcceptDelay = time.Second
+
+// serveModbus accepts Modbus TCP connections until the listener is closed. The unit identifier
+// of requests is ignored. Like net/http, it backs off and retries when accepting fails temporarily,
+// e.g. because the process has run out of file descriptors.
+func serveModbus(listener net.Listener, handler modbusHandler) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	var delay time.Duration
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				if delay == 0 {
+					delay = 5 * time.Millisecond
+				} else {
+					delay *= 2
+				}
+
+				if delay > maxAcceptDelay {
+					delay = maxAcceptDelay
+				}
+
+				util.Log(listener, "Accepting failed, retrying in %v: %v", delay, err)
+				time.Sleep(delay)
+
+				continue
+			}
+
+			return err
+		}
+
+		delay = 0
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+
+			serveModbusConn(conn, handler)
+		}()
+	}
+}
+
+// serveModbusConn processes the requests of a connection one after another.
+func serveModbusConn(conn net.Conn, handler modbusHandler) {
+	r := bufio.NewReader(conn)
+	header := make([]byte, modbusHeaderLength)
+
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return
+		}
+
+		protocol := binary.BigEndian.Uint16(header[2:])
+		length := int(binary.BigEndian.Uint16(header[4:]))
+
+		// The length counts the unit identifier, which is part of the header, and the PDU, which
+		// consists of at least a function code.
+		if protocol != 0 || length < 2 || modbusHeaderLength-1+length > modbusMaxADU {
+			return
+		}
+
+		pdu := make([]byte, length-1)
+		if _, err := io.ReadFull(r, pdu); err != nil {
+			return
+		}
+
+		res := processModbus(handler, pdu)
+
+		frame := make([]byte, modbusHeaderLength, modbusHeaderLength+len(res))
+		copy(frame, header[:4])
+		binary.BigEndian.PutUint16(frame[4:], uint16(len(res)+1))
+		frame[6] = header[6]
+
+		if _, err := conn.Write(append(frame, res...)); err != nil {
+			return
+		}
+	}
+}
+
+// processModbus handles the request PDU and returns the response PDU.
+func processModbus(handler modbusHandler, pdu []byte) []byte {
+	function := pdu[0]
+
+	res, err := dispatchModbus(handler, function, pdu[1:])
+	if err != nil {
+		var exception modbusException
+		if !errors.As(err, &exception) {
+			exception = modbusDeviceFailure
+		}
+
+		return []byte{function | 0x80, byte(exception)}
+	}
+
+	return append([]byte{function}, res...)
+}
+
+// dispatchModbus handles the data of the request PDU with the given function code.
+func dispatchModbus(handler modbusHandler, function byte, data []byte) ([]byte, error) {
+	switch function {
+	case modbusReadCoils, modbusReadDiscreteInputs:
+		if len(data) != 4 {
+			return nil, modbusIllegalValue
+		}
+
+		addr, count := binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		if count == 0 || count > modbusMaxReadBits {
+			return nil, modbusIllegalValue
+		}
+
+		table := tableCoils
+		if function == modbusReadDiscreteInputs {
+			table = tableDiscreteInputs
+		}
+
+		bits, err := handler.readBits(table, addr, count)
+		if err != nil {
+			return nil, err
+		}
+
+		packed := packBits(bits)
+		return append([]byte{byte(len(packed))}, packed...), nil
+
+	case modbusReadHoldingRegisters, modbusReadInputRegisters:
+		if len(data) != 4 {
+			return nil, modbusIllegalValue
+		}
+
+		addr, count := binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		if count == 0 || count > modbusMaxReadRegisters {
+			return nil, modbusIllegalValue
+		}
+
+		table := tableHoldingRegisters
+		if function == modbusReadInputRegisters {
+			table = tableInputRegisters
+		}
+
+		registers, err := handler.readRegisters(table, addr, count)
+		if err != nil {
+			return nil, err
+		}
+
+		res := make([]byte, 1+2*len(registers))
+		res[0] = byte(2 * len(registers))
+
+		for i, register := range registers {
+			binary.BigEndian.PutUint16(res[1+2*i:], register)
+		}
+
+		return res, nil
+
+	case modbusWriteSingleCoil:
+		if len(data) != 4 {
+			return nil, modbusIllegalValue
+		}
+
+		var value bool
+
+		switch binary.BigEndian.Uint16(data[2:]) {
+		case 0xFF00:
+			value = true
+		case 0x0000:
+			value = false
+		default:
+			return nil, modbusIllegalValue
+		}
+
+		if err := handler.writeBits(binary.BigEndian.Uint16(data), []bool{value}); err != nil {
+			return nil, err
+		}
+
+		return data, nil
+
+	case modbusWriteSingleRegister:
+		if len(data) != 4 {
+			return nil, modbusIllegalValue
+		}
+
+		if err := handler.writeRegisters(binary.BigEndian.Uint16(data), []uint16{binary.BigEndian.Uint16(data[2:])}); err != nil {
+			return nil, err
+		}
+
+		return data, nil
+
+	case modbusWriteMultipleCoils:
+		if len(data) < 5 {
+			return nil, modbusIllegalValue
+		}
+
+		addr, count := binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		if count == 0 || count > modbusMaxWriteBits || int(data[4]) != (int(count)+7)/8 || len(data) != 5+int(data[4]) {
+			return nil, modbusIllegalValue
+		}
+
+		if err := handler.writeBits(addr, unpackBits(data[5:], int(count))); err != nil {
+			return nil, err
+		}
+
+		return data[:4], nil
+
+	case modbusWriteMultipleRegisters:
+		if len(data) < 5 {
+			return nil, modbusIllegalValue
+		}
+
+		addr, count := binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		if count == 0 || count > modbusMaxWriteRegisters || int(data[4]) != 2*int(count) || len(data) != 5+int(data[4]) {
+			return nil, modbusIllegalValue
+		}
+
+		registers := make([]uint16, count)
+		for i := range registers {
+			registers[i] = binary.BigEndian.Uint16(data[5+2*i:])
+		}
+
+		if err := handler.writeRegisters(addr, registers); err != nil {
+			return nil, err
+		}
+
+		return data[:4], nil
+
+	default:
+		return nil, modbusIllegalFunction
+	}
+}
+
+// packBits packs the bits into bytes, least significant bit first.
+func packBits(bits []bool) []byte {
+	packed := make([]byte, (len(bits)+7)/8)
+
+	for i, bit := range bits {
+		if bit {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+
+	return packed
+}
+
+// unpackBits extracts the given number of bits from the bytes, least significant bit first.
+func unpackBits(packed []byte, count int) []bool {
+	bits := make([]bool, count)
+
+	for i := range bits {
+		bits[i] = packed[i/8]&(1<<(i%8)) != 0
+	}
+
+	return bits
+}
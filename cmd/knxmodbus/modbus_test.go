@@ -0,0 +1,256 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/registry"
+)
+
+// A testClient is a minimal Modbus TCP client.
+type testClient struct {
+	conn net.Conn
+	id   uint16
+}
+
+// call sends the request PDU and returns the response PDU. Exceptions are returned as error.
+func (c *testClient) call(function byte, data ...byte) ([]byte, error) {
+	c.id++
+
+	frame := make([]byte, modbusHeaderLength, modbusHeaderLength+1+len(data))
+	binary.BigEndian.PutUint16(frame, c.id)
+	binary.BigEndian.PutUint16(frame[4:], uint16(len(data)+2))
+	frame[6] = 1
+
+	if _, err := c.conn.Write(append(append(frame, function), data...)); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, modbusHeaderLength)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return nil, err
+	}
+
+	if binary.BigEndian.Uint16(header) != c.id || header[6] != 1 {
+		return nil, errors.New("response does not match request")
+	}
+
+	pdu := make([]byte, binary.BigEndian.Uint16(header[4:])-1)
+	if _, err := io.ReadFull(c.conn, pdu); err != nil {
+		return nil, err
+	}
+
+	if pdu[0] == function|0x80 {
+		return nil, modbusException(pdu[1])
+	}
+
+	return pdu[1:], nil
+}
+
+// words encodes the values as big-endian 16-bit words.
+func words(values ...uint16) []byte {
+	data := make([]byte, 2*len(values))
+	for i, value := range values {
+		binary.BigEndian.PutUint16(data[2*i:], value)
+	}
+
+	return data
+}
+
+func TestServer(t *testing.T) {
+	reg := registry.New()
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 2, 3), Name: "Temperature", DPT: "9.001"})
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 2, 4), Name: "Light", DPT: "1.001"})
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 2, 5), Name: "Brightness", DPT: "5.001"})
+
+	var points []*point
+	for _, config := range []pointConfig{
+		{Address: "Temperature", Register: 0},
+		{Address: "Temperature", Table: "input", Register: 0, Scale: 10},
+		{Address: "Light", Register: 0},
+		{Address: "Light", Table: "discrete", Register: 3},
+		{Address: "Brightness", Register: 2},
+	} {
+		p, err := config.resolve(reg)
+		require.NoError(t, err)
+
+		points = append(points, p)
+	}
+
+	regs, err := newRegisterMap(points)
+	require.NoError(t, err)
+
+	bus := knx.NewBus()
+	device := knx.NewGroupTransport(bus.Connect())
+	defer device.Close()
+
+	client := knx.NewGroupTransport(bus.Connect())
+	served := make(chan struct{})
+
+	go func() {
+		defer close(served)
+		regs.serve(client, true)
+	}()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go serveModbus(listener, regs)
+	defer listener.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	mb := &testClient{conn: conn}
+
+	// The server asks for the values after connecting.
+	requested := map[cemi.GroupAddr]bool{}
+	for len(requested) < 3 {
+		event := <-device.Inbound()
+		assert.Equal(t, knx.GroupRead, event.Command)
+		requested[event.Destination] = true
+	}
+
+	receive := func(t *testing.T) knx.GroupEvent {
+		select {
+		case event := <-device.Inbound():
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("No group event received")
+			return knx.GroupEvent{}
+		}
+	}
+
+	t.Run("Read", func(t *testing.T) {
+		require.NoError(t, device.Send(knx.GroupEvent{Command: knx.GroupResponse, Destination: cemi.NewGroupAddr3(1, 2, 3), Data: []byte{0, 0x0C, 0x33}}))
+		require.NoError(t, device.Send(knx.GroupEvent{Command: knx.GroupWrite, Destination: cemi.NewGroupAddr3(1, 2, 4), Data: []byte{1}}))
+
+		// Wait for the bus to deliver both values.
+		require.Eventually(t, func() bool {
+			bits, _ := regs.readBits(tableCoils, 0, 1)
+			registers, _ := regs.readRegisters(tableInputRegisters, 0, 1)
+			return bits[0] && registers[0] != 0
+		}, 5*time.Second, 10*time.Millisecond)
+
+		res, err := mb.call(modbusReadHoldingRegisters, words(0, 2)...)
+		require.NoError(t, err)
+		assert.Equal(t, append([]byte{4}, words(0x41AC, 0x0000)...), res)
+
+		res, err = mb.call(modbusReadInputRegisters, words(0, 1)...)
+		require.NoError(t, err)
+		assert.Equal(t, append([]byte{2}, words(215)...), res)
+
+		res, err = mb.call(modbusReadCoils, words(0, 1)...)
+		require.NoError(t, err)
+		assert.Equal(t, []byte{1, 1}, res)
+
+		// Unmapped inputs within the range read as zero.
+		res, err = mb.call(modbusReadDiscreteInputs, words(0, 4)...)
+		require.NoError(t, err)
+		assert.Equal(t, []byte{1, 0x08}, res)
+	})
+
+	t.Run("Write", func(t *testing.T) {
+		res, err := mb.call(modbusWriteSingleCoil, words(0, 0x0000)...)
+		require.NoError(t, err)
+		assert.Equal(t, words(0, 0x0000), res)
+
+		event := receive(t)
+		assert.Equal(t, knx.GroupEvent{Command: knx.GroupWrite, Destination: cemi.NewGroupAddr3(1, 2, 4), Data: []byte{0}}, event)
+
+		res, err = mb.call(modbusWriteMultipleRegisters, append(words(0, 3), append([]byte{6}, words(0x41B0, 0x0000, 50)...)...)...)
+		require.NoError(t, err)
+		assert.Equal(t, words(0, 3), res)
+
+		event = receive(t)
+		assert.Equal(t, cemi.NewGroupAddr3(1, 2, 3), event.Destination)
+		assert.Equal(t, []byte{0, 0x0C, 0x4C}, event.Data)
+
+		event = receive(t)
+		assert.Equal(t, cemi.NewGroupAddr3(1, 2, 5), event.Destination)
+		assert.Equal(t, []byte{0, 0x80}, event.Data)
+
+		// Own writes are reflected by reads.
+		res, err = mb.call(modbusReadHoldingRegisters, words(2, 1)...)
+		require.NoError(t, err)
+		assert.Equal(t, append([]byte{2}, words(50)...), res)
+	})
+
+	t.Run("Exceptions", func(t *testing.T) {
+		for _, tt := range []struct {
+			function byte
+			data     []byte
+			err      modbusException
+		}{
+			{modbusReadHoldingRegisters, words(100, 2), modbusIllegalAddress},
+			{modbusReadHoldingRegisters, words(0, 200), modbusIllegalValue},
+			{modbusWriteSingleRegister, words(1, 5), modbusIllegalAddress},
+			{modbusWriteSingleRegister, words(0, 5), modbusIllegalAddress},
+			{modbusWriteSingleRegister, words(3, 5), modbusIllegalAddress},
+			{modbusWriteSingleCoil, words(0, 1), modbusIllegalValue},
+			{modbusWriteSingleCoil, words(1, 0xFF00), modbusIllegalAddress},
+			{0x2B, nil, modbusIllegalFunction},
+		} {
+			_, err := mb.call(tt.function, tt.data...)
+			assert.Equal(t, tt.err, err, "function %d with %x", tt.function, tt.data)
+		}
+	})
+
+	client.Close()
+	<-served
+
+	t.Run("Disconnected", func(t *testing.T) {
+		_, err := mb.call(modbusWriteSingleCoil, words(0, 0xFF00)...)
+		assert.Equal(t, modbusDeviceFailure, err)
+
+		// Values are still served.
+		res, err := mb.call(modbusReadHoldingRegisters, words(2, 1)...)
+		require.NoError(t, err)
+		assert.Equal(t, append([]byte{2}, words(50)...), res)
+	})
+}
+
+// temporaryError is a net.Error which is temporary.
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "temporary failure" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+// failingListener fails to accept, temporarily for the first few attempts.
+type failingListener struct {
+	net.Listener
+	temporary int
+	attempts  int
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	l.attempts++
+
+	if l.attempts <= l.temporary {
+		return nil, temporaryError{}
+	}
+
+	return nil, net.ErrClosed
+}
+
+func TestServeModbus_acceptErrors(t *testing.T) {
+	listener := &failingListener{temporary: 3}
+
+	err := serveModbus(listener, nil)
+	assert.ErrorIs(t, err, net.ErrClosed)
+	assert.Equal(t, 4, listener.attempts)
+}
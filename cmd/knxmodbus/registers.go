@@ -0,0 +1,454 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/dpt"
+	"github.com/vapourismo/knx-go/knx/registry"
+)
+
+// A pointConfig maps a group address to a coil, discrete input, holding register or input
+// register. The address is given as group address or full name. Datapoint type, table and
+// encoding default to what the project and the datapoint type suggest.
+type pointConfig struct {
+	Address  string  `json:"address"`
+	DPT      string  `json:"dpt,omitempty"`
+	Table    string  `json:"table,omitempty"`
+	Register uint16  `json:"register"`
+	Encoding string  `json:"encoding,omitempty"`
+	Scale    float64 `json:"scale,omitempty"`
+}
+
+// tableNames maps the names used in the configuration to the Modbus tables.
+var tableNames = map[string]modbusTable{
+	"coil":     tableCoils,
+	"discrete": tableDiscreteInputs,
+	"holding":  tableHoldingRegisters,
+	"input":    tableInputRegisters,
+}
+
+// encodingWidths maps the register encodings to the number of registers they occupy. 32-bit
+// values are stored with the high word in the first register.
+var encodingWidths = map[string]uint16{
+	"bit":     1,
+	"uint16":  1,
+	"int16":   1,
+	"uint32":  2,
+	"int32":   2,
+	"float32": 2,
+}
+
+// defaultEncodings maps the main types of the datapoint types to the encoding which holds their
+// values without loss. 16-bit floats of DPT 9 are stored as int16 if a scale is given.
+var defaultEncodings = map[string]string{
+	"1":  "bit",
+	"5":  "uint16",
+	"6":  "int16",
+	"7":  "uint16",
+	"8":  "int16",
+	"9":  "float32",
+	"12": "uint32",
+	"13": "int32",
+	"14": "float32",
+	"17": "uint16",
+	"20": "uint16",
+}
+
+// A point is a group address mapped to the Modbus tables.
+type point struct {
+	addr     cemi.GroupAddr
+	dpt      string
+	table    modbusTable
+	register uint16
+	encoding string
+	scale    float64
+
+	// value is the last value of the group address. It is zero until the first one arrives.
+	value float64
+}
+
+// width returns the number of registers or bits the point occupies.
+func (p *point) width() uint16 {
+	return encodingWidths[p.encoding]
+}
+
+// resolve checks the configuration and derives the defaults from the datapoint type.
+func (config *pointConfig) resolve(reg *registry.Registry) (*point, error) {
+	addr, err := reg.Resolve(config.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &point{addr: addr, dpt: config.DPT, register: config.Register, encoding: config.Encoding, scale: config.Scale}
+
+	if p.dpt == "" {
+		entry, _ := reg.Lookup(addr)
+		p.dpt = entry.DPT
+	}
+
+	if p.dpt == "" {
+		return nil, fmt.Errorf("%s: datapoint type is unknown", config.Address)
+	}
+
+	if _, ok := dpt.Produce(p.dpt); !ok {
+		return nil, fmt.Errorf("%s: unknown datapoint type %q", config.Address, p.dpt)
+	}
+
+	mainType := strings.SplitN(p.dpt, ".", 2)[0]
+
+	if p.encoding == "" {
+		if p.encoding = defaultEncodings[mainType]; p.encoding == "" {
+			return nil, fmt.Errorf("%s: datapoint type %s can't be mapped to registers", config.Address, p.dpt)
+		}
+
+		if mainType == "9" && p.scale != 0 {
+			p.encoding = "int16"
+		}
+	}
+
+	if p.scale == 0 {
+		p.scale = 1
+	}
+
+	width, ok := encodingWidths[p.encoding]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown encoding %q", config.Address, p.encoding)
+	}
+
+	if (p.encoding == "bit") != (mainType == "1") {
+		return nil, fmt.Errorf("%s: encoding %s does not fit datapoint type %s", config.Address, p.encoding, p.dpt)
+	}
+
+	if config.Table == "" {
+		config.Table = "holding"
+		if p.encoding == "bit" {
+			config.Table = "coil"
+		}
+	}
+
+	if p.table, ok = tableNames[config.Table]; !ok {
+		return nil, fmt.Errorf("%s: unknown table %q", config.Address, config.Table)
+	}
+
+	if isBitTable := p.table == tableCoils || p.table == tableDiscreteInputs; isBitTable != (p.encoding == "bit") {
+		return nil, fmt.Errorf("%s: table %s does not fit datapoint type %s", config.Address, config.Table, p.dpt)
+	}
+
+	if int(p.register)+int(width) > 1<<16 {
+		return nil, fmt.Errorf("%s: register %d is out of range", config.Address, p.register)
+	}
+
+	return p, nil
+}
+
+// A cell is a bit or register belonging to a point.
+type cell struct {
+	point *point
+
+	// word is the index of the register within the point.
+	word uint16
+}
+
+// A registerMap serves the Modbus tables from the last known values of the group addresses and
+// turns Modbus writes into group writes.
+type registerMap struct {
+	points map[cemi.GroupAddr][]*point
+	cells  [4]map[uint16]cell
+
+	mu   sync.Mutex
+	send func(knx.GroupEvent) error
+}
+
+var errNotConnected = errors.New("not connected to the KNX network")
+
+// newRegisterMap creates a map for the points. Points must not overlap.
+func newRegisterMap(points []*point) (*registerMap, error) {
+	m := &registerMap{points: map[cemi.GroupAddr][]*point{}}
+
+	for i := range m.cells {
+		m.cells[i] = map[uint16]cell{}
+	}
+
+	for _, p := range points {
+		for word := uint16(0); word < p.width(); word++ {
+			if other, ok := m.cells[p.table][p.register+word]; ok {
+				return nil, fmt.Errorf(
+					"%v and %v overlap at register %d",
+					other.point.addr, p.addr, p.register+word,
+				)
+			}
+
+			m.cells[p.table][p.register+word] = cell{point: p, word: word}
+		}
+
+		m.points[p.addr] = append(m.points[p.addr], p)
+	}
+
+	return m, nil
+}
+
+// loadRegisterMap reads the points from a JSON file.
+func loadRegisterMap(name string, reg *registry.Registry) (*registerMap, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []pointConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, err
+	}
+
+	points := make([]*point, 0, len(configs))
+
+	for i := range configs {
+		p, err := configs[i].resolve(reg)
+		if err != nil {
+			return nil, err
+		}
+
+		points = append(points, p)
+	}
+
+	return newRegisterMap(points)
+}
+
+// addrs returns the mapped group addresses.
+func (m *registerMap) addrs() []cemi.GroupAddr {
+	addrs := make([]cemi.GroupAddr, 0, len(m.points))
+	for addr := range m.points {
+		addrs = append(addrs, addr)
+	}
+
+	return addrs
+}
+
+// setSender sets the function through which group writes are sent. A nil function means that
+// writes fail because there is no connection.
+func (m *registerMap) setSender(send func(knx.GroupEvent) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.send = send
+}
+
+// update remembers the value carried by the event.
+func (m *registerMap) update(event knx.GroupEvent) error {
+	if event.Command == knx.GroupRead {
+		return nil
+	}
+
+	points, ok := m.points[event.Destination]
+	if !ok {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, p := range points {
+		value, _ := dpt.Produce(p.dpt)
+		if err := value.Unpack(event.Data); err != nil {
+			return err
+		}
+
+		p.value = value.Float()
+	}
+
+	return nil
+}
+
+// lookup finds the cells of the given range. At least one of them must belong to a point.
+func (m *registerMap) lookup(table modbusTable, addr, count uint16) ([]cell, error) {
+	if int(addr)+int(count) > 1<<16 {
+		return nil, modbusIllegalAddress
+	}
+
+	cells := make([]cell, count)
+	mapped := false
+
+	for i := range cells {
+		var ok bool
+		cells[i], ok = m.cells[table][addr+uint16(i)]
+		mapped = mapped || ok
+	}
+
+	if !mapped {
+		return nil, modbusIllegalAddress
+	}
+
+	return cells, nil
+}
+
+func (m *registerMap) readBits(table modbusTable, addr, count uint16) ([]bool, error) {
+	cells, err := m.lookup(table, addr, count)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bits := make([]bool, count)
+	for i, c := range cells {
+		bits[i] = c.point != nil && c.point.value != 0
+	}
+
+	return bits, nil
+}
+
+func (m *registerMap) readRegisters(table modbusTable, addr, count uint16) ([]uint16, error) {
+	cells, err := m.lookup(table, addr, count)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	registers := make([]uint16, count)
+	for i, c := range cells {
+		if c.point != nil {
+			registers[i] = encodeRegisters(c.point.encoding, c.point.value*c.point.scale)[c.word]
+		}
+	}
+
+	return registers, nil
+}
+
+func (m *registerMap) writeBits(addr uint16, values []bool) error {
+	cells, err := m.lookup(tableCoils, addr, uint16(len(values)))
+	if err != nil {
+		return err
+	}
+
+	for _, c := range cells {
+		if c.point == nil {
+			return modbusIllegalAddress
+		}
+	}
+
+	for i, c := range cells {
+		value := 0.0
+		if values[i] {
+			value = 1
+		}
+
+		if err := m.write(c.point, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *registerMap) writeRegisters(addr uint16, values []uint16) error {
+	cells, err := m.lookup(tableHoldingRegisters, addr, uint16(len(values)))
+	if err != nil {
+		return err
+	}
+
+	// Every point must be written as a whole.
+	for i, c := range cells {
+		if c.point == nil || (i == 0 && c.word != 0) || (c.word == 0 && i+int(c.point.width()) > len(cells)) {
+			return modbusIllegalAddress
+		}
+	}
+
+	for i, c := range cells {
+		if c.word == 0 {
+			value := decodeRegisters(c.point.encoding, values[i:i+int(c.point.width())]) / c.point.scale
+
+			if err := m.write(c.point, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// write sends the value to the group address of the point.
+func (m *registerMap) write(p *point, value float64) error {
+	datapoint, _ := dpt.Produce(p.dpt)
+
+	data, err := datapoint.ToByteArray(strconv.FormatFloat(value, 'f', -1, 64))
+	if err != nil {
+		return modbusIllegalValue
+	}
+
+	m.mu.Lock()
+	send := m.send
+	m.mu.Unlock()
+
+	if send == nil {
+		return errNotConnected
+	}
+
+	event := knx.GroupEvent{Command: knx.GroupWrite, Destination: p.addr, Data: data}
+	if err := send(event); err != nil {
+		return err
+	}
+
+	// Our own writes are not received, so they are remembered here.
+	return m.update(event)
+}
+
+// encodeRegisters encodes the value in the given encoding. Values outside the range of integer
+// encodings are clamped.
+func encodeRegisters(encoding string, value float64) []uint16 {
+	clamp := func(min, max float64) float64 {
+		return math.Max(min, math.Min(max, math.Round(value)))
+	}
+
+	switch encoding {
+	case "int16":
+		return []uint16{uint16(int16(clamp(math.MinInt16, math.MaxInt16)))}
+
+	case "uint32":
+		raw := uint32(clamp(0, math.MaxUint32))
+		return []uint16{uint16(raw >> 16), uint16(raw)}
+
+	case "int32":
+		raw := uint32(int32(clamp(math.MinInt32, math.MaxInt32)))
+		return []uint16{uint16(raw >> 16), uint16(raw)}
+
+	case "float32":
+		raw := math.Float32bits(float32(value))
+		return []uint16{uint16(raw >> 16), uint16(raw)}
+
+	default:
+		return []uint16{uint16(clamp(0, math.MaxUint16))}
+	}
+}
+
+// decodeRegisters decodes the registers in the given encoding.
+func decodeRegisters(encoding string, registers []uint16) float64 {
+	switch encoding {
+	case "int16":
+		return float64(int16(registers[0]))
+
+	case "uint32":
+		return float64(uint32(registers[0])<<16 | uint32(registers[1]))
+
+	case "int32":
+		return float64(int32(uint32(registers[0])<<16 | uint32(registers[1])))
+
+	case "float32":
+		return float64(math.Float32frombits(uint32(registers[0])<<16 | uint32(registers[1])))
+
+	default:
+		return float64(registers[0])
+	}
+}
@@ -0,0 +1,91 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/registry"
+)
+
+func TestPointConfig(t *testing.T) {
+	reg := registry.New()
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 2, 3), Name: "Temperature", Path: []string{"Kitchen"}, DPT: "9.001"})
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 2, 4), Name: "Light", DPT: "1.001"})
+	reg.Add(registry.Entry{Address: cemi.NewGroupAddr3(1, 2, 5), Name: "Text", DPT: "16.000"})
+
+	for _, tt := range []struct {
+		config   pointConfig
+		table    modbusTable
+		encoding string
+		scale    float64
+	}{
+		{pointConfig{Address: "Kitchen/Temperature"}, tableHoldingRegisters, "float32", 1},
+		{pointConfig{Address: "1/2/3", Table: "input", Scale: 10}, tableInputRegisters, "int16", 10},
+		{pointConfig{Address: "1/2/4"}, tableCoils, "bit", 1},
+		{pointConfig{Address: "1/2/4", Table: "discrete"}, tableDiscreteInputs, "bit", 1},
+		{pointConfig{Address: "1/2/6", DPT: "13.010"}, tableHoldingRegisters, "int32", 1},
+		{pointConfig{Address: "1/2/6", DPT: "5.001", Encoding: "float32"}, tableHoldingRegisters, "float32", 1},
+	} {
+		p, err := tt.config.resolve(reg)
+		if assert.NoError(t, err, tt.config) {
+			assert.Equal(t, tt.table, p.table, tt.config)
+			assert.Equal(t, tt.encoding, p.encoding, tt.config)
+			assert.Equal(t, tt.scale, p.scale, tt.config)
+		}
+	}
+
+	for _, config := range []pointConfig{
+		{Address: "Unknown"},
+		{Address: "1/2/6"},
+		{Address: "1/2/5"},
+		{Address: "1/2/4", Table: "holding"},
+		{Address: "1/2/3", Table: "coil"},
+		{Address: "1/2/3", Encoding: "bit"},
+		{Address: "1/2/3", Encoding: "int64"},
+		{Address: "1/2/3", Table: "registers"},
+		{Address: "1/2/3", Register: 65535},
+	} {
+		_, err := config.resolve(reg)
+		assert.Error(t, err, config)
+	}
+}
+
+func TestRegisterMapOverlap(t *testing.T) {
+	_, err := newRegisterMap([]*point{
+		{addr: 1, table: tableHoldingRegisters, register: 0, encoding: "float32"},
+		{addr: 2, table: tableHoldingRegisters, register: 1, encoding: "int16"},
+	})
+	assert.Error(t, err)
+
+	_, err = newRegisterMap([]*point{
+		{addr: 1, table: tableHoldingRegisters, register: 0, encoding: "float32"},
+		{addr: 2, table: tableInputRegisters, register: 1, encoding: "int16"},
+	})
+	assert.NoError(t, err)
+}
+
+func TestEncodeRegisters(t *testing.T) {
+	for _, tt := range []struct {
+		encoding  string
+		value     float64
+		registers []uint16
+		decoded   float64
+	}{
+		{"uint16", 1234, []uint16{1234}, 1234},
+		{"uint16", -5, []uint16{0}, 0},
+		{"uint16", 70000, []uint16{65535}, 65535},
+		{"int16", -215.4, []uint16{0xFF29}, -215},
+		{"uint32", 100000, []uint16{0x0001, 0x86A0}, 100000},
+		{"int32", -2, []uint16{0xFFFF, 0xFFFE}, -2},
+		{"float32", 21.5, []uint16{0x41AC, 0x0000}, 21.5},
+	} {
+		registers := encodeRegisters(tt.encoding, tt.value)
+		require.Equal(t, tt.registers, registers, tt.encoding)
+		assert.Equal(t, tt.decoded, decodeRegisters(tt.encoding, registers), tt.encoding)
+	}
+}
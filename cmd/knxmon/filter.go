@@ -0,0 +1,191 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/dpt"
+	"github.com/vapourismo/knx-go/knx/registry"
+)
+
+// A dptOverride assigns a datapoint type to the group addresses matching a pattern.
+type dptOverride struct {
+	match func(cemi.GroupAddr) bool
+	dpt   string
+}
+
+// dptFlag collects the datapoint types given on the command line.
+type dptFlag []dptOverride
+
+// String implements flag.Value.
+func (*dptFlag) String() string {
+	return ""
+}
+
+// Set parses an assignment like "1/2/3=9.001" or "1/2/*=1.001".
+func (overrides *dptFlag) Set(value string) error {
+	pattern, name, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("expected <address>=<dpt>, got %q", value)
+	}
+
+	if _, ok := dpt.Produce(name); !ok {
+		return fmt.Errorf("unknown datapoint type %q", name)
+	}
+
+	match, err := registry.ParseAddressPattern(pattern)
+	if err != nil {
+		return err
+	}
+
+	*overrides = append(*overrides, dptOverride{match, name})
+	return nil
+}
+
+// A filter decides which telegrams are shown. Empty criteria match everything.
+type filter struct {
+	destinations []func(cemi.GroupAddr) bool
+	sources      []func(cemi.IndividualAddr) bool
+	commands     map[string]bool
+}
+
+// splitList splits a comma-separated list, ignoring empty elements.
+func splitList(list string) []string {
+	var elems []string
+
+	for _, elem := range strings.Split(list, ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			elems = append(elems, elem)
+		}
+	}
+
+	return elems
+}
+
+// newFilter creates a filter from comma-separated lists. Destinations are given as address patterns
+// or, if a registry is available, as globs over the names of group addresses.
+func newFilter(reg *registry.Registry, destinations, sources, commands string) (*filter, error) {
+	f := &filter{}
+
+	for _, pattern := range splitList(destinations) {
+		match, err := registry.ParseAddressPattern(pattern)
+		if err != nil {
+			entries, err := reg.Find(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("destination %q: %w", pattern, err)
+			}
+
+			addrs := make(map[cemi.GroupAddr]bool, len(entries))
+			for _, entry := range entries {
+				addrs[entry.Address] = true
+			}
+
+			match = func(addr cemi.GroupAddr) bool { return addrs[addr] }
+		}
+
+		f.destinations = append(f.destinations, match)
+	}
+
+	for _, pattern := range splitList(sources) {
+		match, err := parseSourcePattern(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("source %q: %w", pattern, err)
+		}
+
+		f.sources = append(f.sources, match)
+	}
+
+	if names := splitList(commands); len(names) > 0 {
+		f.commands = make(map[string]bool, len(names))
+
+		for _, name := range names {
+			f.commands[strings.ToLower(name)] = true
+		}
+	}
+
+	return f, nil
+}
+
+// parseSourcePattern parses a pattern over individual addresses like "1.1.*" or "1.0-3.*".
+func parseSourcePattern(pattern string) (func(cemi.IndividualAddr) bool, error) {
+	components := strings.Split(pattern, ".")
+	if len(components) != 3 {
+		return nil, fmt.Errorf("expected area.line.device")
+	}
+
+	shifts := []uint{12, 8, 0}
+	masks := []uint64{0xF, 0xF, 0xFF}
+
+	var los, his [3]uint64
+
+	for i, component := range components {
+		if component == "*" {
+			los[i], his[i] = 0, masks[i]
+			continue
+		}
+
+		first, last, _ := strings.Cut(component, "-")
+		if last == "" {
+			last = first
+		}
+
+		lo, err := strconv.ParseUint(first, 10, 8)
+		if err != nil {
+			return nil, err
+		}
+
+		hi, err := strconv.ParseUint(last, 10, 8)
+		if err != nil {
+			return nil, err
+		}
+
+		if lo > hi || hi > masks[i] {
+			return nil, fmt.Errorf("component %q is out of range", component)
+		}
+
+		los[i], his[i] = lo, hi
+	}
+
+	return func(addr cemi.IndividualAddr) bool {
+		for i := range components {
+			value := uint64(addr>>shifts[i]) & masks[i]
+			if value < los[i] || value > his[i] {
+				return false
+			}
+		}
+
+		return true
+	}, nil
+}
+
+// matches determines whether the telegram passes the filter.
+func (f *filter) matches(tg *telegram) bool {
+	if f.commands != nil && !f.commands[strings.ToLower(tg.Command)] {
+		return false
+	}
+
+	if len(f.sources) > 0 && !matchAny(f.sources, tg.Source) {
+		return false
+	}
+
+	if len(f.destinations) > 0 {
+		return tg.Group && matchAny(f.destinations, cemi.GroupAddr(tg.Destination))
+	}
+
+	return true
+}
+
+// matchAny determines whether any of the predicates holds.
+func matchAny[T any](preds []func(T) bool, value T) bool {
+	for _, pred := range preds {
+		if pred(value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,151 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Command knxmon prints the telegrams on a KNX network and decodes their values.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/registry"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags] <address>\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "The address is a gateway (10.0.0.2:3671), a multicast group (224.0.23.12:3671)\n")
+	fmt.Fprintf(os.Stderr, "or a URL as accepted by knx.Dial (tcp://10.0.0.2?heartbeat=5s).\n\n")
+	flag.PrintDefaults()
+}
+
+// transportURL turns the address into a URL for knx.Dial, which selects the busmonitor layer if
+// busmon is set.
+func transportURL(address string, busmon bool) (string, error) {
+	address = knx.TransportURL(address)
+
+	if !busmon {
+		return address, nil
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", err
+	}
+
+	if u.Scheme != "udp" && u.Scheme != "tcp" {
+		return "", fmt.Errorf("busmonitor mode requires a tunnel, not %s", u.Scheme)
+	}
+
+	query := u.Query()
+	query.Set("layer", "busmon")
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+func main() {
+	var overrides dptFlag
+
+	busmon := flag.Bool("busmon", false, "use busmonitor mode, which also shows frames not meant for the gateway")
+	project := flag.String("project", "", "ETS project, group address CSV or XML export used to name and decode addresses")
+	password := flag.String("password", "", "password of the ETS project")
+	flag.Var(&overrides, "dpt", "datapoint type for addresses, e.g. 1/2/3=9.001 or 1/2/*=1.001 (repeatable)")
+	destinations := flag.String("dst", "", "comma-separated destinations to show, as address patterns (1/2/*) or name globs")
+	sources := flag.String("src", "", "comma-separated sources to show, e.g. 1.1.5 or 1.1.*")
+	commands := flag.String("cmd", "", "comma-separated commands to show, e.g. write,response")
+	format := flag.String("format", "table", "output format, either table or json")
+	style := flag.String("style", "3", "group address style, either 3, 2 or free")
+	verbose := flag.Bool("v", false, "log internal messages")
+
+	flag.Usage = printUsage
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		printUsage()
+		os.Exit(2)
+	}
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	if *verbose {
+		util.Logger = logger
+	}
+
+	reg := registry.New()
+
+	if *project != "" {
+		var err error
+		if reg, err = registry.Load(*project, *password); err != nil {
+			logger.Fatalf("Could not load %s: %v", *project, err)
+		}
+	}
+
+	addressStyle, err := registry.ParseAddressStyle(*style)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	reg.SetStyle(addressStyle)
+
+	f, err := newFilter(reg, *destinations, *sources, *commands)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	var out printer
+
+	switch *format {
+	case "table":
+		out = &tablePrinter{w: os.Stdout, reg: reg}
+
+	case "json":
+		out = newJSONPrinter(os.Stdout, reg)
+
+	default:
+		logger.Fatalf("Unknown format %q", *format)
+	}
+
+	address, err := transportURL(flag.Arg(0), *busmon)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	transport, err := knx.Dial(address)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	// Closing the transport terminates the loop below.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	go func() {
+		<-signals
+		transport.Close()
+	}()
+
+	dec := &decoder{reg: reg, overrides: overrides}
+
+	for msg := range transport.Inbound() {
+		tg, err := newTelegram(time.Now(), msg)
+		if err != nil {
+			util.Log(transport, "Skipping message: %v", err)
+			continue
+		}
+
+		if !f.matches(tg) {
+			continue
+		}
+
+		dec.decode(tg)
+
+		if err := out.print(tg); err != nil {
+			logger.Fatal(err)
+		}
+	}
+}
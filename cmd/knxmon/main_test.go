@@ -0,0 +1,212 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/registry"
+)
+
+func makeTestRegistry() *registry.Registry {
+	reg := registry.New()
+	reg.Add(registry.Entry{
+		Address: cemi.NewGroupAddr3(1, 0, 1),
+		Name:    "Kitchen",
+		Path:    []string{"Ground floor", "Lights"},
+		DPT:     "1.001",
+	})
+	reg.Add(registry.Entry{
+		Address: cemi.NewGroupAddr3(2, 0, 1),
+		Name:    "Kitchen",
+		Path:    []string{"Ground floor", "Temperature"},
+		DPT:     "9.001",
+	})
+
+	return reg
+}
+
+func makeGroupInd(src cemi.IndividualAddr, dest cemi.GroupAddr, cmd cemi.APCI, data ...byte) cemi.Message {
+	return &cemi.LDataInd{LData: cemi.LData{
+		Control1:    cemi.Control1StdFrame | cemi.Control1NoRepeat | cemi.Control1NoSysBroadcast,
+		Control2:    cemi.Control2GroupAddr | cemi.Control2Hops(6),
+		Source:      src,
+		Destination: uint16(dest),
+		Data:        &cemi.AppData{Command: cmd, Data: data},
+	}}
+}
+
+func TestTelegram(t *testing.T) {
+	reg := makeTestRegistry()
+
+	var overrides dptFlag
+	if err := overrides.Set("3/*/*=5.001"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, invalid := range []string{"1/2/3", "1/2/3=0.000", "1/8/*=1.001"} {
+		if err := overrides.Set(invalid); err == nil {
+			t.Errorf("%s: assignment was accepted", invalid)
+		}
+	}
+
+	dec := &decoder{reg: reg, overrides: overrides}
+	src := cemi.NewIndividualAddr3(1, 1, 5)
+
+	cases := []struct {
+		msg   cemi.Message
+		value string
+	}{
+		{makeGroupInd(src, cemi.NewGroupAddr3(1, 0, 1), cemi.GroupValueWrite, 1), "On"},
+		{makeGroupInd(src, cemi.NewGroupAddr3(2, 0, 1), cemi.GroupValueResponse, 0, 0x0C, 0x1A), "21.0 °C"},
+		{makeGroupInd(src, cemi.NewGroupAddr3(3, 1, 2), cemi.GroupValueWrite, 0, 0xFF), "100 %"},
+		{makeGroupInd(src, cemi.NewGroupAddr3(4, 0, 0), cemi.GroupValueWrite, 0, 0xFF), ""},
+		{makeGroupInd(src, cemi.NewGroupAddr3(1, 0, 1), cemi.GroupValueRead, 0), ""},
+	}
+
+	for _, c := range cases {
+		tg, err := newTelegram(time.Now(), c.msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dec.decode(tg)
+
+		value := strings.TrimSpace(tg.Value + " " + tg.Unit)
+		if !strings.HasPrefix(value, c.value) || (c.value == "" && value != "") {
+			t.Errorf("%v: got %q, expected %q", tg.Destination, value, c.value)
+		}
+	}
+
+	if _, err := newTelegram(time.Now(), &cemi.LDataCon{}); err == nil {
+		t.Error("Confirmation was accepted")
+	}
+}
+
+func TestTelegram_busmon(t *testing.T) {
+	frame := []byte{0xBC, 0x11, 0x05, 0x08, 0x01, 0xE1, 0x00, 0x81}
+
+	check := byte(0xFF)
+	for _, octet := range frame {
+		check ^= octet
+	}
+
+	msg := cemi.LBusmonInd(append([]byte{0}, append(frame, check)...))
+
+	tg, err := newTelegram(time.Now(), &msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tg.Source != cemi.NewIndividualAddr3(1, 1, 5) || tg.Command != "Write" ||
+		!bytes.Equal(tg.Raw, append(frame, check)) {
+		t.Errorf("Unexpected telegram %+v", tg)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	reg := makeTestRegistry()
+
+	f, err := newFilter(reg, "1/0/*, */Temperature/*", "1.1.*", "write,Response")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		msg     cemi.Message
+		matches bool
+	}{
+		{makeGroupInd(cemi.NewIndividualAddr3(1, 1, 5), cemi.NewGroupAddr3(1, 0, 1), cemi.GroupValueWrite, 1), true},
+		{makeGroupInd(cemi.NewIndividualAddr3(1, 1, 5), cemi.NewGroupAddr3(2, 0, 1), cemi.GroupValueResponse, 0, 1, 2), true},
+		{makeGroupInd(cemi.NewIndividualAddr3(1, 1, 5), cemi.NewGroupAddr3(2, 0, 2), cemi.GroupValueWrite, 0, 1, 2), false},
+		{makeGroupInd(cemi.NewIndividualAddr3(1, 2, 5), cemi.NewGroupAddr3(1, 0, 1), cemi.GroupValueWrite, 1), false},
+		{makeGroupInd(cemi.NewIndividualAddr3(1, 1, 5), cemi.NewGroupAddr3(1, 0, 1), cemi.GroupValueRead, 0), false},
+	}
+
+	for i, c := range cases {
+		tg, err := newTelegram(time.Now(), c.msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if f.matches(tg) != c.matches {
+			t.Errorf("Case %d: expected %v", i, c.matches)
+		}
+	}
+
+	for _, invalid := range []string{"1.1", "1.16.*", "1.2-1.*"} {
+		if _, err := newFilter(reg, "", invalid, ""); err == nil {
+			t.Errorf("%s: source pattern was accepted", invalid)
+		}
+	}
+}
+
+func TestJSONPrinter(t *testing.T) {
+	reg := makeTestRegistry()
+
+	tg, err := newTelegram(
+		time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		makeGroupInd(cemi.NewIndividualAddr3(1, 1, 5), cemi.NewGroupAddr3(1, 0, 1), cemi.GroupValueWrite, 1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	(&decoder{reg: reg}).decode(tg)
+
+	var buffer bytes.Buffer
+	if err := newJSONPrinter(&buffer, reg).print(tg); err != nil {
+		t.Fatal(err)
+	}
+
+	var obj jsonTelegram
+	if err := json.Unmarshal(buffer.Bytes(), &obj); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := jsonTelegram{
+		Time:        "2024-01-02T03:04:05Z",
+		Source:      "1.1.5",
+		Destination: "1/0/1",
+		Group:       true,
+		Name:        "Ground floor/Lights/Kitchen",
+		Command:     "Write",
+		Data:        "01",
+		Raw:         "2900b0e011050801010081",
+		DPT:         "1.001",
+		Value:       "On",
+	}
+
+	if obj != expected {
+		t.Errorf("Unexpected output %+v", obj)
+	}
+}
+
+func TestTransportURL(t *testing.T) {
+	cases := []struct {
+		address string
+		busmon  bool
+		result  string
+	}{
+		{"10.0.0.2:3671", false, "udp://10.0.0.2:3671"},
+		{"224.0.23.12:3671", false, "multicast://224.0.23.12:3671"},
+		{"224.0.23.12", false, "multicast://224.0.23.12"},
+		{"tcp://10.0.0.2", true, "tcp://10.0.0.2?layer=busmon"},
+	}
+
+	for _, c := range cases {
+		result, err := transportURL(c.address, c.busmon)
+		if err != nil || result != c.result {
+			t.Errorf("%s: got %q, %v", c.address, result, err)
+		}
+	}
+
+	if _, err := transportURL("224.0.23.12", true); err == nil {
+		t.Error("Busmonitor mode was accepted for routing")
+	}
+}
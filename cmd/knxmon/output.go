@@ -0,0 +1,122 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/registry"
+)
+
+// A printer writes telegrams in some format.
+type printer interface {
+	print(tg *telegram) error
+}
+
+// spacedHex formats the bytes as hexadecimal octets separated by spaces.
+func spacedHex(data []byte) string {
+	octets := make([]string, len(data))
+	for i, octet := range data {
+		octets[i] = fmt.Sprintf("%02X", octet)
+	}
+
+	return strings.Join(octets, " ")
+}
+
+// tablePrinter writes telegrams as the rows of a table meant for humans.
+type tablePrinter struct {
+	w      io.Writer
+	reg    *registry.Registry
+	header bool
+}
+
+func (p *tablePrinter) print(tg *telegram) error {
+	if !p.header {
+		p.header = true
+
+		if _, err := fmt.Fprintf(
+			p.w, "%-23s  %-9s  %-12s  %-10s  %-17s  %s\n",
+			"Time", "Source", "Destination", "Command", "Data", "Value",
+		); err != nil {
+			return err
+		}
+	}
+
+	value := tg.Value
+	// Some datapoint types include the unit in their string representation.
+	if value != "" && tg.Unit != "" && !strings.HasSuffix(value, tg.Unit) {
+		value += " " + tg.Unit
+	}
+
+	if tg.Group {
+		if entry, ok := p.reg.Lookup(cemi.GroupAddr(tg.Destination)); ok {
+			value = strings.TrimSpace(value + "  " + entry.FullName())
+		}
+	}
+
+	_, err := fmt.Fprintf(
+		p.w, "%-23s  %-9s  %-12s  %-10s  %-17s  %s\n",
+		tg.Time.Format("2006-01-02 15:04:05.000"),
+		tg.Source,
+		tg.DestinationString(p.reg.Style()),
+		tg.Command,
+		spacedHex(tg.Data),
+		value,
+	)
+
+	return err
+}
+
+// jsonTelegram is the JSON representation of a telegram.
+type jsonTelegram struct {
+	Time        string `json:"time"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Group       bool   `json:"group"`
+	Name        string `json:"name,omitempty"`
+	Command     string `json:"command"`
+	Data        string `json:"data"`
+	Raw         string `json:"raw"`
+	DPT         string `json:"dpt,omitempty"`
+	Value       string `json:"value,omitempty"`
+	Unit        string `json:"unit,omitempty"`
+}
+
+// jsonPrinter writes telegrams as JSON objects, one per line.
+type jsonPrinter struct {
+	encoder *json.Encoder
+	reg     *registry.Registry
+}
+
+func newJSONPrinter(w io.Writer, reg *registry.Registry) *jsonPrinter {
+	return &jsonPrinter{json.NewEncoder(w), reg}
+}
+
+func (p *jsonPrinter) print(tg *telegram) error {
+	obj := jsonTelegram{
+		Time:        tg.Time.Format(time.RFC3339Nano),
+		Source:      tg.Source.String(),
+		Destination: tg.DestinationString(p.reg.Style()),
+		Group:       tg.Group,
+		Command:     tg.Command,
+		Data:        hex.EncodeToString(tg.Data),
+		Raw:         hex.EncodeToString(tg.Raw),
+		DPT:         tg.DPT,
+		Value:       tg.Value,
+		Unit:        tg.Unit,
+	}
+
+	if tg.Group {
+		if entry, ok := p.reg.Lookup(cemi.GroupAddr(tg.Destination)); ok {
+			obj.Name = entry.FullName()
+		}
+	}
+
+	return p.encoder.Encode(obj)
+}
@@ -0,0 +1,155 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/dpt"
+	"github.com/vapourismo/knx-go/knx/registry"
+)
+
+var apciNames = map[cemi.APCI]string{
+	cemi.GroupValueRead:         "Read",
+	cemi.GroupValueResponse:     "Response",
+	cemi.GroupValueWrite:        "Write",
+	cemi.IndividualAddrWrite:    "IndividualAddrWrite",
+	cemi.IndividualAddrRequest:  "IndividualAddrRequest",
+	cemi.IndividualAddrResponse: "IndividualAddrResponse",
+	cemi.AdcRead:                "AdcRead",
+	cemi.AdcResponse:            "AdcResponse",
+	cemi.MemoryRead:             "MemoryRead",
+	cemi.MemoryResponse:         "MemoryResponse",
+	cemi.MemoryWrite:            "MemoryWrite",
+	cemi.UserMessage:            "UserMessage",
+	cemi.MaskVersionRead:        "MaskVersionRead",
+	cemi.MaskVersionResponse:    "MaskVersionResponse",
+	cemi.Restart:                "Restart",
+	cemi.Escape:                 "Escape",
+}
+
+var controlNames = []string{"Connect", "Disconnect", "Ack", "Nack"}
+
+// A telegram is a frame observed on the bus.
+type telegram struct {
+	Time        time.Time
+	Source      cemi.IndividualAddr
+	Group       bool
+	Destination uint16
+	Command     string
+
+	// Data is the application data, if any.
+	Data []byte
+
+	// Raw is the CEMI frame, or the TP1 frame in busmonitor mode.
+	Raw []byte
+
+	// DPT, Value and Unit are set if the data could be decoded.
+	DPT   string
+	Value string
+	Unit  string
+}
+
+var errNoTelegram = errors.New("message does not contain a telegram")
+
+// newTelegram extracts the telegram from a message.
+func newTelegram(now time.Time, msg cemi.Message) (*telegram, error) {
+	var (
+		ldata *cemi.LData
+		raw   []byte
+	)
+
+	switch msg := msg.(type) {
+	case *cemi.LDataInd:
+		ldata = &msg.LData
+
+	case *cemi.LBusmonInd:
+		var err error
+		if ldata, err = msg.Frame(); err != nil {
+			return nil, err
+		}
+
+		// Skip the additional information to obtain the TP1 frame.
+		raw = []byte(*msg)[len(ldata.Info)+1:]
+
+	default:
+		return nil, errNoTelegram
+	}
+
+	if raw == nil {
+		raw = make([]byte, cemi.Size(msg))
+		cemi.Pack(raw, msg)
+	}
+
+	tg := &telegram{
+		Time:        now,
+		Source:      ldata.Source,
+		Group:       ldata.Control2.IsGroupAddr(),
+		Destination: ldata.Destination,
+		Raw:         raw,
+	}
+
+	switch unit := ldata.Data.(type) {
+	case *cemi.AppData:
+		tg.Command = apciNames[unit.Command]
+		tg.Data = unit.Data
+
+	case *cemi.ControlData:
+		tg.Command = controlNames[unit.Command&3]
+	}
+
+	return tg, nil
+}
+
+// DestinationString formats the destination, which is either a group or an individual address.
+func (tg *telegram) DestinationString(style registry.AddressStyle) string {
+	if tg.Group {
+		return style.Format(cemi.GroupAddr(tg.Destination))
+	}
+
+	return cemi.IndividualAddr(tg.Destination).String()
+}
+
+// A decoder determines the datapoint types of group addresses.
+type decoder struct {
+	reg       *registry.Registry
+	overrides []dptOverride
+}
+
+// datapoint produces a value of the datapoint type of the address.
+func (dec *decoder) datapoint(addr cemi.GroupAddr) (string, dpt.Datapoint, bool) {
+	// Later overrides take precedence.
+	for i := len(dec.overrides) - 1; i >= 0; i-- {
+		if dec.overrides[i].match(addr) {
+			value, ok := dpt.Produce(dec.overrides[i].dpt)
+			return dec.overrides[i].dpt, value, ok
+		}
+	}
+
+	entry, ok := dec.reg.Lookup(addr)
+	if !ok {
+		return "", nil, false
+	}
+
+	value, ok := entry.Datapoint()
+	return entry.DPT, value, ok
+}
+
+// decode fills in the decoded value of a group telegram carrying a value.
+func (dec *decoder) decode(tg *telegram) {
+	if !tg.Group || tg.Command == "Read" || len(tg.Data) == 0 {
+		return
+	}
+
+	name, value, ok := dec.datapoint(cemi.GroupAddr(tg.Destination))
+	if !ok || value.Unpack(tg.Data) != nil {
+		return
+	}
+
+	tg.DPT = name
+	tg.Value = strings.TrimSpace(value.String())
+	tg.Unit = value.Unit()
+}
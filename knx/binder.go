@@ -0,0 +1,468 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/dpt"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// A BinderConfig determines certain properties of a Binder.
+type BinderConfig struct {
+	// OnChange is called with the name of a field after its value has been changed by an inbound
+	// event. It runs on the goroutine of the Binder while the struct is not locked.
+	OnChange func(field string)
+}
+
+// boundField is a struct field which is bound to group addresses.
+type boundField struct {
+	name     string
+	index    int
+	write    cemi.GroupAddr
+	status   []cemi.GroupAddr
+	read     cemi.GroupAddr
+	dptName  string
+	readOnly bool
+	refresh  time.Duration
+	received bool
+	onChange []func(value dpt.DatapointValue)
+}
+
+// parseFieldTag parses a tag such as "1/2/3,status=1/2/4,readonly,refresh=1m". The first element
+// is the address the field is written to. Values are received through it and the status
+// addresses. Reads are sent to the read address, which defaults to the first status address.
+func parseFieldTag(tag string) (*boundField, error) {
+	parts := strings.Split(tag, ",")
+
+	field := &boundField{}
+
+	write, err := cemi.NewGroupAddrString(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	field.write = write
+	field.read = write
+
+	var hasRead bool
+
+	for _, part := range parts[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+
+		switch key {
+		case "status":
+			addr, err := cemi.NewGroupAddrString(value)
+			if err != nil {
+				return nil, err
+			}
+
+			field.status = append(field.status, addr)
+
+		case "read":
+			addr, err := cemi.NewGroupAddrString(value)
+			if err != nil {
+				return nil, err
+			}
+
+			field.read = addr
+			hasRead = true
+
+		case "dpt":
+			field.dptName = value
+
+		case "readonly":
+			field.readOnly = true
+
+		case "refresh":
+			interval, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, err
+			}
+
+			if interval <= 0 {
+				return nil, errors.New("refresh interval must be positive")
+			}
+
+			field.refresh = interval
+
+		default:
+			return nil, fmt.Errorf("unknown option %q", key)
+		}
+	}
+
+	if !hasRead && len(field.status) > 0 {
+		field.read = field.status[0]
+	}
+
+	return field, nil
+}
+
+// addrs returns the addresses through which the field receives values.
+func (field *boundField) addrs() []cemi.GroupAddr {
+	return append([]cemi.GroupAddr{field.write}, field.status...)
+}
+
+var datapointValueType = reflect.TypeOf((*dpt.DatapointValue)(nil)).Elem()
+
+// A Binder keeps the fields of a struct in sync with group addresses. Fields are bound using the
+// knx tag, for example:
+//
+//	type Room struct {
+//		Temperature dpt.DPT_9001        `knx:"1/2/3,status=1/2/4"`
+//		Window      dpt.DPT_1019        `knx:"1/3/1,readonly,refresh=5m"`
+//		Valve       dpt.DatapointValue `knx:"1/4/1,dpt=5.001"`
+//	}
+//
+// A field must either be a datapoint type, or of type dpt.DatapointValue with the dpt option naming
+// the type to use with dpt.Produce. Received values are stored in the field. Values are only written
+// when requested using Write or WriteAll; fields with the readonly option cannot be written. The
+// refresh option reads the field periodically.
+//
+// The Binder updates the struct from its own goroutine. Use Lock and Unlock around accesses.
+type Binder struct {
+	mux    *GroupMux
+	config BinderConfig
+	target reflect.Value
+
+	fields map[string]*boundField
+	byAddr map[cemi.GroupAddr][]*boundField
+
+	mu      sync.Mutex
+	changed chan struct{}
+
+	events      <-chan GroupEvent
+	unsubscribe func()
+
+	done chan struct{}
+	once sync.Once
+	wait sync.WaitGroup
+}
+
+// NewBinder binds the tagged fields of the struct that target points to. It starts receiving
+// values immediately; use ReadAll to populate the fields initially.
+func NewBinder(mux *GroupMux, target interface{}, config BinderConfig) (*Binder, error) {
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("binder target must be a pointer to a struct")
+	}
+
+	b := &Binder{
+		mux:     mux,
+		config:  config,
+		target:  ptr.Elem(),
+		fields:  make(map[string]*boundField),
+		byAddr:  make(map[cemi.GroupAddr][]*boundField),
+		changed: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	structType := b.target.Type()
+
+	var addrs []cemi.GroupAddr
+
+	for i := 0; i < structType.NumField(); i++ {
+		sf := structType.Field(i)
+
+		tag, ok := sf.Tag.Lookup("knx")
+		if !ok || tag == "-" {
+			continue
+		}
+
+		field, err := parseFieldTag(tag)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+
+		field.name = sf.Name
+		field.index = i
+
+		switch {
+		case sf.PkgPath != "":
+			return nil, fmt.Errorf("field %s is not exported", sf.Name)
+
+		case sf.Type == datapointValueType:
+			if _, ok := dpt.Produce(field.dptName); !ok {
+				return nil, fmt.Errorf("field %s: unknown datapoint type %q", sf.Name, field.dptName)
+			}
+
+		case reflect.PtrTo(sf.Type).Implements(datapointValueType):
+			if field.dptName != "" {
+				return nil, fmt.Errorf("field %s: dpt option requires type dpt.DatapointValue", sf.Name)
+			}
+
+		default:
+			return nil, fmt.Errorf("field %s is not a datapoint type", sf.Name)
+		}
+
+		b.fields[field.name] = field
+
+		for _, addr := range field.addrs() {
+			if len(b.byAddr[addr]) == 0 {
+				addrs = append(addrs, addr)
+			}
+
+			b.byAddr[addr] = append(b.byAddr[addr], field)
+		}
+	}
+
+	if len(b.fields) == 0 {
+		return nil, errors.New("binder target has no bound fields")
+	}
+
+	b.events, b.unsubscribe = mux.Subscribe(addrs...)
+
+	b.wait.Add(1)
+	go b.serve()
+
+	for _, field := range b.fields {
+		if field.refresh > 0 {
+			b.wait.Add(1)
+			go b.refresh(field)
+		}
+	}
+
+	return b, nil
+}
+
+// Lock locks the struct, so that it can be accessed safely.
+func (b *Binder) Lock() {
+	b.mu.Lock()
+}
+
+// Unlock unlocks the struct.
+func (b *Binder) Unlock() {
+	b.mu.Unlock()
+}
+
+// OnFieldChange registers a callback which is invoked with the new value after the given field
+// has been changed by an inbound event.
+func (b *Binder) OnFieldChange(name string, callback func(value dpt.DatapointValue)) error {
+	field, ok := b.fields[name]
+	if !ok {
+		return fmt.Errorf("field %s is not bound", name)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	field.onChange = append(field.onChange, callback)
+
+	return nil
+}
+
+// decode unpacks the data into a new value for the field.
+func (b *Binder) decode(field *boundField, data []byte) (reflect.Value, dpt.DatapointValue, error) {
+	if field.dptName != "" {
+		datapoint, _ := dpt.Produce(field.dptName)
+		if err := datapoint.Unpack(data); err != nil {
+			return reflect.Value{}, nil, err
+		}
+
+		var value dpt.DatapointValue = datapoint
+		return reflect.ValueOf(&value).Elem(), value, nil
+	}
+
+	ptr := reflect.New(b.target.Field(field.index).Type())
+
+	value := ptr.Interface().(dpt.DatapointValue)
+	if err := value.Unpack(data); err != nil {
+		return reflect.Value{}, nil, err
+	}
+
+	return ptr.Elem(), value, nil
+}
+
+// update stores the value carried by the event in the field. It returns the callbacks to invoke.
+func (b *Binder) update(field *boundField, event GroupEvent) []func() {
+	newValue, datapoint, err := b.decode(field, event.Data)
+	if err != nil {
+		util.Log(b, "Failed to decode value for field %s: %v", field.name, err)
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	target := b.target.Field(field.index)
+	changed := !reflect.DeepEqual(target.Interface(), newValue.Interface())
+
+	target.Set(newValue)
+	field.received = true
+
+	// Wake up anyone waiting for values.
+	close(b.changed)
+	b.changed = make(chan struct{})
+
+	if !changed {
+		return nil
+	}
+
+	var callbacks []func()
+
+	for _, callback := range field.onChange {
+		callback := callback
+		callbacks = append(callbacks, func() { callback(datapoint) })
+	}
+
+	if b.config.OnChange != nil {
+		callbacks = append(callbacks, func() { b.config.OnChange(field.name) })
+	}
+
+	return callbacks
+}
+
+// serve stores the received values in the bound fields.
+func (b *Binder) serve() {
+	util.Log(b, "Started worker")
+	defer util.Log(b, "Worker exited")
+
+	defer b.wait.Done()
+
+	for event := range b.events {
+		if event.Command != GroupWrite && event.Command != GroupResponse {
+			continue
+		}
+
+		for _, field := range b.byAddr[event.Destination] {
+			for _, callback := range b.update(field, event) {
+				callback()
+			}
+		}
+	}
+}
+
+// refresh reads the field periodically.
+func (b *Binder) refresh(field *boundField) {
+	defer b.wait.Done()
+
+	ticker := time.NewTicker(field.refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+
+		case <-ticker.C:
+			if err := b.mux.Send(GroupEvent{Command: GroupRead, Destination: field.read}); err != nil {
+				util.Log(b, "Failed to refresh field %s: %v", field.name, err)
+			}
+		}
+	}
+}
+
+// ReadAll sends a read request for every field and waits until each of them has received a value,
+// or until the context is done.
+func (b *Binder) ReadAll(ctx context.Context) error {
+	b.mu.Lock()
+	for _, field := range b.fields {
+		field.received = false
+	}
+	b.mu.Unlock()
+
+	for _, field := range b.fields {
+		if err := b.mux.Send(GroupEvent{Command: GroupRead, Destination: field.read}); err != nil {
+			return err
+		}
+	}
+
+	for {
+		b.mu.Lock()
+
+		var missing []string
+		for _, field := range b.fields {
+			if !field.received {
+				missing = append(missing, field.name)
+			}
+		}
+
+		changed := b.changed
+		b.mu.Unlock()
+
+		if len(missing) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("no value received for %s: %w", strings.Join(missing, ", "), ctx.Err())
+
+		case <-changed:
+		}
+	}
+}
+
+// Write sends the current values of the given fields to their write addresses. The struct must not
+// be locked by the caller.
+func (b *Binder) Write(names ...string) error {
+	for _, name := range names {
+		field, ok := b.fields[name]
+		if !ok {
+			return fmt.Errorf("field %s is not bound", name)
+		}
+
+		if field.readOnly {
+			return fmt.Errorf("field %s is read-only", name)
+		}
+
+		b.mu.Lock()
+		value := b.target.Field(field.index).Addr().Interface()
+		if field.dptName != "" {
+			value = *value.(*dpt.DatapointValue)
+		}
+
+		var data []byte
+		if datapoint, ok := value.(dpt.DatapointValue); ok && datapoint != nil {
+			data = datapoint.Pack()
+		}
+		b.mu.Unlock()
+
+		if data == nil {
+			return fmt.Errorf("field %s has no value", name)
+		}
+
+		if err := b.mux.Send(GroupEvent{
+			Command:     GroupWrite,
+			Destination: field.write,
+			Data:        data,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteAll sends the current values of all fields which are not read-only.
+func (b *Binder) WriteAll() error {
+	var names []string
+
+	for name, field := range b.fields {
+		if !field.readOnly {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return b.Write(names...)
+}
+
+// Close stops synchronising the struct.
+func (b *Binder) Close() {
+	b.once.Do(func() {
+		close(b.done)
+		b.unsubscribe()
+		b.wait.Wait()
+	})
+}
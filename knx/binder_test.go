@@ -0,0 +1,168 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/dpt"
+)
+
+type testRoom struct {
+	Temperature dpt.DPT_9001       `knx:"1/2/3,status=1/2/4"`
+	Window      dpt.DPT_1019       `knx:"1/3/1,readonly"`
+	Valve       dpt.DatapointValue `knx:"1/4/1,dpt=5.001"`
+	Comment     string
+}
+
+func TestBinder(t *testing.T) {
+	conn := newDummyGroupConn()
+	defer conn.Close()
+
+	mux := NewGroupMux(conn)
+
+	var room testRoom
+
+	changes := make(chan string, 10)
+	binder, err := NewBinder(mux, &room, BinderConfig{
+		OnChange: func(field string) { changes <- field },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer binder.Close()
+
+	windows := make(chan dpt.DatapointValue, 10)
+	if err := binder.OnFieldChange("Window", func(value dpt.DatapointValue) {
+		windows <- value
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("ReadAll", func(t *testing.T) {
+		go func() {
+			responses := map[cemi.GroupAddr][]byte{
+				cemi.NewGroupAddr3(1, 2, 4): dpt.DPT_9001(21).Pack(),
+				cemi.NewGroupAddr3(1, 3, 1): dpt.DPT_1019(true).Pack(),
+				cemi.NewGroupAddr3(1, 4, 1): dpt.DPT_5001(100).Pack(),
+			}
+
+			for i := 0; i < 3; i++ {
+				request := <-conn.sent
+				conn.inbound <- GroupEvent{
+					Command:     GroupResponse,
+					Destination: request.Destination,
+					Data:        responses[request.Destination],
+				}
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		if err := binder.ReadAll(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		binder.Lock()
+		defer binder.Unlock()
+
+		if room.Temperature != 21 || !bool(room.Window) {
+			t.Errorf("Unexpected values: %+v", room)
+		}
+
+		if valve, ok := room.Valve.(*dpt.DPT_5001); !ok || *valve != 100 {
+			t.Errorf("Unexpected valve: %v", room.Valve)
+		}
+	})
+
+	t.Run("Inbound", func(t *testing.T) {
+		for len(changes) > 0 {
+			<-changes
+		}
+
+		conn.inbound <- GroupEvent{Command: GroupWrite, Destination: cemi.NewGroupAddr3(1, 3, 1), Data: dpt.DPT_1019(false).Pack()}
+
+		if field := <-changes; field != "Window" {
+			t.Errorf("Unexpected change: %s", field)
+		}
+
+		for value := range windows {
+			if !bool(*value.(*dpt.DPT_1019)) {
+				break
+			}
+		}
+
+		// Unchanged values do not trigger callbacks.
+		conn.inbound <- GroupEvent{Command: GroupWrite, Destination: cemi.NewGroupAddr3(1, 3, 1), Data: dpt.DPT_1019(false).Pack()}
+		conn.inbound <- GroupEvent{Command: GroupWrite, Destination: cemi.NewGroupAddr3(1, 2, 3), Data: dpt.DPT_9001(19).Pack()}
+
+		if field := <-changes; field != "Temperature" {
+			t.Errorf("Unexpected change: %s", field)
+		}
+	})
+
+	t.Run("Write", func(t *testing.T) {
+		binder.Lock()
+		room.Temperature = 22.5
+		binder.Unlock()
+
+		if err := binder.Write("Temperature"); err != nil {
+			t.Fatal(err)
+		}
+
+		event := <-conn.sent
+		if event.Destination != cemi.NewGroupAddr3(1, 2, 3) ||
+			!bytes.Equal(event.Data, dpt.DPT_9001(22.5).Pack()) {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+
+		if err := binder.Write("Window"); err == nil {
+			t.Error("Read-only field was written")
+		}
+
+		if err := binder.WriteAll(); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, addr := range []cemi.GroupAddr{cemi.NewGroupAddr3(1, 2, 3), cemi.NewGroupAddr3(1, 4, 1)} {
+			if event := <-conn.sent; event.Destination != addr {
+				t.Errorf("Expected write to %v, got %v", addr, event.Destination)
+			}
+		}
+	})
+}
+
+func TestNewBinder_invalid(t *testing.T) {
+	conn := newDummyGroupConn()
+	defer conn.Close()
+
+	mux := NewGroupMux(conn)
+
+	targets := []interface{}{
+		testRoom{},
+		&struct {
+			Value float64 `knx:"1/2/3"`
+		}{},
+		&struct {
+			Value dpt.DatapointValue `knx:"1/2/3,dpt=0.000"`
+		}{},
+		&struct {
+			Value dpt.DPT_9001 `knx:"1/2/3,refresh=soon"`
+		}{},
+		&struct {
+			Value dpt.DPT_9001 `knx:"1/2/3,unknown"`
+		}{},
+	}
+
+	for _, target := range targets {
+		if binder, err := NewBinder(mux, target, BinderConfig{}); err == nil {
+			binder.Close()
+			t.Errorf("Binding %T succeeded", target)
+		}
+	}
+}
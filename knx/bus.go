@@ -0,0 +1,175 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"sync"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// busQueueSize is the number of frames a connection to a Bus can lag behind before frames are
+// dropped.
+const busQueueSize = 1024
+
+// A Bus is an in-process KNX network. Frames sent by one connection are delivered to all other
+// connections. It is meant for tests and simulations.
+type Bus struct {
+	name  string
+	mu    sync.Mutex
+	conns map[*BusConn]struct{}
+}
+
+// NewBus creates an empty bus.
+func NewBus() *Bus {
+	return &Bus{conns: map[*BusConn]struct{}{}}
+}
+
+// Connect attaches a new connection to the bus.
+func (bus *Bus) Connect() *BusConn {
+	conn := &BusConn{
+		bus:     bus,
+		inbound: make(chan cemi.Message, busQueueSize),
+	}
+
+	conn.state.set(StateConnected, 0, nil)
+
+	bus.mu.Lock()
+	bus.conns[conn] = struct{}{}
+	bus.mu.Unlock()
+
+	return conn
+}
+
+// deliver hands the frame to all connections except the sender. Connections which do not keep up
+// lose the frame, just like on a real network.
+func (bus *Bus) deliver(sender *BusConn, data cemi.Message) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	for conn := range bus.conns {
+		if conn == sender {
+			continue
+		}
+
+		select {
+		case conn.inbound <- data:
+		default:
+			util.Log(conn, "Inbound queue is full, dropping frame")
+		}
+	}
+}
+
+// A BusConn is a connection to a Bus. It implements Transport.
+type BusConn struct {
+	bus     *Bus
+	inbound chan cemi.Message
+	state   stateWatcher
+	once    sync.Once
+}
+
+var _ Transport = (*BusConn)(nil)
+
+var errBusConnClosed = errors.New("bus connection is closed")
+
+// Send delivers the frame to the other connections. L_Data.req frames arrive as L_Data.ind, as if
+// a gateway had transmitted them.
+func (conn *BusConn) Send(data cemi.Message) error {
+	if data == nil {
+		return errors.New("nil-pointers are not sendable")
+	}
+
+	if conn.state.get() == StateClosed {
+		return errBusConnClosed
+	}
+
+	if req, ok := data.(*cemi.LDataReq); ok {
+		data = &cemi.LDataInd{LData: req.LData}
+	}
+
+	conn.bus.deliver(conn, data)
+
+	return nil
+}
+
+// Inbound returns the channel on which frames from the other connections arrive.
+func (conn *BusConn) Inbound() <-chan cemi.Message {
+	return conn.inbound
+}
+
+// Close detaches the connection from the bus.
+func (conn *BusConn) Close() {
+	conn.once.Do(func() {
+		conn.bus.mu.Lock()
+		delete(conn.bus.conns, conn)
+		close(conn.inbound)
+		conn.bus.mu.Unlock()
+
+		conn.state.set(StateClosed, 0, nil)
+	})
+}
+
+// LocalAddr returns the name of the bus, if it was created by Dial.
+func (conn *BusConn) LocalAddr() net.Addr {
+	return busAddr(conn.bus.name)
+}
+
+// State returns StateConnected until the connection has been closed.
+func (conn *BusConn) State() ConnState {
+	return conn.state.get()
+}
+
+// WatchState returns a channel which receives an event when the connection is closed.
+func (conn *BusConn) WatchState() (<-chan ConnEvent, func()) {
+	return conn.state.watch()
+}
+
+// busAddr is the address of a bus.
+type busAddr string
+
+func (busAddr) Network() string {
+	return "bus"
+}
+
+func (addr busAddr) String() string {
+	return string(addr)
+}
+
+var (
+	busesMu sync.Mutex
+	buses   = map[string]*Bus{}
+)
+
+// NamedBus returns the bus with the given name, creating it if necessary. Dialing "bus://name"
+// connects to it.
+func NamedBus(name string) *Bus {
+	busesMu.Lock()
+	defer busesMu.Unlock()
+
+	bus, ok := buses[name]
+	if !ok {
+		bus = NewBus()
+		bus.name = name
+		buses[name] = bus
+	}
+
+	return bus
+}
+
+// dialBus connects to the named bus given as host of the URL.
+func dialBus(u *url.URL, _ DialConfig) (Transport, error) {
+	if u.Host == "" {
+		return nil, errors.New("address has no bus name")
+	}
+
+	qp := queryParser{query: u.Query()}
+	if err := qp.finish(); err != nil {
+		return nil, err
+	}
+
+	return NamedBus(u.Host).Connect(), nil
+}
@@ -0,0 +1,80 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"testing"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	a, b, c := bus.Connect(), bus.Connect(), bus.Connect()
+	defer a.Close()
+	defer b.Close()
+
+	req := &cemi.LDataReq{LData: cemi.LData{
+		Destination: uint16(cemi.NewGroupAddr3(1, 2, 3)),
+		Data:        &cemi.AppData{Command: cemi.GroupValueWrite, Data: []byte{1}},
+	}}
+
+	c.Close()
+
+	if c.State() != StateClosed {
+		t.Errorf("Unexpected state %v", c.State())
+	}
+
+	if err := c.Send(req); err == nil {
+		t.Error("Closed connection sent a frame")
+	}
+
+	if err := a.Send(req); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-b.Inbound()
+	if ind, ok := msg.(*cemi.LDataInd); !ok || ind.LData.Destination != req.Destination {
+		t.Errorf("Unexpected message %+v", msg)
+	}
+
+	select {
+	case msg := <-a.Inbound():
+		t.Errorf("Sender received its own message %+v", msg)
+	default:
+	}
+
+	if _, open := <-c.Inbound(); open {
+		t.Error("Inbound channel of closed connection is open")
+	}
+}
+
+func TestDial_bus(t *testing.T) {
+	a, err := Dial("bus://dial-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	b, err := Dial("bus://dial-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if a.LocalAddr().String() != "dial-test" {
+		t.Errorf("Unexpected address %v", a.LocalAddr())
+	}
+
+	if err := a.Send(&cemi.LDataInd{LData: cemi.LData{Data: &cemi.AppData{}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := (<-b.Inbound()).(*cemi.LDataInd); !ok {
+		t.Error("Message was not delivered")
+	}
+
+	if _, err := Dial("bus://dial-test?rate=1"); err == nil {
+		t.Error("Unknown parameter was accepted")
+	}
+}
@@ -0,0 +1,152 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Package capture records KNX traffic to pcapng files and reads it back from pcap and pcapng
+// files.
+//
+// Captures written with LinkTypeRaw contain KNXnet/IP packets in IPv4/UDP datagrams, which Wireshark
+// dissects without further configuration. Captures written with LinkTypeCEMI contain the bare
+// cEMI frames. They use the first user link type, which Wireshark can be told to dissect as cEMI
+// in its DLT_USER preferences.
+package capture
+
+import (
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// LinkType identifies the encapsulation of the packets in a capture.
+type LinkType uint16
+
+const (
+	// LinkTypeEthernet is used for Ethernet frames.
+	LinkTypeEthernet LinkType = 1
+
+	// LinkTypeRaw is used for raw IPv4 or IPv6 packets.
+	LinkTypeRaw LinkType = 101
+
+	// LinkTypeLinuxSLL is used for frames captured on the Linux "any" device.
+	LinkTypeLinuxSLL LinkType = 113
+
+	// LinkTypeCEMI is used for bare cEMI frames. It is LINKTYPE_USER0.
+	LinkTypeCEMI LinkType = 147
+
+	// LinkTypeIPv4 is used for raw IPv4 packets.
+	LinkTypeIPv4 LinkType = 228
+
+	// LinkTypeIPv6 is used for raw IPv6 packets.
+	LinkTypeIPv6 LinkType = 229
+)
+
+// Additional info types which carry timestamps.
+const (
+	infoRelativeTimestamp         = 0x04
+	infoExtendedRelativeTimestamp = 0x06
+)
+
+// RelativeTimestampUnit is the duration of one tick of the 16-bit relative timestamp found in the
+// additional info of cEMI frames. The extended relative timestamp always counts microseconds.
+var RelativeTimestampUnit = time.Millisecond
+
+// messageInfo returns the additional info of the message, if it has any.
+func messageInfo(msg cemi.Message) cemi.Info {
+	var raw []byte
+
+	switch msg := msg.(type) {
+	case *cemi.LDataReq:
+		return msg.Info
+
+	case *cemi.LDataInd:
+		return msg.Info
+
+	case *cemi.LDataCon:
+		return msg.Info
+
+	case *cemi.LBusmonInd:
+		raw = *msg
+
+	case *cemi.LRawReq:
+		raw = msg.LRaw
+
+	case *cemi.LRawInd:
+		raw = msg.LRaw
+
+	case *cemi.LRawCon:
+		raw = msg.LRaw
+	}
+
+	var info cemi.Info
+	if _, err := info.Unpack(raw); err != nil {
+		return nil
+	}
+
+	return info
+}
+
+// relativeTimestamp finds the relative timestamp in the additional info. It returns the tick
+// count, the duration of a tick and the number of bits of the counter. The extended relative
+// timestamp is preferred, since it has a higher resolution.
+func relativeTimestamp(info cemi.Info) (ticks uint32, unit time.Duration, bits uint, ok bool) {
+	for len(info) >= 2 {
+		ty, length := info[0], int(info[1])
+		if len(info) < 2+length {
+			break
+		}
+
+		value := info[2 : 2+length]
+
+		switch {
+		case ty == infoExtendedRelativeTimestamp && length == 4:
+			ticks = uint32(value[0])<<24 | uint32(value[1])<<16 | uint32(value[2])<<8 | uint32(value[3])
+			return ticks, time.Microsecond, 32, true
+
+		case ty == infoRelativeTimestamp && length == 2 && !ok:
+			ticks = uint32(value[0])<<8 | uint32(value[1])
+			unit, bits, ok = RelativeTimestampUnit, 16, true
+		}
+
+		info = info[2+length:]
+	}
+
+	return
+}
+
+// A clock derives packet timestamps from the relative timestamps of the frames. The first frame
+// with a relative timestamp anchors the clock at the time it was received, later frames are
+// placed relative to it. Frames without relative timestamp keep the time they were received.
+type clock struct {
+	valid bool
+	unit  time.Duration
+	bits  uint
+	ticks uint32
+	last  time.Time
+}
+
+// timestamp determines the timestamp of a message which was received at the given time.
+func (c *clock) timestamp(received time.Time, msg cemi.Message) time.Time {
+	ticks, unit, bits, ok := relativeTimestamp(messageInfo(msg))
+	if !ok {
+		return received
+	}
+
+	if !c.valid || c.unit != unit || c.bits != bits {
+		*c = clock{valid: true, unit: unit, bits: bits, ticks: ticks, last: received}
+		return received
+	}
+
+	// The counter wraps around, the difference modulo its range is the elapsed time.
+	delta := ticks - c.ticks
+	if bits < 32 {
+		delta &= 1<<bits - 1
+	}
+
+	c.ticks = ticks
+	c.last = c.last.Add(time.Duration(delta) * unit)
+
+	// Keep the clock from drifting too far away from the wall clock, e.g. after a reconnect.
+	if diff := received.Sub(c.last); diff > time.Second || diff < -time.Second {
+		c.last = received
+	}
+
+	return c.last
+}
@@ -0,0 +1,166 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+)
+
+func makeMessage(info cemi.Info) *cemi.LDataInd {
+	return &cemi.LDataInd{LData: cemi.LData{
+		Info:        info,
+		Control1:    cemi.Control1StdFrame | cemi.Control1NoRepeat | cemi.Control1NoSysBroadcast,
+		Control2:    cemi.Control2GroupAddr | cemi.Control2Hops(6),
+		Source:      cemi.NewIndividualAddr3(1, 1, 5),
+		Destination: uint16(cemi.NewGroupAddr3(1, 2, 3)),
+		Data:        &cemi.AppData{Command: cemi.GroupValueWrite, Data: []byte{0, 0x0C, 0x1A}},
+	}}
+}
+
+func readAll(t *testing.T, data []byte) []*Packet {
+	reader, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var packets []*Packet
+
+	for {
+		pkt, err := reader.Next()
+		if err == io.EOF {
+			return packets
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		packets = append(packets, pkt)
+	}
+}
+
+func TestWriter_roundTrip(t *testing.T) {
+	start := time.Date(2026, 10, 19, 12, 0, 0, 123456789, time.UTC)
+
+	for _, linkType := range []LinkType{LinkTypeRaw, LinkTypeCEMI} {
+		buffer := &bytes.Buffer{}
+
+		writer, err := NewWriter(buffer, linkType)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msg := makeMessage(nil)
+
+		if err := writer.WriteMessage(start, msg); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := writer.WriteService(start.Add(time.Second), &knxnet.TunnelReq{Channel: 1, Payload: msg}); err != nil {
+			t.Fatal(err)
+		}
+
+		packets := readAll(t, buffer.Bytes())
+		if len(packets) != 2 {
+			t.Fatalf("%v: got %d packets", linkType, len(packets))
+		}
+
+		for i, pkt := range packets {
+			if !pkt.Time.Equal(start.Add(time.Duration(i) * time.Second)) {
+				t.Errorf("%v: unexpected time %v", linkType, pkt.Time)
+			}
+
+			if !reflect.DeepEqual(pkt.Message, msg) {
+				t.Errorf("%v: unexpected message %+v", linkType, pkt.Message)
+			}
+		}
+
+		if linkType == LinkTypeRaw {
+			if _, ok := packets[0].Service.(*knxnet.RoutingInd); !ok {
+				t.Errorf("Unexpected service %T", packets[0].Service)
+			}
+
+			if req, ok := packets[1].Service.(*knxnet.TunnelReq); !ok || req.Channel != 1 {
+				t.Errorf("Unexpected service %+v", packets[1].Service)
+			}
+		} else if packets[0].Service != nil {
+			t.Errorf("Unexpected service %+v", packets[0].Service)
+		}
+	}
+
+	if _, err := NewWriter(io.Discard, LinkTypeEthernet); err == nil {
+		t.Error("Link type was accepted")
+	}
+}
+
+func TestClock(t *testing.T) {
+	received := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	var c clock
+
+	cases := []struct {
+		received time.Duration
+		info     cemi.Info
+		expected time.Duration
+	}{
+		{0, cemi.Info{0x04, 2, 0xFF, 0xF0}, 0},
+		// Reception jitter is replaced by the timestamp, the counter wraps around.
+		{50 * time.Millisecond, cemi.Info{0x04, 2, 0x00, 0x10}, 32 * time.Millisecond},
+		{100 * time.Millisecond, nil, 100 * time.Millisecond},
+		// Too far from the wall clock, the clock is reset.
+		{10 * time.Second, cemi.Info{0x04, 2, 0x00, 0x20}, 10 * time.Second},
+		// The extended timestamp takes precedence and counts microseconds.
+		{11 * time.Second, cemi.Info{0x04, 2, 0, 0, 0x06, 4, 0, 0, 0, 1}, 11 * time.Second},
+		{12 * time.Second, cemi.Info{0x06, 4, 0, 0x0F, 0x42, 0x42}, 12*time.Second + time.Microsecond},
+	}
+
+	for i, tc := range cases {
+		got := c.timestamp(received.Add(tc.received), makeMessage(tc.info))
+		if !got.Equal(received.Add(tc.expected)) {
+			t.Errorf("Case %d: got %v", i, got.Sub(received))
+		}
+	}
+}
+
+func TestReader_pcap(t *testing.T) {
+	// A big-endian pcap file with one Ethernet frame carrying a routing indication.
+	payload := knxnet.AllocAndPack(&knxnet.RoutingInd{Payload: makeMessage(nil)})
+
+	writer := &Writer{Source: DefaultSource, Destination: DefaultDestination}
+
+	datagram, err := writer.datagram(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame := append(make([]byte, 12), 0x08, 0x00)
+	frame = append(frame, datagram...)
+
+	file := &bytes.Buffer{}
+	binary.Write(file, binary.BigEndian, []uint32{pcapMagicMicroseconds, 0x00020004, 0, 0, 65535, uint32(LinkTypeEthernet)})
+	binary.Write(file, binary.BigEndian, []uint32{1000, 250000, uint32(len(frame)), uint32(len(frame))})
+	file.Write(frame)
+
+	// Traffic other than KNX is skipped.
+	binary.Write(file, binary.BigEndian, []uint32{1001, 0, 14, 14})
+	file.Write(make([]byte, 14))
+
+	packets := readAll(t, file.Bytes())
+	if len(packets) != 1 {
+		t.Fatalf("Got %d packets", len(packets))
+	}
+
+	if !packets[0].Time.Equal(time.Unix(1000, 250000000)) || !reflect.DeepEqual(packets[0].Message, makeMessage(nil)) {
+		t.Errorf("Unexpected packet %+v", packets[0])
+	}
+
+	if _, err := NewReader(bytes.NewReader(make([]byte, 24))); err == nil {
+		t.Error("Invalid file was accepted")
+	}
+}
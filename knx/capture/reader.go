@@ -0,0 +1,422 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+)
+
+// pcap file magic numbers
+const (
+	pcapMagicMicroseconds = 0xA1B2C3D4
+	pcapMagicNanoseconds  = 0xA1B23C4D
+)
+
+// A Packet is a KNX packet read from a capture.
+type Packet struct {
+	// Time at which the packet was captured
+	Time time.Time
+
+	// Link type of the interface on which the packet was captured
+	LinkType LinkType
+
+	// Data as captured, including the link-layer headers
+	Data []byte
+
+	// Service is the KNXnet/IP packet. It is nil for captures of bare cEMI frames.
+	Service knxnet.Service
+
+	// Message is the cEMI frame, if there is one.
+	Message cemi.Message
+}
+
+// pcapInterface describes an interface of a capture.
+type pcapInterface struct {
+	linkType LinkType
+
+	// Number of timestamp units per second
+	unitsPerSecond uint64
+}
+
+// timestamp converts the timestamp units of the interface to a time.
+func (ifc *pcapInterface) timestamp(units uint64) time.Time {
+	fraction := units % ifc.unitsPerSecond
+	nanos := fraction * uint64(time.Second) / ifc.unitsPerSecond
+
+	// Avoid overflows for resolutions finer than nanoseconds.
+	if ifc.unitsPerSecond > uint64(time.Second) {
+		nanos = fraction / (ifc.unitsPerSecond / uint64(time.Second))
+	}
+
+	return time.Unix(int64(units/ifc.unitsPerSecond), int64(nanos))
+}
+
+// A Reader reads KNX packets from a pcap or pcapng file. Packets which do not contain KNX traffic
+// are skipped.
+type Reader struct {
+	r          *bufio.Reader
+	ng         bool
+	order      binary.ByteOrder
+	interfaces []pcapInterface
+}
+
+var errFormat = errors.New("file is neither pcap nor pcapng")
+
+// NewReader reads the file header and determines the format of the capture.
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{r: bufio.NewReader(r)}
+
+	header, err := reader.r.Peek(4)
+	if err != nil {
+		return nil, err
+	}
+
+	if binary.LittleEndian.Uint32(header) == blockSectionHeader {
+		reader.ng = true
+		return reader, nil
+	}
+
+	global := make([]byte, 24)
+	if _, err := io.ReadFull(reader.r, global); err != nil {
+		return nil, err
+	}
+
+	ifc := pcapInterface{}
+
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(global) {
+		case pcapMagicMicroseconds:
+			ifc.unitsPerSecond = 1e6
+
+		case pcapMagicNanoseconds:
+			ifc.unitsPerSecond = 1e9
+
+		default:
+			continue
+		}
+
+		reader.order = order
+		ifc.linkType = LinkType(order.Uint32(global[20:]))
+		reader.interfaces = []pcapInterface{ifc}
+
+		return reader, nil
+	}
+
+	return nil, errFormat
+}
+
+// Next returns the next KNX packet. It returns io.EOF at the end of the capture.
+func (reader *Reader) Next() (*Packet, error) {
+	for {
+		var (
+			pkt *Packet
+			err error
+		)
+
+		if reader.ng {
+			pkt, err = reader.nextBlock()
+		} else {
+			pkt, err = reader.nextRecord()
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if pkt != nil && decode(pkt) {
+			return pkt, nil
+		}
+	}
+}
+
+// nextRecord reads a packet record from a pcap file.
+func (reader *Reader) nextRecord() (*Packet, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(reader.r, header); err != nil {
+		return nil, err
+	}
+
+	ifc := &reader.interfaces[0]
+	seconds := reader.order.Uint32(header[0:])
+	fraction := reader.order.Uint32(header[4:])
+	length := reader.order.Uint32(header[8:])
+
+	if length > 1<<24 {
+		return nil, fmt.Errorf("packet length %d is too large", length)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(reader.r, data); err != nil {
+		return nil, unexpected(err)
+	}
+
+	return &Packet{
+		Time:     ifc.timestamp(uint64(seconds)*ifc.unitsPerSecond + uint64(fraction)),
+		LinkType: ifc.linkType,
+		Data:     data,
+	}, nil
+}
+
+// unexpected turns io.EOF into io.ErrUnexpectedEOF.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// nextBlock reads a block from a pcapng file. It returns a nil packet for blocks which do not
+// contain packets.
+func (reader *Reader) nextBlock() (*Packet, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(reader.r, header[:8]); err != nil {
+		return nil, err
+	}
+
+	blockType := binary.LittleEndian.Uint32(header)
+
+	if blockType == blockSectionHeader {
+		// The byte order of the section is given by the magic number.
+		if _, err := io.ReadFull(reader.r, header[8:]); err != nil {
+			return nil, unexpected(err)
+		}
+
+		switch binary.LittleEndian.Uint32(header[8:]) {
+		case byteOrderMagic:
+			reader.order = binary.LittleEndian
+
+		case 0x4D3C2B1A:
+			reader.order = binary.BigEndian
+
+		default:
+			return nil, errFormat
+		}
+
+		reader.interfaces = nil
+	} else if reader.order == nil {
+		return nil, errFormat
+	} else {
+		blockType = reader.order.Uint32(header)
+	}
+
+	length := reader.order.Uint32(header[4:])
+	if length < 12 || length%4 != 0 || length > 1<<24 {
+		return nil, fmt.Errorf("block length %d is invalid", length)
+	}
+
+	body := make([]byte, length-8)
+	read := body
+	if blockType == blockSectionHeader {
+		read = body[4:]
+	}
+
+	if _, err := io.ReadFull(reader.r, read); err != nil {
+		return nil, unexpected(err)
+	}
+
+	// Strip the trailing length.
+	body = body[:len(body)-4]
+
+	switch blockType {
+	case blockInterface:
+		return nil, reader.addInterface(body)
+
+	case blockEnhancedPacket:
+		if len(body) < 20 {
+			return nil, errors.New("enhanced packet block is too short")
+		}
+
+		id := reader.order.Uint32(body)
+		if id >= uint32(len(reader.interfaces)) {
+			return nil, fmt.Errorf("interface %d is not defined", id)
+		}
+
+		ifc := &reader.interfaces[id]
+		units := uint64(reader.order.Uint32(body[4:]))<<32 | uint64(reader.order.Uint32(body[8:]))
+		captured := reader.order.Uint32(body[12:])
+
+		if uint32(len(body)-20) < captured {
+			return nil, errors.New("enhanced packet block is too short")
+		}
+
+		return &Packet{
+			Time:     ifc.timestamp(units),
+			LinkType: ifc.linkType,
+			Data:     body[20 : 20+captured],
+		}, nil
+
+	case blockSimplePacket:
+		if len(body) < 4 || len(reader.interfaces) == 0 {
+			return nil, errors.New("simple packet block is invalid")
+		}
+
+		captured := reader.order.Uint32(body)
+		if rest := uint32(len(body) - 4); rest < captured {
+			captured = rest
+		}
+
+		return &Packet{
+			LinkType: reader.interfaces[0].linkType,
+			Data:     body[4 : 4+captured],
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// addInterface parses an Interface Description Block.
+func (reader *Reader) addInterface(body []byte) error {
+	if len(body) < 8 {
+		return errors.New("interface description block is too short")
+	}
+
+	// The default resolution is microseconds.
+	ifc := pcapInterface{
+		linkType:       LinkType(reader.order.Uint16(body)),
+		unitsPerSecond: 1e6,
+	}
+
+	options := body[8:]
+
+	for len(options) >= 4 {
+		code := reader.order.Uint16(options)
+		length := int(reader.order.Uint16(options[2:]))
+
+		if code == optionEndOfOptions || len(options) < 4+length {
+			break
+		}
+
+		if code == optionTimestampFormat && length == 1 {
+			value := options[4]
+
+			// The resolution is a negative power of 2 or 10.
+			if value&0x80 != 0 && value&0x7F < 64 {
+				ifc.unitsPerSecond = 1 << (value & 0x7F)
+			} else if value < 20 {
+				ifc.unitsPerSecond = 1
+				for i := uint8(0); i < value; i++ {
+					ifc.unitsPerSecond *= 10
+				}
+			}
+		}
+
+		options = options[4+(length+3)&^3:]
+	}
+
+	reader.interfaces = append(reader.interfaces, ifc)
+
+	return nil
+}
+
+// decode fills in the service and message of the packet. It returns false if the packet does not
+// contain KNX traffic.
+func decode(pkt *Packet) bool {
+	data := pkt.Data
+
+	switch pkt.LinkType {
+	case LinkTypeCEMI:
+		_, err := cemi.Unpack(data, &pkt.Message)
+		return err == nil
+
+	case LinkTypeEthernet:
+		if len(data) < 14 {
+			return false
+		}
+
+		etherType := binary.BigEndian.Uint16(data[12:])
+		data = data[14:]
+
+		// Skip VLAN tags.
+		for (etherType == 0x8100 || etherType == 0x88A8) && len(data) >= 4 {
+			etherType = binary.BigEndian.Uint16(data[2:])
+			data = data[4:]
+		}
+
+		if etherType != 0x0800 && etherType != 0x86DD {
+			return false
+		}
+
+	case LinkTypeLinuxSLL:
+		if len(data) < 16 {
+			return false
+		}
+
+		data = data[16:]
+
+	case LinkTypeRaw, LinkTypeIPv4, LinkTypeIPv6:
+
+	default:
+		return false
+	}
+
+	payload, ok := udpPayload(data)
+	if !ok {
+		return false
+	}
+
+	if _, err := knxnet.Unpack(payload, &pkt.Service); err != nil {
+		return false
+	}
+
+	switch srv := pkt.Service.(type) {
+	case *knxnet.TunnelReq:
+		pkt.Message = srv.Payload
+
+	case *knxnet.RoutingInd:
+		pkt.Message = srv.Payload
+	}
+
+	return true
+}
+
+// udpPayload extracts the payload of an IPv4 or IPv6 packet carrying a UDP datagram.
+func udpPayload(data []byte) ([]byte, bool) {
+	if len(data) < 1 {
+		return nil, false
+	}
+
+	switch data[0] >> 4 {
+	case 4:
+		headerLen := int(data[0]&0x0F) * 4
+		if headerLen < 20 || len(data) < headerLen || data[9] != 17 {
+			return nil, false
+		}
+
+		// Fragments other than the first do not start with a UDP header.
+		if binary.BigEndian.Uint16(data[6:])&0x1FFF != 0 {
+			return nil, false
+		}
+
+		data = data[headerLen:]
+
+	case 6:
+		if len(data) < 40 || data[6] != 17 {
+			return nil, false
+		}
+
+		data = data[40:]
+
+	default:
+		return nil, false
+	}
+
+	if len(data) < 8 {
+		return nil, false
+	}
+
+	length := int(binary.BigEndian.Uint16(data[4:]))
+	if length < 8 || length > len(data) {
+		return nil, false
+	}
+
+	return data[8:length], true
+}
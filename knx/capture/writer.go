@@ -0,0 +1,232 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package capture
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+)
+
+// pcapng block types
+const (
+	blockSectionHeader    = 0x0A0D0D0A
+	blockInterface        = 0x00000001
+	blockSimplePacket     = 0x00000003
+	blockEnhancedPacket   = 0x00000006
+	byteOrderMagic        = 0x1A2B3C4D
+	optionEndOfOptions    = 0
+	optionTimestampFormat = 9
+)
+
+// snapLength is the maximum packet size announced in the interface description.
+const snapLength = 65535
+
+// Default endpoints of the datagrams written with LinkTypeRaw
+var (
+	DefaultSource      = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 3671}
+	DefaultDestination = &net.UDPAddr{IP: net.IPv4(224, 0, 23, 12), Port: 3671}
+)
+
+var errLinkType = errors.New("link type is not supported for writing")
+
+// A Writer writes packets to a pcapng file.
+type Writer struct {
+	// Source and Destination are the endpoints of the IPv4/UDP datagrams which carry the
+	// KNXnet/IP packets, if the link type is LinkTypeRaw.
+	Source      *net.UDPAddr
+	Destination *net.UDPAddr
+
+	mu       sync.Mutex
+	w        io.Writer
+	linkType LinkType
+	clock    clock
+}
+
+// NewWriter writes the pcapng file header. The link type must be LinkTypeRaw or LinkTypeCEMI.
+func NewWriter(w io.Writer, linkType LinkType) (*Writer, error) {
+	if linkType != LinkTypeRaw && linkType != LinkTypeCEMI {
+		return nil, errLinkType
+	}
+
+	writer := &Writer{
+		Source:      DefaultSource,
+		Destination: DefaultDestination,
+		w:           w,
+		linkType:    linkType,
+	}
+
+	shb := make([]byte, 16)
+	binary.LittleEndian.PutUint32(shb[0:], byteOrderMagic)
+	binary.LittleEndian.PutUint16(shb[4:], 1)
+	binary.LittleEndian.PutUint16(shb[6:], 0)
+	binary.LittleEndian.PutUint64(shb[8:], ^uint64(0))
+
+	if err := writer.writeBlock(blockSectionHeader, shb); err != nil {
+		return nil, err
+	}
+
+	// Timestamps are given in nanoseconds.
+	idb := make([]byte, 20)
+	binary.LittleEndian.PutUint16(idb[0:], uint16(linkType))
+	binary.LittleEndian.PutUint32(idb[4:], snapLength)
+	binary.LittleEndian.PutUint16(idb[8:], optionTimestampFormat)
+	binary.LittleEndian.PutUint16(idb[10:], 1)
+	idb[12] = 9
+	binary.LittleEndian.PutUint16(idb[16:], optionEndOfOptions)
+
+	if err := writer.writeBlock(blockInterface, idb); err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+// LinkType returns the link type of the capture.
+func (writer *Writer) LinkType() LinkType {
+	return writer.linkType
+}
+
+// writeBlock writes a pcapng block with the given body, which must be padded to 32 bits.
+func (writer *Writer) writeBlock(blockType uint32, body []byte) error {
+	length := uint32(12 + len(body))
+
+	block := make([]byte, length)
+	binary.LittleEndian.PutUint32(block[0:], blockType)
+	binary.LittleEndian.PutUint32(block[4:], length)
+	copy(block[8:], body)
+	binary.LittleEndian.PutUint32(block[length-4:], length)
+
+	_, err := writer.w.Write(block)
+	return err
+}
+
+// writePacket writes an Enhanced Packet Block.
+func (writer *Writer) writePacket(t time.Time, data []byte) error {
+	padded := (len(data) + 3) &^ 3
+
+	epb := make([]byte, 20+padded)
+	ts := uint64(t.UnixNano())
+	binary.LittleEndian.PutUint32(epb[4:], uint32(ts>>32))
+	binary.LittleEndian.PutUint32(epb[8:], uint32(ts))
+	binary.LittleEndian.PutUint32(epb[12:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(epb[16:], uint32(len(data)))
+	copy(epb[20:], data)
+
+	return writer.writeBlock(blockEnhancedPacket, epb)
+}
+
+// checksum computes the internet checksum of the data.
+func checksum(data []byte) uint16 {
+	var sum uint32
+
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(data[i])<<8 | uint32(data[i+1])
+	}
+
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+
+	for sum > 0xFFFF {
+		sum = sum&0xFFFF + sum>>16
+	}
+
+	return ^uint16(sum)
+}
+
+// datagram wraps the payload in an IPv4/UDP datagram from the source to the destination.
+func (writer *Writer) datagram(payload []byte) ([]byte, error) {
+	src, dst := writer.Source.IP.To4(), writer.Destination.IP.To4()
+	if src == nil || dst == nil {
+		return nil, fmt.Errorf("endpoints %v and %v are not IPv4 addresses", writer.Source, writer.Destination)
+	}
+
+	packet := make([]byte, 28+len(payload))
+
+	// IPv4 header without options, the Don't Fragment flag is set.
+	packet[0] = 0x45
+	binary.BigEndian.PutUint16(packet[2:], uint16(len(packet)))
+	packet[6] = 0x40
+	packet[8] = 64
+	packet[9] = 17
+	copy(packet[12:], src)
+	copy(packet[16:], dst)
+	binary.BigEndian.PutUint16(packet[10:], checksum(packet[:20]))
+
+	// UDP header, a zero checksum means that there is none.
+	binary.BigEndian.PutUint16(packet[20:], uint16(writer.Source.Port))
+	binary.BigEndian.PutUint16(packet[22:], uint16(writer.Destination.Port))
+	binary.BigEndian.PutUint16(packet[24:], uint16(8+len(payload)))
+	copy(packet[28:], payload)
+
+	return packet, nil
+}
+
+// WriteService writes a KNXnet/IP packet which was received at the given time. Captures with link
+// type LinkTypeCEMI only take tunnel requests and routing indications, of which the cEMI frame is
+// written.
+func (writer *Writer) WriteService(t time.Time, srv knxnet.ServicePackable) error {
+	if writer.linkType == LinkTypeCEMI {
+		switch srv := srv.(type) {
+		case *knxnet.TunnelReq:
+			return writer.WriteMessage(t, srv.Payload)
+
+		case *knxnet.RoutingInd:
+			return writer.WriteMessage(t, srv.Payload)
+		}
+
+		return fmt.Errorf("service %v does not carry a cEMI frame", srv.Service())
+	}
+
+	packet, err := writer.datagram(knxnet.AllocAndPack(srv))
+	if err != nil {
+		return err
+	}
+
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+
+	return writer.writePacket(t, packet)
+}
+
+// WriteMessage writes a cEMI frame which was received at the given time. If the frame carries a
+// relative timestamp in its additional info, the timestamp is used to place it relative to the
+// previous frames. Captures with link type LinkTypeRaw carry the frame in a routing indication.
+func (writer *Writer) WriteMessage(t time.Time, msg cemi.Message) error {
+	var data []byte
+
+	if writer.linkType == LinkTypeCEMI {
+		data = make([]byte, cemi.Size(msg))
+		cemi.Pack(data, msg)
+	} else {
+		var err error
+		if data, err = writer.datagram(knxnet.AllocAndPack(&knxnet.RoutingInd{Payload: msg})); err != nil {
+			return err
+		}
+	}
+
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+
+	return writer.writePacket(writer.clock.timestamp(t, msg), data)
+}
+
+// Record writes the frames from the channel until it is closed. Use it with the Inbound channel of
+// a Tunnel or Router.
+func Record(writer *Writer, inbound <-chan cemi.Message) error {
+	for msg := range inbound {
+		if err := writer.WriteMessage(time.Now(), msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,21 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package cemi
+
+import (
+	"testing"
+)
+
+func TestControlField2_Hops(t *testing.T) {
+	for hops := uint8(0); hops < 8; hops++ {
+		ctrl2 := Control2GroupAddr | Control2Hops(hops)
+
+		if ctrl2.Hops() != hops {
+			t.Errorf("Expected %d hops, got %d", hops, ctrl2.Hops())
+		}
+
+		if !ctrl2.IsGroupAddr() {
+			t.Errorf("Hop count %d clobbered the address type", hops)
+		}
+	}
+}
@@ -3,6 +3,11 @@
 
 package cemi
 
+import (
+	"errors"
+	"io"
+)
+
 // A LBusmonInd represents a L_Busmon.ind message.
 type LBusmonInd []byte
 
@@ -34,3 +39,61 @@ func (lbm *LBusmonInd) Unpack(data []byte) (n uint, err error) {
 
 	return
 }
+
+// Frame extracts the link-layer data from the TP1 frame carried by the message. The additional
+// information of the message is placed in the Info field of the result.
+func (lbm LBusmonInd) Frame() (*LData, error) {
+	var info Info
+
+	n, err := info.Unpack(lbm)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := []byte(lbm[n:])
+
+	// The last octet is a checksum, such that all octets XOR to 0xFF.
+	var check byte
+	for _, octet := range frame {
+		check ^= octet
+	}
+
+	var header []byte
+
+	switch {
+	case len(frame) < 8:
+		// Acknowledgements and polling frames do not carry any data.
+		return nil, errors.New("frame does not contain link-layer data")
+
+	case check != 0xFF:
+		return nil, errors.New("frame has an invalid checksum")
+
+	case frame[0]&0x80 != 0:
+		// Standard frame: Control, Source, Destination, address type, hop count and length
+		header = []byte{
+			frame[0], frame[5] & 0xF0, frame[1], frame[2], frame[3], frame[4], frame[5] & 0x0F,
+		}
+		frame = frame[6:]
+
+	case len(frame) < 9:
+		return nil, io.ErrUnexpectedEOF
+
+	default:
+		// Extended frame: Control, extended control, Source, Destination and length
+		header = append([]byte(nil), frame[:7]...)
+		frame = frame[7:]
+	}
+
+	// The TP1 control fields have the same layout as those of CEMI. Prepending empty additional
+	// information and stripping the checksum yields a CEMI L_Data body.
+	body := append(append([]byte{0}, header...), frame[:len(frame)-1]...)
+
+	ldata := &LData{}
+	if _, err := ldata.Unpack(body); err != nil {
+		return nil, err
+	}
+
+	ldata.Info = info
+
+	return ldata, nil
+}
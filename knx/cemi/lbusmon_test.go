@@ -0,0 +1,59 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package cemi
+
+import (
+	"bytes"
+	"testing"
+)
+
+// makeTP1Frame appends the checksum to the frame and prepends additional information.
+func makeTP1Frame(info []byte, frame ...byte) LBusmonInd {
+	check := byte(0xFF)
+	for _, octet := range frame {
+		check ^= octet
+	}
+
+	return LBusmonInd(append(append([]byte{byte(len(info))}, info...), append(frame, check)...))
+}
+
+func TestLBusmonInd_Frame(t *testing.T) {
+	frames := map[string]LBusmonInd{
+		"Standard": makeTP1Frame([]byte{3, 2, 0, 1}, 0xBC, 0x11, 0x05, 0x08, 0x01, 0xE1, 0x00, 0x81),
+		"Extended": makeTP1Frame(nil, 0x3C, 0xE0, 0x11, 0x05, 0x08, 0x01, 0x01, 0x00, 0x81),
+	}
+
+	for name, frame := range frames {
+		ldata, err := frame.Frame()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		if ldata.Source != NewIndividualAddr3(1, 1, 5) ||
+			GroupAddr(ldata.Destination) != NewGroupAddr3(1, 0, 1) ||
+			!ldata.Control2.IsGroupAddr() {
+			t.Errorf("%s: unexpected frame %+v", name, ldata)
+		}
+
+		app, ok := ldata.Data.(*AppData)
+		if !ok || app.Command != GroupValueWrite || !bytes.Equal(app.Data, []byte{1}) {
+			t.Errorf("%s: unexpected data %+v", name, ldata.Data)
+		}
+	}
+
+	if ldata, _ := frames["Standard"].Frame(); !bytes.Equal(ldata.Info, []byte{3, 2, 0, 1}) {
+		t.Errorf("Unexpected additional information %v", ldata.Info)
+	}
+
+	invalid := map[string]LBusmonInd{
+		"Acknowledgement": makeTP1Frame(nil, 0xCC),
+		"Checksum":        append(makeTP1Frame(nil, 0xBC, 0x11, 0x05, 0x08, 0x01, 0xE1, 0x00, 0x81)[:9], 0),
+	}
+
+	for name, frame := range invalid {
+		if _, err := frame.Frame(); err == nil {
+			t.Errorf("%s: frame was accepted", name)
+		}
+	}
+}
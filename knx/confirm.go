@@ -0,0 +1,145 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// ErrNegativeConfirmation is returned if the gateway reports that it could not transmit a frame on
+// the bus.
+var ErrNegativeConfirmation = errors.New("gateway could not transmit the frame")
+
+// A GroupConfirmer is a GroupClient which can wait for the gateway to confirm that a group
+// communication has been transmitted on the bus.
+type GroupConfirmer interface {
+	GroupClient
+
+	// SendConfirmed sends the event and waits for its L_Data.con. It fails with
+	// ErrNegativeConfirmation if the gateway could not transmit the frame, or with the error of the
+	// context if the confirmation does not arrive in time.
+	SendConfirmed(ctx context.Context, event GroupEvent) error
+}
+
+// A confirmationWaiter is a sender waiting for the confirmation of its frame.
+type confirmationWaiter struct {
+	command GroupCommand
+	data    []byte
+	result  chan error
+}
+
+// matches determines whether the confirmed frame is the one the sender is waiting for.
+func (waiter *confirmationWaiter) matches(app *cemi.AppData) bool {
+	return GroupCommand(app.Command) == waiter.command && bytes.Equal(app.Data, waiter.data)
+}
+
+// confirmations matches L_Data.con frames with the senders waiting for them. Gateways confirm
+// requests in the order they were sent, so the oldest sender for a destination is served first.
+// Confirmations must also carry the same command and data, which keeps a late confirmation for a
+// sender that gave up from reaching a sender of a different frame.
+type confirmations struct {
+	mu      sync.Mutex
+	waiting map[cemi.GroupAddr][]*confirmationWaiter
+}
+
+// expect registers a sender waiting for the confirmation of the event. The returned function
+// unregisters it again.
+func (confs *confirmations) expect(event GroupEvent) (<-chan error, func()) {
+	confs.mu.Lock()
+	defer confs.mu.Unlock()
+
+	if confs.waiting == nil {
+		confs.waiting = make(map[cemi.GroupAddr][]*confirmationWaiter)
+	}
+
+	addr := event.Destination
+	waiter := &confirmationWaiter{
+		command: event.Command,
+		data:    event.Data,
+		result:  make(chan error, 1),
+	}
+	confs.waiting[addr] = append(confs.waiting[addr], waiter)
+
+	cancel := func() {
+		confs.mu.Lock()
+		defer confs.mu.Unlock()
+
+		confs.remove(addr, waiter)
+	}
+
+	return waiter.result, cancel
+}
+
+// remove unregisters the waiter. The caller must hold the lock.
+func (confs *confirmations) remove(addr cemi.GroupAddr, waiter *confirmationWaiter) {
+	waiting := confs.waiting[addr]
+	for i, other := range waiting {
+		if other == waiter {
+			waiting = append(waiting[:i:i], waiting[i+1:]...)
+			break
+		}
+	}
+
+	if len(waiting) == 0 {
+		delete(confs.waiting, addr)
+	} else {
+		confs.waiting[addr] = waiting
+	}
+}
+
+// confirm passes the outcome of the confirmation to the oldest sender waiting for it.
+func (confs *confirmations) confirm(con *cemi.LDataCon) {
+	app, ok := con.Data.(*cemi.AppData)
+	if !ok {
+		return
+	}
+
+	addr := cemi.GroupAddr(con.Destination)
+
+	confs.mu.Lock()
+	defer confs.mu.Unlock()
+
+	for _, waiter := range confs.waiting[addr] {
+		if !waiter.matches(app) {
+			continue
+		}
+
+		var err error
+		if con.Control1&cemi.Control1HasError != 0 {
+			err = ErrNegativeConfirmation
+		}
+
+		waiter.result <- err
+		confs.remove(addr, waiter)
+
+		return
+	}
+}
+
+// send sends the event using the given function and waits for its confirmation.
+func (confs *confirmations) send(
+	ctx context.Context,
+	event GroupEvent,
+	send func(GroupEvent) error,
+) error {
+	// Register before sending, so that the confirmation cannot be missed.
+	result, cancel := confs.expect(event)
+	defer cancel()
+
+	if err := send(event); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-result:
+		return err
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,88 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+func TestConfirmations(t *testing.T) {
+	inbound := make(chan cemi.Message)
+	outbound := make(chan GroupEvent)
+	confs := &confirmations{}
+
+	go serveGroupInbound(inbound, outbound, confs)
+	defer close(inbound)
+
+	addr := cemi.NewGroupAddr3(1, 2, 3)
+
+	confirm := func(fail bool) func(GroupEvent) error {
+		return func(event GroupEvent) error {
+			ldata := buildGroupOutbound(event, cemi.PrioLow)
+			if fail {
+				ldata.Control1 |= cemi.Control1HasError
+			}
+
+			go func() { inbound <- &cemi.LDataCon{LData: ldata} }()
+			return nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	t.Run("Positive", func(t *testing.T) {
+		if err := confs.send(ctx, GroupEvent{Command: GroupWrite, Destination: addr}, confirm(false)); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Negative", func(t *testing.T) {
+		err := confs.send(ctx, GroupEvent{Command: GroupWrite, Destination: addr}, confirm(true))
+		if err != ErrNegativeConfirmation {
+			t.Errorf("Expected negative confirmation, got %v", err)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := confs.send(ctx, GroupEvent{Command: GroupWrite, Destination: addr}, func(GroupEvent) error { return nil })
+		if err != context.DeadlineExceeded {
+			t.Errorf("Expected deadline to be exceeded, got %v", err)
+		}
+
+		if len(confs.waiting) != 0 {
+			t.Error("Sender is still waiting")
+		}
+	})
+
+	// The confirmation for a sender which gave up does not reach the next one.
+	t.Run("Late", func(t *testing.T) {
+		gaveUp := GroupEvent{Command: GroupWrite, Destination: addr, Data: []byte{1}}
+		late := buildGroupOutbound(gaveUp, cemi.PrioLow)
+
+		send := func(event GroupEvent) error {
+			ldata := buildGroupOutbound(event, cemi.PrioLow)
+			ldata.Control1 |= cemi.Control1HasError
+
+			go func() {
+				inbound <- &cemi.LDataCon{LData: late}
+				inbound <- &cemi.LDataCon{LData: ldata}
+			}()
+
+			return nil
+		}
+
+		event := GroupEvent{Command: GroupWrite, Destination: addr, Data: []byte{2}}
+
+		if err := confs.send(ctx, event, send); err != ErrNegativeConfirmation {
+			t.Errorf("Expected negative confirmation, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,535 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// A GroupFilterTable lists the group addresses which a coupler passes.
+type GroupFilterTable struct {
+	addrs map[cemi.GroupAddr]struct{}
+}
+
+// NewGroupFilterTable creates a filter table containing the given addresses.
+func NewGroupFilterTable(addrs ...cemi.GroupAddr) *GroupFilterTable {
+	table := &GroupFilterTable{addrs: map[cemi.GroupAddr]struct{}{}}
+	table.Add(addrs...)
+
+	return table
+}
+
+// Add adds the addresses to the table.
+func (table *GroupFilterTable) Add(addrs ...cemi.GroupAddr) {
+	for _, addr := range addrs {
+		table.addrs[addr] = struct{}{}
+	}
+}
+
+// Contains determines whether the address is in the table.
+func (table *GroupFilterTable) Contains(addr cemi.GroupAddr) bool {
+	_, ok := table.addrs[addr]
+	return ok
+}
+
+// Len returns the number of addresses in the table.
+func (table *GroupFilterTable) Len() int {
+	return len(table.addrs)
+}
+
+// A CouplerLine is a sub line which a Coupler connects to the main line.
+type CouplerLine struct {
+	// Address is the individual address of the coupler, e.g. 1.1.0. Its area and line determine
+	// which individual addresses are located on the line.
+	Address cemi.IndividualAddr
+
+	// Transport connects to the line, e.g. a Tunnel or a BusConn.
+	Transport Transport
+
+	// Filter is the group address filter table of the line. Group telegrams only pass the coupler
+	// if their destination is in the table, or if they are broadcasts. If it is nil, all group
+	// telegrams pass.
+	Filter *GroupFilterTable
+}
+
+// A CouplerConfig determines certain properties of a Coupler.
+type CouplerConfig struct {
+	// Repetitions is the number of times a frame is repeated if it could not be sent, or if a
+	// tunnel does not confirm it.
+	Repetitions int
+
+	// ConfirmTimeout is how long to wait for the L_Data.con of a frame sent through a tunnel.
+	ConfirmTimeout time.Duration
+
+	// DuplicateWindow is the time during which a repeated frame is discarded if the original frame
+	// has already been forwarded.
+	DuplicateWindow time.Duration
+}
+
+// DefaultCouplerConfig is a good default configuration for a Coupler.
+var DefaultCouplerConfig = CouplerConfig{
+	Repetitions:     3,
+	ConfirmTimeout:  3 * time.Second,
+	DuplicateWindow: time.Second,
+}
+
+// checkCouplerConfig validates the given CouplerConfig.
+func checkCouplerConfig(config CouplerConfig) CouplerConfig {
+	if config.Repetitions < 0 {
+		config.Repetitions = 0
+	}
+
+	if config.ConfirmTimeout <= 0 {
+		config.ConfirmTimeout = DefaultCouplerConfig.ConfirmTimeout
+	}
+
+	if config.DuplicateWindow <= 0 {
+		config.DuplicateWindow = DefaultCouplerConfig.DuplicateWindow
+	}
+
+	return config
+}
+
+// CouplerStats counts the frames which a Coupler has handled on a line.
+type CouplerStats struct {
+	// Line is "main" for the main line, otherwise the area and line of the sub line, e.g. "1.1".
+	Line string
+
+	// Received counts the frames received from the line.
+	Received uint64
+
+	// Sent counts the frames forwarded to the line.
+	Sent uint64
+
+	// Filtered counts the frames from the line which no other line was supposed to receive.
+	Filtered uint64
+
+	// HopLimit counts the frames from the line which were discarded because their hop count was
+	// exhausted.
+	HopLimit uint64
+
+	// Duplicates counts the repeated frames from the line which had already been forwarded.
+	Duplicates uint64
+
+	// Repeated counts the repetitions of frames sent to the line.
+	Repeated uint64
+
+	// Failed counts the frames which could not be sent to the line despite repetitions.
+	Failed uint64
+}
+
+// couplerQueueSize is the number of frames that may be waiting to be sent to a line. Further frames
+// are dropped.
+const couplerQueueSize = 64
+
+// unlimitedHops is the hop count of frames which couplers never discard.
+const unlimitedHops = 7
+
+var (
+	errConfirmTimeout = errors.New("confirmation timeout reached")
+	errCouplerClosed  = errors.New("coupler has been closed")
+)
+
+// A couplerPort is the connection of a Coupler to a line.
+type couplerPort struct {
+	line      *CouplerLine
+	transport Transport
+	outbound  chan cemi.LData
+
+	// confirm receives the L_Data.con frames, if the transport is a tunnel.
+	confirm chan *cemi.LDataCon
+
+	mu    sync.Mutex
+	stats CouplerStats
+}
+
+// count updates the statistics of the port.
+func (port *couplerPort) count(update func(stats *CouplerStats)) {
+	port.mu.Lock()
+	update(&port.stats)
+	port.mu.Unlock()
+}
+
+// contains determines whether the individual address is located on the line of the port. The main
+// line contains none, because all addresses outside of the sub lines are reached through it.
+func (port *couplerPort) contains(addr cemi.IndividualAddr) bool {
+	return port.line != nil && addr>>8 == port.line.Address>>8
+}
+
+// passes determines whether a group telegram to the address passes the filter of the port.
+func (port *couplerPort) passes(addr cemi.GroupAddr) bool {
+	return port.line == nil || port.line.Filter == nil || addr == 0 || port.line.Filter.Contains(addr)
+}
+
+// message wraps the frame in the message that the transport expects.
+func (port *couplerPort) message(ldata cemi.LData) cemi.Message {
+	return OutboundFrame(port.transport, ldata)
+}
+
+// A Coupler connects a main line with one or more sub lines like a KNX line coupler or KNX IP
+// router does. Group telegrams pass according to the filter tables of the sub lines, individual
+// telegrams according to the line of their destination. The hop count of forwarded frames is
+// decremented once for every coupler they pass, frames whose hop count is exhausted are discarded.
+//
+// Frames to a sub line which is connected through a Tunnel are repeated until the tunnel confirms
+// them, frames to other transports are only repeated if sending fails. Repeated frames are marked
+// as such. A repeated frame which arrives after the original one has been forwarded is discarded.
+type Coupler struct {
+	config CouplerConfig
+	ports  []*couplerPort
+
+	mu     sync.Mutex
+	recent map[string]time.Time
+	pruned time.Time
+
+	done chan struct{}
+	wait sync.WaitGroup
+	once sync.Once
+}
+
+// NewCoupler creates a Coupler which connects the main line, usually a Router, with the sub lines
+// and starts forwarding. The Coupler takes ownership of the transports.
+func NewCoupler(main Transport, lines []CouplerLine, config CouplerConfig) (*Coupler, error) {
+	if main == nil {
+		return nil, errors.New("main line has no transport")
+	}
+
+	coupler := &Coupler{
+		config: checkCouplerConfig(config),
+		recent: map[string]time.Time{},
+		done:   make(chan struct{}),
+	}
+
+	coupler.ports = append(coupler.ports, newCouplerPort(nil, main))
+
+	seen := map[cemi.IndividualAddr]bool{}
+	lines = append([]CouplerLine(nil), lines...)
+
+	for i := range lines {
+		line := &lines[i]
+
+		if line.Transport == nil {
+			return nil, fmt.Errorf("line %s has no transport", lineName(line))
+		}
+
+		if seen[line.Address>>8] {
+			return nil, fmt.Errorf("line %s is given more than once", lineName(line))
+		}
+
+		seen[line.Address>>8] = true
+		coupler.ports = append(coupler.ports, newCouplerPort(line, line.Transport))
+	}
+
+	for _, port := range coupler.ports {
+		coupler.wait.Add(2)
+		go coupler.serveInbound(port)
+		go coupler.serveOutbound(port)
+	}
+
+	return coupler, nil
+}
+
+// newCouplerPort creates the port for the line.
+func newCouplerPort(line *CouplerLine, transport Transport) *couplerPort {
+	port := &couplerPort{
+		line:      line,
+		transport: transport,
+		outbound:  make(chan cemi.LData, couplerQueueSize),
+	}
+
+	port.stats.Line = "main"
+	if line != nil {
+		port.stats.Line = lineName(line)
+	}
+
+	if _, ok := transport.(*Tunnel); ok {
+		port.confirm = make(chan *cemi.LDataCon, 1)
+	}
+
+	return port
+}
+
+// lineName formats the area and line of the line, e.g. "1.1".
+func lineName(line *CouplerLine) string {
+	return fmt.Sprintf("%d.%d", uint8(line.Address>>12), uint8(line.Address>>8)&0xF)
+}
+
+// serveInbound routes the frames received from the line until its transport closes.
+func (coupler *Coupler) serveInbound(port *couplerPort) {
+	defer coupler.wait.Done()
+
+	for msg := range port.transport.Inbound() {
+		switch msg := msg.(type) {
+		case *cemi.LDataInd:
+			coupler.route(port, msg.LData, time.Now())
+
+		case *cemi.LDataCon:
+			if port.confirm != nil {
+				select {
+				case port.confirm <- msg:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// serveOutbound sends the frames queued for the line.
+func (coupler *Coupler) serveOutbound(port *couplerPort) {
+	defer coupler.wait.Done()
+
+	for {
+		select {
+		case <-coupler.done:
+			return
+
+		case ldata := <-port.outbound:
+			coupler.transmit(port, ldata)
+		}
+	}
+}
+
+// frameKey identifies a frame regardless of its repeat flag and hop count.
+func frameKey(ldata cemi.LData) string {
+	ldata.Control1 |= cemi.Control1NoRepeat
+	ldata.Control2 &^= cemi.Control2Hops(unlimitedHops)
+
+	buffer := make([]byte, ldata.Size())
+	ldata.Pack(buffer)
+
+	return string(buffer)
+}
+
+// duplicate determines whether the frame is a repetition of a frame which has been forwarded
+// recently. Otherwise the frame is remembered.
+func (coupler *Coupler) duplicate(ldata cemi.LData, now time.Time) bool {
+	window := coupler.config.DuplicateWindow
+	key := frameKey(ldata)
+
+	coupler.mu.Lock()
+	defer coupler.mu.Unlock()
+
+	if now.Sub(coupler.pruned) >= window {
+		for other, seen := range coupler.recent {
+			if now.Sub(seen) >= window {
+				delete(coupler.recent, other)
+			}
+		}
+
+		coupler.pruned = now
+	}
+
+	if seen, ok := coupler.recent[key]; ok && ldata.Control1&cemi.Control1NoRepeat == 0 &&
+		now.Sub(seen) < window {
+		return true
+	}
+
+	coupler.recent[key] = now
+
+	return false
+}
+
+// couplers determines how many couplers a frame from one line passes to reach the other one. It is
+// 0 if the frame is not supposed to reach the other line.
+func couplers(from, to *couplerPort, ldata *cemi.LData) int {
+	if ldata.Control2.IsGroupAddr() {
+		dest := cemi.GroupAddr(ldata.Destination)
+
+		switch {
+		case !from.passes(dest) || !to.passes(dest):
+			return 0
+
+		case from.line != nil && to.line != nil:
+			return 2
+
+		default:
+			return 1
+		}
+	}
+
+	dest := cemi.IndividualAddr(ldata.Destination)
+
+	switch {
+	case from.contains(dest) || to.line != nil && !to.contains(dest):
+		return 0
+
+	case from.line != nil && to.line != nil:
+		return 2
+
+	default:
+		return 1
+	}
+}
+
+// route forwards the frame received from the line to the other lines which shall receive it.
+func (coupler *Coupler) route(from *couplerPort, ldata cemi.LData, now time.Time) {
+	from.count(func(stats *CouplerStats) { stats.Received++ })
+
+	hops := ldata.Control2.Hops()
+	if hops == 0 {
+		from.count(func(stats *CouplerStats) { stats.HopLimit++ })
+		return
+	}
+
+	if coupler.duplicate(ldata, now) {
+		from.count(func(stats *CouplerStats) { stats.Duplicates++ })
+		return
+	}
+
+	// The coupler sends the frame anew, so it is not a repetition anymore.
+	ldata.Control1 |= cemi.Control1NoRepeat
+
+	forwarded, exhausted := false, false
+
+	for _, to := range coupler.ports {
+		if to == from {
+			continue
+		}
+
+		passes := couplers(from, to, &ldata)
+		if passes == 0 {
+			continue
+		}
+
+		out := ldata
+
+		if hops < unlimitedHops {
+			if int(hops) < passes {
+				exhausted = true
+				continue
+			}
+
+			out.Control2 = out.Control2&^cemi.Control2Hops(unlimitedHops) |
+				cemi.Control2Hops(hops-uint8(passes))
+		}
+
+		forwarded = true
+
+		select {
+		case to.outbound <- out:
+		default:
+			util.Log(coupler, "Queue of line %s is full, dropping frame", to.stats.Line)
+			to.count(func(stats *CouplerStats) { stats.Failed++ })
+		}
+	}
+
+	switch {
+	case forwarded:
+	case exhausted:
+		from.count(func(stats *CouplerStats) { stats.HopLimit++ })
+	default:
+		from.count(func(stats *CouplerStats) { stats.Filtered++ })
+	}
+}
+
+// transmit sends the frame to the line, repeating it if necessary.
+func (coupler *Coupler) transmit(port *couplerPort, ldata cemi.LData) {
+	for attempt := 0; attempt <= coupler.config.Repetitions; attempt++ {
+		if attempt > 0 {
+			ldata.Control1 &^= cemi.Control1NoRepeat
+			port.count(func(stats *CouplerStats) { stats.Repeated++ })
+		}
+
+		err := coupler.transmitOnce(port, ldata)
+		if err == nil {
+			port.count(func(stats *CouplerStats) { stats.Sent++ })
+			return
+		}
+
+		util.Log(coupler, "Sending to line %s failed: %v", port.stats.Line, err)
+
+		if err == errCouplerClosed {
+			break
+		}
+	}
+
+	port.count(func(stats *CouplerStats) { stats.Failed++ })
+}
+
+// transmitOnce sends the frame to the line. Frames sent through a tunnel must be confirmed.
+func (coupler *Coupler) transmitOnce(port *couplerPort, ldata cemi.LData) error {
+	if port.confirm == nil {
+		return port.transport.Send(port.message(ldata))
+	}
+
+	// Confirmations which arrived too late must not be taken for the confirmation of this frame.
+	select {
+	case <-port.confirm:
+	default:
+	}
+
+	if err := port.transport.Send(port.message(ldata)); err != nil {
+		return err
+	}
+
+	timeout := time.NewTimer(coupler.config.ConfirmTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case con := <-port.confirm:
+			if !confirms(con, ldata) {
+				continue
+			}
+
+			if con.Control1&cemi.Control1HasError != 0 {
+				return ErrNegativeConfirmation
+			}
+
+			return nil
+
+		case <-timeout.C:
+			return errConfirmTimeout
+
+		case <-coupler.done:
+			return errCouplerClosed
+		}
+	}
+}
+
+// confirms determines whether the confirmation belongs to the frame. Like the confirmations of group
+// clients, it must carry the same destination, command and data.
+func confirms(con *cemi.LDataCon, ldata cemi.LData) bool {
+	if con.Destination != ldata.Destination {
+		return false
+	}
+
+	if con.Data == nil || ldata.Data == nil {
+		return con.Data == nil && ldata.Data == nil
+	}
+
+	return bytes.Equal(util.AllocAndPack(con.Data), util.AllocAndPack(ldata.Data))
+}
+
+// Stats returns the statistics of the main line, followed by those of the sub lines.
+func (coupler *Coupler) Stats() []CouplerStats {
+	stats := make([]CouplerStats, len(coupler.ports))
+
+	for i, port := range coupler.ports {
+		port.mu.Lock()
+		stats[i] = port.stats
+		port.mu.Unlock()
+	}
+
+	return stats
+}
+
+// Close stops forwarding and closes the transports of all lines.
+func (coupler *Coupler) Close() {
+	coupler.once.Do(func() {
+		close(coupler.done)
+
+		for _, port := range coupler.ports {
+			port.transport.Close()
+		}
+
+		coupler.wait.Wait()
+	})
+}
@@ -0,0 +1,301 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// receiveFrame waits for a frame on the connection.
+func receiveFrame(t *testing.T, conn *BusConn) cemi.LData {
+	t.Helper()
+
+	select {
+	case msg := <-conn.Inbound():
+		ind, ok := msg.(*cemi.LDataInd)
+		if !ok {
+			t.Fatalf("Unexpected message %v", msg)
+		}
+
+		return ind.LData
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("No frame received")
+		return cemi.LData{}
+	}
+}
+
+// receiveNoFrame makes sure that no frame arrives on the connection.
+func receiveNoFrame(t *testing.T, conn *BusConn) {
+	t.Helper()
+
+	select {
+	case msg := <-conn.Inbound():
+		t.Errorf("Unexpected message %v", msg)
+
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func makeCouplerFrame(dest uint16, group bool, hops uint8) *cemi.LDataInd {
+	ldata := cemi.LData{
+		Control1:    cemi.Control1StdFrame | cemi.Control1NoRepeat,
+		Control2:    cemi.Control2Hops(hops),
+		Source:      cemi.NewIndividualAddr3(1, 1, 5),
+		Destination: dest,
+		Data:        &cemi.AppData{Command: cemi.GroupValueWrite, Data: []byte{byte(dest)}},
+	}
+
+	if group {
+		ldata.Control2 |= cemi.Control2GroupAddr
+	}
+
+	return &cemi.LDataInd{LData: ldata}
+}
+
+func TestCoupler(t *testing.T) {
+	buses := []*Bus{NewBus(), NewBus(), NewBus()}
+
+	coupler, err := NewCoupler(buses[0].Connect(), []CouplerLine{
+		{
+			Address:   cemi.NewIndividualAddr3(1, 1, 0),
+			Transport: buses[1].Connect(),
+			Filter:    NewGroupFilterTable(cemi.NewGroupAddr3(1, 0, 0), cemi.NewGroupAddr3(1, 0, 1)),
+		},
+		{
+			Address:   cemi.NewIndividualAddr3(1, 2, 0),
+			Transport: buses[2].Connect(),
+			Filter:    NewGroupFilterTable(cemi.NewGroupAddr3(1, 0, 0)),
+		},
+	}, DefaultCouplerConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer coupler.Close()
+
+	main, line1, line2 := buses[0].Connect(), buses[1].Connect(), buses[2].Connect()
+	defer main.Close()
+	defer line1.Close()
+	defer line2.Close()
+
+	switchGroup := uint16(cemi.NewGroupAddr3(1, 0, 1))
+	centralGroup := uint16(cemi.NewGroupAddr3(1, 0, 0))
+
+	individual := func(a, b, c uint8) *cemi.LDataInd {
+		return makeCouplerFrame(uint16(cemi.NewIndividualAddr3(a, b, c)), false, 6)
+	}
+
+	t.Run("GroupFromMain", func(t *testing.T) {
+		if err := main.Send(makeCouplerFrame(switchGroup, true, 6)); err != nil {
+			t.Fatal(err)
+		}
+
+		if ldata := receiveFrame(t, line1); ldata.Control2.Hops() != 5 || !ldata.Control2.IsGroupAddr() {
+			t.Errorf("Unexpected frame %+v", ldata)
+		}
+
+		receiveNoFrame(t, line2)
+	})
+
+	t.Run("GroupFromLine", func(t *testing.T) {
+		if err := line1.Send(makeCouplerFrame(centralGroup, true, 6)); err != nil {
+			t.Fatal(err)
+		}
+
+		if hops := receiveFrame(t, main).Control2.Hops(); hops != 5 {
+			t.Errorf("Unexpected hop count %d on the main line", hops)
+		}
+
+		if hops := receiveFrame(t, line2).Control2.Hops(); hops != 4 {
+			t.Errorf("Unexpected hop count %d on the other line", hops)
+		}
+	})
+
+	t.Run("Individual", func(t *testing.T) {
+		if err := main.Send(individual(1, 2, 5)); err != nil {
+			t.Fatal(err)
+		}
+
+		receiveFrame(t, line2)
+		receiveNoFrame(t, line1)
+
+		// Frames to a device on the same line stay there.
+		if err := line1.Send(individual(1, 1, 3)); err != nil {
+			t.Fatal(err)
+		}
+
+		receiveNoFrame(t, main)
+		receiveNoFrame(t, line2)
+
+		if err := line1.Send(individual(2, 1, 3)); err != nil {
+			t.Fatal(err)
+		}
+
+		receiveFrame(t, main)
+		receiveNoFrame(t, line2)
+	})
+
+	t.Run("Hops", func(t *testing.T) {
+		if err := main.Send(makeCouplerFrame(switchGroup, true, 0)); err != nil {
+			t.Fatal(err)
+		}
+
+		receiveNoFrame(t, line1)
+
+		if err := main.Send(makeCouplerFrame(switchGroup, true, 7)); err != nil {
+			t.Fatal(err)
+		}
+
+		if hops := receiveFrame(t, line1).Control2.Hops(); hops != 7 {
+			t.Errorf("Unlimited hop count was changed to %d", hops)
+		}
+
+		// One hop is enough for the main line, but not for the other line behind it.
+		if err := line1.Send(makeCouplerFrame(centralGroup, true, 1)); err != nil {
+			t.Fatal(err)
+		}
+
+		if hops := receiveFrame(t, main).Control2.Hops(); hops != 0 {
+			t.Errorf("Unexpected hop count %d", hops)
+		}
+
+		receiveNoFrame(t, line2)
+	})
+
+	t.Run("Repetition", func(t *testing.T) {
+		frame := makeCouplerFrame(centralGroup, true, 6)
+		frame.Source = cemi.NewIndividualAddr3(1, 2, 9)
+
+		if err := line2.Send(frame); err != nil {
+			t.Fatal(err)
+		}
+
+		if ldata := receiveFrame(t, main); ldata.Control1&cemi.Control1NoRepeat == 0 {
+			t.Errorf("Forwarded frame is marked as repetition: %+v", ldata)
+		}
+
+		receiveFrame(t, line1)
+
+		// The repetition of a frame which has been forwarded is discarded.
+		frame.Control1 &^= cemi.Control1NoRepeat
+
+		if err := line2.Send(frame); err != nil {
+			t.Fatal(err)
+		}
+
+		receiveNoFrame(t, main)
+		receiveNoFrame(t, line1)
+	})
+
+	stats := coupler.Stats()
+	if len(stats) != 3 || stats[0].Line != "main" || stats[1].Line != "1.1" || stats[2].Line != "1.2" {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+
+	if main := stats[0]; main.Received != 4 || main.Sent != 4 || main.HopLimit != 1 ||
+		main.Filtered != 0 {
+		t.Errorf("Unexpected stats of the main line %+v", main)
+	}
+
+	if line1 := stats[1]; line1.Received != 4 || line1.Sent != 3 || line1.Filtered != 1 {
+		t.Errorf("Unexpected stats of the first line %+v", line1)
+	}
+
+	if line2 := stats[2]; line2.Received != 2 || line2.Duplicates != 1 || line2.Sent != 2 {
+		t.Errorf("Unexpected stats of the second line %+v", line2)
+	}
+}
+
+func TestCoupler_transmit(t *testing.T) {
+	bus := NewBus()
+
+	conn := bus.Connect()
+	defer conn.Close()
+
+	port := newCouplerPort(nil, bus.Connect())
+	port.confirm = make(chan *cemi.LDataCon, 1)
+
+	coupler := &Coupler{
+		config: checkCouplerConfig(CouplerConfig{Repetitions: 2, ConfirmTimeout: 50 * time.Millisecond}),
+		done:   make(chan struct{}),
+	}
+
+	ldata := makeCouplerFrame(uint16(cemi.NewGroupAddr3(1, 0, 1)), true, 6).LData
+
+	confirm := func(ldata cemi.LData, negative bool) {
+		if negative {
+			ldata.Control1 |= cemi.Control1HasError
+		}
+
+		port.confirm <- &cemi.LDataCon{LData: ldata}
+	}
+
+	// The first attempt is rejected, the second one is confirmed.
+	go func() {
+		for _, negative := range []bool{true, false} {
+			if ind, ok := (<-conn.Inbound()).(*cemi.LDataInd); ok {
+				confirm(ind.LData, negative)
+			}
+		}
+	}()
+
+	coupler.transmit(port, ldata)
+
+	if stats := port.stats; stats.Sent != 1 || stats.Repeated != 1 || stats.Failed != 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	// Frames which are never confirmed fail after all repetitions.
+	coupler.transmit(port, ldata)
+
+	for i := 0; i < 3; i++ {
+		if repeated := receiveFrame(t, conn).Control1&cemi.Control1NoRepeat == 0; repeated != (i > 0) {
+			t.Errorf("Attempt %d has an unexpected repeat flag", i)
+		}
+	}
+
+	if stats := port.stats; stats.Sent != 1 || stats.Repeated != 3 || stats.Failed != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	// The rejection of another frame to the same destination does not concern this frame.
+	go func() {
+		if ind, ok := (<-conn.Inbound()).(*cemi.LDataInd); ok {
+			other := ind.LData
+			other.Data = &cemi.AppData{Command: cemi.GroupValueWrite, Data: []byte{0xFF}}
+
+			confirm(other, true)
+			confirm(ind.LData, false)
+		}
+	}()
+
+	coupler.transmit(port, ldata)
+
+	if stats := port.stats; stats.Sent != 2 || stats.Repeated != 3 || stats.Failed != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestNewCoupler(t *testing.T) {
+	bus := NewBus()
+
+	if _, err := NewCoupler(nil, nil, DefaultCouplerConfig); err == nil {
+		t.Error("Coupler without main line was accepted")
+	}
+
+	if _, err := NewCoupler(
+		bus.Connect(), []CouplerLine{{Address: cemi.NewIndividualAddr3(1, 1, 0)}}, DefaultCouplerConfig,
+	); err == nil {
+		t.Error("Line without transport was accepted")
+	}
+
+	if _, err := NewCoupler(bus.Connect(), []CouplerLine{
+		{Address: cemi.NewIndividualAddr3(1, 1, 0), Transport: bus.Connect()},
+		{Address: cemi.NewIndividualAddr3(1, 1, 1), Transport: bus.Connect()},
+	}, DefaultCouplerConfig); err == nil {
+		t.Error("Duplicate line was accepted")
+	}
+}
@@ -0,0 +1,156 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"context"
+	"sync"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/dpt"
+)
+
+// DatapointValuePtr constrains a type parameter to be a pointer to T which implements
+// dpt.DatapointValue.
+type DatapointValuePtr[T any] interface {
+	*T
+	dpt.DatapointValue
+}
+
+// A Datapoint binds one datapoint type to its group addresses. Values are written to the write
+// address and received from the write and status addresses. Reads are directed at the first status
+// address, or the write address if there are none. It works with any GroupSubscriber; wrap a plain
+// GroupClient in a GroupMux to obtain one.
+//
+// Use NewDatapoint to create one; the pointer type is inferred:
+//
+//	setpoint := knx.NewDatapoint[dpt.DPT_9001](mux, cemi.NewGroupAddr3(1, 2, 3))
+//	err := setpoint.Write(20.5)
+type Datapoint[T any, PT DatapointValuePtr[T]] struct {
+	mux    GroupSubscriber
+	write  cemi.GroupAddr
+	status []cemi.GroupAddr
+}
+
+// NewDatapoint creates a Datapoint which writes to the given address and receives its status
+// through the status addresses.
+func NewDatapoint[T any, PT DatapointValuePtr[T]](
+	mux GroupSubscriber,
+	write cemi.GroupAddr,
+	status ...cemi.GroupAddr,
+) *Datapoint[T, PT] {
+	return &Datapoint[T, PT]{
+		mux:    mux,
+		write:  write,
+		status: append([]cemi.GroupAddr(nil), status...),
+	}
+}
+
+// readAddr returns the address to which read requests are sent.
+func (dp *Datapoint[T, PT]) readAddr() cemi.GroupAddr {
+	if len(dp.status) > 0 {
+		return dp.status[0]
+	}
+
+	return dp.write
+}
+
+// addrs returns all addresses on which values are received.
+func (dp *Datapoint[T, PT]) addrs() []cemi.GroupAddr {
+	return append([]cemi.GroupAddr{dp.write}, dp.status...)
+}
+
+// decode extracts the value from the event, if it carries one.
+func (dp *Datapoint[T, PT]) decode(event GroupEvent) (value T, ok bool) {
+	if event.Command != GroupWrite && event.Command != GroupResponse {
+		return value, false
+	}
+
+	if err := PT(&value).Unpack(event.Data); err != nil {
+		return value, false
+	}
+
+	return value, true
+}
+
+// Write sends the value to the write address.
+func (dp *Datapoint[T, PT]) Write(value T) error {
+	return dp.mux.Send(GroupEvent{
+		Command:     GroupWrite,
+		Destination: dp.write,
+		Data:        PT(&value).Pack(),
+	})
+}
+
+// Read requests the current value and waits for the response, or until the context is done.
+func (dp *Datapoint[T, PT]) Read(ctx context.Context) (value T, err error) {
+	addr := dp.readAddr()
+
+	// Subscribe before asking, so that the response cannot be missed.
+	events, stop := dp.mux.Subscribe(addr)
+	defer stop()
+
+	if err = dp.mux.Send(GroupEvent{Command: GroupRead, Destination: addr}); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return value, ctx.Err()
+
+		case event, open := <-events:
+			if !open {
+				return value, errGroupClientClosed
+			}
+
+			if event.Command != GroupResponse {
+				continue
+			}
+
+			if err = PT(&value).Unpack(event.Data); err != nil {
+				return
+			}
+
+			return value, nil
+		}
+	}
+}
+
+// Watch returns a channel on which the values received through the write and status addresses are
+// delivered. Events that cannot be decoded are skipped. The channel is closed when the underlying
+// client closes or when the returned function is called.
+func (dp *Datapoint[T, PT]) Watch() (<-chan T, func()) {
+	events, unsubscribe := dp.mux.Subscribe(dp.addrs()...)
+
+	values := make(chan T, groupEventBuffer)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(values)
+
+		for event := range events {
+			value, ok := dp.decode(event)
+			if !ok {
+				continue
+			}
+
+			select {
+			case values <- value:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+
+	stop := func() {
+		once.Do(func() {
+			close(done)
+			unsubscribe()
+		})
+	}
+
+	return values, stop
+}
@@ -0,0 +1,122 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/dpt"
+)
+
+func TestDatapoint(t *testing.T) {
+	conn := newDummyGroupConn()
+	defer conn.Close()
+
+	mux := NewGroupMux(conn)
+
+	write := cemi.NewGroupAddr3(1, 2, 3)
+	status := cemi.NewGroupAddr3(1, 2, 4)
+	setpoint := NewDatapoint[dpt.DPT_9001](mux, write, status)
+
+	t.Run("Write", func(t *testing.T) {
+		if err := setpoint.Write(20.5); err != nil {
+			t.Fatal(err)
+		}
+
+		event := <-conn.sent
+		if event.Command != GroupWrite || event.Destination != write ||
+			!bytes.Equal(event.Data, dpt.DPT_9001(20.5).Pack()) {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+	})
+
+	t.Run("Read", func(t *testing.T) {
+		go func() {
+			request := <-conn.sent
+			if request.Command != GroupRead || request.Destination != status {
+				t.Errorf("Unexpected request: %+v", request)
+			}
+
+			conn.inbound <- GroupEvent{Command: GroupWrite, Destination: status, Data: dpt.DPT_9001(1).Pack()}
+			conn.inbound <- GroupEvent{Command: GroupResponse, Destination: status, Data: dpt.DPT_9001(21).Pack()}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		value, err := setpoint.Read(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if value != 21 {
+			t.Errorf("Expected 21, got %v", value)
+		}
+	})
+
+	t.Run("Watch", func(t *testing.T) {
+		values, stop := setpoint.Watch()
+		defer stop()
+
+		conn.inbound <- GroupEvent{Command: GroupWrite, Destination: cemi.NewGroupAddr3(1, 2, 5), Data: dpt.DPT_9001(1).Pack()}
+		conn.inbound <- GroupEvent{Command: GroupRead, Destination: status}
+		conn.inbound <- GroupEvent{Command: GroupResponse, Destination: status, Data: dpt.DPT_9001(22).Pack()}
+		conn.inbound <- GroupEvent{Command: GroupWrite, Destination: write, Data: dpt.DPT_9001(23).Pack()}
+
+		for _, expected := range []dpt.DPT_9001{22, 23} {
+			if value := <-values; value != expected {
+				t.Errorf("Expected %v, got %v", expected, value)
+			}
+		}
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		conn.Close()
+
+		go func() { <-conn.sent }()
+
+		if _, err := setpoint.Read(context.Background()); err == nil {
+			t.Error("Read succeeded on a closed client")
+		}
+	})
+}
+
+// recordingSubscriber is a GroupSubscriber which hands out a single channel.
+type recordingSubscriber struct {
+	events chan GroupEvent
+	addrs  []cemi.GroupAddr
+}
+
+func (sub *recordingSubscriber) Send(event GroupEvent) error {
+	return nil
+}
+
+func (sub *recordingSubscriber) Subscribe(addrs ...cemi.GroupAddr) (<-chan GroupEvent, func()) {
+	sub.addrs = addrs
+	return sub.events, func() {}
+}
+
+func TestDatapoint_subscriber(t *testing.T) {
+	sub := &recordingSubscriber{events: make(chan GroupEvent, 1)}
+
+	write := cemi.NewGroupAddr3(1, 2, 3)
+	status := cemi.NewGroupAddr3(1, 2, 4)
+	setpoint := NewDatapoint[dpt.DPT_9001](sub, write, status)
+
+	values, stop := setpoint.Watch()
+	defer stop()
+
+	if len(sub.addrs) != 2 || sub.addrs[0] != write || sub.addrs[1] != status {
+		t.Errorf("Unexpected subscription: %v", sub.addrs)
+	}
+
+	sub.events <- GroupEvent{Command: GroupWrite, Destination: status, Data: dpt.DPT_9001(19).Pack()}
+
+	if value := <-values; value != 19 {
+		t.Errorf("Expected 19, got %v", value)
+	}
+}
@@ -3,10 +3,15 @@
 package knx
 
 import (
+	"errors"
+	"fmt"
 	"net"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/vapourismo/knx-go/knx/knxnet"
+	"github.com/vapourismo/knx-go/knx/util"
 )
 
 // Discover all KNXnet/IP servers.
@@ -14,10 +19,26 @@ func Discover(multicastDiscoveryAddress string, searchTimeout time.Duration) ([]
 	return DiscoverOnInterface(nil, multicastDiscoveryAddress, searchTimeout)
 }
 
+// A searchSocket is a socket which has joined the discovery multicast group.
+type searchSocket interface {
+	knxnet.Socket
+	Addr() *net.UDPAddr
+}
+
+// listenSearch creates the socket for a search on the interface. Given an interface, the search
+// request is sent on it and only the responses arriving on it are considered.
+func listenSearch(ifi *net.Interface, multicastDiscoveryAddress string) (searchSocket, error) {
+	if ifi == nil {
+		return knxnet.ListenRouterOnInterface(nil, multicastDiscoveryAddress, false)
+	}
+
+	return knxnet.ListenRouterOnInterfaces([]*net.Interface{ifi}, multicastDiscoveryAddress, false)
+}
+
 // DiscoverOnInterface discovers all KNXnet/IP servers on a specific interface. If the
 // interface is nil, the system-assigned multicast interface is used.
 func DiscoverOnInterface(ifi *net.Interface, multicastDiscoveryAddress string, searchTimeout time.Duration) ([]*knxnet.SearchRes, error) {
-	socket, err := knxnet.ListenRouterOnInterface(ifi, multicastDiscoveryAddress, false)
+	socket, err := listenSearch(ifi, multicastDiscoveryAddress)
 	if err != nil {
 		return nil, err
 	}
@@ -39,6 +60,10 @@ loop:
 	for {
 		select {
 		case msg := <-socket.Inbound():
+			if received, ok := msg.(*knxnet.ReceivedService); ok {
+				msg = received.Payload
+			}
+
 			searchRes, ok := msg.(*knxnet.SearchRes)
 			if !ok {
 				continue
@@ -52,3 +77,133 @@ loop:
 
 	return results, nil
 }
+
+// A DiscoveryResult is a search response together with the interface on which it was received.
+type DiscoveryResult struct {
+	Interface *net.Interface
+	Response  *knxnet.SearchRes
+
+	// Interfaces lists every interface on which the server responded, starting with Interface. It
+	// is filled in by DiscoverAll.
+	Interfaces []*net.Interface
+}
+
+// A responder identifies a KNXnet/IP server by its control endpoint and serial number.
+type responder struct {
+	control knxnet.HostInfo
+	serial  knxnet.DeviceSerialNumber
+}
+
+// dedupResults merges the results of servers which responded on several interfaces. The order of
+// first appearance is kept.
+func dedupResults(results []DiscoveryResult) []DiscoveryResult {
+	var merged []DiscoveryResult
+	index := map[responder]int{}
+
+	for _, result := range results {
+		key := responder{
+			result.Response.Control,
+			result.Response.DescriptionB.DeviceHardware.SerialNumber,
+		}
+
+		if i, ok := index[key]; ok {
+			merged[i].Interfaces = append(merged[i].Interfaces, result.Interface)
+			continue
+		}
+
+		index[key] = len(merged)
+		result.Interfaces = []*net.Interface{result.Interface}
+		merged = append(merged, result)
+	}
+
+	return merged
+}
+
+// multicastInterfaces returns the interfaces which are up, support multicast and have an IPv4
+// address.
+func multicastInterfaces() ([]net.Interface, error) {
+	ifis, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []net.Interface
+
+	for _, ifi := range ifis {
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+				result = append(result, ifi)
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// DiscoverAll discovers all KNXnet/IP servers on all multicast-capable interfaces. The interfaces
+// are searched in parallel. Servers which respond on several interfaces are reported once, with
+// all of these interfaces. An error is only returned if no interface could be searched.
+func DiscoverAll(multicastDiscoveryAddress string, searchTimeout time.Duration) ([]DiscoveryResult, error) {
+	ifis, err := multicastInterfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ifis) == 0 {
+		return nil, errors.New("no multicast-capable interfaces found")
+	}
+
+	var (
+		mu      sync.Mutex
+		wait    sync.WaitGroup
+		results []DiscoveryResult
+		errs    []error
+	)
+
+	for i := range ifis {
+		ifi := &ifis[i]
+
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+
+			responses, err := DiscoverOnInterface(ifi, multicastDiscoveryAddress, searchTimeout)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				util.Log(ifi, "Discovery on %s failed: %v", ifi.Name, err)
+				errs = append(errs, fmt.Errorf("%s: %w", ifi.Name, err))
+				return
+			}
+
+			for _, res := range responses {
+				results = append(results, DiscoveryResult{Interface: ifi, Response: res})
+			}
+		}()
+	}
+
+	wait.Wait()
+
+	if len(errs) == len(ifis) {
+		return nil, errs[0]
+	}
+
+	// The searches complete in any order, but the result should not depend on it.
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Interface.Index < results[j].Interface.Index
+	})
+
+	return dedupResults(results), nil
+}
@@ -0,0 +1,45 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"net"
+	"testing"
+
+	"github.com/vapourismo/knx-go/knx/knxnet"
+)
+
+func TestDedupResults(t *testing.T) {
+	eth0 := &net.Interface{Index: 2, Name: "eth0"}
+	vlan10 := &net.Interface{Index: 3, Name: "vlan10"}
+
+	response := func(address knxnet.Address, serial byte) *knxnet.SearchRes {
+		res := &knxnet.SearchRes{Control: knxnet.HostInfo{Protocol: knxnet.UDP4, Address: address, Port: 3671}}
+		res.DescriptionB.DeviceHardware.SerialNumber[5] = serial
+		return res
+	}
+
+	results := dedupResults([]DiscoveryResult{
+		{Interface: eth0, Response: response(knxnet.Address{10, 0, 0, 2}, 1)},
+		{Interface: eth0, Response: response(knxnet.Address{10, 0, 0, 3}, 2)},
+		{Interface: vlan10, Response: response(knxnet.Address{10, 0, 0, 2}, 1)},
+
+		// Servers behind NAT report no control endpoint, but differ by serial number.
+		{Interface: vlan10, Response: response(knxnet.Address{}, 3)},
+		{Interface: vlan10, Response: response(knxnet.Address{}, 4)},
+	})
+
+	if len(results) != 4 {
+		t.Fatalf("Unexpected results: %+v", results)
+	}
+
+	if first := results[0]; first.Interface != eth0 || len(first.Interfaces) != 2 ||
+		first.Interfaces[0] != eth0 || first.Interfaces[1] != vlan10 {
+		t.Errorf("Unexpected interfaces of the first result: %+v", first)
+	}
+
+	if second := results[1]; second.Response.Control.Address != (knxnet.Address{10, 0, 0, 3}) ||
+		len(second.Interfaces) != 1 {
+		t.Errorf("Unexpected second result: %+v", second)
+	}
+}
@@ -5,7 +5,9 @@
 package dpt
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
 )
 
 var dptTypes = map[string]Datapoint{
@@ -249,3 +251,41 @@ func Produce(name string) (d Datapoint, ok bool) {
 
 	return d, ok
 }
+
+// numericMainTypes are the main types of the datapoint types whose Float method yields a
+// meaningful number.
+var numericMainTypes = map[string]bool{
+	"5": true, "6": true, "7": true, "8": true, "9": true, "12": true, "13": true, "14": true,
+	"17": true, "20": true,
+}
+
+// IsNumeric determines whether the Float method of the named datapoint type, e.g. "9.001", yields a
+// meaningful number. Booleans (1.xxx) are not considered numeric.
+func IsNumeric(name string) bool {
+	return numericMainTypes[strings.SplitN(name, ".", 2)[0]]
+}
+
+// booleans maps the common spellings of booleans to the form understood by 1.xxx.
+var booleans = map[string]string{
+	"true": "1", "on": "1", "yes": "1",
+	"false": "0", "off": "0", "no": "0",
+}
+
+// Encode packs the textual value according to the named datapoint type. Besides the form accepted
+// by ToByteArray, 1.xxx understands true, on, yes, false, off and no.
+func Encode(name, text string) ([]byte, error) {
+	d, ok := Produce(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown datapoint type %q", name)
+	}
+
+	text = strings.TrimSpace(text)
+
+	if strings.HasPrefix(name, "1.") {
+		if normalized, ok := booleans[strings.ToLower(text)]; ok {
+			text = normalized
+		}
+	}
+
+	return d.ToByteArray(text)
+}
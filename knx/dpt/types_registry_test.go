@@ -0,0 +1,46 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package dpt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestIsNumeric(t *testing.T) {
+	for name, numeric := range map[string]bool{
+		"9.001": true, "5.001": true, "14.056": true, "1.001": false, "16.000": false, "9": true,
+	} {
+		if IsNumeric(name) != numeric {
+			t.Errorf("%s: expected numeric to be %v", name, numeric)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	for _, c := range []struct {
+		name, text string
+		data       []byte
+	}{
+		{"1.001", "on", []byte{1}},
+		{"1.001", " No ", []byte{0}},
+		{"1.001", "1", []byte{1}},
+		{"9.001", "21", []byte{0, 0x0C, 0x1A}},
+		{"9.001", "-5", []byte{0, 0x86, 0x0C}},
+	} {
+		data, err := Encode(c.name, c.text)
+		if err != nil {
+			t.Errorf("%s %q: %v", c.name, c.text, err)
+		} else if !bytes.Equal(data, c.data) {
+			t.Errorf("%s %q: expected %x, got %x", c.name, c.text, c.data, data)
+		}
+	}
+
+	if _, err := Encode("0.000", "1"); err == nil {
+		t.Error("Unknown datapoint type was accepted")
+	}
+
+	if _, err := Encode("9.001", "warm"); err == nil {
+		t.Error("Invalid value was accepted")
+	}
+}
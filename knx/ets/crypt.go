@@ -0,0 +1,363 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package ets
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"unicode/utf16"
+)
+
+var (
+	errPasswordRequired = errors.New("project is password-protected")
+	errWrongPassword    = errors.New("wrong project password")
+)
+
+// pbkdf2 derives a key from the password as specified in RFC 8018.
+func pbkdf2(h func() hash.Hash, password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(h, password)
+	hashLen := prf.Size()
+
+	key := make([]byte, 0, (keyLen+hashLen-1)/hashLen*hashLen)
+	block := make([]byte, 4)
+
+	for i := uint32(1); len(key) < keyLen; i++ {
+		binary.BigEndian.PutUint32(block, i)
+
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(block)
+		u := prf.Sum(nil)
+
+		t := append([]byte(nil), u...)
+
+		for n := 1; n < iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+
+		key = append(key, t...)
+	}
+
+	return key[:keyLen]
+}
+
+// projectSalt is used to derive the archive password of projects created by ETS 5.5 and later.
+var projectSalt = []byte("21.project.ets.knx.org")
+
+// archivePassword derives the password of the project archive from the project password. Starting
+// with schema version 21 (ETS 5.5), ETS does not use the project password directly.
+func archivePassword(password string, schemaVersion int) []byte {
+	if schemaVersion < 21 {
+		return []byte(password)
+	}
+
+	units := utf16.Encode([]rune(password))
+	encoded := make([]byte, 2*len(units))
+
+	for i, unit := range units {
+		binary.LittleEndian.PutUint16(encoded[2*i:], unit)
+	}
+
+	key := pbkdf2(sha256.New, encoded, projectSalt, 65536, 32)
+
+	return []byte(base64.StdEncoding.EncodeToString(key))
+}
+
+// These are the parameters of the WinZip AES encryption.
+const (
+	aesExtraID      = 0x9901
+	aesMethod       = 99
+	aesIterations   = 1000
+	aesAuthCodeSize = 10
+	aesVerifierSize = 2
+)
+
+// aesExtra contains the parameters which are stored in the AES extra field of a zip entry.
+type aesExtra struct {
+	version  uint16
+	strength uint8
+	method   uint16
+}
+
+// parseAESExtra extracts the AES parameters from the extra fields of a zip entry.
+func parseAESExtra(extra []byte) (aesExtra, error) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		extra = extra[4:]
+
+		if size > len(extra) {
+			break
+		}
+
+		if id == aesExtraID && size >= 7 {
+			return aesExtra{
+				version:  binary.LittleEndian.Uint16(extra),
+				strength: extra[4],
+				method:   binary.LittleEndian.Uint16(extra[5:]),
+			}, nil
+		}
+
+		extra = extra[size:]
+	}
+
+	return aesExtra{}, errors.New("AES extra field is missing")
+}
+
+// aesKeySize determines the key size for the given encryption strength.
+func aesKeySize(strength uint8) (int, error) {
+	switch strength {
+	case 1:
+		return 16, nil
+
+	case 2:
+		return 24, nil
+
+	case 3:
+		return 32, nil
+	}
+
+	return 0, fmt.Errorf("unknown AES encryption strength %d", strength)
+}
+
+// aesKeys derives the encryption key, authentication key and password verifier.
+func aesKeys(password, salt []byte, keySize int) (encKey, authKey, verifier []byte) {
+	keys := pbkdf2(sha1.New, password, salt, aesIterations, 2*keySize+aesVerifierSize)
+	return keys[:keySize], keys[keySize : 2*keySize], keys[2*keySize:]
+}
+
+// aesCTR applies the WinZip flavour of AES-CTR, which uses a little-endian counter starting at 1.
+func aesCTR(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, len(data))
+	counter := make([]byte, aes.BlockSize)
+	stream := make([]byte, aes.BlockSize)
+
+	for offset := 0; offset < len(data); offset += aes.BlockSize {
+		// Increment the little-endian counter.
+		for i := range counter {
+			counter[i]++
+			if counter[i] != 0 {
+				break
+			}
+		}
+
+		block.Encrypt(stream, counter)
+
+		end := offset + aes.BlockSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		for i := offset; i < end; i++ {
+			out[i] = data[i] ^ stream[i-offset]
+		}
+	}
+
+	return out, nil
+}
+
+// decryptAES decrypts the raw data of a WinZip AES encrypted entry. It returns the data which is
+// still compressed, and the actual compression method.
+func decryptAES(file *zip.File, raw, password []byte) ([]byte, uint16, error) {
+	extra, err := parseAESExtra(file.Extra)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	keySize, err := aesKeySize(extra.strength)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	saltSize := keySize / 2
+	if len(raw) < saltSize+aesVerifierSize+aesAuthCodeSize {
+		return nil, 0, errors.New("encrypted entry is too short")
+	}
+
+	salt := raw[:saltSize]
+	verifier := raw[saltSize : saltSize+aesVerifierSize]
+	data := raw[saltSize+aesVerifierSize : len(raw)-aesAuthCodeSize]
+	authCode := raw[len(raw)-aesAuthCodeSize:]
+
+	encKey, authKey, expectedVerifier := aesKeys(password, salt, keySize)
+
+	if subtle.ConstantTimeCompare(verifier, expectedVerifier) != 1 {
+		return nil, 0, errWrongPassword
+	}
+
+	mac := hmac.New(sha1.New, authKey)
+	mac.Write(data)
+
+	if !hmac.Equal(mac.Sum(nil)[:aesAuthCodeSize], authCode) {
+		return nil, 0, errors.New("encrypted entry failed authentication")
+	}
+
+	plain, err := aesCTR(encKey, data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return plain, extra.method, nil
+}
+
+// zipCrypto implements the traditional PKWARE encryption.
+type zipCrypto struct {
+	keys [3]uint32
+}
+
+// newZipCrypto initialises the keys using the password.
+func newZipCrypto(password []byte) *zipCrypto {
+	zc := &zipCrypto{keys: [3]uint32{0x12345678, 0x23456789, 0x34567890}}
+
+	for _, b := range password {
+		zc.update(b)
+	}
+
+	return zc
+}
+
+// crc32Update updates a CRC-32 with a single byte.
+func crc32Update(crc uint32, b byte) uint32 {
+	return crc32.IEEETable[byte(crc)^b] ^ (crc >> 8)
+}
+
+// update mixes the byte into the keys.
+func (zc *zipCrypto) update(b byte) {
+	zc.keys[0] = crc32Update(zc.keys[0], b)
+	zc.keys[1] = (zc.keys[1]+zc.keys[0]&0xff)*134775813 + 1
+	zc.keys[2] = crc32Update(zc.keys[2], byte(zc.keys[1]>>24))
+}
+
+// streamByte returns the next byte of the key stream.
+func (zc *zipCrypto) streamByte() byte {
+	temp := uint16(zc.keys[2]) | 2
+	return byte((uint32(temp) * uint32(temp^1)) >> 8)
+}
+
+// decrypt decrypts the data in place.
+func (zc *zipCrypto) decrypt(data []byte) {
+	for i, c := range data {
+		p := c ^ zc.streamByte()
+		zc.update(p)
+		data[i] = p
+	}
+}
+
+// decryptZipCrypto decrypts the raw data of a traditionally encrypted entry.
+func decryptZipCrypto(file *zip.File, raw, password []byte) ([]byte, error) {
+	if len(raw) < 12 {
+		return nil, errors.New("encrypted entry is too short")
+	}
+
+	data := append([]byte(nil), raw...)
+
+	zc := newZipCrypto(password)
+	zc.decrypt(data)
+
+	// The last byte of the header allows to check the password.
+	check := byte(file.CRC32 >> 24)
+	if file.Flags&0x8 != 0 {
+		//lint:ignore SA1019 the legacy MS-DOS time is what the header is checked against
+		check = byte(file.ModifiedTime >> 8)
+	}
+
+	if data[11] != check {
+		return nil, errWrongPassword
+	}
+
+	return data[12:], nil
+}
+
+// decompress inflates the data using the given compression method.
+func decompress(data []byte, method uint16) ([]byte, error) {
+	switch method {
+	case zip.Store:
+		return data, nil
+
+	case zip.Deflate:
+		reader := flate.NewReader(bytes.NewReader(data))
+		defer reader.Close()
+
+		return io.ReadAll(reader)
+	}
+
+	return nil, fmt.Errorf("unsupported compression method %d", method)
+}
+
+// readFile reads the entire contents of a zip entry, decrypting it with the password if needed.
+func readFile(file *zip.File, password []byte) ([]byte, error) {
+	if file.Flags&0x1 == 0 {
+		reader, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+
+		return io.ReadAll(reader)
+	}
+
+	if password == nil {
+		return nil, errPasswordRequired
+	}
+
+	rawReader, err := file.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := io.ReadAll(rawReader)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		compressed []byte
+		method     = file.Method
+	)
+
+	if file.Method == aesMethod {
+		compressed, method, err = decryptAES(file, raw, password)
+	} else {
+		compressed, err = decryptZipCrypto(file, raw, password)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := decompress(compressed, method)
+	if err != nil {
+		return nil, err
+	}
+
+	// AE-2 entries carry no checksum, the authentication code protects them instead.
+	if file.CRC32 != 0 && crc32.ChecksumIEEE(data) != file.CRC32 {
+		return nil, fmt.Errorf("checksum mismatch in %s", file.Name)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,349 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package ets
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// CSVFormat selects the layout of a CSV export.
+type CSVFormat uint8
+
+// These are the layouts which ETS offers when exporting group addresses.
+const (
+	// CSVOneColumn is the "1/1" layout, which has one column for the names of groups and addresses.
+	CSVOneColumn CSVFormat = iota
+
+	// CSVThreeColumns is the "3/1" layout, which has separate columns for the names of main groups,
+	// middle groups and addresses.
+	CSVThreeColumns
+)
+
+// csvColumns locates the columns of a CSV export. Columns which are not present are -1.
+type csvColumns struct {
+	name, main, middle, sub             int
+	address                             int
+	mainNumber, middleNumber, subNumber int
+	description, datapointType          int
+}
+
+// field returns the value of the given column.
+func field(record []string, column int) string {
+	if column < 0 || column >= len(record) {
+		return ""
+	}
+
+	return strings.TrimSpace(record[column])
+}
+
+// parseCSVHeader locates the columns using the header. Old exports repeat the columns "Main",
+// "Middle" and "Sub", the second time for the numbers of the groups.
+func parseCSVHeader(header []string) (csvColumns, bool) {
+	cols := csvColumns{-1, -1, -1, -1, -1, -1, -1, -1, -1, -1}
+	known := false
+
+	for i, title := range header {
+		switch strings.ToLower(strings.TrimSpace(title)) {
+		case "group name", "name":
+			cols.name, known = i, true
+
+		case "main", "main group":
+			if cols.main < 0 {
+				cols.main = i
+			} else {
+				cols.mainNumber = i
+			}
+			known = true
+
+		case "middle", "middle group":
+			if cols.middle < 0 {
+				cols.middle = i
+			} else {
+				cols.middleNumber = i
+			}
+
+		case "sub", "sub group":
+			if cols.sub < 0 {
+				cols.sub = i
+			} else {
+				cols.subNumber = i
+			}
+
+		case "address", "group address":
+			cols.address, known = i, true
+
+		case "description":
+			cols.description = i
+
+		case "datapointtype", "datapoint type", "dpt", "dpts":
+			cols.datapointType = i
+		}
+	}
+
+	return cols, known
+}
+
+// isAddress determines whether the value is a group address or group range.
+func isAddress(value string) bool {
+	if _, _, ok := parseRange(value); ok {
+		return true
+	}
+
+	_, err := cemi.NewGroupAddrString(value)
+	return err == nil
+}
+
+// guessCSVColumns locates the columns of an export without header.
+func guessCSVColumns(record []string) (csvColumns, error) {
+	if isAddress(field(record, 3)) {
+		// "Main";"Middle";"Sub";"Address";"Central";"Unfiltered";"Description";"DatapointType"
+		return csvColumns{-1, 0, 1, 2, 3, -1, -1, -1, 6, 7}, nil
+	}
+
+	if isAddress(field(record, 1)) {
+		// "Group name";"Address";"Central";"Unfiltered";"Description";"DatapointType"
+		return csvColumns{0, -1, -1, -1, 1, -1, -1, -1, 4, 5}, nil
+	}
+
+	return csvColumns{}, errors.New("unknown CSV layout")
+}
+
+// address determines the address of the record, which is either given in one column or, in old
+// exports, split into three columns.
+func (cols csvColumns) addressOf(record []string) string {
+	if cols.address >= 0 {
+		return field(record, cols.address)
+	}
+
+	main := field(record, cols.mainNumber)
+	middle := field(record, cols.middleNumber)
+	sub := field(record, cols.subNumber)
+
+	switch {
+	case main == "":
+		return ""
+
+	case middle == "":
+		return main + "/-/-"
+
+	case sub == "":
+		return main + "/" + middle + "/-"
+	}
+
+	return main + "/" + middle + "/" + sub
+}
+
+// nameOf determines the name of the record.
+func (cols csvColumns) nameOf(record []string) string {
+	if cols.name >= 0 {
+		return field(record, cols.name)
+	}
+
+	for _, column := range []int{cols.sub, cols.middle, cols.main} {
+		if name := field(record, column); name != "" {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// decodeText converts the contents of an export to UTF-8. Older versions of ETS do not use UTF-8.
+func decodeText(data []byte) ([]byte, error) {
+	data = bytes.TrimPrefix(data, utf8BOM)
+
+	if utf8.Valid(data) {
+		return data, nil
+	}
+
+	return charmap.ISO8859_1.NewDecoder().Bytes(data)
+}
+
+// detectDelimiter picks the most frequent delimiter in the first line.
+func detectDelimiter(data []byte) rune {
+	line := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		line = data[:i]
+	}
+
+	delimiter, count := ';', bytes.Count(line, []byte{';'})
+
+	for _, candidate := range []rune{'\t', ','} {
+		if n := bytes.Count(line, []byte(string(candidate))); n > count {
+			delimiter, count = candidate, n
+		}
+	}
+
+	return delimiter
+}
+
+// ReadCSV reads a group address export in CSV format. Both layouts are supported, in the old and
+// new format, with or without header.
+func ReadCSV(r io.Reader) (*GroupAddressTable, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err = decodeText(data); err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = detectDelimiter(data)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return NewGroupAddressTable(), nil
+	}
+
+	cols, ok := parseCSVHeader(records[0])
+	if ok {
+		records = records[1:]
+	} else if cols, err = guessCSVColumns(records[0]); err != nil {
+		return nil, err
+	}
+
+	table := NewGroupAddressTable()
+
+	var addresses []*GroupAddress
+
+	for line, record := range records {
+		address := cols.addressOf(record)
+		if address == "" {
+			continue
+		}
+
+		name := cols.nameOf(record)
+
+		if gr, middle, ok := parseRange(address); ok {
+			gr.Name = name
+			table.addRange(gr, middle)
+			continue
+		}
+
+		addr, err := cemi.NewGroupAddrString(address)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", line+1, err)
+		}
+
+		ga := &GroupAddress{
+			Address:       addr,
+			Name:          name,
+			Description:   field(record, cols.description),
+			DatapointType: field(record, cols.datapointType),
+		}
+
+		ga.DPT, _ = DPTName(ga.DatapointType)
+
+		addresses = append(addresses, ga)
+	}
+
+	// Ranges are added first, so that every address ends up in the right range.
+	for _, ga := range addresses {
+		table.add(ga)
+	}
+
+	return table, nil
+}
+
+// datapointTypeOf returns the datapoint type of the group address as ETS writes it.
+func datapointTypeOf(ga *GroupAddress) string {
+	if ga.DatapointType != "" {
+		return ga.DatapointType
+	}
+
+	ref, _ := DPTRef(ga.DPT)
+	return ref
+}
+
+// WriteCSV writes the table in the new CSV format of ETS, using the given layout.
+func WriteCSV(w io.Writer, table *GroupAddressTable, format CSVFormat) error {
+	writer := csv.NewWriter(w)
+	writer.Comma = ';'
+	writer.UseCRLF = true
+
+	// row writes a record. Exactly one of the three names is set, depending on the level.
+	row := func(names [3]string, address, description, datapointType string) {
+		var record []string
+
+		if format == CSVThreeColumns {
+			record = append(record, names[:]...)
+		} else {
+			record = append(record, names[0]+names[1]+names[2])
+		}
+
+		writer.Write(append(record, address, "", "", description, datapointType, "Auto"))
+	}
+
+	if format == CSVThreeColumns {
+		writer.Write([]string{
+			"Main", "Middle", "Sub", "Address", "Central", "Unfiltered", "Description",
+			"DatapointType", "Security",
+		})
+	} else {
+		writer.Write([]string{
+			"Group name", "Address", "Central", "Unfiltered", "Description", "DatapointType",
+			"Security",
+		})
+	}
+
+	written := make(map[cemi.GroupAddr]bool)
+
+	addresses := func(list []*GroupAddress) {
+		for _, ga := range sortAddresses(list) {
+			row([3]string{"", "", ga.Name}, ga.Address.String(), ga.Description, datapointTypeOf(ga))
+			written[ga.Address] = true
+		}
+	}
+
+	for _, main := range table.Ranges {
+		row([3]string{main.Name, "", ""}, formatRange(main, false), "", "")
+
+		for _, middle := range main.Ranges {
+			row([3]string{"", middle.Name, ""}, formatRange(middle, true), "", "")
+			addresses(middle.Addresses)
+		}
+
+		addresses(main.Addresses)
+	}
+
+	// Addresses which are not part of a range come last.
+	var rest []*GroupAddress
+
+	for _, ga := range table.Sorted() {
+		if !written[ga.Address] {
+			rest = append(rest, ga)
+		}
+	}
+
+	addresses(rest)
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// sortAddresses returns a copy of the list, ordered by address.
+func sortAddresses(list []*GroupAddress) []*GroupAddress {
+	table := GroupAddressTable{Addresses: make(map[cemi.GroupAddr]*GroupAddress, len(list))}
+	for _, ga := range list {
+		table.Addresses[ga.Address] = ga
+	}
+
+	return table.Sorted()
+}
@@ -0,0 +1,69 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package ets
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DPTName converts a datapoint type reference as used by ETS, such as "DPST-9-1", into the name
+// which dpt.Produce understands, such as "9.001". If several types are given, separated by spaces,
+// the first one is used. Names in the form "9.001" are accepted as well. The result is false if
+// the reference does not name a subtype, e.g. "DPT-9", or is malformed.
+func DPTName(ref string) (string, bool) {
+	fields := strings.Fields(ref)
+	if len(fields) == 0 {
+		return "", false
+	}
+
+	ref = fields[0]
+
+	var main, sub string
+
+	switch {
+	case strings.HasPrefix(ref, "DPST-"):
+		parts := strings.Split(ref[5:], "-")
+		if len(parts) != 2 {
+			return "", false
+		}
+
+		main, sub = parts[0], parts[1]
+
+	case strings.HasPrefix(ref, "DPT-"):
+		return "", false
+
+	default:
+		var ok bool
+		if main, sub, ok = strings.Cut(ref, "."); !ok {
+			return "", false
+		}
+	}
+
+	mainNumber, err := strconv.ParseUint(main, 10, 16)
+	if err != nil {
+		return "", false
+	}
+
+	subNumber, err := strconv.ParseUint(sub, 10, 16)
+	if err != nil {
+		return "", false
+	}
+
+	return fmt.Sprintf("%d.%03d", mainNumber, subNumber), true
+}
+
+// DPTRef converts a name such as "9.001" into the datapoint type reference used by ETS, such as
+// "DPST-9-1".
+func DPTRef(name string) (string, bool) {
+	name, ok := DPTName(name)
+	if !ok {
+		return "", false
+	}
+
+	main, sub, _ := strings.Cut(name, ".")
+	subNumber, _ := strconv.ParseUint(sub, 10, 16)
+
+	return fmt.Sprintf("DPST-%s-%d", main, subNumber), true
+}
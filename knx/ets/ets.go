@@ -0,0 +1,601 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Package ets reads the group addresses, datapoint types and topology from ETS projects and
+// group address exports.
+package ets
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// A GroupAddress is a group address as defined in a project.
+type GroupAddress struct {
+	Address cemi.GroupAddr
+	Name    string
+
+	// Description is the description of the group address, if any.
+	Description string
+
+	// DatapointType is the datapoint type as given by ETS, e.g. "DPST-9-1".
+	DatapointType string
+
+	// DPT is the name of the datapoint type which can be used with dpt.Produce, e.g. "9.001". It is
+	// empty if the datapoint type has not been specified precisely enough.
+	DPT string
+
+	// Main and Middle are the names of the main and middle group containing the address.
+	Main   string
+	Middle string
+}
+
+// A GroupRange is a main or middle group.
+type GroupRange struct {
+	Name       string
+	Start, End cemi.GroupAddr
+
+	// Ranges contains the middle groups of a main group.
+	Ranges []*GroupRange
+
+	// Addresses contains the group addresses directly inside this range.
+	Addresses []*GroupAddress
+}
+
+// A GroupObject is a communication object of a device that is assigned to group addresses.
+type GroupObject struct {
+	Number       int
+	Name         string
+	Text         string
+	FunctionText string
+
+	// DatapointType and DPT are specified like those of a GroupAddress.
+	DatapointType string
+	DPT           string
+
+	// GroupAddresses lists the assigned group addresses. The first one is the sending address.
+	GroupAddresses []cemi.GroupAddr
+}
+
+// A Device is a device in the topology.
+type Device struct {
+	Address     cemi.IndividualAddr
+	Name        string
+	Description string
+
+	// Addressed is false if the device has not been assigned an individual address yet.
+	Addressed bool
+
+	Manufacturer string
+	ProductName  string
+	OrderNumber  string
+
+	GroupObjects []GroupObject
+}
+
+// A Line is a line within an area.
+type Line struct {
+	Address uint8
+	Name    string
+	Devices []*Device
+}
+
+// An Area is an area of the topology.
+type Area struct {
+	Address uint8
+	Name    string
+	Lines   []*Line
+}
+
+// A Project contains the information read from an ETS project.
+type Project struct {
+	ID   string
+	Name string
+
+	// SchemaVersion is the version of the ETS XML schema, e.g. 20 for ETS 5 and 21 for ETS 5.5 and
+	// later.
+	SchemaVersion int
+
+	// GroupRanges contains the main groups.
+	GroupRanges []*GroupRange
+
+	// GroupAddresses contains all group addresses, ordered by address.
+	GroupAddresses []*GroupAddress
+
+	Areas []*Area
+
+	byAddress map[cemi.GroupAddr]*GroupAddress
+}
+
+// GroupAddress looks up the given group address.
+func (p *Project) GroupAddress(addr cemi.GroupAddr) (*GroupAddress, bool) {
+	ga, ok := p.byAddress[addr]
+	return ga, ok
+}
+
+// FilterTable returns the group addresses which the coupler of the given line must pass, because
+// group objects of devices on the line are assigned to them. They are ordered by address.
+func (p *Project) FilterTable(area, line uint8) []cemi.GroupAddr {
+	seen := map[cemi.GroupAddr]bool{}
+
+	for _, a := range p.Areas {
+		if a.Address != area {
+			continue
+		}
+
+		for _, l := range a.Lines {
+			if l.Address != line {
+				continue
+			}
+
+			for _, device := range l.Devices {
+				for _, object := range device.GroupObjects {
+					for _, addr := range object.GroupAddresses {
+						seen[addr] = true
+					}
+				}
+			}
+		}
+	}
+
+	addrs := make([]cemi.GroupAddr, 0, len(seen))
+	for addr := range seen {
+		addrs = append(addrs, addr)
+	}
+
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+
+	return addrs
+}
+
+// Open reads the project file (.knxproj) at the given path. The password is only needed for
+// password-protected projects.
+func Open(name string, password string) (*Project, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return Read(data, password)
+}
+
+// archive provides access to the files of a project archive.
+type archive struct {
+	outer *zip.Reader
+
+	// project contains the files of the project, which may be stored in a nested archive.
+	project map[string]*zip.File
+
+	password []byte
+}
+
+// read reads the project file with the given name.
+func (a *archive) read(name string) ([]byte, error) {
+	file, ok := a.project[name]
+	if !ok {
+		return nil, fmt.Errorf("project file %s is missing", name)
+	}
+
+	return readFile(file, a.password)
+}
+
+// find returns the entry of the outer archive with the given name.
+func (a *archive) find(name string) *zip.File {
+	for _, file := range a.outer.File {
+		if file.Name == name {
+			return file
+		}
+	}
+
+	return nil
+}
+
+// openArchive locates the project files, which are either found in a directory named after the
+// project, e.g. "P-0123/0.xml", or in a nested archive, e.g. "P-0123.zip", which is encrypted if
+// the project is password-protected.
+func openArchive(data []byte, password string) (*archive, error) {
+	outer, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	a := &archive{outer: outer, project: make(map[string]*zip.File)}
+
+	for _, file := range outer.File {
+		dir, base := path.Split(file.Name)
+
+		switch {
+		case strings.HasPrefix(dir, "P-") && strings.Count(dir, "/") == 1:
+			a.project[base] = file
+
+		case dir == "" && strings.HasPrefix(base, "P-") && strings.HasSuffix(base, ".zip"):
+			if err := a.openNested(file, password); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if _, ok := a.project["0.xml"]; !ok {
+		return nil, errors.New("archive does not contain a project")
+	}
+
+	return a, nil
+}
+
+// openNested opens the nested project archive.
+func (a *archive) openNested(file *zip.File, password string) error {
+	version := 0
+
+	if master := a.find("knx_master.xml"); master != nil {
+		if data, err := readFile(master, nil); err == nil {
+			version = schemaVersion(data)
+		}
+	}
+
+	data, err := readFile(file, nil)
+	if err != nil {
+		return err
+	}
+
+	nested, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range nested.File {
+		a.project[path.Base(file.Name)] = file
+
+		if file.Flags&0x1 != 0 && a.password == nil {
+			if password == "" {
+				return errPasswordRequired
+			}
+
+			a.password = archivePassword(password, version)
+		}
+	}
+
+	return nil
+}
+
+// Read parses the contents of a project file (.knxproj). The password is only needed for
+// password-protected projects.
+func Read(data []byte, password string) (*Project, error) {
+	a, err := openArchive(data, password)
+	if err != nil {
+		return nil, err
+	}
+
+	project := &Project{byAddress: make(map[cemi.GroupAddr]*GroupAddress)}
+
+	if info, err := a.read("project.xml"); err == nil {
+		var file xmlProjectFile
+		if err := decodeXML(info, &file); err != nil {
+			return nil, err
+		}
+
+		project.Name = file.Project.Information.Name
+	}
+
+	installation, err := a.read("0.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	var file xmlInstallationFile
+	if err := decodeXML(installation, &file); err != nil {
+		return nil, err
+	}
+
+	project.ID = file.Project.ID
+	project.SchemaVersion = schemaVersion(installation)
+
+	if len(file.Project.Installations) == 0 {
+		return project, nil
+	}
+
+	inst := file.Project.Installations[0]
+
+	ids := make(map[string]cemi.GroupAddr)
+	project.GroupRanges = project.addGroupRanges(inst.GroupRanges, nil, ids)
+
+	sort.Slice(project.GroupAddresses, func(i, j int) bool {
+		return project.GroupAddresses[i].Address < project.GroupAddresses[j].Address
+	})
+
+	catalog := newCatalog(a)
+
+	for _, xmlArea := range inst.Areas {
+		area := &Area{Address: xmlArea.Address, Name: xmlArea.Name}
+
+		for _, xmlLine := range xmlArea.Lines {
+			line := &Line{Address: xmlLine.Address, Name: xmlLine.Name}
+
+			devices := xmlLine.Devices
+			for _, segment := range xmlLine.Segments {
+				devices = append(devices, segment.Devices...)
+			}
+
+			for _, xmlDevice := range devices {
+				device, err := catalog.device(xmlDevice, ids)
+				if err != nil {
+					return nil, err
+				}
+
+				if device.Addressed {
+					device.Address = cemi.NewIndividualAddr3(area.Address, line.Address, uint8(device.Address))
+				}
+
+				line.Devices = append(line.Devices, device)
+			}
+
+			area.Lines = append(area.Lines, line)
+		}
+
+		project.Areas = append(project.Areas, area)
+	}
+
+	return project, nil
+}
+
+// addGroupRanges converts the group ranges and collects their addresses. The identifiers of the
+// group addresses are recorded in ids.
+func (p *Project) addGroupRanges(
+	xmlRanges []xmlGroupRange,
+	parent *GroupRange,
+	ids map[string]cemi.GroupAddr,
+) []*GroupRange {
+	var ranges []*GroupRange
+
+	for _, xmlRange := range xmlRanges {
+		gr := &GroupRange{
+			Name:  xmlRange.Name,
+			Start: cemi.GroupAddr(xmlRange.Start),
+			End:   cemi.GroupAddr(xmlRange.End),
+		}
+
+		for _, xmlAddr := range xmlRange.Addresses {
+			ga := &GroupAddress{
+				Address:       cemi.GroupAddr(xmlAddr.Address),
+				Name:          xmlAddr.Name,
+				Description:   xmlAddr.Description,
+				DatapointType: xmlAddr.DatapointType,
+			}
+
+			ga.DPT, _ = DPTName(xmlAddr.DatapointType)
+
+			if parent != nil {
+				ga.Main, ga.Middle = parent.Name, gr.Name
+			} else {
+				ga.Main = gr.Name
+			}
+
+			gr.Addresses = append(gr.Addresses, ga)
+			p.GroupAddresses = append(p.GroupAddresses, ga)
+			p.byAddress[ga.Address] = ga
+
+			ids[xmlAddr.ID] = ga.Address
+			ids[shortID(xmlAddr.ID)] = ga.Address
+		}
+
+		gr.Ranges = p.addGroupRanges(xmlRange.Ranges, gr, ids)
+		ranges = append(ranges, gr)
+	}
+
+	return ranges
+}
+
+// shortID strips the project prefix from an identifier, e.g. "P-0123-0_GA-1" becomes "GA-1".
+func shortID(id string) string {
+	return id[strings.LastIndex(id, "_")+1:]
+}
+
+// product contains the catalog information about a product.
+type product struct {
+	manufacturer string
+	name         string
+	orderNumber  string
+}
+
+// catalog provides the manufacturer data which is referenced by devices.
+type catalog struct {
+	archive       *archive
+	manufacturers map[string]string
+	products      map[string]product
+	programs      map[string]string
+	applications  map[string]map[string]comObject
+}
+
+// newCatalog loads the manufacturer and product information of the archive. Missing or malformed
+// catalog files are ignored, as they only provide supplementary information.
+func newCatalog(a *archive) *catalog {
+	c := &catalog{
+		archive:       a,
+		manufacturers: make(map[string]string),
+		products:      make(map[string]product),
+		programs:      make(map[string]string),
+		applications:  make(map[string]map[string]comObject),
+	}
+
+	if master := a.find("knx_master.xml"); master != nil {
+		var file xmlMasterFile
+
+		if data, err := readFile(master, nil); err == nil && decodeXML(data, &file) == nil {
+			for _, manufacturer := range file.Manufacturers {
+				c.manufacturers[manufacturer.ID] = manufacturer.Name
+			}
+		}
+	}
+
+	for _, entry := range a.outer.File {
+		if !strings.HasPrefix(entry.Name, "M-") || path.Base(entry.Name) != "Hardware.xml" {
+			continue
+		}
+
+		var file xmlHardwareFile
+
+		data, err := readFile(entry, nil)
+		if err != nil || decodeXML(data, &file) != nil {
+			continue
+		}
+
+		for _, manufacturer := range file.Manufacturers {
+			for _, hardware := range manufacturer.Hardware {
+				for _, p := range hardware.Products {
+					c.products[p.ID] = product{
+						manufacturer: c.manufacturers[manufacturerID(p.ID)],
+						name:         p.Text,
+						orderNumber:  p.OrderNumber,
+					}
+				}
+
+				for _, program := range hardware.Programs {
+					if len(program.Applications) > 0 {
+						c.programs[program.ID] = program.Applications[0].RefID
+					}
+				}
+			}
+		}
+	}
+
+	return c
+}
+
+// manufacturerID extracts the manufacturer from an identifier, e.g. "M-0083_H-1" yields "M-0083".
+func manufacturerID(id string) string {
+	if i := strings.Index(id, "_"); i >= 0 {
+		return id[:i]
+	}
+
+	return id
+}
+
+// application loads the group objects of the given application program.
+func (c *catalog) application(id string) map[string]comObject {
+	if objects, ok := c.applications[id]; ok {
+		return objects
+	}
+
+	var objects map[string]comObject
+
+	if entry := c.archive.find(manufacturerID(id) + "/" + id + ".xml"); entry != nil {
+		if data, err := readFile(entry, nil); err == nil {
+			objects, _ = parseApplication(data)
+		}
+	}
+
+	c.applications[id] = objects
+
+	return objects
+}
+
+// device converts a device instance.
+func (c *catalog) device(xmlDevice xmlDevice, ids map[string]cemi.GroupAddr) (*Device, error) {
+	device := &Device{
+		Name:        xmlDevice.Name,
+		Description: xmlDevice.Description,
+	}
+
+	if xmlDevice.Address != "" {
+		number, err := strconv.ParseUint(xmlDevice.Address, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("device %s has an invalid address: %w", xmlDevice.ID, err)
+		}
+
+		device.Address = cemi.IndividualAddr(number)
+		device.Addressed = true
+	}
+
+	if p, ok := c.products[xmlDevice.ProductRefID]; ok {
+		device.Manufacturer = p.manufacturer
+		device.ProductName = p.name
+		device.OrderNumber = p.orderNumber
+	} else {
+		device.Manufacturer = c.manufacturers[manufacturerID(xmlDevice.ProductRefID)]
+	}
+
+	appID := c.programs[xmlDevice.Hardware2ProgramRefID]
+	objects := c.application(appID)
+
+	for _, ref := range xmlDevice.ComObjects {
+		var addrs []cemi.GroupAddr
+
+		var links []string
+		for _, connector := range append(ref.Send, ref.Receive...) {
+			links = append(links, connector.GroupAddressRefID)
+		}
+		links = append(links, strings.Fields(ref.Links)...)
+
+		for _, link := range links {
+			if addr, ok := ids[link]; ok {
+				addrs = append(addrs, addr)
+			}
+		}
+
+		if len(addrs) == 0 {
+			continue
+		}
+
+		refID := ref.RefID
+		if !strings.HasPrefix(refID, "M-") && appID != "" {
+			refID = appID + "_" + refID
+		}
+
+		object := objects[refID]
+
+		if ref.Text != "" {
+			object.text = ref.Text
+		}
+
+		if ref.FunctionText != "" {
+			object.functionText = ref.FunctionText
+		}
+
+		if ref.DatapointType != "" {
+			object.datapointType = ref.DatapointType
+		}
+
+		groupObject := GroupObject{
+			Number:         object.number,
+			Name:           object.name,
+			Text:           object.text,
+			FunctionText:   object.functionText,
+			DatapointType:  object.datapointType,
+			GroupAddresses: addrs,
+		}
+
+		groupObject.DPT, _ = DPTName(object.datapointType)
+
+		if !object.hasNumber {
+			groupObject.Number = objectNumber(ref.RefID)
+		}
+
+		device.GroupObjects = append(device.GroupObjects, groupObject)
+	}
+
+	return device, nil
+}
+
+// objectNumber guesses the number of a group object from its reference, e.g. "O-12_R-1" yields 12.
+// It is used when the application program is not available.
+func objectNumber(refID string) int {
+	i := strings.LastIndex(refID, "O-")
+	if i < 0 {
+		return 0
+	}
+
+	digits := refID[i+2:]
+	if j := strings.IndexFunc(digits, func(r rune) bool { return r < '0' || r > '9' }); j >= 0 {
+		digits = digits[:j]
+	}
+
+	number, _ := strconv.Atoi(digits)
+	return number
+}
@@ -0,0 +1,429 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package ets
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"hash/crc32"
+	"reflect"
+	"testing"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+const testMaster = `<?xml version="1.0" encoding="utf-8"?>
+<KNX xmlns="http://knx.org/xml/project/21">
+  <MasterData>
+    <Manufacturers>
+      <Manufacturer Id="M-0083" Name="MDT technologies" />
+    </Manufacturers>
+  </MasterData>
+</KNX>`
+
+const testHardware = `<?xml version="1.0" encoding="utf-8"?>
+<KNX xmlns="http://knx.org/xml/project/21">
+  <ManufacturerData>
+    <Manufacturer RefId="M-0083">
+      <Hardware>
+        <Hardware Id="M-0083_H-1">
+          <Products>
+            <Product Id="M-0083_H-1_P-1" Text="Switch Actuator 4-fold" OrderNumber="AKS-0416.03" />
+          </Products>
+          <Hardware2Programs>
+            <Hardware2Program Id="M-0083_H-1_HP-1">
+              <ApplicationProgramRef RefId="M-0083_A-0001-10-ABCD" />
+            </Hardware2Program>
+          </Hardware2Programs>
+        </Hardware>
+      </Hardware>
+    </Manufacturer>
+  </ManufacturerData>
+</KNX>`
+
+const testApplication = `<?xml version="1.0" encoding="utf-8"?>
+<KNX xmlns="http://knx.org/xml/project/21">
+  <ManufacturerData>
+    <Manufacturer RefId="M-0083">
+      <ApplicationPrograms>
+        <ApplicationProgram Id="M-0083_A-0001-10-ABCD">
+          <Static>
+            <ComObjectTable>
+              <ComObject Id="M-0083_A-0001-10-ABCD_O-0" Name="Switch" Text="Channel A" FunctionText="Switch" Number="0" DatapointType="DPST-1-1" />
+              <ComObject Id="M-0083_A-0001-10-ABCD_O-1" Name="Status" Text="Channel A" FunctionText="Status" Number="1" />
+            </ComObjectTable>
+            <ComObjectRefs>
+              <ComObjectRef Id="M-0083_A-0001-10-ABCD_O-0_R-1" RefId="M-0083_A-0001-10-ABCD_O-0" />
+              <ComObjectRef Id="M-0083_A-0001-10-ABCD_O-1_R-2" RefId="M-0083_A-0001-10-ABCD_O-1" DatapointType="DPST-1-11" />
+            </ComObjectRefs>
+          </Static>
+        </ApplicationProgram>
+      </ApplicationPrograms>
+    </Manufacturer>
+  </ManufacturerData>
+</KNX>`
+
+const testProjectInfo = `<?xml version="1.0" encoding="utf-8"?>
+<KNX xmlns="http://knx.org/xml/project/21">
+  <Project Id="P-0123">
+    <ProjectInformation Name="Office" />
+  </Project>
+</KNX>`
+
+const testInstallation = "\xef\xbb\xbf" + `<?xml version="1.0" encoding="utf-8"?>
+<KNX xmlns="http://knx.org/xml/project/21">
+  <Project Id="P-0123">
+    <Installations>
+      <Installation Name="" InstallationId="0">
+        <Topology>
+          <Area Id="P-0123-0_A-1" Name="Building" Address="1">
+            <Line Id="P-0123-0_L-1" Name="Ground floor" Address="1">
+              <Segment Id="P-0123-0_S-1">
+                <DeviceInstance Id="P-0123-0_DI-1" Name="Lights" Address="7" ProductRefId="M-0083_H-1_P-1" Hardware2ProgramRefId="M-0083_H-1_HP-1">
+                  <ComObjectInstanceRefs>
+                    <ComObjectInstanceRef RefId="O-0_R-1" Links="GA-1" />
+                    <ComObjectInstanceRef RefId="O-1_R-2" Text="Kitchen" Links="GA-2 GA-3" />
+                  </ComObjectInstanceRefs>
+                </DeviceInstance>
+              </Segment>
+            </Line>
+            <Line Id="P-0123-0_L-2" Name="First floor" Address="2">
+              <DeviceInstance Id="P-0123-0_DI-2" Name="Old" Address="1" ProductRefId="M-0083_H-2_P-2">
+                <ComObjectInstanceRefs>
+                  <ComObjectInstanceRef RefId="M-0083_A-0002-10-ABCD_O-3_R-3" DatapointType="DPST-5-1">
+                    <Connectors>
+                      <Send GroupAddressRefId="P-0123-0_GA-3" />
+                    </Connectors>
+                  </ComObjectInstanceRef>
+                  <ComObjectInstanceRef RefId="M-0083_A-0002-10-ABCD_O-4_R-4" />
+                </ComObjectInstanceRefs>
+              </DeviceInstance>
+              <DeviceInstance Id="P-0123-0_DI-3" Name="New" ProductRefId="M-0083_H-1_P-1" />
+            </Line>
+          </Area>
+        </Topology>
+        <GroupAddresses>
+          <GroupRanges>
+            <GroupRange Id="P-0123-0_GR-1" Name="Lights" RangeStart="2048" RangeEnd="4095">
+              <GroupRange Id="P-0123-0_GR-2" Name="Kitchen" RangeStart="2048" RangeEnd="2303">
+                <GroupAddress Id="P-0123-0_GA-1" Address="2049" Name="Switch" DatapointType="DPST-1-1" />
+                <GroupAddress Id="P-0123-0_GA-2" Address="2050" Name="Status" Description="Feedback" DatapointType="DPT-1" />
+              </GroupRange>
+              <GroupAddress Id="P-0123-0_GA-3" Address="2048" Name="Central" DatapointType="DPST-5-1 DPST-5-4" />
+            </GroupRange>
+          </GroupRanges>
+        </GroupAddresses>
+      </Installation>
+    </Installations>
+  </Project>
+</KNX>`
+
+type testFile struct {
+	name string
+	data string
+}
+
+// buildZip creates an archive. The files are encrypted using the given function, if any.
+func buildZip(t *testing.T, files []testFile, encrypt func(*zip.Writer, string, []byte)) []byte {
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+
+	for _, file := range files {
+		if encrypt != nil {
+			encrypt(writer, file.name, []byte(file.data))
+			continue
+		}
+
+		w, err := writer.Create(file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w.Write([]byte(file.data))
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
+
+func deflate(data []byte) []byte {
+	var buffer bytes.Buffer
+
+	writer, _ := flate.NewWriter(&buffer, flate.DefaultCompression)
+	writer.Write(data)
+	writer.Close()
+
+	return buffer.Bytes()
+}
+
+// encryptAES returns a function which adds AE-2 encrypted entries.
+func encryptAES(t *testing.T, password []byte) func(*zip.Writer, string, []byte) {
+	return func(writer *zip.Writer, name string, data []byte) {
+		salt := []byte("0123456789abcdef")
+		encKey, authKey, verifier := aesKeys(password, salt, 32)
+
+		encrypted, err := aesCTR(encKey, deflate(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mac := hmac.New(sha1.New, authKey)
+		mac.Write(encrypted)
+
+		raw := append(append(append(append([]byte(nil), salt...), verifier...), encrypted...), mac.Sum(nil)[:10]...)
+
+		extra := make([]byte, 11)
+		binary.LittleEndian.PutUint16(extra, aesExtraID)
+		binary.LittleEndian.PutUint16(extra[2:], 7)
+		binary.LittleEndian.PutUint16(extra[4:], 2)
+		copy(extra[6:], "AE")
+		extra[8] = 3
+		binary.LittleEndian.PutUint16(extra[9:], zip.Deflate)
+
+		w, err := writer.CreateRaw(&zip.FileHeader{
+			Name:               name,
+			Method:             aesMethod,
+			Flags:              0x1,
+			Extra:              extra,
+			CompressedSize64:   uint64(len(raw)),
+			UncompressedSize64: uint64(len(data)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w.Write(raw)
+	}
+}
+
+// encryptZipCrypto returns a function which adds traditionally encrypted entries.
+func encryptZipCrypto(t *testing.T, password []byte) func(*zip.Writer, string, []byte) {
+	return func(writer *zip.Writer, name string, data []byte) {
+		checksum := crc32.ChecksumIEEE(data)
+
+		plain := append(make([]byte, 12), deflate(data)...)
+		plain[11] = byte(checksum >> 24)
+
+		zc := newZipCrypto(password)
+		raw := make([]byte, len(plain))
+
+		for i, p := range plain {
+			raw[i] = p ^ zc.streamByte()
+			zc.update(p)
+		}
+
+		w, err := writer.CreateRaw(&zip.FileHeader{
+			Name:               name,
+			Method:             zip.Deflate,
+			Flags:              0x1,
+			CRC32:              checksum,
+			CompressedSize64:   uint64(len(raw)),
+			UncompressedSize64: uint64(len(data)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w.Write(raw)
+	}
+}
+
+var testCatalog = []testFile{
+	{"knx_master.xml", testMaster},
+	{"M-0083/Hardware.xml", testHardware},
+	{"M-0083/M-0083_A-0001-10-ABCD.xml", testApplication},
+}
+
+func checkProject(t *testing.T, project *Project) {
+	t.Helper()
+
+	if project.ID != "P-0123" || project.Name != "Office" || project.SchemaVersion != 21 {
+		t.Errorf("Unexpected project: %s %q %d", project.ID, project.Name, project.SchemaVersion)
+	}
+
+	if len(project.GroupAddresses) != 3 {
+		t.Fatalf("Expected 3 group addresses, got %d", len(project.GroupAddresses))
+	}
+
+	central := project.GroupAddresses[0]
+	if central.Address != cemi.NewGroupAddr3(1, 0, 0) || central.DPT != "5.001" ||
+		central.Main != "Lights" || central.Middle != "" {
+		t.Errorf("Unexpected group address: %+v", central)
+	}
+
+	status, ok := project.GroupAddress(cemi.NewGroupAddr3(1, 0, 2))
+	if !ok || status.Name != "Status" || status.Description != "Feedback" || status.DPT != "" ||
+		status.Main != "Lights" || status.Middle != "Kitchen" {
+		t.Errorf("Unexpected group address: %+v", status)
+	}
+
+	if len(project.GroupRanges) != 1 || len(project.GroupRanges[0].Ranges) != 1 ||
+		len(project.GroupRanges[0].Ranges[0].Addresses) != 2 {
+		t.Errorf("Unexpected group ranges: %+v", project.GroupRanges)
+	}
+
+	if len(project.Areas) != 1 || len(project.Areas[0].Lines) != 2 {
+		t.Fatalf("Unexpected topology: %+v", project.Areas)
+	}
+
+	lights := project.Areas[0].Lines[0].Devices[0]
+	if lights.Address != cemi.NewIndividualAddr3(1, 1, 7) || !lights.Addressed ||
+		lights.ProductName != "Switch Actuator 4-fold" || lights.Manufacturer != "MDT technologies" {
+		t.Errorf("Unexpected device: %+v", lights)
+	}
+
+	if len(lights.GroupObjects) != 2 {
+		t.Fatalf("Unexpected group objects: %+v", lights.GroupObjects)
+	}
+
+	if object := lights.GroupObjects[0]; object.Number != 0 || object.Name != "Switch" ||
+		object.DPT != "1.001" || len(object.GroupAddresses) != 1 {
+		t.Errorf("Unexpected group object: %+v", object)
+	}
+
+	if object := lights.GroupObjects[1]; object.Number != 1 || object.Text != "Kitchen" ||
+		object.DPT != "1.011" || len(object.GroupAddresses) != 2 {
+		t.Errorf("Unexpected group object: %+v", object)
+	}
+
+	old := project.Areas[0].Lines[1].Devices[0]
+	if len(old.GroupObjects) != 1 || old.GroupObjects[0].Number != 3 ||
+		old.GroupObjects[0].DPT != "5.001" || old.Manufacturer != "MDT technologies" {
+		t.Errorf("Unexpected device: %+v", old)
+	}
+
+	if unaddressed := project.Areas[0].Lines[1].Devices[1]; unaddressed.Addressed {
+		t.Errorf("Unexpected device: %+v", unaddressed)
+	}
+}
+
+func TestRead(t *testing.T) {
+	files := append(testCatalog,
+		testFile{"P-0123.signature", ""},
+		testFile{"P-0123/project.xml", testProjectInfo},
+		testFile{"P-0123/0.xml", testInstallation},
+	)
+
+	project, err := Read(buildZip(t, files, nil), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkProject(t, project)
+}
+
+func TestProject_FilterTable(t *testing.T) {
+	files := append(testCatalog,
+		testFile{"P-0123/project.xml", testProjectInfo},
+		testFile{"P-0123/0.xml", testInstallation},
+	)
+
+	project, err := Read(buildZip(t, files, nil), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		area, line uint8
+		addrs      []cemi.GroupAddr
+	}{
+		{1, 1, []cemi.GroupAddr{
+			cemi.NewGroupAddr3(1, 0, 0), cemi.NewGroupAddr3(1, 0, 1), cemi.NewGroupAddr3(1, 0, 2),
+		}},
+		{1, 2, []cemi.GroupAddr{cemi.NewGroupAddr3(1, 0, 0)}},
+		{1, 3, []cemi.GroupAddr{}},
+	} {
+		if addrs := project.FilterTable(tt.area, tt.line); !reflect.DeepEqual(addrs, tt.addrs) {
+			t.Errorf("Unexpected filter table of line %d.%d: %v", tt.area, tt.line, addrs)
+		}
+	}
+}
+
+func TestRead_protected(t *testing.T) {
+	projectFiles := []testFile{
+		{"project.xml", testProjectInfo},
+		{"0.xml", testInstallation},
+	}
+
+	for name, encrypt := range map[string]func(*testing.T, []byte) func(*zip.Writer, string, []byte){
+		"AES":       encryptAES,
+		"ZipCrypto": encryptZipCrypto,
+	} {
+		t.Run(name, func(t *testing.T) {
+			nested := buildZip(t, projectFiles, encrypt(t, archivePassword("secret", 21)))
+			data := buildZip(t, append(testCatalog, testFile{"P-0123.zip", string(nested)}), nil)
+
+			project, err := Read(data, "secret")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checkProject(t, project)
+
+			if _, err := Read(data, "wrong"); err != errWrongPassword {
+				t.Errorf("Expected wrong password error, got %v", err)
+			}
+
+			if _, err := Read(data, ""); err != errPasswordRequired {
+				t.Errorf("Expected password required error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPBKDF2(t *testing.T) {
+	// Test vectors from RFC 6070.
+	key := pbkdf2(sha1.New, []byte("password"), []byte("salt"), 2, 20)
+	expected := []byte{
+		0xea, 0x6c, 0x01, 0x4d, 0xc7, 0x2d, 0x6f, 0x8c, 0xcd, 0x1e,
+		0xd9, 0x2a, 0xce, 0x1d, 0x41, 0xf0, 0xd8, 0xde, 0x89, 0x57,
+	}
+
+	if !bytes.Equal(key, expected) {
+		t.Errorf("Unexpected key: %x", key)
+	}
+
+	key = pbkdf2(sha1.New, []byte("passwordPASSWORDpassword"), []byte("saltSALTsaltSALTsaltSALTsaltSALTsalt"), 4096, 25)
+	expected = []byte{
+		0x3d, 0x2e, 0xec, 0x4f, 0xe4, 0x1c, 0x84, 0x9b, 0x80, 0xc8,
+		0xd8, 0x36, 0x62, 0xc0, 0xe4, 0x4a, 0x8b, 0x29, 0x1a, 0x96,
+		0x4c, 0xf2, 0xf0, 0x70, 0x38,
+	}
+
+	if !bytes.Equal(key, expected) {
+		t.Errorf("Unexpected key: %x", key)
+	}
+}
+
+func TestDPTName(t *testing.T) {
+	cases := map[string]string{
+		"DPST-9-1":          "9.001",
+		"DPST-232-600":      "232.600",
+		"DPST-1-1 DPST-1-2": "1.001",
+		"5.010":             "5.010",
+		"5.1":               "5.001",
+	}
+
+	for ref, expected := range cases {
+		if name, ok := DPTName(ref); !ok || name != expected {
+			t.Errorf("%s: expected %s, got %s", ref, expected, name)
+		}
+	}
+
+	for _, ref := range []string{"", "DPT-9", "DPST-9", "nine", "9.x"} {
+		if name, ok := DPTName(ref); ok {
+			t.Errorf("%s: expected failure, got %s", ref, name)
+		}
+	}
+
+	if ref, ok := DPTRef("9.001"); !ok || ref != "DPST-9-1" {
+		t.Errorf("Unexpected reference: %s", ref)
+	}
+}
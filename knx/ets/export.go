@@ -0,0 +1,190 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package ets
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// exportNamespace is the namespace of the XML group address export.
+const exportNamespace = "http://knx.org/xml/ga-export/01"
+
+type xmlExport struct {
+	XMLName   xml.Name           `xml:"GroupAddress-Export"`
+	Namespace string             `xml:"xmlns,attr,omitempty"`
+	Ranges    []xmlExportRange   `xml:"GroupRange"`
+	Addresses []xmlExportAddress `xml:"GroupAddress"`
+}
+
+type xmlExportRange struct {
+	Name      string             `xml:"Name,attr"`
+	Start     uint16             `xml:"RangeStart,attr"`
+	End       uint16             `xml:"RangeEnd,attr"`
+	Ranges    []xmlExportRange   `xml:"GroupRange"`
+	Addresses []xmlExportAddress `xml:"GroupAddress"`
+}
+
+type xmlExportAddress struct {
+	Name        string `xml:"Name,attr"`
+	Address     string `xml:"Address,attr"`
+	Description string `xml:"Description,attr,omitempty"`
+	DPTs        string `xml:"DPTs,attr,omitempty"`
+}
+
+// convertExportAddresses adds the group addresses to the table and the range.
+func convertExportAddresses(
+	table *GroupAddressTable,
+	gr *GroupRange,
+	main, middle string,
+	xmlAddrs []xmlExportAddress,
+) error {
+	for _, xmlAddr := range xmlAddrs {
+		addr, err := cemi.NewGroupAddrString(xmlAddr.Address)
+		if err != nil {
+			return fmt.Errorf("group address %q: %w", xmlAddr.Name, err)
+		}
+
+		ga := &GroupAddress{
+			Address:       addr,
+			Name:          xmlAddr.Name,
+			Description:   xmlAddr.Description,
+			DatapointType: xmlAddr.DPTs,
+			Main:          main,
+			Middle:        middle,
+		}
+
+		ga.DPT, _ = DPTName(ga.DatapointType)
+
+		table.Addresses[addr] = ga
+
+		if gr != nil {
+			gr.Addresses = append(gr.Addresses, ga)
+		}
+	}
+
+	return nil
+}
+
+// ReadXML reads a group address export in XML format.
+func ReadXML(r io.Reader) (*GroupAddressTable, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var export xmlExport
+	if err := decodeXML(data, &export); err != nil {
+		return nil, err
+	}
+
+	table := NewGroupAddressTable()
+
+	if err := convertExportAddresses(table, nil, "", "", export.Addresses); err != nil {
+		return nil, err
+	}
+
+	for _, xmlMain := range export.Ranges {
+		main := &GroupRange{
+			Name:  xmlMain.Name,
+			Start: cemi.GroupAddr(xmlMain.Start),
+			End:   cemi.GroupAddr(xmlMain.End),
+		}
+
+		for _, xmlMiddle := range xmlMain.Ranges {
+			middle := &GroupRange{
+				Name:  xmlMiddle.Name,
+				Start: cemi.GroupAddr(xmlMiddle.Start),
+				End:   cemi.GroupAddr(xmlMiddle.End),
+			}
+
+			if err := convertExportAddresses(
+				table, middle, main.Name, middle.Name, xmlMiddle.Addresses,
+			); err != nil {
+				return nil, err
+			}
+
+			main.Ranges = append(main.Ranges, middle)
+		}
+
+		if err := convertExportAddresses(table, main, main.Name, "", xmlMain.Addresses); err != nil {
+			return nil, err
+		}
+
+		table.Ranges = append(table.Ranges, main)
+	}
+
+	return table, nil
+}
+
+// exportAddresses converts the group addresses for the export.
+func exportAddresses(list []*GroupAddress, written map[cemi.GroupAddr]bool) []xmlExportAddress {
+	var xmlAddrs []xmlExportAddress
+
+	for _, ga := range sortAddresses(list) {
+		xmlAddrs = append(xmlAddrs, xmlExportAddress{
+			Name:        ga.Name,
+			Address:     ga.Address.String(),
+			Description: ga.Description,
+			DPTs:        datapointTypeOf(ga),
+		})
+
+		written[ga.Address] = true
+	}
+
+	return xmlAddrs
+}
+
+// exportRange converts a group range for the export.
+func exportRange(gr *GroupRange, written map[cemi.GroupAddr]bool) xmlExportRange {
+	xmlRange := xmlExportRange{
+		Name:      gr.Name,
+		Start:     uint16(gr.Start),
+		End:       uint16(gr.End),
+		Addresses: exportAddresses(gr.Addresses, written),
+	}
+
+	for _, sub := range gr.Ranges {
+		xmlRange.Ranges = append(xmlRange.Ranges, exportRange(sub, written))
+	}
+
+	return xmlRange
+}
+
+// WriteXML writes the table in the XML format of ETS.
+func WriteXML(w io.Writer, table *GroupAddressTable) error {
+	export := xmlExport{Namespace: exportNamespace}
+	written := make(map[cemi.GroupAddr]bool)
+
+	for _, main := range table.Ranges {
+		export.Ranges = append(export.Ranges, exportRange(main, written))
+	}
+
+	// Addresses which are not part of a range are placed at the top level.
+	var rest []*GroupAddress
+
+	for _, ga := range table.Sorted() {
+		if !written[ga.Address] {
+			rest = append(rest, ga)
+		}
+	}
+
+	export.Addresses = exportAddresses(rest, written)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(export); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
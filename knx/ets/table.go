@@ -0,0 +1,175 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package ets
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/dpt"
+)
+
+// Datapoint produces a value of the datapoint type of the group address.
+func (ga *GroupAddress) Datapoint() (dpt.Datapoint, bool) {
+	if ga.DPT == "" {
+		return nil, false
+	}
+
+	return dpt.Produce(ga.DPT)
+}
+
+// A GroupAddressTable contains group addresses and the group ranges they are organised in.
+type GroupAddressTable struct {
+	// Ranges contains the main groups.
+	Ranges []*GroupRange
+
+	// Addresses contains all group addresses.
+	Addresses map[cemi.GroupAddr]*GroupAddress
+}
+
+// NewGroupAddressTable creates an empty table.
+func NewGroupAddressTable() *GroupAddressTable {
+	return &GroupAddressTable{Addresses: make(map[cemi.GroupAddr]*GroupAddress)}
+}
+
+// GroupAddressTable returns the group addresses of the project.
+func (p *Project) GroupAddressTable() *GroupAddressTable {
+	return &GroupAddressTable{Ranges: p.GroupRanges, Addresses: p.byAddress}
+}
+
+// Sorted returns the group addresses ordered by address.
+func (table *GroupAddressTable) Sorted() []*GroupAddress {
+	list := make([]*GroupAddress, 0, len(table.Addresses))
+	for _, ga := range table.Addresses {
+		list = append(list, ga)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Address < list[j].Address
+	})
+
+	return list
+}
+
+// addRange adds a main group, or a middle group to the main group that contains it.
+func (table *GroupAddressTable) addRange(gr *GroupRange, middle bool) {
+	if middle {
+		for _, main := range table.Ranges {
+			if main.Start <= gr.Start && gr.End <= main.End {
+				main.Ranges = append(main.Ranges, gr)
+				return
+			}
+		}
+	}
+
+	table.Ranges = append(table.Ranges, gr)
+}
+
+// add inserts the group address into the innermost range containing it and records the names of
+// the main and middle group.
+func (table *GroupAddressTable) add(ga *GroupAddress) {
+	table.Addresses[ga.Address] = ga
+
+	for _, main := range table.Ranges {
+		if ga.Address < main.Start || main.End < ga.Address {
+			continue
+		}
+
+		ga.Main = main.Name
+
+		for _, middle := range main.Ranges {
+			if middle.Start <= ga.Address && ga.Address <= middle.End {
+				ga.Middle = middle.Name
+				middle.Addresses = append(middle.Addresses, ga)
+				return
+			}
+		}
+
+		main.Addresses = append(main.Addresses, ga)
+		return
+	}
+}
+
+// parseRange parses the address of a group range as found in exports, e.g. "1/-/-" for a main
+// group or "1/2/-" for a middle group. Two-level addresses such as "1/-" are accepted as well.
+func parseRange(addr string) (gr *GroupRange, middle bool, ok bool) {
+	parts := strings.Split(addr, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[len(parts)-1] != "-" {
+		return nil, false, false
+	}
+
+	main, err := strconv.ParseUint(parts[0], 10, 5)
+	if err != nil {
+		return nil, false, false
+	}
+
+	if len(parts) == 2 {
+		return &GroupRange{
+			Start: cemi.NewGroupAddr2(uint8(main), 0),
+			End:   cemi.NewGroupAddr2(uint8(main), 2047),
+		}, false, true
+	}
+
+	if parts[1] == "-" {
+		return &GroupRange{
+			Start: cemi.NewGroupAddr3(uint8(main), 0, 0),
+			End:   cemi.NewGroupAddr3(uint8(main), 7, 255),
+		}, false, true
+	}
+
+	mid, err := strconv.ParseUint(parts[1], 10, 3)
+	if err != nil {
+		return nil, false, false
+	}
+
+	return &GroupRange{
+		Start: cemi.NewGroupAddr3(uint8(main), uint8(mid), 0),
+		End:   cemi.NewGroupAddr3(uint8(main), uint8(mid), 255),
+	}, true, true
+}
+
+// formatRange formats the address of a group range as ETS does in its exports.
+func formatRange(gr *GroupRange, middle bool) string {
+	main := strconv.Itoa(int(gr.Start>>11) & 0x1f)
+
+	if !middle {
+		return main + "/-/-"
+	}
+
+	return main + "/" + strconv.Itoa(int(gr.Start>>8)&0x7) + "/-"
+}
+
+// LoadGroupAddresses reads the group addresses from a file, which may be an ETS project, an XML
+// export or a CSV export. The format is determined from the contents of the file. The password is
+// only needed for password-protected projects.
+func LoadGroupAddresses(name string, password string) (*GroupAddressTable, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, utf8BOM))
+
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("PK")):
+		project, err := Read(data, password)
+		if err != nil {
+			return nil, err
+		}
+
+		return project.GroupAddressTable(), nil
+
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return ReadXML(bytes.NewReader(data))
+
+	case len(trimmed) > 0:
+		return ReadCSV(bytes.NewReader(data))
+	}
+
+	return nil, errors.New("file is empty")
+}
@@ -0,0 +1,225 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package ets
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/dpt"
+)
+
+const testCSVOneColumn = "\xef\xbb\xbf" +
+	`"Group name";"Address";"Central";"Unfiltered";"Description";"DatapointType";"Security"
+"Lighting";"1/-/-";"";"";"";"";"Auto"
+"Living room";"1/0/-";"";"";"";"";"Auto"
+"Ceiling switch";"1/0/1";"";"";"Main light";"DPST-1-1";"Auto"
+"Ceiling status";"1/0/2";"";"";"";"DPST-1-1";"Auto"
+"Temperature";"2/0/0";"";"";"";"DPST-9-1";"Auto"
+`
+
+const testCSVThreeColumns = `"Main";"Middle";"Sub";"Address";"Central";"Unfiltered";"Description";"DatapointType";"Security"
+"Lighting";"";"";"1/-/-";"";"";"";"";"Auto"
+"";"Living room";"";"1/0/-";"";"";"";"";"Auto"
+"";"";"Ceiling switch";"1/0/1";"";"";"Main light";"DPST-1-1";"Auto"
+"";"";"Ceiling status";"1/0/2";"";"";"";"DPST-1-1";"Auto"
+"";"";"Temperature";"2/0/0";"";"";"";"DPST-9-1";"Auto"
+`
+
+const testCSVOld = "Main,Middle,Sub,Main,Middle,Sub,Central,Unfiltered,Description,DatapointType\n" +
+	"Lighting,,,1,,,,,,\n" +
+	"Lighting,Living room,,1,0,,,,,\n" +
+	"Lighting,Living room,Ceiling switch,1,0,1,,,Main light,DPST-1-1\n" +
+	"Lighting,Living room,Ceiling status,1,0,2,,,,DPST-1-1\n" +
+	",,Temperature,2,0,0,,,,DPST-9-1\n"
+
+const testCSVNoHeader = "Lighting\t1/-/-\t\t\t\t\n" +
+	"Living room\t1/0/-\t\t\t\t\n" +
+	"Ceiling switch\t1/0/1\t\t\tMain light\tDPST-1-1\n" +
+	"Ceiling status\t1/0/2\t\t\t\tDPST-1-1\n" +
+	"Temperature\t2/0/0\t\t\t\tDPST-9-1\n"
+
+const testXMLExport = `<?xml version="1.0" encoding="utf-8"?>
+<GroupAddress-Export xmlns="http://knx.org/xml/ga-export/01">
+  <GroupRange Name="Lighting" RangeStart="2048" RangeEnd="4095">
+    <GroupRange Name="Living room" RangeStart="2048" RangeEnd="2303">
+      <GroupAddress Name="Ceiling switch" Address="1/0/1" Description="Main light" DPTs="DPST-1-1" />
+      <GroupAddress Name="Ceiling status" Address="1/0/2" DPTs="DPST-1-1" />
+    </GroupRange>
+  </GroupRange>
+  <GroupAddress Name="Temperature" Address="2/0/0" DPTs="DPST-9-1" />
+</GroupAddress-Export>
+`
+
+func checkTable(t *testing.T, table *GroupAddressTable) {
+	t.Helper()
+
+	if len(table.Addresses) != 3 {
+		t.Fatalf("Unexpected number of group addresses: %d", len(table.Addresses))
+	}
+
+	ga := table.Addresses[cemi.NewGroupAddr3(1, 0, 1)]
+	if ga == nil {
+		t.Fatal("Group address 1/0/1 is missing")
+	}
+
+	if ga.Name != "Ceiling switch" || ga.Description != "Main light" || ga.DPT != "1.001" ||
+		ga.Main != "Lighting" || ga.Middle != "Living room" {
+		t.Errorf("Unexpected group address: %+v", ga)
+	}
+
+	if value, ok := ga.Datapoint(); !ok {
+		t.Error("Datapoint could not be produced")
+	} else if _, ok := value.(*dpt.DPT_1001); !ok {
+		t.Errorf("Unexpected datapoint type %T", value)
+	}
+
+	temperature := table.Addresses[cemi.NewGroupAddr3(2, 0, 0)]
+	if temperature == nil || temperature.DPT != "9.001" || temperature.Main != "" {
+		t.Errorf("Unexpected group address: %+v", temperature)
+	}
+
+	if len(table.Ranges) != 1 || len(table.Ranges[0].Ranges) != 1 {
+		t.Fatalf("Unexpected group ranges: %+v", table.Ranges)
+	}
+
+	main := table.Ranges[0]
+	if main.Name != "Lighting" || main.Start != cemi.NewGroupAddr3(1, 0, 0) ||
+		main.End != cemi.NewGroupAddr3(1, 7, 255) {
+		t.Errorf("Unexpected main group: %+v", main)
+	}
+
+	middle := main.Ranges[0]
+	if middle.Name != "Living room" || len(middle.Addresses) != 2 {
+		t.Errorf("Unexpected middle group: %+v", middle)
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	latin1 := strings.Replace(testCSVThreeColumns, "Living room", "Wohnzimmer K\xfcche", 1)
+
+	inputs := map[string]string{
+		"OneColumn":   testCSVOneColumn,
+		"ThreeColumn": testCSVThreeColumns,
+		"Old":         testCSVOld,
+		"NoHeader":    testCSVNoHeader,
+		"Latin1":      latin1,
+	}
+
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			table, err := ReadCSV(strings.NewReader(input))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if name == "Latin1" {
+				if name := table.Ranges[0].Ranges[0].Name; name != "Wohnzimmer Küche" {
+					t.Errorf("Unexpected name %q", name)
+				}
+
+				table.Ranges[0].Ranges[0].Name = "Living room"
+				for _, ga := range table.Addresses {
+					if ga.Middle != "" {
+						ga.Middle = "Living room"
+					}
+				}
+			}
+
+			checkTable(t, table)
+		})
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	table, err := ReadXML(strings.NewReader(testXMLExport))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []CSVFormat{CSVOneColumn, CSVThreeColumns} {
+		var buffer bytes.Buffer
+		if err := WriteCSV(&buffer, table, format); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := ReadCSV(&buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkTable(t, result)
+	}
+}
+
+func TestReadXML(t *testing.T) {
+	table, err := ReadXML(strings.NewReader(testXMLExport))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkTable(t, table)
+}
+
+func TestWriteXML(t *testing.T) {
+	table, err := ReadCSV(strings.NewReader(testCSVOneColumn))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buffer bytes.Buffer
+	if err := WriteXML(&buffer, table); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ReadXML(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkTable(t, result)
+}
+
+func TestLoadGroupAddresses(t *testing.T) {
+	dir := t.TempDir()
+
+	projectFiles := []testFile{
+		{"P-0123/project.xml", testProjectInfo},
+		{"P-0123/0.xml", testInstallation},
+	}
+
+	files := map[string][]byte{
+		"project.knxproj": buildZip(t, projectFiles, nil),
+		"export.xml":      []byte(testXMLExport),
+		"export.csv":      []byte(testCSVThreeColumns),
+	}
+
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		table, err := LoadGroupAddresses(path, "")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if len(table.Addresses) == 0 {
+			t.Errorf("%s: no group addresses", name)
+		}
+	}
+
+	empty := filepath.Join(dir, "empty.csv")
+	if err := os.WriteFile(empty, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadGroupAddresses(empty, ""); err == nil {
+		t.Error("Empty file was accepted")
+	}
+}
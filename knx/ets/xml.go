@@ -0,0 +1,244 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package ets
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// These structures mirror the parts of the ETS XML schema which are of interest. Element names are
+// matched regardless of their namespace, which differs between schema versions.
+
+type xmlProjectFile struct {
+	XMLName xml.Name `xml:"KNX"`
+	Project struct {
+		ID          string `xml:"Id,attr"`
+		Information struct {
+			Name string `xml:"Name,attr"`
+		} `xml:"ProjectInformation"`
+	} `xml:"Project"`
+}
+
+type xmlInstallationFile struct {
+	XMLName xml.Name `xml:"KNX"`
+	Project struct {
+		ID            string            `xml:"Id,attr"`
+		Installations []xmlInstallation `xml:"Installations>Installation"`
+	} `xml:"Project"`
+}
+
+type xmlInstallation struct {
+	Name        string          `xml:"Name,attr"`
+	Areas       []xmlArea       `xml:"Topology>Area"`
+	GroupRanges []xmlGroupRange `xml:"GroupAddresses>GroupRanges>GroupRange"`
+}
+
+type xmlArea struct {
+	Name    string    `xml:"Name,attr"`
+	Address uint8     `xml:"Address,attr"`
+	Lines   []xmlLine `xml:"Line"`
+}
+
+type xmlLine struct {
+	Name     string      `xml:"Name,attr"`
+	Address  uint8       `xml:"Address,attr"`
+	Devices  []xmlDevice `xml:"DeviceInstance"`
+	Segments []struct {
+		Devices []xmlDevice `xml:"DeviceInstance"`
+	} `xml:"Segment"`
+}
+
+type xmlDevice struct {
+	ID                    string                    `xml:"Id,attr"`
+	Name                  string                    `xml:"Name,attr"`
+	Description           string                    `xml:"Description,attr"`
+	Address               string                    `xml:"Address,attr"`
+	ProductRefID          string                    `xml:"ProductRefId,attr"`
+	Hardware2ProgramRefID string                    `xml:"Hardware2ProgramRefId,attr"`
+	ComObjects            []xmlComObjectInstanceRef `xml:"ComObjectInstanceRefs>ComObjectInstanceRef"`
+}
+
+type xmlConnector struct {
+	GroupAddressRefID string `xml:"GroupAddressRefId,attr"`
+}
+
+type xmlComObjectInstanceRef struct {
+	RefID         string         `xml:"RefId,attr"`
+	Text          string         `xml:"Text,attr"`
+	FunctionText  string         `xml:"FunctionText,attr"`
+	DatapointType string         `xml:"DatapointType,attr"`
+	Links         string         `xml:"Links,attr"`
+	Send          []xmlConnector `xml:"Connectors>Send"`
+	Receive       []xmlConnector `xml:"Connectors>Receive"`
+}
+
+type xmlGroupRange struct {
+	Name      string            `xml:"Name,attr"`
+	Start     uint16            `xml:"RangeStart,attr"`
+	End       uint16            `xml:"RangeEnd,attr"`
+	Ranges    []xmlGroupRange   `xml:"GroupRange"`
+	Addresses []xmlGroupAddress `xml:"GroupAddress"`
+}
+
+type xmlGroupAddress struct {
+	ID            string `xml:"Id,attr"`
+	Name          string `xml:"Name,attr"`
+	Description   string `xml:"Description,attr"`
+	DatapointType string `xml:"DatapointType,attr"`
+	Address       uint16 `xml:"Address,attr"`
+}
+
+type xmlMasterFile struct {
+	Manufacturers []struct {
+		ID   string `xml:"Id,attr"`
+		Name string `xml:"Name,attr"`
+	} `xml:"MasterData>Manufacturers>Manufacturer"`
+}
+
+type xmlHardwareFile struct {
+	Manufacturers []struct {
+		Hardware []struct {
+			Products []struct {
+				ID          string `xml:"Id,attr"`
+				Text        string `xml:"Text,attr"`
+				OrderNumber string `xml:"OrderNumber,attr"`
+			} `xml:"Products>Product"`
+			Programs []struct {
+				ID           string `xml:"Id,attr"`
+				Applications []struct {
+					RefID string `xml:"RefId,attr"`
+				} `xml:"ApplicationProgramRef"`
+			} `xml:"Hardware2Programs>Hardware2Program"`
+		} `xml:"Hardware>Hardware"`
+	} `xml:"ManufacturerData>Manufacturer"`
+}
+
+// comObject contains the properties of a group object as defined by an application program.
+type comObject struct {
+	name          string
+	text          string
+	functionText  string
+	datapointType string
+	number        int
+	hasNumber     bool
+}
+
+// utf8BOM is the byte order mark which ETS places in front of its files.
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// newDecoder creates an XML decoder which skips the byte order mark.
+func newDecoder(data []byte) *xml.Decoder {
+	return xml.NewDecoder(bytes.NewReader(bytes.TrimPrefix(data, utf8BOM)))
+}
+
+// decodeXML parses the data into the given structure.
+func decodeXML(data []byte, output interface{}) error {
+	return newDecoder(data).Decode(output)
+}
+
+// schemaVersion determines the version of the ETS schema from the namespace of the root element,
+// e.g. "http://knx.org/xml/project/21".
+func schemaVersion(data []byte) int {
+	decoder := newDecoder(data)
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return 0
+		}
+
+		if start, ok := token.(xml.StartElement); ok {
+			space := start.Name.Space
+
+			version, err := strconv.Atoi(space[strings.LastIndex(space, "/")+1:])
+			if err != nil {
+				return 0
+			}
+
+			return version
+		}
+	}
+}
+
+// attrs collects the attributes of an element.
+func attrs(start xml.StartElement) map[string]string {
+	values := make(map[string]string, len(start.Attr))
+	for _, attr := range start.Attr {
+		values[attr.Name.Local] = attr.Value
+	}
+
+	return values
+}
+
+// parseApplication extracts the group objects of an application program. Application programs can
+// be large, which is why the file is scanned for the relevant elements instead of being decoded as
+// a whole. The result maps the identifiers of the ComObjectRef elements to the resolved properties.
+func parseApplication(data []byte) (map[string]comObject, error) {
+	objects := make(map[string]comObject)
+	refs := make(map[string]map[string]string)
+
+	decoder := newDecoder(data)
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "ComObject":
+			values := attrs(start)
+			number, err := strconv.Atoi(values["Number"])
+
+			objects[values["Id"]] = comObject{
+				name:          values["Name"],
+				text:          values["Text"],
+				functionText:  values["FunctionText"],
+				datapointType: values["DatapointType"],
+				number:        number,
+				hasNumber:     err == nil,
+			}
+
+		case "ComObjectRef":
+			values := attrs(start)
+			refs[values["Id"]] = values
+		}
+	}
+
+	resolved := make(map[string]comObject, len(refs))
+
+	for id, values := range refs {
+		object := objects[values["RefId"]]
+
+		if name, ok := values["Name"]; ok {
+			object.name = name
+		}
+
+		if text, ok := values["Text"]; ok {
+			object.text = text
+		}
+
+		if functionText, ok := values["FunctionText"]; ok {
+			object.functionText = functionText
+		}
+
+		if datapointType, ok := values["DatapointType"]; ok {
+			object.datapointType = datapointType
+		}
+
+		resolved[id] = object
+	}
+
+	return resolved, nil
+}
@@ -0,0 +1,415 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// An Endpoint describes a KNXnet/IP server which a FailoverClient may use.
+type Endpoint struct {
+	// Address is the address of the gateway when tunnelling, or the multicast group when routing.
+	Address string
+
+	// Routing selects multicast routing instead of tunnelling.
+	Routing bool
+
+	// OnlyWhenBusy restricts the endpoint to be used only if all tunnelling endpoints that precede it
+	// have no free connection slots. Use this to fall back from tunnelling to routing.
+	OnlyWhenBusy bool
+
+	// TunnelConfig configures tunnelling endpoints. Set UseTCP to tunnel over TCP. Its reconnect
+	// policy is ignored, because the FailoverClient takes care of lost connections.
+	TunnelConfig TunnelConfig
+
+	// RouterConfig configures routing endpoints.
+	RouterConfig RouterConfig
+}
+
+// String generates a string representation of the endpoint.
+func (ep Endpoint) String() string {
+	switch {
+	case ep.Routing:
+		return "multicast://" + ep.Address
+
+	case ep.TunnelConfig.UseTCP:
+		return "tcp://" + ep.Address
+
+	default:
+		return "udp://" + ep.Address
+	}
+}
+
+// groupConn is a group client that can be closed.
+type groupConn interface {
+	GroupClient
+	Enqueue(event GroupEvent, prio cemi.Priority, callback func(error)) *SendFuture
+	QueueDepth() int
+	Close()
+}
+
+// dialEndpoint connects to the given endpoint.
+func dialEndpoint(ep Endpoint) (groupConn, error) {
+	if ep.Routing {
+		router, err := NewGroupRouter(ep.Address, ep.RouterConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		return &router, nil
+	}
+
+	config := ep.TunnelConfig
+	config.Reconnect.MaxAttempts = -1
+	config.FailWhenBusy = true
+
+	tunnel, err := NewGroupTunnel(ep.Address, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tunnel, nil
+}
+
+// isBusy determines whether the error indicates that a gateway has no free connection slots.
+func isBusy(err error) bool {
+	return errors.Is(err, knxnet.ErrCode(knxnet.ErrNoMoreConnections)) ||
+		errors.Is(err, knxnet.ErrCode(knxnet.ErrNoMoreUniqueConnections))
+}
+
+// A FailoverConfig determines certain properties of a FailoverClient.
+type FailoverConfig struct {
+	// RetryInterval is the time to wait before trying all endpoints again, after none of them could
+	// be connected to.
+	RetryInterval time.Duration
+
+	// DedupWindow is the time span in which an event received through one endpoint is considered a
+	// duplicate of an identical event received through another endpoint. Such duplicates occur
+	// while switching from one endpoint to another.
+	DedupWindow time.Duration
+}
+
+// DefaultFailoverConfig is a good default configuration for a FailoverClient.
+var DefaultFailoverConfig = FailoverConfig{
+	RetryInterval: 5 * time.Second,
+	DedupWindow:   2 * time.Second,
+}
+
+// checkFailoverConfig makes sure that the configuration is actually usable.
+func checkFailoverConfig(config FailoverConfig) FailoverConfig {
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = DefaultFailoverConfig.RetryInterval
+	}
+
+	if config.DedupWindow <= 0 {
+		config.DedupWindow = DefaultFailoverConfig.DedupWindow
+	}
+
+	return config
+}
+
+var (
+	errNoEndpoints      = errors.New("no endpoints given")
+	errNoActiveEndpoint = errors.New("no endpoint is active")
+)
+
+// recentEvent is an event that has been received recently.
+type recentEvent struct {
+	event    GroupEvent
+	endpoint int
+	received time.Time
+}
+
+// A FailoverClient is a GroupClient which is connected to one of several redundant endpoints. It
+// prefers the endpoints in the given order. When the active endpoint fails, e.g. because its
+// heartbeat failed or the gateway terminated the connection, the client switches over to the first
+// endpoint that is available.
+type FailoverClient struct {
+	endpoints []Endpoint
+	config    FailoverConfig
+	dial      func(Endpoint) (groupConn, error)
+
+	mu     sync.Mutex
+	active groupConn
+	index  int
+
+	recent []recentEvent
+
+	inbound chan GroupEvent
+	state   stateWatcher
+
+	done chan struct{}
+	once sync.Once
+	wait sync.WaitGroup
+}
+
+// connect tries the endpoints in order and returns the first one that could be connected to.
+func (fc *FailoverClient) connect() (groupConn, int, error) {
+	var (
+		lastErr       error
+		tunnels, busy int
+	)
+
+	for index, ep := range fc.endpoints {
+		if ep.OnlyWhenBusy && (tunnels == 0 || busy < tunnels) {
+			continue
+		}
+
+		client, err := fc.dial(ep)
+		if err == nil {
+			return client, index, nil
+		}
+
+		util.Log(fc, "Failed to connect to %v: %v", ep, err)
+
+		if !ep.Routing {
+			tunnels++
+
+			if isBusy(err) {
+				busy++
+			}
+		}
+
+		lastErr = err
+	}
+
+	return nil, -1, lastErr
+}
+
+// dedup determines whether the event has recently been received through a different endpoint.
+func (fc *FailoverClient) dedup(event GroupEvent, endpoint int, now time.Time) bool {
+	// Forget about events that are too old.
+	keep := fc.recent[:0]
+	for _, recent := range fc.recent {
+		if now.Sub(recent.received) < fc.config.DedupWindow {
+			keep = append(keep, recent)
+		}
+	}
+	fc.recent = keep
+
+	for _, recent := range fc.recent {
+		if recent.endpoint != endpoint &&
+			recent.event.Command == event.Command &&
+			recent.event.Source == event.Source &&
+			recent.event.Destination == event.Destination &&
+			bytes.Equal(recent.event.Data, event.Data) {
+			return true
+		}
+	}
+
+	fc.recent = append(fc.recent, recentEvent{event, endpoint, now})
+
+	return false
+}
+
+// setActive changes the active endpoint.
+func (fc *FailoverClient) setActive(client groupConn, index int) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	fc.active = client
+	fc.index = index
+}
+
+// relay forwards the events of the active endpoint until it fails or the client is closed.
+func (fc *FailoverClient) relay(client groupConn, index int) error {
+	for {
+		select {
+		case <-fc.done:
+			return nil
+
+		case event, open := <-client.Inbound():
+			if !open {
+				return errors.New("endpoint " + fc.endpoints[index].String() + " has failed")
+			}
+
+			if fc.dedup(event, index, time.Now()) {
+				continue
+			}
+
+			select {
+			case <-fc.done:
+				return nil
+
+			case fc.inbound <- event:
+			}
+		}
+	}
+}
+
+// serve relays the events of the active endpoint and switches over to another endpoint when needed.
+func (fc *FailoverClient) serve(client groupConn, index int) {
+	util.Log(fc, "Started worker")
+	defer util.Log(fc, "Worker exited")
+
+	defer close(fc.inbound)
+	defer fc.wait.Done()
+
+	for {
+		util.Log(fc, "Active endpoint is %v", fc.endpoints[index])
+
+		err := fc.relay(client, index)
+
+		fc.setActive(nil, -1)
+		client.Close()
+
+		select {
+		case <-fc.done:
+			return
+		default:
+		}
+
+		util.Log(fc, "Switching over: %v", err)
+		fc.state.set(StateReconnecting, 0, err)
+
+		for attempts := 1; ; attempts++ {
+			client, index, err = fc.connect()
+			if err == nil {
+				break
+			}
+
+			fc.state.set(StateReconnecting, attempts, err)
+
+			select {
+			case <-fc.done:
+				return
+
+			case <-time.After(fc.config.RetryInterval):
+			}
+		}
+
+		fc.setActive(client, index)
+		fc.state.set(StateConnected, 0, nil)
+	}
+}
+
+// NewFailoverClient connects to the first available endpoint. Endpoints are preferred in the given
+// order. You may pass a zero-initialized value as parameter config, the default values will be set up.
+func NewFailoverClient(endpoints []Endpoint, config FailoverConfig) (*FailoverClient, error) {
+	return newFailoverClient(endpoints, config, dialEndpoint)
+}
+
+func newFailoverClient(
+	endpoints []Endpoint,
+	config FailoverConfig,
+	dial func(Endpoint) (groupConn, error),
+) (*FailoverClient, error) {
+	if len(endpoints) == 0 {
+		return nil, errNoEndpoints
+	}
+
+	fc := &FailoverClient{
+		endpoints: append([]Endpoint(nil), endpoints...),
+		config:    checkFailoverConfig(config),
+		dial:      dial,
+		index:     -1,
+		inbound:   make(chan GroupEvent),
+		done:      make(chan struct{}),
+	}
+
+	client, index, err := fc.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	fc.setActive(client, index)
+	fc.state.set(StateConnected, 0, nil)
+
+	fc.wait.Add(1)
+	go fc.serve(client, index)
+
+	return fc, nil
+}
+
+// Active returns the endpoint which is currently in use. The result is false while the client is
+// switching over to another endpoint.
+func (fc *FailoverClient) Active() (Endpoint, bool) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	if fc.active == nil {
+		return Endpoint{}, false
+	}
+
+	return fc.endpoints[fc.index], true
+}
+
+// Send a group communication through the active endpoint. Like the Send method of the endpoint,
+// it has low priority and blocks until the group communication has been sent.
+func (fc *FailoverClient) Send(event GroupEvent) error {
+	fc.mu.Lock()
+	active := fc.active
+	fc.mu.Unlock()
+
+	if active == nil {
+		return errNoActiveEndpoint
+	}
+
+	return active.Send(event)
+}
+
+// Enqueue queues a group communication with the given priority at the active endpoint, without
+// waiting for it to be sent. See Tunnel.Enqueue for details.
+func (fc *FailoverClient) Enqueue(
+	event GroupEvent,
+	prio cemi.Priority,
+	callback func(error),
+) *SendFuture {
+	fc.mu.Lock()
+	active := fc.active
+	fc.mu.Unlock()
+
+	if active == nil {
+		return failedSendFuture(errNoActiveEndpoint, callback)
+	}
+
+	return active.Enqueue(event, prio, callback)
+}
+
+// QueueDepth returns the number of group communications waiting to be sent at the active endpoint.
+func (fc *FailoverClient) QueueDepth() int {
+	fc.mu.Lock()
+	active := fc.active
+	fc.mu.Unlock()
+
+	if active == nil {
+		return 0
+	}
+
+	return active.QueueDepth()
+}
+
+// Inbound returns the channel on which group communication can be received. Events received
+// through any endpoint are delivered on this channel.
+func (fc *FailoverClient) Inbound() <-chan GroupEvent {
+	return fc.inbound
+}
+
+// State returns the current state of the client. It is StateReconnecting while switching over.
+func (fc *FailoverClient) State() ConnState {
+	return fc.state.get()
+}
+
+// WatchState returns a channel which receives an event whenever the client switches over to another
+// endpoint. Use Active to find out which endpoint is in use. The channel is closed once the client
+// has been closed or the returned stop function has been called.
+func (fc *FailoverClient) WatchState() (<-chan ConnEvent, func()) {
+	return fc.state.watch()
+}
+
+// Close terminates the connection to the active endpoint.
+func (fc *FailoverClient) Close() {
+	fc.once.Do(func() {
+		close(fc.done)
+		fc.wait.Wait()
+
+		fc.state.set(StateClosed, 0, nil)
+	})
+}
@@ -0,0 +1,204 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+)
+
+type dummyGroupConn struct {
+	inbound chan GroupEvent
+	sent    chan GroupEvent
+	once    sync.Once
+}
+
+func newDummyGroupConn() *dummyGroupConn {
+	return &dummyGroupConn{
+		inbound: make(chan GroupEvent),
+		sent:    make(chan GroupEvent, 10),
+	}
+}
+
+func (conn *dummyGroupConn) Send(event GroupEvent) error {
+	conn.sent <- event
+	return nil
+}
+
+func (conn *dummyGroupConn) Enqueue(
+	event GroupEvent,
+	prio cemi.Priority,
+	callback func(error),
+) *SendFuture {
+	return failedSendFuture(conn.Send(event), callback)
+}
+
+func (conn *dummyGroupConn) QueueDepth() int {
+	return 0
+}
+
+func (conn *dummyGroupConn) Inbound() <-chan GroupEvent {
+	return conn.inbound
+}
+
+func (conn *dummyGroupConn) Close() {
+	conn.once.Do(func() { close(conn.inbound) })
+}
+
+func TestFailoverClient(t *testing.T) {
+	endpoints := []Endpoint{
+		{Address: "10.0.0.1:3671"},
+		{Address: "10.0.0.2:3671"},
+	}
+
+	primary := newDummyGroupConn()
+	secondary := newDummyGroupConn()
+
+	var mu sync.Mutex
+	primaryUp := false
+
+	dial := func(ep Endpoint) (groupConn, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch ep.Address {
+		case "10.0.0.1:3671":
+			if primaryUp {
+				return primary, nil
+			}
+
+			return nil, errors.New("unreachable")
+
+		default:
+			return secondary, nil
+		}
+	}
+
+	fc, err := newFailoverClient(endpoints, FailoverConfig{RetryInterval: time.Millisecond}, dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fc.Close()
+
+	if ep, ok := fc.Active(); !ok || ep.Address != "10.0.0.2:3671" {
+		t.Fatalf("Expected secondary endpoint to be active, got %v", ep)
+	}
+
+	event := GroupEvent{Command: GroupWrite, Destination: cemi.NewGroupAddr3(1, 2, 3), Data: []byte{1}}
+
+	if err := fc.Send(event); err != nil {
+		t.Fatal(err)
+	}
+
+	if sent := <-secondary.sent; sent.Destination != event.Destination {
+		t.Errorf("Unexpected event sent: %+v", sent)
+	}
+
+	secondary.inbound <- event
+	if received := <-fc.Inbound(); received.Destination != event.Destination {
+		t.Errorf("Unexpected event received: %+v", received)
+	}
+
+	events, stop := fc.WatchState()
+	defer stop()
+
+	// Let the secondary fail. The client shall switch over to the primary.
+	mu.Lock()
+	primaryUp = true
+	mu.Unlock()
+
+	secondary.Close()
+
+	for ev := range events {
+		if ev.State == StateConnected {
+			break
+		}
+	}
+
+	if ep, ok := fc.Active(); !ok || ep.Address != "10.0.0.1:3671" {
+		t.Fatalf("Expected primary endpoint to be active, got %v", ep)
+	}
+
+	// The primary delivers the same event again, which shall be dropped. The next one passes.
+	other := event
+	other.Data = []byte{0}
+
+	primary.inbound <- event
+	primary.inbound <- other
+
+	if received := <-fc.Inbound(); received.Data[0] != 0 {
+		t.Errorf("Duplicate event was not dropped: %+v", received)
+	}
+}
+
+func TestFailoverClient_busyFallback(t *testing.T) {
+	endpoints := []Endpoint{
+		{Address: "10.0.0.1:3671"},
+		{Address: "224.0.23.12:3671", Routing: true, OnlyWhenBusy: true},
+	}
+
+	router := newDummyGroupConn()
+
+	t.Run("Busy", func(t *testing.T) {
+		dial := func(ep Endpoint) (groupConn, error) {
+			if ep.Routing {
+				return router, nil
+			}
+
+			return nil, knxnet.ErrCode(knxnet.ErrNoMoreConnections)
+		}
+
+		fc, err := newFailoverClient(endpoints, FailoverConfig{}, dial)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer fc.Close()
+
+		if ep, ok := fc.Active(); !ok || !ep.Routing {
+			t.Fatalf("Expected routing endpoint to be active, got %v", ep)
+		}
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		dial := func(ep Endpoint) (groupConn, error) {
+			if ep.Routing {
+				t.Fatal("Routing endpoint should not be used")
+			}
+
+			return nil, errors.New("unreachable")
+		}
+
+		_, err := newFailoverClient(endpoints, FailoverConfig{}, dial)
+		if err == nil {
+			t.Fatal("Should not succeed")
+		}
+	})
+}
+
+func TestFailoverClient_dedup(t *testing.T) {
+	fc := &FailoverClient{config: checkFailoverConfig(FailoverConfig{DedupWindow: time.Second})}
+
+	event := GroupEvent{Command: GroupWrite, Destination: 1, Data: []byte{1}}
+	now := time.Now()
+
+	if fc.dedup(event, 0, now) {
+		t.Error("First event must not be a duplicate")
+	}
+
+	if fc.dedup(event, 0, now) {
+		t.Error("Repeated event from the same endpoint must not be a duplicate")
+	}
+
+	if !fc.dedup(event, 1, now.Add(500*time.Millisecond)) {
+		t.Error("Event from another endpoint within the window must be a duplicate")
+	}
+
+	if fc.dedup(event, 1, now.Add(2*time.Second)) {
+		t.Error("Event from another endpoint outside of the window must not be a duplicate")
+	}
+}
@@ -0,0 +1,213 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/knxnet"
+)
+
+// These are the timing parameters of the routing flow control, as given by the KNXnet/IP routing
+// specification.
+const (
+	// busyIncrementInterval is the minimum time between two RoutingBusy indications for both of
+	// them to increment the busy counter.
+	busyIncrementInterval = 10 * time.Millisecond
+
+	// busySlowDuration is the time per busy count after the last RoutingBusy, before the busy
+	// counter starts to decrement.
+	busySlowDuration = 100 * time.Millisecond
+
+	// busyDecrementInterval is the time in which the busy counter decrements by one.
+	busyDecrementInterval = 5 * time.Millisecond
+
+	// busyRandomWait is the maximum random time per busy count that is added to the wait time.
+	busyRandomWait = 50 * time.Millisecond
+)
+
+// RouterFlowState describes the flow control state of a Router.
+type RouterFlowState struct {
+	// DeviceState is the state that is announced to other routers. It indicates an IP error when
+	// the last transmission failed.
+	DeviceState knxnet.DeviceState
+
+	// PausedUntil is the time until which sending is paused, because another router is busy.
+	PausedUntil time.Time
+
+	// BusyCounter is the number of recent RoutingBusy indications. It scales the random time that
+	// is added to the wait time.
+	BusyCounter int
+
+	// Queued is the number of received frames that have not been read from Inbound yet.
+	Queued int
+
+	// Lost is the total number of received frames that had to be dropped, because the inbound
+	// queue was full.
+	Lost uint64
+}
+
+// flowControl keeps track of the routing flow control state.
+type flowControl struct {
+	mu sync.Mutex
+
+	// interval limits how often RoutingBusy and RoutingLost indications are sent.
+	interval time.Duration
+
+	state       knxnet.DeviceState
+	pausedUntil time.Time
+
+	// busyCount is the busy counter at the time lastBusy.
+	busyCount int
+	lastBusy  time.Time
+
+	lastBusySent time.Time
+	lastLostSent time.Time
+	lost         uint16
+	lostTotal    uint64
+	flushPending bool
+}
+
+// counter computes the busy counter at the given time. The counter decrements once the slow
+// duration has passed since the last RoutingBusy.
+func (fc *flowControl) counter(now time.Time) int {
+	slow := time.Duration(fc.busyCount) * busySlowDuration
+
+	elapsed := now.Sub(fc.lastBusy)
+	if elapsed <= slow {
+		return fc.busyCount
+	}
+
+	count := fc.busyCount - int((elapsed-slow)/busyDecrementInterval)
+	if count < 0 {
+		count = 0
+	}
+
+	return count
+}
+
+// busy processes a RoutingBusy indication from another router. The random number is expected to
+// be in the interval [0, 1).
+func (fc *flowControl) busy(msg *knxnet.RoutingBusy, now time.Time, random float64) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	count := fc.counter(now)
+	if fc.lastBusy.IsZero() || now.Sub(fc.lastBusy) > busyIncrementInterval {
+		count++
+	}
+
+	fc.busyCount = count
+	fc.lastBusy = now
+
+	wait := msg.WaitTime
+
+	// If Control is 0, all devices have to add the random time. Otherwise the behaviour is not
+	// specified, which is why we only wait for the given time.
+	if msg.Control == 0 {
+		wait += time.Duration(random * float64(count) * float64(busyRandomWait))
+	}
+
+	if until := now.Add(wait); until.After(fc.pausedUntil) {
+		fc.pausedUntil = until
+	}
+}
+
+// pause returns the time for which sending has to be paused.
+func (fc *flowControl) pause(now time.Time) time.Duration {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	return fc.pausedUntil.Sub(now)
+}
+
+// congested is called when the inbound queue reaches its threshold. It returns the RoutingBusy
+// indication to send, if any.
+func (fc *flowControl) congested(now time.Time, wait time.Duration) *knxnet.RoutingBusy {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	if !fc.lastBusySent.IsZero() && now.Sub(fc.lastBusySent) < fc.interval {
+		return nil
+	}
+
+	fc.lastBusySent = now
+
+	return &knxnet.RoutingBusy{Status: fc.state, WaitTime: wait}
+}
+
+// dropped is called when a received frame has been dropped. It returns the RoutingLost indication
+// to send, if any. Otherwise the lost frames are announced later and flush indicates whether the
+// caller must schedule that.
+func (fc *flowControl) dropped(now time.Time) (lost *knxnet.RoutingLost, flush bool) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	fc.lostTotal++
+	if fc.lost < ^uint16(0) {
+		fc.lost++
+	}
+
+	if !fc.lastLostSent.IsZero() && now.Sub(fc.lastLostSent) < fc.interval {
+		flush = !fc.flushPending
+		fc.flushPending = true
+
+		return nil, flush
+	}
+
+	return fc.takeLost(now), false
+}
+
+// flushLost returns the RoutingLost indication for the frames that have not been announced yet.
+func (fc *flowControl) flushLost(now time.Time) *knxnet.RoutingLost {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	fc.flushPending = false
+
+	if fc.lost == 0 {
+		return nil
+	}
+
+	return fc.takeLost(now)
+}
+
+// takeLost generates a RoutingLost indication and resets the number of lost frames.
+func (fc *flowControl) takeLost(now time.Time) *knxnet.RoutingLost {
+	lost := &knxnet.RoutingLost{Status: fc.state, Count: fc.lost}
+
+	fc.lost = 0
+	fc.lastLostSent = now
+
+	return lost
+}
+
+// setState changes the device state.
+func (fc *flowControl) setState(state knxnet.DeviceState) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	fc.state = state
+}
+
+// deviceState returns the device state.
+func (fc *flowControl) deviceState() knxnet.DeviceState {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	return fc.state
+}
+
+// snapshot returns the flow control state at the given time.
+func (fc *flowControl) snapshot(now time.Time) RouterFlowState {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	return RouterFlowState{
+		DeviceState: fc.state,
+		PausedUntil: fc.pausedUntil,
+		BusyCounter: fc.counter(now),
+		Lost:        fc.lostTotal,
+	}
+}
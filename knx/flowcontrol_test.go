@@ -0,0 +1,96 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/knxnet"
+)
+
+func TestFlowControl_busy(t *testing.T) {
+	var fc flowControl
+
+	start := time.Now()
+	busy := &knxnet.RoutingBusy{WaitTime: 100 * time.Millisecond}
+
+	fc.busy(busy, start, 0.5)
+
+	if pause := fc.pause(start); pause != 100*time.Millisecond+25*time.Millisecond {
+		t.Errorf("Unexpected pause after first indication: %v", pause)
+	}
+
+	// Indications in quick succession only increment the counter once.
+	fc.busy(busy, start.Add(5*time.Millisecond), 0)
+	if count := fc.counter(start.Add(5 * time.Millisecond)); count != 1 {
+		t.Errorf("Expected busy counter 1, got %d", count)
+	}
+
+	now := start.Add(20 * time.Millisecond)
+	fc.busy(busy, now, 0.5)
+
+	if count := fc.counter(now); count != 2 {
+		t.Errorf("Expected busy counter 2, got %d", count)
+	}
+
+	if pause := fc.pause(now); pause != 100*time.Millisecond+50*time.Millisecond {
+		t.Errorf("Unexpected pause after second indication: %v", pause)
+	}
+
+	// The counter remains during the slow duration and then decrements every 5 ms.
+	if count := fc.counter(now.Add(200 * time.Millisecond)); count != 2 {
+		t.Errorf("Expected busy counter 2 during slow duration, got %d", count)
+	}
+
+	if count := fc.counter(now.Add(205 * time.Millisecond)); count != 1 {
+		t.Errorf("Expected busy counter 1, got %d", count)
+	}
+
+	if count := fc.counter(now.Add(time.Second)); count != 0 {
+		t.Errorf("Expected busy counter 0, got %d", count)
+	}
+
+	// A non-zero control field disables the random wait time.
+	fc.busy(&knxnet.RoutingBusy{WaitTime: time.Second, Control: 1}, now, 0.9)
+	if pause := fc.pause(now); pause != time.Second {
+		t.Errorf("Unexpected pause: %v", pause)
+	}
+}
+
+func TestFlowControl_announce(t *testing.T) {
+	fc := flowControl{interval: 100 * time.Millisecond}
+	now := time.Now()
+
+	if busy := fc.congested(now, 20*time.Millisecond); busy == nil || busy.WaitTime != 20*time.Millisecond {
+		t.Errorf("Unexpected busy indication: %+v", busy)
+	}
+
+	if busy := fc.congested(now.Add(50*time.Millisecond), 20*time.Millisecond); busy != nil {
+		t.Errorf("Busy indication was sent too early: %+v", busy)
+	}
+
+	if lost, _ := fc.dropped(now); lost == nil || lost.Count != 1 {
+		t.Errorf("Unexpected lost indication: %+v", lost)
+	}
+
+	if lost, flush := fc.dropped(now); lost != nil || !flush {
+		t.Errorf("Expected a flush to be scheduled, got %+v", lost)
+	}
+
+	if lost, flush := fc.dropped(now); lost != nil || flush {
+		t.Errorf("Expected the flush to be pending, got %+v", lost)
+	}
+
+	if lost := fc.flushLost(now.Add(100 * time.Millisecond)); lost == nil || lost.Count != 2 {
+		t.Errorf("Unexpected lost indication: %+v", lost)
+	}
+
+	if lost := fc.flushLost(now.Add(200 * time.Millisecond)); lost != nil {
+		t.Errorf("Unexpected lost indication: %+v", lost)
+	}
+
+	if state := fc.snapshot(now); state.Lost != 3 {
+		t.Errorf("Expected 3 lost frames, got %d", state.Lost)
+	}
+}
@@ -48,8 +48,9 @@ type GroupClient interface {
 	Inbound() <-chan GroupEvent
 }
 
-// serveGroupInbound serves a group communication.
-func serveGroupInbound(inbound <-chan cemi.Message, outbound chan<- GroupEvent) {
+// serveGroupInbound serves a group communication. Confirmations are passed to confs, if it is not
+// nil.
+func serveGroupInbound(inbound <-chan cemi.Message, outbound chan<- GroupEvent, confs *confirmations) {
 	util.Log(inbound, "Started worker")
 	defer util.Log(inbound, "Worker exited")
 
@@ -71,6 +72,10 @@ func serveGroupInbound(inbound <-chan cemi.Message, outbound chan<- GroupEvent)
 			} else {
 				util.Log(inbound, "Received L_Data.ind frame does not contain application data")
 			}
+		} else if con, ok := msg.(*cemi.LDataCon); ok && confs != nil {
+			if con.Control2.IsGroupAddr() {
+				confs.confirm(con)
+			}
 		} else {
 			util.Log(inbound, "Received frame is not a L_Data.ind frame")
 		}
@@ -80,13 +85,14 @@ func serveGroupInbound(inbound <-chan cemi.Message, outbound chan<- GroupEvent)
 }
 
 var defaultGroupLData = cemi.LData{
-	Control1: cemi.Control1NoRepeat | cemi.Control1NoSysBroadcast | cemi.Control1WantAck | cemi.Control1Prio(cemi.PrioLow),
+	Control1: cemi.Control1NoRepeat | cemi.Control1NoSysBroadcast | cemi.Control1WantAck,
 	Control2: cemi.Control2GroupAddr | cemi.Control2Hops(6),
 }
 
 // buildGroupOutbound constructs the L_Data core frame for group communication.
-func buildGroupOutbound(event GroupEvent) cemi.LData {
+func buildGroupOutbound(event GroupEvent, prio cemi.Priority) cemi.LData {
 	ldata := defaultGroupLData
+	ldata.Control1 |= cemi.Control1Prio(prio)
 	ldata.Data = &cemi.AppData{
 		Command: cemi.APCI(event.Command),
 		Data:    event.Data,
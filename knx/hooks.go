@@ -0,0 +1,172 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"net"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+)
+
+// Hooks are notified about the operation of a Tunnel or Router, e.g. to collect metrics. Every
+// field may be nil. The functions are called on the workers of the connection and must not block.
+type Hooks struct {
+	// PacketIn is called for every KNXnet/IP packet received by the socket.
+	PacketIn func(service knxnet.Service)
+
+	// PacketOut is called for every KNXnet/IP packet sent through the socket, along with the error
+	// if sending failed.
+	PacketOut func(service knxnet.ServicePackable, err error)
+
+	// FrameIn is called for every frame received from the network.
+	FrameIn func(msg cemi.Message)
+
+	// FrameOut is called for every frame that has been sent, along with the error if sending
+	// failed.
+	FrameOut func(msg cemi.Message, err error)
+
+	// Resend is called when a tunnel request is repeated, because the gateway has not acknowledged
+	// it in time.
+	Resend func()
+
+	// RoundTrip is called with the time it took the gateway to acknowledge a tunnel request.
+	RoundTrip func(latency time.Duration)
+
+	// HeartbeatFailed is called when the gateway does not answer a connection state request or
+	// reports a bad connection state.
+	HeartbeatFailed func(err error)
+
+	// Reconnect is called after every attempt to re-establish the connection. The error is nil if
+	// the attempt succeeded.
+	Reconnect func(attempt int, err error)
+
+	// RoutingBusy is called for every RoutingBusy indication received from another router.
+	RoutingBusy func(msg *knxnet.RoutingBusy)
+
+	// RoutingLost is called for every RoutingLost indication received from another router.
+	RoutingLost func(msg *knxnet.RoutingLost)
+}
+
+func (hooks *Hooks) frameIn(msg cemi.Message) {
+	if hooks.FrameIn != nil {
+		hooks.FrameIn(msg)
+	}
+}
+
+func (hooks *Hooks) frameOut(msg cemi.Message, err error) {
+	if hooks.FrameOut != nil {
+		hooks.FrameOut(msg, err)
+	}
+}
+
+func (hooks *Hooks) resend() {
+	if hooks.Resend != nil {
+		hooks.Resend()
+	}
+}
+
+func (hooks *Hooks) roundTrip(latency time.Duration) {
+	if hooks.RoundTrip != nil {
+		hooks.RoundTrip(latency)
+	}
+}
+
+func (hooks *Hooks) heartbeatFailed(err error) {
+	if hooks.HeartbeatFailed != nil {
+		hooks.HeartbeatFailed(err)
+	}
+}
+
+func (hooks *Hooks) reconnect(attempt int, err error) {
+	if hooks.Reconnect != nil {
+		hooks.Reconnect(attempt, err)
+	}
+}
+
+func (hooks *Hooks) routingBusy(msg *knxnet.RoutingBusy) {
+	if hooks.RoutingBusy != nil {
+		hooks.RoutingBusy(msg)
+	}
+}
+
+func (hooks *Hooks) routingLost(msg *knxnet.RoutingLost) {
+	if hooks.RoutingLost != nil {
+		hooks.RoutingLost(msg)
+	}
+}
+
+// socket wraps the socket, so that its packets are passed to the packet hooks. The socket is
+// returned as is if there are no packet hooks.
+func (hooks *Hooks) socket(sock knxnet.Socket) knxnet.Socket {
+	if hooks.PacketIn == nil && hooks.PacketOut == nil {
+		return sock
+	}
+
+	hs := &hookedSocket{sock: sock, hooks: *hooks, inbound: make(chan knxnet.Service)}
+	go hs.serve()
+
+	return hs
+}
+
+// hookedSocket passes the packets of a socket to the packet hooks.
+type hookedSocket struct {
+	sock    knxnet.Socket
+	hooks   Hooks
+	inbound chan knxnet.Service
+}
+
+// serve relays the inbound packets until the socket closes its inbound channel.
+func (hs *hookedSocket) serve() {
+	defer close(hs.inbound)
+
+	for service := range hs.sock.Inbound() {
+		if hs.hooks.PacketIn != nil {
+			if received, ok := service.(*knxnet.ReceivedService); ok {
+				hs.hooks.PacketIn(received.Payload)
+			} else {
+				hs.hooks.PacketIn(service)
+			}
+		}
+
+		hs.inbound <- service
+	}
+}
+
+func (hs *hookedSocket) Send(payload knxnet.ServicePackable) error {
+	err := hs.sock.Send(payload)
+
+	if hs.hooks.PacketOut != nil {
+		hs.hooks.PacketOut(payload, err)
+	}
+
+	return err
+}
+
+func (hs *hookedSocket) SendOn(payload knxnet.ServicePackable, ifis ...*net.Interface) error {
+	sock, ok := hs.sock.(interfaceSocket)
+	if !ok {
+		return errNoInterfaceSelection
+	}
+
+	err := sock.SendOn(payload, ifis...)
+
+	if hs.hooks.PacketOut != nil {
+		hs.hooks.PacketOut(payload, err)
+	}
+
+	return err
+}
+
+func (hs *hookedSocket) Inbound() <-chan knxnet.Service {
+	return hs.inbound
+}
+
+func (hs *hookedSocket) Close() error {
+	return hs.sock.Close()
+}
+
+func (hs *hookedSocket) LocalAddr() net.Addr {
+	return hs.sock.LocalAddr()
+}
@@ -0,0 +1,104 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+)
+
+// hookCounter counts the invocations of the hooks.
+type hookCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (hc *hookCounter) count(name string) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	hc.counts[name]++
+}
+
+func (hc *hookCounter) get(name string) int {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	return hc.counts[name]
+}
+
+func (hc *hookCounter) hooks() Hooks {
+	return Hooks{
+		PacketIn:    func(knxnet.Service) { hc.count("PacketIn") },
+		PacketOut:   func(knxnet.ServicePackable, error) { hc.count("PacketOut") },
+		FrameIn:     func(cemi.Message) { hc.count("FrameIn") },
+		FrameOut:    func(cemi.Message, error) { hc.count("FrameOut") },
+		Resend:      func() { hc.count("Resend") },
+		RoundTrip:   func(time.Duration) { hc.count("RoundTrip") },
+		RoutingBusy: func(*knxnet.RoutingBusy) { hc.count("RoutingBusy") },
+		RoutingLost: func(*knxnet.RoutingLost) { hc.count("RoutingLost") },
+	}
+}
+
+func TestHooks_tunnel(t *testing.T) {
+	client, gateway := newDummySockets()
+	defer client.Close()
+	defer gateway.Close()
+
+	hc := &hookCounter{counts: map[string]int{}}
+
+	config := DefaultTunnelConfig
+	config.ResendInterval = 10 * time.Millisecond
+	config.Hooks = hc.hooks()
+
+	conn := makeTunnelConn(config.Hooks.socket(client), config, 1)
+
+	go func() {
+		// Acknowledge the resent request only.
+		<-gateway.Inbound()
+		<-gateway.Inbound()
+
+		conn.ack <- &knxnet.TunnelRes{Channel: 1, SeqNumber: 0}
+	}()
+
+	if err := conn.send(&cemi.LDataReq{LData: cemi.LData{Data: &cemi.AppData{}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]int{"PacketOut": 2, "Resend": 1, "RoundTrip": 1, "FrameOut": 1} {
+		if count := hc.get(name); count != expected {
+			t.Errorf("%s was called %d times instead of %d", name, count, expected)
+		}
+	}
+}
+
+func TestHooks_router(t *testing.T) {
+	sock, peer := newDummySockets()
+
+	hc := &hookCounter{counts: map[string]int{}}
+
+	router := newRouter(sock, checkRouterConfig(RouterConfig{Hooks: hc.hooks()}))
+	defer router.Close()
+
+	peer.sendAny(&knxnet.RoutingBusy{WaitTime: time.Millisecond, Control: 1})
+	peer.sendAny(&knxnet.RoutingLost{Count: 0})
+	peer.sendAny(&knxnet.RoutingInd{Payload: makePrioMessage(cemi.PrioLow, 1)})
+
+	<-router.Inbound()
+
+	if err := router.Send(makePrioMessage(cemi.PrioLow, 2)); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]int{
+		"PacketIn": 3, "PacketOut": 1, "FrameIn": 1, "FrameOut": 1, "RoutingBusy": 1, "RoutingLost": 1,
+	} {
+		if count := hc.get(name); count != expected {
+			t.Errorf("%s was called %d times instead of %d", name, count, expected)
+		}
+	}
+}
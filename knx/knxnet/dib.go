@@ -4,6 +4,7 @@ package knxnet
 
 import (
 	"errors"
+	"fmt"
 	"net"
 
 	"github.com/vapourismo/knx-go/knx/cemi"
@@ -33,6 +34,9 @@ const (
 	// DescriptionTypeKNXAddresses describes KNX addresses.
 	DescriptionTypeKNXAddresses DescriptionType = 0x05
 
+	// DescriptionTypeTunnellingInfo describes the tunnelling slots of a device.
+	DescriptionTypeTunnellingInfo DescriptionType = 0x07
+
 	// DescriptionTypeManufacturerData describes a DIB structure for further data defined by device manufacturer.
 	DescriptionTypeManufacturerData DescriptionType = 0xfe
 )
@@ -51,6 +55,25 @@ const (
 	KNXMediumIP KNXMedium = 0x20
 )
 
+// String generates a string representation of the medium.
+func (medium KNXMedium) String() string {
+	switch medium {
+	case KNXMediumTP1:
+		return "TP1"
+
+	case KNXMediumPL110:
+		return "PL110"
+
+	case KNXMediumRF:
+		return "RF"
+
+	case KNXMediumIP:
+		return "IP"
+	}
+
+	return fmt.Sprintf("0x%02x", uint8(medium))
+}
+
 // ProjectInstallationIdentifier describes a KNX project installation identifier.
 type ProjectInstallationIdentifier uint16
 
@@ -60,6 +83,12 @@ type DeviceStatus uint8
 // DeviceSerialNumber desribes the serial number of a device.
 type DeviceSerialNumber [6]byte
 
+// String formats the serial number as ETS does, e.g. "00FA:12345678". The first part identifies
+// the manufacturer.
+func (serial DeviceSerialNumber) String() string {
+	return fmt.Sprintf("%X:%X", serial[:2], serial[2:])
+}
+
 // DeviceInformationBlock contains information about a device.
 type DeviceInformationBlock struct {
 	Type                    DescriptionType
@@ -205,6 +234,25 @@ const (
 	ServiceFamilyTypeIPObjectServer = 0x08
 )
 
+var serviceFamilyNames = map[ServiceFamilyType]string{
+	ServiceFamilyTypeIPCore:                            "Core",
+	ServiceFamilyTypeIPDeviceManagement:                "DeviceManagement",
+	ServiceFamilyTypeIPTunnelling:                      "Tunnelling",
+	ServiceFamilyTypeIPRouting:                         "Routing",
+	ServiceFamilyTypeIPRemoteLogging:                   "RemoteLogging",
+	ServiceFamilyTypeIPRemoteConfigurationAndDiagnosis: "RemoteConfigurationAndDiagnosis",
+	ServiceFamilyTypeIPObjectServer:                    "ObjectServer",
+}
+
+// String generates a string representation of the service family type.
+func (ty ServiceFamilyType) String() string {
+	if name, ok := serviceFamilyNames[ty]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("0x%02x", uint8(ty))
+}
+
 // ServiceFamily describes a KNXnet service supported by a device.
 type ServiceFamily struct {
 	Type    ServiceFamilyType
@@ -229,11 +277,97 @@ func (f *ServiceFamily) Unpack(data []byte) (n uint, err error) {
 	return util.UnpackSome(data, (*uint8)(&f.Type), &f.Version)
 }
 
+// String generates a string representation of the service family, e.g. "Tunnelling v2".
+func (f ServiceFamily) String() string {
+	return fmt.Sprintf("%v v%d", f.Type, f.Version)
+}
+
+// TunnellingSlotStatus describes the state of a tunnelling slot.
+type TunnellingSlotStatus uint16
+
+// These are the flags of a tunnelling slot.
+const (
+	// TunnellingSlotFree indicates that the slot is not in use.
+	TunnellingSlotFree TunnellingSlotStatus = 1 << 0
+
+	// TunnellingSlotAuthorised indicates that the client is authorised to use the slot.
+	TunnellingSlotAuthorised TunnellingSlotStatus = 1 << 1
+
+	// TunnellingSlotUsable indicates that the slot can be used.
+	TunnellingSlotUsable TunnellingSlotStatus = 1 << 2
+)
+
+// A TunnellingSlot is a tunnelling connection offered by a device.
+type TunnellingSlot struct {
+	Address cemi.IndividualAddr
+	Status  TunnellingSlotStatus
+}
+
+// TunnellingInfoDIB contains information about the tunnelling slots of a device.
+type TunnellingInfoDIB struct {
+	MaxAPDULength uint16
+	Slots         []TunnellingSlot
+}
+
+// Size returns the packed size.
+func (info TunnellingInfoDIB) Size() uint {
+	return 4 + 4*uint(len(info.Slots))
+}
+
+// Pack assembles the tunnelling information structure in the given buffer.
+func (info *TunnellingInfoDIB) Pack(buffer []byte) {
+	util.PackSome(
+		buffer,
+		uint8(info.Size()), uint8(DescriptionTypeTunnellingInfo),
+		info.MaxAPDULength,
+	)
+
+	for i, slot := range info.Slots {
+		util.PackSome(buffer[4+4*i:], uint16(slot.Address), uint16(slot.Status))
+	}
+}
+
+// Unpack parses the given data in order to initialize the structure.
+func (info *TunnellingInfoDIB) Unpack(data []byte) (n uint, err error) {
+	var (
+		length uint8
+		ty     DescriptionType
+	)
+
+	if n, err = util.UnpackSome(data, &length, (*uint8)(&ty), &info.MaxAPDULength); err != nil {
+		return
+	}
+
+	if length < 4 || length%4 != 0 || uint(len(data)) < uint(length) {
+		return n, errors.New("invalid length for Tunnelling Info structure")
+	}
+
+	info.Slots = nil
+
+	for n < uint(length) {
+		var slot TunnellingSlot
+
+		nn, err := util.UnpackSome(data[n:], (*uint16)(&slot.Address), (*uint16)(&slot.Status))
+		if err != nil {
+			return n, err
+		}
+
+		n += nn
+		info.Slots = append(info.Slots, slot)
+	}
+
+	return
+}
+
 // DescriptionBlock is returned by a Search Request or a Description Request.
 type DescriptionBlock struct {
 	DeviceHardware    DeviceInformationBlock
 	SupportedServices SupportedServicesDIB
-	UnknownBlocks     []UnknownDescriptionBlock
+
+	// TunnellingInfo is only provided by devices which implement version 2 of KNXnet/IP Core.
+	TunnellingInfo *TunnellingInfoDIB
+
+	UnknownBlocks []UnknownDescriptionBlock
 }
 
 // Unpack parses the given service payload in order to initialize the Description Block.
@@ -250,6 +384,10 @@ func (di *DescriptionBlock) Unpack(data []byte) (n uint, err error) {
 			return 0, err
 		}
 
+		if length < 2 || n+uint(length) > uint(len(data)) {
+			return 0, errors.New("DIB length is invalid")
+		}
+
 		switch ty {
 		case DescriptionTypeDeviceInfo:
 			_, err = di.DeviceHardware.Unpack(data[n : n+uint(length)])
@@ -265,6 +403,15 @@ func (di *DescriptionBlock) Unpack(data []byte) (n uint, err error) {
 			}
 			n += uint(length)
 
+		case DescriptionTypeTunnellingInfo:
+			info := &TunnellingInfoDIB{}
+			_, err = info.Unpack(data[n : n+uint(length)])
+			if err != nil {
+				return 0, err
+			}
+			di.TunnellingInfo = info
+			n += uint(length)
+
 		case DescriptionTypeIPConfig, DescriptionTypeIPCurrentConfig,
 			DescriptionTypeKNXAddresses, DescriptionTypeManufacturerData:
 			u := UnknownDescriptionBlock{Type: ty}
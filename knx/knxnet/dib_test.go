@@ -0,0 +1,75 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+func TestDescriptionRes_Unpack(t *testing.T) {
+	info := DeviceInformationBlock{
+		Type:         DescriptionTypeDeviceInfo,
+		Medium:       KNXMediumTP1,
+		Source:       cemi.NewIndividualAddr3(1, 1, 0),
+		SerialNumber: DeviceSerialNumber{0x00, 0xFA, 0x12, 0x34, 0x56, 0x78},
+		HardwareAddr: net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		FriendlyName: "Line coupler",
+	}
+
+	services := SupportedServicesDIB{
+		Type: DescriptionTypeSupportedServiceFamilies,
+		Families: []ServiceFamily{
+			{Type: ServiceFamilyTypeIPCore, Version: 2},
+			{Type: ServiceFamilyTypeIPTunnelling, Version: 2},
+		},
+	}
+
+	tunnels := TunnellingInfoDIB{
+		MaxAPDULength: 254,
+		Slots: []TunnellingSlot{
+			{cemi.NewIndividualAddr3(1, 1, 10), TunnellingSlotFree | TunnellingSlotUsable},
+			{cemi.NewIndividualAddr3(1, 1, 11), TunnellingSlotUsable},
+		},
+	}
+
+	data := util.AllocAndPack(&info, &services, &tunnels)
+
+	var res DescriptionRes
+	if _, err := res.Unpack(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(res.DeviceHardware, info) {
+		t.Errorf("Unexpected device information %+v", res.DeviceHardware)
+	}
+
+	if !reflect.DeepEqual(res.SupportedServices, services) {
+		t.Errorf("Unexpected services %+v", res.SupportedServices)
+	}
+
+	if res.TunnellingInfo == nil || !reflect.DeepEqual(*res.TunnellingInfo, tunnels) {
+		t.Errorf("Unexpected tunnelling information %+v", res.TunnellingInfo)
+	}
+
+	if s := info.SerialNumber.String(); s != "00FA:12345678" {
+		t.Errorf("Unexpected serial number %q", s)
+	}
+
+	if s := services.Families[1].String(); s != "Tunnelling v2" {
+		t.Errorf("Unexpected service family %q", s)
+	}
+
+	// A DIB without length must not cause an endless loop.
+	if _, err := res.Unpack(append(data, 0, 0)); err == nil {
+		t.Error("DIB without length was accepted")
+	}
+
+	if _, err := res.Unpack(append(data, 8, 0xFE)); err == nil {
+		t.Error("Truncated DIB was accepted")
+	}
+}
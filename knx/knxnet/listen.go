@@ -0,0 +1,32 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"context"
+	"net"
+	"syscall"
+)
+
+// listenUDP binds a UDP socket with SO_REUSEADDR. Multicast routers are bound to all addresses on
+// the KNXnet/IP port, which a unicast router can only share if it is bound to a specific address.
+func listenUDP(addr *net.UDPAddr) (*net.UDPConn, error) {
+	config := net.ListenConfig{
+		Control: func(network, address string, conn syscall.RawConn) error {
+			var err error
+
+			if ctrlErr := conn.Control(func(fd uintptr) { err = reuseAddress(fd) }); ctrlErr != nil {
+				return ctrlErr
+			}
+
+			return err
+		},
+	}
+
+	conn, err := config.ListenPacket(context.Background(), "udp4", addr.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.(*net.UDPConn), nil
+}
@@ -0,0 +1,10 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !windows
+
+package knxnet
+
+// reuseAddress does nothing on platforms without SO_REUSEADDR.
+func reuseAddress(fd uintptr) error {
+	return nil
+}
@@ -0,0 +1,12 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package knxnet
+
+import "syscall"
+
+// reuseAddress sets SO_REUSEADDR on the socket.
+func reuseAddress(fd uintptr) error {
+	return syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+}
@@ -0,0 +1,10 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import "syscall"
+
+// reuseAddress sets SO_REUSEADDR on the socket.
+func reuseAddress(fd uintptr) error {
+	return syscall.SetsockoptInt(syscall.Handle(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+}
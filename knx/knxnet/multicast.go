@@ -0,0 +1,197 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/vapourismo/knx-go/knx/util"
+	"golang.org/x/net/ipv4"
+)
+
+// A ReceivedService is a service together with the network interface on which it was received.
+// MultiRouterSocket delivers these on its inbound channel.
+type ReceivedService struct {
+	Payload   Service
+	Interface *net.Interface
+}
+
+// Service returns the service identifier of the payload.
+func (rs *ReceivedService) Service() ServiceID {
+	return rs.Payload.Service()
+}
+
+// MultiRouterSocket is a UDP socket which has joined a multicast group on several interfaces. In
+// contrast to RouterSocket, it reports the interface on which each packet arrived and can send on
+// selected interfaces.
+type MultiRouterSocket struct {
+	conn    *net.UDPConn
+	pc      *ipv4.PacketConn
+	addr    *net.UDPAddr
+	ifis    []*net.Interface
+	inbound chan Service
+
+	// The multicast interface is a property of the socket, hence sending must be serialized.
+	sendMu sync.Mutex
+}
+
+// ListenRouterOnInterfaces creates a new Socket which joins the multicast group on each of the
+// given interfaces. Packets arriving on other interfaces are discarded.
+func ListenRouterOnInterfaces(
+	ifis []*net.Interface,
+	multicastAddress string,
+	multicastLoopbackEnabled bool,
+) (*MultiRouterSocket, error) {
+	if len(ifis) == 0 {
+		return nil, errors.New("no interfaces given")
+	}
+
+	addr, err := net.ResolveUDPAddr("udp4", multicastAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp4", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	pc := ipv4.NewPacketConn(conn)
+
+	for _, ifi := range ifis {
+		if err := pc.JoinGroup(ifi, addr); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("joining %v on %s: %w", addr, ifi.Name, err)
+		}
+	}
+
+	if err := pc.SetControlMessage(ipv4.FlagInterface, true); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := pc.SetMulticastLoopback(multicastLoopbackEnabled); err != nil {
+		util.Log(conn, "SetMulticastLoopback error: %v", err)
+	}
+
+	sock := &MultiRouterSocket{
+		conn:    conn,
+		pc:      pc,
+		addr:    addr,
+		ifis:    ifis,
+		inbound: make(chan Service),
+	}
+
+	go sock.serve()
+
+	return sock, nil
+}
+
+// Addr returns the multicast destination address.
+func (sock *MultiRouterSocket) Addr() *net.UDPAddr {
+	return sock.addr
+}
+
+// Interfaces returns the interfaces on which the multicast group has been joined.
+func (sock *MultiRouterSocket) Interfaces() []*net.Interface {
+	return sock.ifis
+}
+
+// Send transmits a KNXnet/IP packet on all interfaces.
+func (sock *MultiRouterSocket) Send(payload ServicePackable) error {
+	return sock.SendOn(payload)
+}
+
+// SendOn transmits a KNXnet/IP packet on the given interfaces, or on all interfaces if none are
+// given. The first error is returned, but all interfaces are tried.
+func (sock *MultiRouterSocket) SendOn(payload ServicePackable, ifis ...*net.Interface) error {
+	if len(ifis) == 0 {
+		ifis = sock.ifis
+	}
+
+	buffer := make([]byte, Size(payload))
+	Pack(buffer, payload)
+
+	sock.sendMu.Lock()
+	defer sock.sendMu.Unlock()
+
+	var first error
+
+	for _, ifi := range ifis {
+		err := sock.pc.SetMulticastInterface(ifi)
+		if err == nil {
+			_, err = sock.conn.WriteToUDP(buffer, sock.addr)
+		}
+
+		if err != nil && first == nil {
+			first = fmt.Errorf("sending on %s: %w", ifi.Name, err)
+		}
+	}
+
+	return first
+}
+
+// lookup finds the joined interface with the given index.
+func (sock *MultiRouterSocket) lookup(index int) *net.Interface {
+	for _, ifi := range sock.ifis {
+		if ifi.Index == index {
+			return ifi
+		}
+	}
+
+	return nil
+}
+
+// serve is the receiver worker. Packets are wrapped in a ReceivedService.
+func (sock *MultiRouterSocket) serve() {
+	util.Log(sock, "Started worker")
+	defer util.Log(sock, "Worker exited")
+
+	// A closed inbound channel indicates to its readers that the worker has terminated.
+	defer close(sock.inbound)
+
+	buffer := [1024]byte{}
+
+	for {
+		len, cm, _, err := sock.pc.ReadFrom(buffer[:])
+		if err != nil {
+			util.Log(sock, "Error during ReadFrom: %v", err)
+			return
+		}
+
+		// Platforms without control messages can't tell the interface apart.
+		var ifi *net.Interface
+		if cm != nil {
+			if ifi = sock.lookup(cm.IfIndex); ifi == nil {
+				continue
+			}
+		}
+
+		var payload Service
+		if _, err := Unpack(buffer[:len], &payload); err != nil {
+			util.Log(sock, "Error during Unpack: %v", err)
+			continue
+		}
+
+		sock.inbound <- &ReceivedService{Payload: payload, Interface: ifi}
+	}
+}
+
+// Inbound provides a channel from which you can retrieve incoming packets. Each of them is a
+// *ReceivedService.
+func (sock *MultiRouterSocket) Inbound() <-chan Service {
+	return sock.inbound
+}
+
+// Close shuts the socket down. This will indirectly terminate the associated workers.
+func (sock *MultiRouterSocket) Close() error {
+	return sock.conn.Close()
+}
+
+// LocalAddr returns the local UDP address.
+func (sock *MultiRouterSocket) LocalAddr() net.Addr {
+	return sock.conn.LocalAddr()
+}
@@ -0,0 +1,77 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// multicastInterface finds an interface which is up, supports multicast and has an IPv4 address.
+func multicastInterface(t *testing.T) *net.Interface {
+	t.Helper()
+
+	ifis, err := net.Interfaces()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	for i := range ifis {
+		ifi := &ifis[i]
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+
+		addrs, _ := ifi.Addrs()
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+				return ifi
+			}
+		}
+	}
+
+	t.Skip("No multicast-capable interface")
+	return nil
+}
+
+func TestMultiRouterSocket(t *testing.T) {
+	if _, err := ListenRouterOnInterfaces(nil, "224.0.23.12:3671", false); err == nil {
+		t.Error("Socket without interfaces was accepted")
+	}
+
+	ifi := multicastInterface(t)
+
+	sock, err := ListenRouterOnInterfaces([]*net.Interface{ifi}, "224.0.23.12:36711", true)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer sock.Close()
+
+	if err := sock.SendOn(&RoutingLost{Count: 3}, ifi); err != nil {
+		t.Skip(err)
+	}
+
+	select {
+	case srv := <-sock.Inbound():
+		received, ok := srv.(*ReceivedService)
+		if !ok {
+			t.Fatalf("Unexpected service: %v", srv)
+		}
+
+		if lost, ok := received.Payload.(*RoutingLost); !ok || lost.Count != 3 {
+			t.Errorf("Unexpected payload: %v", received.Payload)
+		}
+
+		if received.Interface != nil && received.Interface.Index != ifi.Index {
+			t.Errorf("Packet was attributed to %v instead of %v", received.Interface, ifi)
+		}
+
+		if received.Service() != RoutingLostService {
+			t.Errorf("Unexpected service identifier: %v", received.Service())
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Skip("Multicast loopback is not available")
+	}
+}
@@ -4,6 +4,7 @@
 package knxnet
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -81,12 +82,32 @@ func (RoutingLost) Service() ServiceID {
 	return RoutingLostService
 }
 
+// routingLostSize is the value of the structure length field of a routing lost indication.
+const routingLostSize = 4
+
+// Size returns the packed size.
+func (RoutingLost) Size() uint {
+	return routingLostSize
+}
+
+// Pack assembles the service payload in the given buffer.
+func (rl *RoutingLost) Pack(buffer []byte) {
+	util.PackSome(buffer, uint8(routingLostSize), uint8(rl.Status), rl.Count)
+}
+
 // Unpack parses the given service payload in order to initialize the structure.
-func (rl *RoutingLost) Unpack(data []byte) (uint, error) {
+func (rl *RoutingLost) Unpack(data []byte) (n uint, err error) {
 	var length uint8
-	return util.UnpackSome(data, &length, (*uint8)(&rl.Status), &rl.Count)
 
-	// TODO: Find out if length is supposed to be 4; validate it, if so.
+	if n, err = util.UnpackSome(data, &length, (*uint8)(&rl.Status), &rl.Count); err != nil {
+		return
+	}
+
+	if length != routingLostSize {
+		return n, errors.New("routing lost structure length is invalid")
+	}
+
+	return
 }
 
 // A RoutingBusy indicates that a router is busy.
@@ -106,6 +127,22 @@ func (RoutingBusy) Service() ServiceID {
 	return RoutingBusyService
 }
 
+// routingBusySize is the value of the structure length field of a routing busy indication.
+const routingBusySize = 6
+
+// Size returns the packed size.
+func (RoutingBusy) Size() uint {
+	return routingBusySize
+}
+
+// Pack assembles the service payload in the given buffer.
+func (rl *RoutingBusy) Pack(buffer []byte) {
+	util.PackSome(
+		buffer,
+		uint8(routingBusySize), uint8(rl.Status), uint16(rl.WaitTime/time.Millisecond), rl.Control,
+	)
+}
+
 // Unpack parses the given service payload in order to initialize the structure.
 func (rl *RoutingBusy) Unpack(data []byte) (n uint, err error) {
 	var length uint8
@@ -117,7 +154,9 @@ func (rl *RoutingBusy) Unpack(data []byte) (n uint, err error) {
 		return
 	}
 
-	// TODO: Find out if length is supposed to be 6; validate it, if so.
+	if length != routingBusySize {
+		return n, errors.New("routing busy structure length is invalid")
+	}
 
 	rl.WaitTime = time.Duration(waitTime) * time.Millisecond
 
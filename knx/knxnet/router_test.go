@@ -0,0 +1,61 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+func TestRoutingLost(t *testing.T) {
+	lost := &RoutingLost{Status: DeviceStateOk, Count: 0x1234}
+
+	data := AllocAndPack(lost)
+	expected := []byte{6, 16, 0x05, 0x31, 0, 10, 4, 0, 0x12, 0x34}
+
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("Unexpected packet: %v", data)
+	}
+
+	var srv Service
+	if _, err := Unpack(data, &srv); err != nil {
+		t.Fatal(err)
+	}
+
+	if unpacked, ok := srv.(*RoutingLost); !ok || *unpacked != *lost {
+		t.Errorf("Unexpected service: %+v", srv)
+	}
+
+	var invalid RoutingLost
+	if _, err := invalid.Unpack([]byte{6, 0, 0x12, 0x34}); err == nil {
+		t.Error("Invalid structure length was accepted")
+	}
+}
+
+func TestRoutingBusy(t *testing.T) {
+	busy := &RoutingBusy{Status: DeviceStateIPError, WaitTime: 100 * time.Millisecond, Control: 0}
+
+	data := util.AllocAndPack(busy)
+	expected := []byte{6, 2, 0, 100, 0, 0}
+
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("Unexpected payload: %v", data)
+	}
+
+	var unpacked RoutingBusy
+	if _, err := unpacked.Unpack(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if unpacked != *busy {
+		t.Errorf("Unexpected service: %+v", unpacked)
+	}
+
+	var invalid RoutingBusy
+	if _, err := invalid.Unpack([]byte{4, 0, 0, 100, 0, 0}); err == nil {
+		t.Error("Invalid structure length was accepted")
+	}
+}
@@ -0,0 +1,286 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/util"
+	"golang.org/x/net/ipv4"
+)
+
+// UnicastRouterConfig configures a UnicastRouterSocket.
+type UnicastRouterConfig struct {
+	// Peers are the addresses of the static peers, e.g. "10.1.0.2:3671".
+	Peers []string
+
+	// Dynamic allows unknown senders to join. They are added as peers when their first routing
+	// packet arrives. Otherwise packets from unknown senders are discarded.
+	Dynamic bool
+
+	// PeerTimeout is the time after which a dynamic peer that has not sent anything is removed. 0
+	// means that dynamic peers are kept until the socket is closed.
+	PeerTimeout time.Duration
+
+	// Reflect forwards the routing packets received from one peer to all other peers. This turns
+	// the socket into the hub of a star, whose spokes only need to know the hub.
+	Reflect bool
+}
+
+// A unicastPeer is a member of a unicast routing domain.
+type unicastPeer struct {
+	addr     *net.UDPAddr
+	static   bool
+	lastSeen time.Time
+}
+
+// UnicastRouterSocket exchanges routing packets with a list of peers over unicast UDP. It is an
+// alternative to RouterSocket for networks which can't carry IP multicast, such as most VPNs.
+// Packets are sent to every peer.
+type UnicastRouterSocket struct {
+	conn    *net.UDPConn
+	pc      *ipv4.PacketConn
+	config  UnicastRouterConfig
+	inbound chan Service
+
+	mu    sync.Mutex
+	peers map[string]*unicastPeer
+}
+
+// ListenUnicastRouter creates a Socket bound to the local address, e.g. ":3671", which exchanges
+// routing packets with the configured peers. To share the port with a multicast router in the same
+// process, bind it to a specific address, e.g. "10.8.0.5:3671".
+func ListenUnicastRouter(localAddress string, config UnicastRouterConfig) (*UnicastRouterSocket, error) {
+	if len(config.Peers) == 0 && !config.Dynamic {
+		return nil, errors.New("unicast routing needs peers or dynamic registration")
+	}
+
+	sock := &UnicastRouterSocket{
+		config:  config,
+		inbound: make(chan Service),
+		peers:   map[string]*unicastPeer{},
+	}
+
+	for _, peer := range config.Peers {
+		if err := sock.AddPeer(peer); err != nil {
+			return nil, err
+		}
+	}
+
+	addr, err := net.ResolveUDPAddr("udp4", localAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	if sock.conn, err = listenUDP(addr); err != nil {
+		return nil, err
+	}
+
+	// A multicast router in the same process may listen on the same port. Its packets must be told
+	// apart by their destination.
+	sock.pc = ipv4.NewPacketConn(sock.conn)
+	if err := sock.pc.SetControlMessage(ipv4.FlagDst, true); err != nil {
+		util.Log(sock, "SetControlMessage error: %v", err)
+	}
+
+	go sock.serve()
+
+	return sock, nil
+}
+
+// AddPeer adds a static peer.
+func (sock *UnicastRouterSocket) AddPeer(address string) error {
+	addr, err := net.ResolveUDPAddr("udp4", address)
+	if err != nil {
+		return err
+	}
+
+	if addr.IP.IsMulticast() {
+		return errors.New("unicast peers must not be multicast addresses")
+	}
+
+	sock.mu.Lock()
+	defer sock.mu.Unlock()
+
+	sock.peers[addr.String()] = &unicastPeer{addr: addr, static: true}
+
+	return nil
+}
+
+// RemovePeer removes a static or dynamic peer.
+func (sock *UnicastRouterSocket) RemovePeer(address string) error {
+	addr, err := net.ResolveUDPAddr("udp4", address)
+	if err != nil {
+		return err
+	}
+
+	sock.mu.Lock()
+	defer sock.mu.Unlock()
+
+	delete(sock.peers, addr.String())
+
+	return nil
+}
+
+// Peers returns the addresses of the current peers in lexical order.
+func (sock *UnicastRouterSocket) Peers() []*net.UDPAddr {
+	sock.mu.Lock()
+	defer sock.mu.Unlock()
+
+	sock.expire(time.Now())
+
+	addrs := make([]*net.UDPAddr, 0, len(sock.peers))
+	for _, peer := range sock.peers {
+		addrs = append(addrs, peer.addr)
+	}
+
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i].String() < addrs[j].String() })
+
+	return addrs
+}
+
+// expire removes the dynamic peers which have been silent for too long. The caller must hold the
+// lock.
+func (sock *UnicastRouterSocket) expire(now time.Time) {
+	if sock.config.PeerTimeout <= 0 {
+		return
+	}
+
+	for key, peer := range sock.peers {
+		if !peer.static && now.Sub(peer.lastSeen) > sock.config.PeerTimeout {
+			delete(sock.peers, key)
+		}
+	}
+}
+
+// admit determines whether a packet from the sender is accepted, registering the sender if dynamic
+// registration is enabled.
+func (sock *UnicastRouterSocket) admit(sender *net.UDPAddr, now time.Time) bool {
+	sock.mu.Lock()
+	defer sock.mu.Unlock()
+
+	sock.expire(now)
+
+	peer, ok := sock.peers[sender.String()]
+	if !ok {
+		if !sock.config.Dynamic {
+			return false
+		}
+
+		util.Log(sock, "Peer %v has joined", sender)
+
+		peer = &unicastPeer{addr: sender}
+		sock.peers[sender.String()] = peer
+	}
+
+	peer.lastSeen = now
+
+	return true
+}
+
+// sendTo transmits the packet to all peers except the given one. The first error is returned, but
+// all peers are tried.
+func (sock *UnicastRouterSocket) sendTo(buffer []byte, except *net.UDPAddr) error {
+	sock.mu.Lock()
+	sock.expire(time.Now())
+
+	addrs := make([]*net.UDPAddr, 0, len(sock.peers))
+	for _, peer := range sock.peers {
+		if except == nil || peer.addr.String() != except.String() {
+			addrs = append(addrs, peer.addr)
+		}
+	}
+
+	sock.mu.Unlock()
+
+	var first error
+
+	for _, addr := range addrs {
+		if _, err := sock.conn.WriteToUDP(buffer, addr); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// Send transmits a KNXnet/IP packet to all peers.
+func (sock *UnicastRouterSocket) Send(payload ServicePackable) error {
+	buffer := make([]byte, Size(payload))
+	Pack(buffer, payload)
+
+	return sock.sendTo(buffer, nil)
+}
+
+// serve is the receiver worker. Only routing packets are accepted.
+func (sock *UnicastRouterSocket) serve() {
+	util.Log(sock, "Started worker")
+	defer util.Log(sock, "Worker exited")
+
+	// A closed inbound channel indicates to its readers that the worker has terminated.
+	defer close(sock.inbound)
+
+	buffer := [1024]byte{}
+
+	for {
+		len, cm, src, err := sock.pc.ReadFrom(buffer[:])
+		if err != nil {
+			util.Log(sock, "Error during ReadFrom: %v", err)
+			return
+		}
+
+		// Packets addressed to a multicast group belong to multicast routers.
+		if cm != nil && cm.Dst.IsMulticast() {
+			continue
+		}
+
+		sender, ok := src.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+
+		var payload Service
+		if _, err := Unpack(buffer[:len], &payload); err != nil {
+			util.Log(sock, "Error during Unpack: %v", err)
+			continue
+		}
+
+		switch payload.(type) {
+		case *RoutingInd, *RoutingBusy, *RoutingLost:
+		default:
+			continue
+		}
+
+		if !sock.admit(sender, time.Now()) {
+			util.Log(sock, "Discarded packet from unknown peer %v", sender)
+			continue
+		}
+
+		if sock.config.Reflect {
+			if err := sock.sendTo(buffer[:len], sender); err != nil {
+				util.Log(sock, "Error while reflecting packet: %v", err)
+			}
+		}
+
+		sock.inbound <- payload
+	}
+}
+
+// Inbound provides a channel from which you can retrieve incoming packets.
+func (sock *UnicastRouterSocket) Inbound() <-chan Service {
+	return sock.inbound
+}
+
+// Close shuts the socket down. This will indirectly terminate the associated workers.
+func (sock *UnicastRouterSocket) Close() error {
+	return sock.conn.Close()
+}
+
+// LocalAddr returns the local UDP address.
+func (sock *UnicastRouterSocket) LocalAddr() net.Addr {
+	return sock.conn.LocalAddr()
+}
@@ -0,0 +1,129 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func receiveService(t *testing.T, sock *UnicastRouterSocket) Service {
+	t.Helper()
+
+	select {
+	case srv := <-sock.Inbound():
+		return srv
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("No packet received")
+		return nil
+	}
+}
+
+func TestUnicastRouterSocket(t *testing.T) {
+	if _, err := ListenUnicastRouter("127.0.0.1:0", UnicastRouterConfig{}); err == nil {
+		t.Error("Socket without peers was accepted")
+	}
+
+	hub, err := ListenUnicastRouter("127.0.0.1:0", UnicastRouterConfig{Dynamic: true, Reflect: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hub.Close()
+
+	spokes := make([]*UnicastRouterSocket, 2)
+	for i := range spokes {
+		spokes[i], err = ListenUnicastRouter("127.0.0.1:0", UnicastRouterConfig{
+			Peers: []string{hub.LocalAddr().String()},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer spokes[i].Close()
+	}
+
+	// The first spoke joins the hub, but there is nobody to reflect its packet to yet.
+	if err := spokes[0].Send(&RoutingBusy{WaitTime: 20 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := receiveService(t, hub).(*RoutingBusy); !ok {
+		t.Error("Hub did not receive RoutingBusy")
+	}
+
+	// Packets of unknown senders are discarded by static sockets.
+	stranger, err := net.DialUDP("udp4", nil, spokes[0].LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stranger.Close()
+
+	if _, err := stranger.Write(AllocAndPack(&RoutingLost{Count: 1})); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second spoke joins and its packet is reflected to the first one.
+	if err := spokes[1].Send(&RoutingLost{Count: 7}); err != nil {
+		t.Fatal(err)
+	}
+
+	if lost, ok := receiveService(t, hub).(*RoutingLost); !ok || lost.Count != 7 {
+		t.Error("Hub did not receive RoutingLost")
+	}
+
+	if lost, ok := receiveService(t, spokes[0]).(*RoutingLost); !ok || lost.Count != 7 {
+		t.Error("RoutingLost was not reflected")
+	}
+
+	if peers := hub.Peers(); len(peers) != 2 {
+		t.Errorf("Unexpected peers of the hub: %v", peers)
+	}
+
+	// Removed peers don't receive packets anymore.
+	if err := hub.RemovePeer(spokes[0].LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	if peers := hub.Peers(); len(peers) != 1 || peers[0].String() != spokes[1].LocalAddr().String() {
+		t.Errorf("Unexpected peers of the hub: %v", peers)
+	}
+}
+
+func TestUnicastRouterSocket_expire(t *testing.T) {
+	sock := &UnicastRouterSocket{
+		config: UnicastRouterConfig{Dynamic: true, PeerTimeout: time.Minute},
+		peers:  map[string]*unicastPeer{},
+	}
+
+	if err := sock.AddPeer("127.0.0.1:3671"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sock.AddPeer("224.0.23.12:3671"); err == nil {
+		t.Error("Multicast peer was accepted")
+	}
+
+	now := time.Now()
+
+	if !sock.admit(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 2), Port: 3671}, now) {
+		t.Fatal("Dynamic peer was rejected")
+	}
+
+	sock.mu.Lock()
+	sock.expire(now.Add(30 * time.Second))
+	if len(sock.peers) != 2 {
+		t.Errorf("Dynamic peer expired too early")
+	}
+
+	sock.expire(now.Add(2 * time.Minute))
+	if len(sock.peers) != 1 || sock.peers["127.0.0.1:3671"] == nil {
+		t.Errorf("Unexpected peers after expiry: %v", sock.peers)
+	}
+	sock.mu.Unlock()
+
+	sock.config.Dynamic = false
+	if sock.admit(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 3), Port: 3671}, now) {
+		t.Error("Unknown peer was admitted")
+	}
+}
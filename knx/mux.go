@@ -0,0 +1,164 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// groupEventBuffer is the number of events a subscriber can lag behind before old events are
+// dropped.
+const groupEventBuffer = 64
+
+var errGroupClientClosed = errors.New("group client has been closed")
+
+// groupSub is a subscription to group events.
+type groupSub struct {
+	events chan GroupEvent
+	addrs  map[cemi.GroupAddr]struct{}
+}
+
+// matches determines whether the subscriber is interested in the event.
+func (sub *groupSub) matches(event GroupEvent) bool {
+	if sub.addrs == nil {
+		return true
+	}
+
+	_, ok := sub.addrs[event.Destination]
+	return ok
+}
+
+// deliver passes the event to the subscriber without blocking. If the subscriber falls behind, its
+// oldest event is dropped.
+func (sub *groupSub) deliver(event GroupEvent) {
+	select {
+	case sub.events <- event:
+		return
+	default:
+	}
+
+	select {
+	case <-sub.events:
+	default:
+	}
+
+	select {
+	case sub.events <- event:
+	default:
+	}
+}
+
+// A GroupSubscriber sends group communications and lets several consumers subscribe to the events
+// of particular group addresses. GroupMux is the implementation provided by this package.
+type GroupSubscriber interface {
+	Send(event GroupEvent) error
+	Subscribe(addrs ...cemi.GroupAddr) (<-chan GroupEvent, func())
+}
+
+var _ GroupSubscriber = (*GroupMux)(nil)
+
+// A GroupMux distributes the group events of a GroupClient to any number of subscribers, so that
+// several consumers can share one connection. It is a GroupClient itself.
+type GroupMux struct {
+	client GroupClient
+
+	mu     sync.Mutex
+	subs   map[*groupSub]struct{}
+	closed bool
+
+	inbound <-chan GroupEvent
+}
+
+// NewGroupMux starts distributing the inbound events of the given client. The client must not be
+// read from by anyone else afterwards.
+func NewGroupMux(client GroupClient) *GroupMux {
+	mux := &GroupMux{
+		client: client,
+		subs:   make(map[*groupSub]struct{}),
+	}
+
+	mux.inbound, _ = mux.Subscribe()
+
+	go mux.serve()
+
+	return mux
+}
+
+// serve distributes the inbound events until the client closes its inbound channel.
+func (mux *GroupMux) serve() {
+	util.Log(mux, "Started worker")
+	defer util.Log(mux, "Worker exited")
+
+	for event := range mux.client.Inbound() {
+		mux.mu.Lock()
+		for sub := range mux.subs {
+			if sub.matches(event) {
+				sub.deliver(event)
+			}
+		}
+		mux.mu.Unlock()
+	}
+
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	mux.closed = true
+
+	for sub := range mux.subs {
+		close(sub.events)
+	}
+
+	mux.subs = nil
+}
+
+// Subscribe returns a channel on which the events targeting the given group addresses are
+// delivered. Without addresses, all events are delivered. The channel is closed when the client
+// closes its inbound channel or when the returned function is called. Subscribers that fall behind
+// lose their oldest events.
+func (mux *GroupMux) Subscribe(addrs ...cemi.GroupAddr) (<-chan GroupEvent, func()) {
+	sub := &groupSub{events: make(chan GroupEvent, groupEventBuffer)}
+
+	if len(addrs) > 0 {
+		sub.addrs = make(map[cemi.GroupAddr]struct{}, len(addrs))
+		for _, addr := range addrs {
+			sub.addrs[addr] = struct{}{}
+		}
+	}
+
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	if mux.closed {
+		close(sub.events)
+		return sub.events, func() {}
+	}
+
+	mux.subs[sub] = struct{}{}
+
+	unsubscribe := func() {
+		mux.mu.Lock()
+		defer mux.mu.Unlock()
+
+		if _, ok := mux.subs[sub]; ok {
+			delete(mux.subs, sub)
+			close(sub.events)
+		}
+	}
+
+	return sub.events, unsubscribe
+}
+
+// Send a group communication through the client.
+func (mux *GroupMux) Send(event GroupEvent) error {
+	return mux.client.Send(event)
+}
+
+// Inbound returns a channel on which all events are delivered. Events are dropped if nobody reads
+// from it.
+func (mux *GroupMux) Inbound() <-chan GroupEvent {
+	return mux.inbound
+}
@@ -0,0 +1,93 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"math/rand"
+	"time"
+)
+
+// A ReconnectPolicy determines how a lost connection is re-established.
+type ReconnectPolicy struct {
+	// InitialInterval is the time to wait after the first failed reconnection attempt.
+	InitialInterval time.Duration
+
+	// MaxInterval caps the time to wait between two reconnection attempts.
+	MaxInterval time.Duration
+
+	// Multiplier is the factor by which the interval grows after each failed attempt.
+	Multiplier float64
+
+	// Jitter is the fraction of the interval which is randomised, e.g. 0.2 means ±20%. A negative
+	// value disables the randomisation.
+	Jitter float64
+
+	// MaxAttempts limits the number of consecutive reconnection attempts. Zero means there is no
+	// limit, a negative value disables reconnecting altogether.
+	MaxAttempts int
+}
+
+// DefaultReconnectPolicy retries forever, backing off from 1 second to 1 minute.
+var DefaultReconnectPolicy = ReconnectPolicy{
+	InitialInterval: time.Second,
+	MaxInterval:     time.Minute,
+	Multiplier:      2,
+	Jitter:          0.2,
+	MaxAttempts:     0,
+}
+
+// checkReconnectPolicy fills in the default values for unset fields.
+func checkReconnectPolicy(policy ReconnectPolicy) ReconnectPolicy {
+	if policy.InitialInterval <= 0 {
+		policy.InitialInterval = DefaultReconnectPolicy.InitialInterval
+	}
+
+	if policy.MaxInterval <= 0 {
+		policy.MaxInterval = DefaultReconnectPolicy.MaxInterval
+	}
+
+	if policy.MaxInterval < policy.InitialInterval {
+		policy.MaxInterval = policy.InitialInterval
+	}
+
+	if policy.Multiplier < 1 {
+		policy.Multiplier = DefaultReconnectPolicy.Multiplier
+	}
+
+	if policy.Jitter == 0 {
+		policy.Jitter = DefaultReconnectPolicy.Jitter
+	} else if policy.Jitter > 1 {
+		policy.Jitter = 1
+	}
+
+	return policy
+}
+
+// enabled determines whether any reconnection attempts shall be made.
+func (policy ReconnectPolicy) enabled() bool {
+	return policy.MaxAttempts >= 0
+}
+
+// exhausted determines whether the given number of failed attempts reaches the limit.
+func (policy ReconnectPolicy) exhausted(attempts int) bool {
+	return policy.MaxAttempts > 0 && attempts >= policy.MaxAttempts
+}
+
+// delay computes the time to wait after the given number of failed attempts.
+func (policy ReconnectPolicy) delay(attempts int) time.Duration {
+	interval := float64(policy.InitialInterval)
+
+	for i := 1; i < attempts && interval < float64(policy.MaxInterval); i++ {
+		interval *= policy.Multiplier
+	}
+
+	if interval > float64(policy.MaxInterval) {
+		interval = float64(policy.MaxInterval)
+	}
+
+	if policy.Jitter > 0 {
+		interval += interval * policy.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(interval)
+}
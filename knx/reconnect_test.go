@@ -0,0 +1,62 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReconnectPolicy_delay(t *testing.T) {
+	policy := checkReconnectPolicy(ReconnectPolicy{
+		InitialInterval: time.Second,
+		MaxInterval:     10 * time.Second,
+		Multiplier:      2,
+		Jitter:          -1,
+	})
+
+	expected := []time.Duration{
+		time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		8 * time.Second,
+		10 * time.Second,
+		10 * time.Second,
+	}
+
+	for i, delay := range expected {
+		if actual := policy.delay(i + 1); actual != delay {
+			t.Errorf("Attempt %d: expected delay %v, got %v", i+1, delay, actual)
+		}
+	}
+}
+
+func TestReconnectPolicy_jitter(t *testing.T) {
+	policy := checkReconnectPolicy(ReconnectPolicy{
+		InitialInterval: time.Second,
+		Jitter:          0.5,
+	})
+
+	for i := 0; i < 100; i++ {
+		delay := policy.delay(1)
+		if delay < 500*time.Millisecond || delay > 1500*time.Millisecond {
+			t.Fatalf("Delay %v is outside of the jitter range", delay)
+		}
+	}
+}
+
+func TestReconnectPolicy_attempts(t *testing.T) {
+	if !DefaultReconnectPolicy.enabled() || DefaultReconnectPolicy.exhausted(1000) {
+		t.Error("Default policy should retry forever")
+	}
+
+	limited := ReconnectPolicy{MaxAttempts: 3}
+	if limited.exhausted(2) || !limited.exhausted(3) {
+		t.Error("Limited policy should give up after 3 attempts")
+	}
+
+	disabled := ReconnectPolicy{MaxAttempts: -1}
+	if disabled.enabled() {
+		t.Error("Policy with negative MaxAttempts should be disabled")
+	}
+}
@@ -0,0 +1,494 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Package registry keeps the names, datapoint types and other metadata of group addresses in one
+// place, so that tools can share them.
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/dpt"
+	"github.com/vapourismo/knx-go/knx/ets"
+)
+
+// Flags are the communication flags of a group address.
+type Flags uint8
+
+// These are the flags known from ETS.
+const (
+	FlagCommunication Flags = 1 << iota
+	FlagRead
+	FlagWrite
+	FlagTransmit
+	FlagUpdate
+	FlagInit
+)
+
+var flagLetters = []struct {
+	flag   Flags
+	letter byte
+}{
+	{FlagCommunication, 'C'},
+	{FlagRead, 'R'},
+	{FlagWrite, 'W'},
+	{FlagTransmit, 'T'},
+	{FlagUpdate, 'U'},
+	{FlagInit, 'I'},
+}
+
+// String generates the letters of the flags as ETS shows them, e.g. "CWT".
+func (flags Flags) String() string {
+	var letters []byte
+
+	for _, fl := range flagLetters {
+		if flags&fl.flag != 0 {
+			letters = append(letters, fl.letter)
+		}
+	}
+
+	return string(letters)
+}
+
+// ParseFlags parses flags from their letters, e.g. "CWT".
+func ParseFlags(letters string) (Flags, error) {
+	var flags Flags
+
+outer:
+	for _, letter := range strings.ToUpper(letters) {
+		for _, fl := range flagLetters {
+			if rune(fl.letter) == letter {
+				flags |= fl.flag
+				continue outer
+			}
+		}
+
+		return 0, fmt.Errorf("unknown flag %q", letter)
+	}
+
+	return flags, nil
+}
+
+// AddressStyle determines how group addresses are formatted.
+type AddressStyle uint8
+
+// These are the address styles known from ETS.
+const (
+	// ThreeLevel formats addresses as "main/middle/sub".
+	ThreeLevel AddressStyle = iota
+
+	// TwoLevel formats addresses as "main/sub".
+	TwoLevel
+
+	// Free formats addresses as a plain number.
+	Free
+)
+
+// ParseAddressStyle parses the name of an address style, which is either "3", "2" or "free".
+func ParseAddressStyle(name string) (AddressStyle, error) {
+	switch strings.ToLower(name) {
+	case "3", "3-level", "three":
+		return ThreeLevel, nil
+
+	case "2", "2-level", "two":
+		return TwoLevel, nil
+
+	case "free", "1":
+		return Free, nil
+	}
+
+	return 0, fmt.Errorf("unknown address style %q", name)
+}
+
+// String generates the name of the style.
+func (style AddressStyle) String() string {
+	switch style {
+	case ThreeLevel:
+		return "3-level"
+
+	case TwoLevel:
+		return "2-level"
+
+	case Free:
+		return "free"
+	}
+
+	return "unknown"
+}
+
+// Format formats the group address.
+func (style AddressStyle) Format(addr cemi.GroupAddr) string {
+	switch style {
+	case TwoLevel:
+		return fmt.Sprintf("%d/%d", uint8(addr>>11)&0x1F, uint16(addr)&0x7FF)
+
+	case Free:
+		return strconv.Itoa(int(addr))
+	}
+
+	return addr.String()
+}
+
+// An Entry contains the metadata of a group address.
+type Entry struct {
+	Address cemi.GroupAddr
+	Name    string
+
+	// Path contains the names of the groups containing the address, outermost first.
+	Path []string
+
+	// DPT is the name of the datapoint type which can be used with dpt.Produce, e.g. "9.001".
+	DPT string
+
+	Flags   Flags
+	Comment string
+}
+
+// FullName returns the path and the name joined by "/", e.g. "Ground floor/Lights/Kitchen".
+func (entry Entry) FullName() string {
+	return strings.Join(append(append([]string(nil), entry.Path...), entry.Name), "/")
+}
+
+// Datapoint produces a value of the datapoint type of the entry.
+func (entry Entry) Datapoint() (dpt.Datapoint, bool) {
+	if entry.DPT == "" {
+		return nil, false
+	}
+
+	return dpt.Produce(entry.DPT)
+}
+
+// A Registry holds the metadata of group addresses. It is safe for concurrent use.
+type Registry struct {
+	mu     sync.RWMutex
+	style  AddressStyle
+	byAddr map[cemi.GroupAddr]*Entry
+	byName map[string][]*Entry
+}
+
+// New creates an empty registry which formats addresses in 3-level style.
+func New() *Registry {
+	return &Registry{
+		byAddr: make(map[cemi.GroupAddr]*Entry),
+		byName: make(map[string][]*Entry),
+	}
+}
+
+// FromTable creates a registry from a group address table, e.g. one imported from ETS.
+func FromTable(table *ets.GroupAddressTable) *Registry {
+	reg := New()
+
+	for _, ga := range table.Addresses {
+		var groups []string
+		for _, name := range []string{ga.Main, ga.Middle} {
+			if name != "" {
+				groups = append(groups, name)
+			}
+		}
+
+		reg.Add(Entry{
+			Address: ga.Address,
+			Name:    ga.Name,
+			Path:    groups,
+			DPT:     ga.DPT,
+			Comment: ga.Description,
+		})
+	}
+
+	return reg
+}
+
+// Load creates a registry from an ETS project or group address export.
+func Load(name, password string) (*Registry, error) {
+	table, err := ets.LoadGroupAddresses(name, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return FromTable(table), nil
+}
+
+// SetStyle changes the style in which addresses are formatted.
+func (reg *Registry) SetStyle(style AddressStyle) {
+	reg.mu.Lock()
+	reg.style = style
+	reg.mu.Unlock()
+}
+
+// Style returns the style in which addresses are formatted.
+func (reg *Registry) Style() AddressStyle {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	return reg.style
+}
+
+// Format formats the group address in the style of the registry.
+func (reg *Registry) Format(addr cemi.GroupAddr) string {
+	return reg.Style().Format(addr)
+}
+
+// Describe formats the group address followed by its full name, if it is known.
+func (reg *Registry) Describe(addr cemi.GroupAddr) string {
+	text := reg.Format(addr)
+
+	if entry, ok := reg.Lookup(addr); ok {
+		text += " " + entry.FullName()
+	}
+
+	return text
+}
+
+// unlink removes the entry from the name index.
+func (reg *Registry) unlink(entry *Entry) {
+	name := entry.FullName()
+	entries := reg.byName[name]
+
+	for i, other := range entries {
+		if other == entry {
+			entries = append(entries[:i], entries[i+1:]...)
+			break
+		}
+	}
+
+	if len(entries) == 0 {
+		delete(reg.byName, name)
+	} else {
+		reg.byName[name] = entries
+	}
+}
+
+// Add adds an entry or replaces the entry for the same address.
+func (reg *Registry) Add(entry Entry) {
+	entry.Path = append([]string(nil), entry.Path...)
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if old, ok := reg.byAddr[entry.Address]; ok {
+		reg.unlink(old)
+	}
+
+	reg.byAddr[entry.Address] = &entry
+
+	// Names need not be unique. Entries with the same name are ordered by address.
+	name := entry.FullName()
+	entries := append(reg.byName[name], &entry)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Address < entries[j].Address
+	})
+	reg.byName[name] = entries
+}
+
+// Remove removes the entry for the address.
+func (reg *Registry) Remove(addr cemi.GroupAddr) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if entry, ok := reg.byAddr[addr]; ok {
+		reg.unlink(entry)
+		delete(reg.byAddr, addr)
+	}
+}
+
+// Len returns the number of entries.
+func (reg *Registry) Len() int {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	return len(reg.byAddr)
+}
+
+// copyEntry copies the entry, so that the caller cannot modify the registry.
+func copyEntry(entry *Entry) Entry {
+	result := *entry
+	result.Path = append([]string(nil), entry.Path...)
+
+	return result
+}
+
+// Lookup finds the entry for the address.
+func (reg *Registry) Lookup(addr cemi.GroupAddr) (Entry, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	entry, ok := reg.byAddr[addr]
+	if !ok {
+		return Entry{}, false
+	}
+
+	return copyEntry(entry), true
+}
+
+// LookupName finds the entry by its full name, e.g. "Ground floor/Lights/Kitchen". If several
+// entries have the same name, the one with the lowest address is returned.
+func (reg *Registry) LookupName(name string) (Entry, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	entries := reg.byName[name]
+	if len(entries) == 0 {
+		return Entry{}, false
+	}
+
+	return copyEntry(entries[0]), true
+}
+
+// Resolve parses a group address or looks up an entry by its full name.
+func (reg *Registry) Resolve(nameOrAddr string) (cemi.GroupAddr, error) {
+	if entry, ok := reg.LookupName(nameOrAddr); ok {
+		return entry.Address, nil
+	}
+
+	if addr, err := cemi.NewGroupAddrString(nameOrAddr); err == nil {
+		return addr, nil
+	}
+
+	return 0, fmt.Errorf("unknown group address %q", nameOrAddr)
+}
+
+// filter returns the entries matching the predicate, ordered by address.
+func (reg *Registry) filter(pred func(*Entry) bool) []Entry {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	var result []Entry
+
+	for _, entry := range reg.byAddr {
+		if pred(entry) {
+			result = append(result, copyEntry(entry))
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Address < result[j].Address
+	})
+
+	return result
+}
+
+// All returns all entries ordered by address.
+func (reg *Registry) All() []Entry {
+	return reg.filter(func(*Entry) bool { return true })
+}
+
+// Range returns the entries with addresses from first to last, inclusively.
+func (reg *Registry) Range(first, last cemi.GroupAddr) []Entry {
+	return reg.filter(func(entry *Entry) bool {
+		return first <= entry.Address && entry.Address <= last
+	})
+}
+
+// Prefix returns the entries whose full name starts with the given groups, e.g. "Ground floor"
+// matches "Ground floor/Lights/Kitchen" but not "Ground floor 2/Lights/Kitchen".
+func (reg *Registry) Prefix(prefix string) []Entry {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	return reg.filter(func(entry *Entry) bool {
+		name := entry.FullName()
+		return prefix == "" || name == prefix || strings.HasPrefix(name, prefix+"/")
+	})
+}
+
+// Find returns the entries matching the pattern. Patterns consisting of digits, "/", "-" and "*"
+// select addresses, e.g. "1/2/*", "1/0-3/*" or "2/100-199". Any other pattern is matched against
+// the full names using path.Match, e.g. "Ground floor/*/Kitchen*".
+func (reg *Registry) Find(pattern string) ([]Entry, error) {
+	if strings.Trim(pattern, "0123456789/-*") == "" && strings.Contains(pattern, "/") {
+		match, err := ParseAddressPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		return reg.filter(func(entry *Entry) bool { return match(entry.Address) }), nil
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	return reg.filter(func(entry *Entry) bool {
+		ok, _ := path.Match(pattern, entry.FullName())
+		return ok
+	}), nil
+}
+
+var errBadAddressPattern = errors.New("malformed address pattern")
+
+// parseComponent parses a component of an address pattern, which is either "*", a number or a
+// range of numbers like "10-19".
+func parseComponent(component string, max uint64) (lo, hi uint64, err error) {
+	if component == "*" {
+		return 0, max, nil
+	}
+
+	first, last := component, component
+	if i := strings.IndexByte(component, '-'); i >= 0 {
+		first, last = component[:i], component[i+1:]
+	}
+
+	if lo, err = strconv.ParseUint(first, 10, 16); err != nil {
+		return 0, 0, errBadAddressPattern
+	}
+
+	if hi, err = strconv.ParseUint(last, 10, 16); err != nil {
+		return 0, 0, errBadAddressPattern
+	}
+
+	if lo > hi || hi > max {
+		return 0, 0, errBadAddressPattern
+	}
+
+	return lo, hi, nil
+}
+
+// ParseAddressPattern creates a predicate for a pattern over 2-level or 3-level addresses. Each
+// component of the pattern is either "*", a number or a range of numbers, e.g. "1/0-3/*".
+func ParseAddressPattern(pattern string) (func(cemi.GroupAddr) bool, error) {
+	components := strings.Split(pattern, "/")
+
+	var (
+		shifts []uint
+		masks  []uint64
+	)
+
+	switch len(components) {
+	case 2:
+		shifts, masks = []uint{11, 0}, []uint64{0x1F, 0x7FF}
+
+	case 3:
+		shifts, masks = []uint{11, 8, 0}, []uint64{0x1F, 0x7, 0xFF}
+
+	default:
+		return nil, errBadAddressPattern
+	}
+
+	los := make([]uint64, len(components))
+	his := make([]uint64, len(components))
+
+	for i, component := range components {
+		lo, hi, err := parseComponent(component, masks[i])
+		if err != nil {
+			return nil, err
+		}
+
+		los[i], his[i] = lo, hi
+	}
+
+	return func(addr cemi.GroupAddr) bool {
+		for i := range components {
+			value := uint64(addr>>shifts[i]) & masks[i]
+			if value < los[i] || value > his[i] {
+				return false
+			}
+		}
+
+		return true
+	}, nil
+}
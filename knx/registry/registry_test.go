@@ -0,0 +1,230 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package registry
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/dpt"
+	"github.com/vapourismo/knx-go/knx/ets"
+)
+
+func makeTestRegistry() *Registry {
+	reg := New()
+
+	reg.Add(Entry{
+		Address: cemi.NewGroupAddr3(1, 0, 1),
+		Name:    "Kitchen",
+		Path:    []string{"Ground floor", "Lights"},
+		DPT:     "1.001",
+		Flags:   FlagCommunication | FlagWrite,
+	})
+	reg.Add(Entry{
+		Address: cemi.NewGroupAddr3(1, 0, 2),
+		Name:    "Kitchen status",
+		Path:    []string{"Ground floor", "Lights"},
+		DPT:     "1.001",
+	})
+	reg.Add(Entry{
+		Address: cemi.NewGroupAddr3(1, 1, 1),
+		Name:    "Kitchen",
+		Path:    []string{"Ground floor", "Blinds"},
+		DPT:     "5.001",
+	})
+	reg.Add(Entry{
+		Address: cemi.NewGroupAddr3(2, 0, 1),
+		Name:    "Kitchen",
+		Path:    []string{"Ground floor 2", "Lights"},
+	})
+
+	return reg
+}
+
+func addresses(entries []Entry) string {
+	var addrs []string
+	for _, entry := range entries {
+		addrs = append(addrs, entry.Address.String())
+	}
+
+	return strings.Join(addrs, " ")
+}
+
+func TestRegistry_Lookup(t *testing.T) {
+	reg := makeTestRegistry()
+
+	entry, ok := reg.Lookup(cemi.NewGroupAddr3(1, 0, 1))
+	if !ok || entry.FullName() != "Ground floor/Lights/Kitchen" || entry.Flags.String() != "CW" {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+
+	if value, ok := entry.Datapoint(); !ok {
+		t.Error("Datapoint could not be produced")
+	} else if _, ok := value.(*dpt.DPT_1001); !ok {
+		t.Errorf("Unexpected datapoint type %T", value)
+	}
+
+	// Modifying the result must not modify the registry.
+	entry.Path[0] = "Modified"
+	if entry, _ := reg.Lookup(cemi.NewGroupAddr3(1, 0, 1)); entry.Path[0] != "Ground floor" {
+		t.Error("Registry was modified through a lookup result")
+	}
+
+	entry, ok = reg.LookupName("Ground floor/Blinds/Kitchen")
+	if !ok || entry.Address != cemi.NewGroupAddr3(1, 1, 1) {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+
+	if _, ok := reg.LookupName("Ground floor/Kitchen"); ok {
+		t.Error("Found entry for an incomplete name")
+	}
+
+	addr, err := reg.Resolve("Ground floor/Lights/Kitchen status")
+	if err != nil || addr != cemi.NewGroupAddr3(1, 0, 2) {
+		t.Errorf("Unexpected result %v, %v", addr, err)
+	}
+
+	addr, err = reg.Resolve("3/0/0")
+	if err != nil || addr != cemi.NewGroupAddr3(3, 0, 0) {
+		t.Errorf("Unexpected result %v, %v", addr, err)
+	}
+
+	if _, err := reg.Resolve("Nowhere"); err == nil {
+		t.Error("Resolved an unknown name")
+	}
+}
+
+func TestRegistry_Replace(t *testing.T) {
+	reg := makeTestRegistry()
+
+	reg.Add(Entry{Address: cemi.NewGroupAddr3(1, 0, 1), Name: "Pantry", Path: []string{"Ground floor"}})
+
+	if _, ok := reg.LookupName("Ground floor/Lights/Kitchen"); ok {
+		t.Error("Old name is still known")
+	}
+
+	if entry, ok := reg.LookupName("Ground floor/Pantry"); !ok || entry.Address != cemi.NewGroupAddr3(1, 0, 1) {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+
+	reg.Remove(cemi.NewGroupAddr3(1, 0, 1))
+
+	if _, ok := reg.LookupName("Ground floor/Pantry"); ok || reg.Len() != 3 {
+		t.Error("Entry has not been removed")
+	}
+}
+
+func TestRegistry_Find(t *testing.T) {
+	reg := makeTestRegistry()
+
+	cases := []struct {
+		pattern string
+		result  string
+	}{
+		{"1/0/*", "1/0/1 1/0/2"},
+		{"1/*/1", "1/0/1 1/1/1"},
+		{"1-2/0/1", "1/0/1 2/0/1"},
+		{"1/0-300", "1/0/1 1/0/2 1/1/1"},
+		{"*/*/2", "1/0/2"},
+		{"Ground floor/*/Kitchen", "1/0/1 1/1/1"},
+		{"*/Lights/Kitchen*", "1/0/1 1/0/2 2/0/1"},
+		{"Nowhere", ""},
+	}
+
+	for _, c := range cases {
+		entries, err := reg.Find(c.pattern)
+		if err != nil {
+			t.Errorf("%s: %v", c.pattern, err)
+		} else if result := addresses(entries); result != c.result {
+			t.Errorf("%s: got %q, expected %q", c.pattern, result, c.result)
+		}
+	}
+
+	for _, pattern := range []string{"1/8/*", "1/2-1/*", "1/2/3/4", "Ground[floor"} {
+		if _, err := reg.Find(pattern); err == nil {
+			t.Errorf("%s: pattern was accepted", pattern)
+		}
+	}
+
+	if result := addresses(reg.Prefix("Ground floor")); result != "1/0/1 1/0/2 1/1/1" {
+		t.Errorf("Unexpected prefix result %q", result)
+	}
+
+	if result := addresses(reg.Prefix("Ground floor/Lights/")); result != "1/0/1 1/0/2" {
+		t.Errorf("Unexpected prefix result %q", result)
+	}
+
+	first, last := cemi.NewGroupAddr3(1, 0, 2), cemi.NewGroupAddr3(2, 0, 1)
+	if result := addresses(reg.Range(first, last)); result != "1/0/2 1/1/1 2/0/1" {
+		t.Errorf("Unexpected range result %q", result)
+	}
+}
+
+func TestAddressStyle(t *testing.T) {
+	addr := cemi.NewGroupAddr3(1, 2, 3)
+
+	for style, expected := range map[AddressStyle]string{
+		ThreeLevel: "1/2/3",
+		TwoLevel:   "1/515",
+		Free:       "2563",
+	} {
+		if result := style.Format(addr); result != expected {
+			t.Errorf("%v: got %q, expected %q", style, result, expected)
+		}
+
+		parsed, err := ParseAddressStyle(style.String())
+		if err != nil || parsed != style {
+			t.Errorf("%v: parsed as %v, %v", style, parsed, err)
+		}
+	}
+
+	reg := makeTestRegistry()
+	reg.SetStyle(TwoLevel)
+
+	if result := reg.Describe(cemi.NewGroupAddr3(1, 0, 1)); result != "1/1 Ground floor/Lights/Kitchen" {
+		t.Errorf("Unexpected description %q", result)
+	}
+
+	if result := reg.Describe(cemi.NewGroupAddr3(5, 0, 1)); result != "5/1" {
+		t.Errorf("Unexpected description %q", result)
+	}
+}
+
+func TestFlags(t *testing.T) {
+	flags, err := ParseFlags("crwtu")
+	if err != nil || flags.String() != "CRWTU" {
+		t.Errorf("Unexpected flags %v, %v", flags, err)
+	}
+
+	if _, err := ParseFlags("CX"); err == nil {
+		t.Error("Unknown flag was accepted")
+	}
+}
+
+func TestFromTable(t *testing.T) {
+	table := ets.NewGroupAddressTable()
+	table.Addresses[cemi.NewGroupAddr3(1, 0, 1)] = &ets.GroupAddress{
+		Address:     cemi.NewGroupAddr3(1, 0, 1),
+		Name:        "Kitchen",
+		Description: "Ceiling",
+		DPT:         "1.001",
+		Main:        "Ground floor",
+		Middle:      "Lights",
+	}
+	table.Addresses[cemi.NewGroupAddr3(2, 0, 0)] = &ets.GroupAddress{
+		Address: cemi.NewGroupAddr3(2, 0, 0),
+		Name:    "Central",
+	}
+
+	reg := FromTable(table)
+
+	entry, ok := reg.LookupName("Ground floor/Lights/Kitchen")
+	if !ok || entry.Comment != "Ceiling" || entry.DPT != "1.001" {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+
+	if entry, ok := reg.LookupName("Central"); !ok || len(entry.Path) != 0 {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+}
@@ -0,0 +1,132 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Package replay records telegram streams to files and plays them back.
+//
+// Recordings are JSON lines. Each line holds the time at which a frame was received and the
+// frame in hexadecimal cEMI encoding, e.g.
+//
+//	{"time":"2026-10-19T12:00:00.123Z","cemi":"2900bce011051203030080"}
+package replay
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// A Frame is a recorded cEMI frame.
+type Frame struct {
+	Time    time.Time
+	Message cemi.Message
+}
+
+// line is the JSON representation of a frame.
+type line struct {
+	Time time.Time `json:"time"`
+	CEMI string    `json:"cemi"`
+}
+
+// A Recorder writes frames to a recording.
+type Recorder struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewRecorder creates a recorder which writes to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{encoder: json.NewEncoder(w)}
+}
+
+// Write records a frame which was received at the given time.
+func (rec *Recorder) Write(t time.Time, msg cemi.Message) error {
+	buffer := make([]byte, cemi.Size(msg))
+	cemi.Pack(buffer, msg)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return rec.encoder.Encode(line{t, hex.EncodeToString(buffer)})
+}
+
+// Record writes the frames from the channel until it is closed. Use it with the Inbound channel of
+// a Tunnel, Router or any other Transport.
+func Record(rec *Recorder, inbound <-chan cemi.Message) error {
+	for msg := range inbound {
+		if err := rec.Write(time.Now(), msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// A Reader reads frames from a recording.
+type Reader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewReader creates a reader for the recording.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{scanner: bufio.NewScanner(r)}
+}
+
+// Next returns the next frame. It returns io.EOF at the end of the recording. Empty lines are
+// skipped.
+func (reader *Reader) Next() (Frame, error) {
+	for reader.scanner.Scan() {
+		reader.line++
+
+		text := reader.scanner.Bytes()
+		if len(text) == 0 {
+			continue
+		}
+
+		var l line
+		if err := json.Unmarshal(text, &l); err != nil {
+			return Frame{}, fmt.Errorf("line %d: %w", reader.line, err)
+		}
+
+		data, err := hex.DecodeString(l.CEMI)
+		if err != nil {
+			return Frame{}, fmt.Errorf("line %d: %w", reader.line, err)
+		}
+
+		frame := Frame{Time: l.Time}
+		if _, err := cemi.Unpack(data, &frame.Message); err != nil {
+			return Frame{}, fmt.Errorf("line %d: %w", reader.line, err)
+		}
+
+		return frame, nil
+	}
+
+	if err := reader.scanner.Err(); err != nil {
+		return Frame{}, err
+	}
+
+	return Frame{}, io.EOF
+}
+
+// ReadAll reads all frames of the recording.
+func ReadAll(r io.Reader) ([]Frame, error) {
+	reader := NewReader(r)
+
+	var frames []Frame
+
+	for {
+		frame, err := reader.Next()
+		if err == io.EOF {
+			return frames, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		frames = append(frames, frame)
+	}
+}
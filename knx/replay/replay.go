@@ -0,0 +1,165 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package replay
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// A Sender transmits frames. Tunnel, Router and every other Transport are senders.
+type Sender interface {
+	Send(data cemi.Message) error
+}
+
+// A Config controls how a recording is played back.
+type Config struct {
+	// Speed is the factor by which playback is accelerated. 1 keeps the original pacing, 0 sends
+	// the frames as fast as possible.
+	Speed float64
+
+	// Filter, if not nil, decides which frames are replayed. It is given the frame after the
+	// addresses have been remapped.
+	Filter func(frame *cemi.LData) bool
+
+	// Groups maps the group addresses of the recording to the ones used during playback.
+	Groups map[cemi.GroupAddr]cemi.GroupAddr
+
+	// Sources maps the individual addresses of the recording to the ones used during playback.
+	Sources map[cemi.IndividualAddr]cemi.IndividualAddr
+}
+
+// DefaultConfig keeps the original pacing and addresses.
+var DefaultConfig = Config{
+	Speed: 1,
+}
+
+// checkConfig validates the given configuration.
+func checkConfig(config Config) (Config, error) {
+	if config.Speed < 0 {
+		return config, errors.New("speed must not be negative")
+	}
+
+	return config, nil
+}
+
+// linkData extracts the link-layer data of a recorded frame. Frames recorded by a bus monitor are
+// decoded. Frames which carry no link-layer data yield nil.
+func linkData(msg cemi.Message) *cemi.LData {
+	switch msg := msg.(type) {
+	case *cemi.LDataInd:
+		return &msg.LData
+
+	case *cemi.LDataReq:
+		return &msg.LData
+
+	case *cemi.LDataCon:
+		return &msg.LData
+
+	case *cemi.LBusmonInd:
+		if ldata, err := msg.Frame(); err == nil {
+			return ldata
+		}
+	}
+
+	return nil
+}
+
+// prepare turns a recorded frame into the frame which is sent, or returns nil if the frame is not
+// replayed.
+func prepare(msg cemi.Message, target Sender, config *Config) cemi.Message {
+	original := linkData(msg)
+	if original == nil {
+		return nil
+	}
+
+	// Copy the frame so that the recording is not modified.
+	ldata := *original
+	ldata.Info = nil
+
+	if mapped, ok := config.Sources[ldata.Source]; ok {
+		ldata.Source = mapped
+	}
+
+	if ldata.Control2.IsGroupAddr() {
+		if mapped, ok := config.Groups[cemi.GroupAddr(ldata.Destination)]; ok {
+			ldata.Destination = uint16(mapped)
+		}
+	}
+
+	if config.Filter != nil && !config.Filter(&ldata) {
+		return nil
+	}
+
+	// Routers forward indications, everything else expects requests.
+	if _, ok := target.(*knx.Router); ok {
+		return &cemi.LDataInd{LData: ldata}
+	}
+
+	return &cemi.LDataReq{LData: ldata}
+}
+
+// Replay sends the frames of the recording to the target. It returns the number of frames that
+// were sent. Playback stops early if the context is cancelled or sending fails.
+func Replay(ctx context.Context, reader *Reader, target Sender, config Config) (int, error) {
+	config, err := checkConfig(config)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		sent      int
+		first     time.Time
+		start     time.Time
+		haveFirst bool
+	)
+
+	for {
+		frame, err := reader.Next()
+		if err == io.EOF {
+			return sent, nil
+		} else if err != nil {
+			return sent, err
+		}
+
+		if !haveFirst {
+			first, start, haveFirst = frame.Time, time.Now(), true
+		}
+
+		msg := prepare(frame.Message, target, &config)
+		if msg == nil {
+			continue
+		}
+
+		if config.Speed > 0 {
+			offset := time.Duration(float64(frame.Time.Sub(first)) / config.Speed)
+
+			if wait := time.Until(start.Add(offset)); wait > 0 {
+				timer := time.NewTimer(wait)
+
+				select {
+				case <-timer.C:
+
+				case <-ctx.Done():
+					timer.Stop()
+					return sent, ctx.Err()
+				}
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+
+		if err := target.Send(msg); err != nil {
+			return sent, err
+		}
+
+		sent++
+	}
+}
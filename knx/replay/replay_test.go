@@ -0,0 +1,146 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package replay
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+func makeFrame(dst cemi.GroupAddr, value byte) *cemi.LDataInd {
+	return &cemi.LDataInd{LData: cemi.LData{
+		Control1:    cemi.Control1StdFrame | cemi.Control1NoRepeat | cemi.Control1NoSysBroadcast,
+		Control2:    cemi.Control2GroupAddr | cemi.Control2Hops(6),
+		Source:      cemi.NewIndividualAddr3(1, 1, 5),
+		Destination: uint16(dst),
+		Data:        &cemi.AppData{Command: cemi.GroupValueWrite, Data: []byte{value}},
+	}}
+}
+
+// makeRecording records a frame for 1/2/3 and 1/2/4, 100 milliseconds apart.
+func makeRecording(t *testing.T) []byte {
+	buffer := &bytes.Buffer{}
+	bus := knx.NewBus()
+	source, sink := bus.Connect(), bus.Connect()
+	rec := NewRecorder(buffer)
+
+	done := make(chan error)
+	go func() { done <- Record(rec, sink.Inbound()) }()
+
+	if err := source.Send(makeFrame(cemi.NewGroupAddr3(1, 2, 3), 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if err := source.Send(makeFrame(cemi.NewGroupAddr3(1, 2, 4), 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Frames which are still queued are recorded before the channel reports its closure.
+	sink.Close()
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
+
+func TestRecord(t *testing.T) {
+	frames, err := ReadAll(bytes.NewReader(makeRecording(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(frames) != 2 || !reflect.DeepEqual(frames[0].Message, makeFrame(cemi.NewGroupAddr3(1, 2, 3), 1)) {
+		t.Fatalf("Unexpected frames %+v", frames)
+	}
+
+	if gap := frames[1].Time.Sub(frames[0].Time); gap < 100*time.Millisecond {
+		t.Errorf("Unexpected gap %v", gap)
+	}
+
+	if _, err := ReadAll(strings.NewReader("{\"time\":\"2026-10-19T12:00:00Z\",\"cemi\":\"xy\"}\n")); err == nil {
+		t.Error("Invalid recording was accepted")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	recording := makeRecording(t)
+
+	bus := knx.NewBus()
+	target, monitor := bus.Connect(), bus.Connect()
+	defer target.Close()
+	defer monitor.Close()
+
+	t.Run("Pacing", func(t *testing.T) {
+		start := time.Now()
+
+		sent, err := Replay(context.Background(), NewReader(bytes.NewReader(recording)), target, DefaultConfig)
+		if err != nil || sent != 2 {
+			t.Fatalf("Sent %d frames: %v", sent, err)
+		}
+
+		if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+			t.Errorf("Replay took only %v", elapsed)
+		}
+
+		<-monitor.Inbound()
+		<-monitor.Inbound()
+	})
+
+	t.Run("Remap and filter", func(t *testing.T) {
+		config := Config{
+			Groups: map[cemi.GroupAddr]cemi.GroupAddr{
+				cemi.NewGroupAddr3(1, 2, 3): cemi.NewGroupAddr3(5, 0, 1),
+			},
+			Sources: map[cemi.IndividualAddr]cemi.IndividualAddr{
+				cemi.NewIndividualAddr3(1, 1, 5): cemi.NewIndividualAddr3(15, 15, 1),
+			},
+			Filter: func(frame *cemi.LData) bool {
+				return cemi.GroupAddr(frame.Destination) != cemi.NewGroupAddr3(1, 2, 4)
+			},
+		}
+
+		start := time.Now()
+
+		sent, err := Replay(context.Background(), NewReader(bytes.NewReader(recording)), target, config)
+		if err != nil || sent != 1 {
+			t.Fatalf("Sent %d frames: %v", sent, err)
+		}
+
+		if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+			t.Errorf("Replay took %v", elapsed)
+		}
+
+		ind, ok := (<-monitor.Inbound()).(*cemi.LDataInd)
+		if !ok || cemi.GroupAddr(ind.Destination) != cemi.NewGroupAddr3(5, 0, 1) ||
+			ind.Source != cemi.NewIndividualAddr3(15, 15, 1) {
+			t.Errorf("Unexpected frame %+v", ind)
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		config := DefaultConfig
+		config.Speed = 0.01
+
+		if sent, err := Replay(ctx, NewReader(bytes.NewReader(recording)), target, config); err != context.Canceled || sent != 0 {
+			t.Errorf("Sent %d frames: %v", sent, err)
+		}
+
+		if _, err := Replay(ctx, NewReader(bytes.NewReader(recording)), target, Config{Speed: -1}); err == nil {
+			t.Error("Negative speed was accepted")
+		}
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"math/rand"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/vapourismo/knx-go/knx/cemi"
@@ -24,12 +25,29 @@ type RouterConfig struct {
 	// Specifies the interface used to send and receive KNXnet/IP packets. If the interface
 	// is nil, the system-assigned multicast interface is used.
 	Interface *net.Interface
+	// Specifies several interfaces on which the multicast group is joined. Frames are sent on all
+	// of them unless SendOn selects some, and InboundFrames reports where each frame arrived.
+	// Takes precedence over Interface.
+	Interfaces []*net.Interface
 	// Specifies if Multicast Loopback should be enabled.
 	MulticastLoopbackEnabled bool
 	// Pause duration after sending. 0 means disabled.
 	// According to the specification, we may choose to always pause for 20 ms // after transmitting,
 	// bu we should always pause for at least 5 ms on a multicast address.
 	PostSendPauseDuration time.Duration
+	// Determines how outbound telegrams are queued and paced.
+	Scheduler SchedulerConfig
+	// Number of received frames that are buffered until they are read from Inbound. Frames that are
+	// received while the queue is full are dropped, which is announced to the other routers. Make
+	// sure to consume Inbound, even if you only intend to send.
+	InboundQueueSize int
+	// Number of buffered frames at which the other routers are asked to pause sending.
+	BusyThreshold int
+	// Wait time that is announced to the other routers when asking them to pause. The specification
+	// suggests 20 to 100 ms. It also limits how often such requests are sent.
+	BusyWaitTime time.Duration
+	// Hooks are notified about frames, packets and flow control indications.
+	Hooks Hooks
 }
 
 // DefaultRouterConfig is a good default configuration for a Router client.
@@ -37,6 +55,10 @@ var DefaultRouterConfig = RouterConfig{
 	RetainCount:              32,
 	MulticastLoopbackEnabled: false,
 	PostSendPauseDuration:    20 * time.Millisecond,
+	Scheduler:                DefaultSchedulerConfig,
+	InboundQueueSize:         64,
+	BusyThreshold:            48,
+	BusyWaitTime:             100 * time.Millisecond,
 }
 
 // checkRouterConfig validates the given RouterConfig.
@@ -45,18 +67,61 @@ func checkRouterConfig(config RouterConfig) RouterConfig {
 		config.RetainCount = DefaultRouterConfig.RetainCount
 	}
 
+	config.Scheduler = checkSchedulerConfig(config.Scheduler)
+
+	if config.InboundQueueSize <= 0 {
+		config.InboundQueueSize = DefaultRouterConfig.InboundQueueSize
+	}
+
+	if config.BusyThreshold <= 0 || config.BusyThreshold > config.InboundQueueSize {
+		config.BusyThreshold = config.InboundQueueSize * 3 / 4
+	}
+
+	if config.BusyWaitTime <= 0 {
+		config.BusyWaitTime = DefaultRouterConfig.BusyWaitTime
+	}
+
 	return config
 }
 
+// A RoutedFrame is a frame together with the interface on which the Router received it. The
+// interface is only known if the Router has been configured with Interfaces.
+type RoutedFrame struct {
+	Message   cemi.Message
+	Interface *net.Interface
+}
+
+// A targetedMessage is a message which is only sent on the given interfaces.
+type targetedMessage struct {
+	cemi.Message
+	ifis []*net.Interface
+}
+
+// An interfaceSocket is a socket which can send on selected interfaces.
+type interfaceSocket interface {
+	SendOn(payload knxnet.ServicePackable, ifis ...*net.Interface) error
+}
+
+var errNoInterfaceSelection = errors.New("socket cannot send on selected interfaces")
+
 // A Router provides the means to communicate with KNXnet/IP routers in a IP multicast group.
-// It supports sending and receiving CEMI-encoded frames, aswell as basic flow control.
+// It supports sending and receiving CEMI-encoded frames, aswell as the flow control that the
+// KNXnet/IP routing specification demands.
 type Router struct {
 	sock          knxnet.Socket
 	config        RouterConfig
+	frames        chan RoutedFrame
 	inbound       chan cemi.Message
+	inboundOnce   sync.Once
+	consumer      int32
+	sched         *scheduler
+	flow          flowControl
+	state         stateWatcher
 	sendMu        sync.Mutex
 	retainer      *list.List
 	postSendPause time.Duration
+	done          chan struct{}
+	once          sync.Once
 }
 
 // sendMultiple sends each message from the slice. Doesn't matter if one fails, all will be tried.
@@ -87,57 +152,106 @@ func (router *Router) resendLost(count uint16) {
 	go router.sendMultiple(messages)
 }
 
-// pushInbound sends the message through the inbound channel. If the sending blocks, it will launch
-// a goroutine which will do the sending.
-func (router *Router) pushInbound(msg cemi.Message) {
+// sendFlowControl transmits a flow control indication. These are exempt from pausing.
+func (router *Router) sendFlowControl(msg knxnet.ServicePackable) {
+	if err := router.sock.Send(msg); err != nil {
+		util.Log(router, "Failed to send flow control indication: %v", err)
+	}
+}
+
+// flushLost announces the frames which have been dropped but not announced yet. Nothing is announced
+// once the Router has been closed.
+func (router *Router) flushLost() {
 	select {
-	case router.inbound <- msg:
+	case <-router.done:
+		return
 
 	default:
-		go func() {
-			// Since this goroutine decouples from the server goroutine, it might try to send when
-			// the server closed the inbound channel. Sending to a closed channel will panic. But we
-			// don't care, because cool guys don't look at explosions.
-			defer func() { recover() }()
-			router.inbound <- msg
-		}()
+	}
+
+	if lost := router.flow.flushLost(time.Now()); lost != nil {
+		router.sendFlowControl(lost)
+	}
+}
+
+// pushInbound queues the frame for the client. When the queue fills up, the other routers are
+// asked to pause. When it is full, the frame is dropped and the loss is announced. As long as no
+// client has attached through Inbound or InboundFrames, nobody is slowed down by the queue, so
+// frames that do not fit are dropped without signalling anything.
+func (router *Router) pushInbound(frame RoutedFrame) {
+	if atomic.LoadInt32(&router.consumer) == consumerNone {
+		select {
+		case router.frames <- frame:
+		default:
+		}
+
+		return
+	}
+
+	select {
+	case router.frames <- frame:
+		if len(router.frames) < router.config.BusyThreshold {
+			return
+		}
+
+	default:
+		lost, flush := router.flow.dropped(time.Now())
+		if lost != nil {
+			router.sendFlowControl(lost)
+		} else if flush {
+			time.AfterFunc(router.config.BusyWaitTime, router.flushLost)
+		}
+	}
+
+	if busy := router.flow.congested(time.Now(), router.config.BusyWaitTime); busy != nil {
+		router.sendFlowControl(busy)
 	}
 }
 
-const maxWaitTime = 50 * time.Millisecond
+// waitForPause blocks while sending is paused because another router is busy.
+func (router *Router) waitForPause() error {
+	for {
+		pause := router.flow.pause(time.Now())
+		if pause <= 0 {
+			return nil
+		}
+
+		select {
+		case <-router.done:
+			return errors.New("router has been closed")
+
+		case <-time.After(pause):
+		}
+	}
+}
 
 // serve listens for incoming routing-related packets.
 func (router *Router) serve() {
 	util.Log(router, "Started worker")
 	defer util.Log(router, "Worker exited")
 
-	defer close(router.inbound)
+	defer router.state.set(StateClosed, 0, nil)
+	defer close(router.frames)
 
 	for msg := range router.sock.Inbound() {
+		var ifi *net.Interface
+
+		if received, ok := msg.(*knxnet.ReceivedService); ok {
+			msg, ifi = received.Payload, received.Interface
+		}
+
 		switch msg := msg.(type) {
 		case *knxnet.RoutingInd:
-			// Try to push it to the client without blocking this goroutine too long.
-			router.pushInbound(msg.Payload)
+			router.config.Hooks.frameIn(msg.Payload)
+			router.pushInbound(RoutedFrame{msg.Payload, ifi})
 
 		case *knxnet.RoutingBusy:
-			var trandom time.Duration
-			// If Control is 0, we should add a specified random amount of time
-			// to the WaitTime. Otherwise, it is not specified, we just wait WaitTime.
-			if msg.Control == 0 {
-				trandom = time.Duration(rand.Float64()*50) * time.Millisecond
-			}
-
-			// Inhibit sending for the given time.
-			router.sendMu.Lock()
-
-			waitTime := msg.WaitTime + trandom
-			if waitTime > maxWaitTime {
-				waitTime = maxWaitTime
-			}
-
-			time.AfterFunc(waitTime, router.sendMu.Unlock)
+			router.config.Hooks.routingBusy(msg)
+			router.flow.busy(msg, time.Now(), rand.Float64())
 
 		case *knxnet.RoutingLost:
+			router.config.Hooks.routingLost(msg)
+
 			// Resend the last msg.Count messages.
 			router.resendLost(msg.Count)
 		}
@@ -149,31 +263,116 @@ func (router *Router) serve() {
 func NewRouter(multicastAddress string, config RouterConfig) (*Router, error) {
 	config = checkRouterConfig(config)
 
+	if len(config.Interfaces) > 0 {
+		sock, err := knxnet.ListenRouterOnInterfaces(
+			config.Interfaces, multicastAddress, config.MulticastLoopbackEnabled,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		return newRouter(sock, config), nil
+	}
+
 	sock, err := knxnet.ListenRouterOnInterface(config.Interface, multicastAddress, config.MulticastLoopbackEnabled)
 	if err != nil {
 		return nil, err
 	}
 
+	return newRouter(sock, config), nil
+}
+
+// NewUnicastRouter creates a new Router which exchanges routing packets with the peers given by
+// unicast over unicast UDP, instead of joining a multicast group. The socket is bound to the local
+// address, e.g. ":3671", or "10.8.0.5:3671" to share the port with a multicast Router. The
+// Interface, Interfaces and MulticastLoopbackEnabled settings of config have no effect.
+func NewUnicastRouter(
+	localAddress string,
+	unicast knxnet.UnicastRouterConfig,
+	config RouterConfig,
+) (*Router, error) {
+	config = checkRouterConfig(config)
+
+	sock, err := knxnet.ListenUnicastRouter(localAddress, unicast)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRouter(sock, config), nil
+}
+
+// newRouter creates a Router that uses the given socket. The config must have been checked.
+func newRouter(sock knxnet.Socket, config RouterConfig) *Router {
 	r := &Router{
-		sock:          sock,
+		sock:          config.Hooks.socket(sock),
 		config:        config,
-		inbound:       make(chan cemi.Message),
+		frames:        make(chan RoutedFrame, config.InboundQueueSize),
+		flow:          flowControl{interval: config.BusyWaitTime},
 		retainer:      list.New(),
 		postSendPause: config.PostSendPauseDuration,
+		done:          make(chan struct{}),
 	}
 
+	r.sched = newScheduler(r.send, config.Scheduler)
+	r.state.set(StateConnected, 0, nil)
+
 	go r.serve()
 
-	return r, nil
+	return r
 }
 
-// Send transmits a packet.
-func (router *Router) Send(data cemi.Message) (err error) {
+// Send transmits a packet. The packet is queued according to its priority and waits for its turn,
+// which includes waiting for the rate limit. Send blocks until the packet has been sent; use Enqueue
+// to avoid waiting.
+func (router *Router) Send(data cemi.Message) error {
+	return router.Enqueue(data, nil).Wait()
+}
+
+// Enqueue queues a packet without waiting for it to be sent. Packets are sent in order of their
+// priority, which is taken from the L_Data control field. The callback, if not nil, is invoked once
+// the packet has been sent or has failed. It runs on the sending goroutine and must not block.
+func (router *Router) Enqueue(data cemi.Message, callback func(error)) *SendFuture {
+	return router.sched.enqueue(data, messagePriority(data), callback)
+}
+
+// SendOn works like Send, but transmits the packet only on the given interfaces. The Router must
+// have been configured with Interfaces.
+func (router *Router) SendOn(data cemi.Message, ifis ...*net.Interface) error {
+	if len(ifis) == 0 {
+		return router.Send(data)
+	}
+
+	return router.sched.enqueue(
+		&targetedMessage{data, ifis}, messagePriority(data), nil,
+	).Wait()
+}
+
+// outboundFrame wraps frames as L_Data.ind, because routers distribute them to each other.
+func (router *Router) outboundFrame(ldata cemi.LData) cemi.Message {
+	return &cemi.LDataInd{LData: ldata}
+}
+
+// QueueDepth returns the number of packets that are waiting to be sent.
+func (router *Router) QueueDepth() int {
+	return router.sched.queueDepth()
+}
+
+// send transmits a packet.
+func (router *Router) send(data cemi.Message) (err error) {
 	if data == nil {
 		return errors.New("nil-pointers are not sendable")
 	}
 
-	// We lock this before doing any sending so the server goroutine can adjust the flow control.
+	if err := router.waitForPause(); err != nil {
+		return err
+	}
+
+	msg, ifis := data, []*net.Interface(nil)
+	if targeted, ok := data.(*targetedMessage); ok {
+		msg, ifis = targeted.Message, targeted.ifis
+	}
+
+	// The lock protects the retainer and is held a while longer to pause after sending.
 	router.sendMu.Lock()
 
 	defer func() {
@@ -187,10 +386,22 @@ func (router *Router) Send(data cemi.Message) (err error) {
 		}()
 	}()
 
-	err = router.sock.Send(&knxnet.RoutingInd{Payload: data})
+	if len(ifis) == 0 {
+		err = router.sock.Send(&knxnet.RoutingInd{Payload: msg})
+	} else if sock, ok := router.sock.(interfaceSocket); ok {
+		err = sock.SendOn(&knxnet.RoutingInd{Payload: msg}, ifis...)
+	} else {
+		err = errNoInterfaceSelection
+	}
 
-	if err == nil {
-		// Store this for potential resending.
+	router.config.Hooks.frameOut(msg, err)
+
+	if err != nil {
+		router.flow.setState(knxnet.DeviceStateIPError)
+	} else {
+		router.flow.setState(knxnet.DeviceStateOk)
+
+		// Store this for potential resending. Targeted messages are resent on the same interfaces.
 		// TODO: Ensure that the retained value is independent from the parameter, i.e. not modified
 		//       when the user changes a member of data.
 		router.retainer.PushBack(data)
@@ -204,15 +415,97 @@ func (router *Router) Send(data cemi.Message) (err error) {
 	return err
 }
 
+// These are the kinds of consumers that can attach to the inbound frames of a Router.
+const (
+	consumerNone int32 = iota
+	consumerMessages
+	consumerFrames
+)
+
+// attach records which kind of consumer reads the inbound frames. Only one kind may, because every
+// frame is delivered once.
+func (router *Router) attach(kind int32) {
+	if !atomic.CompareAndSwapInt32(&router.consumer, consumerNone, kind) &&
+		atomic.LoadInt32(&router.consumer) != kind {
+		panic("knx: Inbound and InboundFrames cannot both be used on the same Router")
+	}
+}
+
 // Inbound returns the channel which transmits incoming data. The channel will be closed when the
 // underlying Socket closes its inbound channel (which happens on read errors or upon closing it).
+// Inbound and InboundFrames share the same frames, so only one of them may be used; calling the
+// other one afterwards panics. GroupRouter uses Inbound.
 func (router *Router) Inbound() <-chan cemi.Message {
+	router.attach(consumerMessages)
+
+	router.inboundOnce.Do(func() {
+		router.inbound = make(chan cemi.Message)
+
+		go func() {
+			defer close(router.inbound)
+
+			for frame := range router.frames {
+				select {
+				case router.inbound <- frame.Message:
+
+				// Nobody might be reading anymore.
+				case <-router.done:
+					return
+				}
+			}
+		}()
+	})
+
 	return router.inbound
 }
 
-// Close closes the underlying socket and terminates the Router thereby.
+// InboundFrames returns the channel which transmits incoming frames along with the interface on
+// which they have been received. It is closed like the channel returned by Inbound. It panics if
+// Inbound has been used.
+func (router *Router) InboundFrames() <-chan RoutedFrame {
+	router.attach(consumerFrames)
+
+	return router.frames
+}
+
+// LocalAddr returns the local address of the underlying socket.
+func (router *Router) LocalAddr() net.Addr {
+	return router.sock.LocalAddr()
+}
+
+// State returns the current state of the Router. It is StateConnected until the socket has been
+// closed.
+func (router *Router) State() ConnState {
+	return router.state.get()
+}
+
+// WatchState returns a channel which receives an event when the state of the Router changes. The
+// channel is closed once the Router has been closed or the returned stop function has been called.
+func (router *Router) WatchState() (<-chan ConnEvent, func()) {
+	return router.state.watch()
+}
+
+// DeviceState returns the state that the Router announces to other routers.
+func (router *Router) DeviceState() knxnet.DeviceState {
+	return router.flow.deviceState()
+}
+
+// FlowState returns the current flow control state.
+func (router *Router) FlowState() RouterFlowState {
+	state := router.flow.snapshot(time.Now())
+	state.Queued = len(router.frames)
+
+	return state
+}
+
+// Close closes the underlying socket and terminates the Router thereby. Packets that are still
+// queued will fail.
 func (router *Router) Close() {
-	router.sock.Close()
+	router.once.Do(func() {
+		close(router.done)
+		router.sock.Close()
+		router.sched.close()
+	})
 }
 
 // GroupRouter is a Router that provides only a group communication interface.
@@ -227,15 +520,27 @@ func NewGroupRouter(multicastAddress string, config RouterConfig) (gr GroupRoute
 
 	if err == nil {
 		gr.inbound = make(chan GroupEvent)
-		go serveGroupInbound(gr.Router.Inbound(), gr.inbound)
+		go serveGroupInbound(gr.Router.Inbound(), gr.inbound, nil)
 	}
 
 	return
 }
 
-// Send a group communication.
+// Send a group communication with low priority, which group communications have always had. It
+// waits behind queued telegrams of higher priority and for the rate limit, and blocks until it has
+// been sent. Use Enqueue to choose another priority or to avoid waiting.
 func (gr *GroupRouter) Send(event GroupEvent) error {
-	return gr.Router.Send(&cemi.LDataInd{LData: buildGroupOutbound(event)})
+	return gr.Router.Send(&cemi.LDataInd{LData: buildGroupOutbound(event, cemi.PrioLow)})
+}
+
+// Enqueue queues a group communication with the given priority without waiting for it to be sent.
+// See Router.Enqueue for details.
+func (gr *GroupRouter) Enqueue(
+	event GroupEvent,
+	prio cemi.Priority,
+	callback func(error),
+) *SendFuture {
+	return gr.Router.Enqueue(&cemi.LDataInd{LData: buildGroupOutbound(event, prio)}, callback)
 }
 
 // Inbound returns the channel on which group communication can be received.
@@ -0,0 +1,305 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+)
+
+func TestRouter_flowControl(t *testing.T) {
+	sock, peer := newDummySockets()
+
+	router := newRouter(sock, checkRouterConfig(RouterConfig{
+		InboundQueueSize: 4,
+		BusyThreshold:    2,
+		BusyWaitTime:     time.Second,
+	}))
+	defer router.Close()
+
+	// The client attaches but does not read the inbound queue, so it fills up.
+	router.InboundFrames()
+
+	for i := 0; i < 6; i++ {
+		peer.sendAny(&knxnet.RoutingInd{Payload: makePrioMessage(cemi.PrioLow, uint16(i))})
+	}
+
+	if busy, ok := (<-peer.Inbound()).(*knxnet.RoutingBusy); !ok || busy.WaitTime != time.Second {
+		t.Errorf("Expected a busy indication, got %+v", busy)
+	}
+
+	if lost, ok := (<-peer.Inbound()).(*knxnet.RoutingLost); !ok || lost.Count != 1 {
+		t.Errorf("Expected a lost indication, got %+v", lost)
+	}
+
+	// The second lost frame is announced after the wait time.
+	if lost, ok := (<-peer.Inbound()).(*knxnet.RoutingLost); !ok || lost.Count != 1 {
+		t.Errorf("Expected a lost indication, got %+v", lost)
+	}
+
+	if state := router.FlowState(); state.Queued != 4 || state.Lost != 2 {
+		t.Errorf("Unexpected flow state: %+v", state)
+	}
+}
+
+func TestRouter_sendOnly(t *testing.T) {
+	sock, peer := newDummySockets()
+
+	router := newRouter(sock, checkRouterConfig(RouterConfig{
+		InboundQueueSize: 4,
+		BusyThreshold:    2,
+		BusyWaitTime:     time.Second,
+	}))
+	defer router.Close()
+
+	// Nobody has attached to the inbound queue, so the frames that overflow it go unannounced.
+	for i := 0; i < 6; i++ {
+		peer.sendAny(&knxnet.RoutingInd{Payload: makePrioMessage(cemi.PrioLow, uint16(i))})
+	}
+
+	// The busy indication is processed after all frames.
+	peer.sendAny(&knxnet.RoutingBusy{WaitTime: time.Millisecond, Control: 1})
+
+	for router.FlowState().PausedUntil.IsZero() {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := router.Send(makePrioMessage(cemi.PrioLow, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if msg := <-peer.Inbound(); msg.Service() != knxnet.RoutingIndService {
+		t.Errorf("Expected a routing indication, got %+v", msg)
+	}
+
+	if state := router.FlowState(); state.Lost != 0 {
+		t.Errorf("Unexpected flow state: %+v", state)
+	}
+}
+
+func TestRouter_inboundExclusive(t *testing.T) {
+	sock, _ := newDummySockets()
+
+	router := newRouter(sock, checkRouterConfig(RouterConfig{}))
+	defer router.Close()
+
+	// Using the same kind again is fine.
+	if router.InboundFrames() != router.InboundFrames() {
+		t.Error("InboundFrames returned different channels")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Inbound did not panic after InboundFrames had been used")
+		}
+	}()
+
+	router.Inbound()
+}
+
+func TestRouter_pause(t *testing.T) {
+	sock, peer := newDummySockets()
+
+	router := newRouter(sock, checkRouterConfig(RouterConfig{}))
+	defer router.Close()
+
+	peer.sendAny(&knxnet.RoutingBusy{WaitTime: 100 * time.Millisecond, Control: 1})
+
+	for router.FlowState().PausedUntil.IsZero() {
+		time.Sleep(time.Millisecond)
+	}
+
+	start := time.Now()
+
+	if err := router.Send(makePrioMessage(cemi.PrioLow, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Sending was not paused: %v", elapsed)
+	}
+
+	if _, ok := (<-peer.Inbound()).(*knxnet.RoutingInd); !ok {
+		t.Error("Expected a routing indication")
+	}
+
+	if state := router.DeviceState(); state != knxnet.DeviceStateOk {
+		t.Errorf("Unexpected device state: %v", state)
+	}
+}
+
+func TestUnicastRouter(t *testing.T) {
+	hub, err := NewUnicastRouter("127.0.0.1:0", knxnet.UnicastRouterConfig{Dynamic: true}, DefaultRouterConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hub.Close()
+
+	transport, err := Dial("unicast://127.0.0.1:0?post_send_pause=0s&peer=" + hub.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+
+	spoke, ok := transport.(*Router)
+	if !ok {
+		t.Fatalf("Unexpected transport %T", transport)
+	}
+
+	receive := func(router *Router) cemi.Message {
+		select {
+		case msg := <-router.Inbound():
+			return msg
+
+		case <-time.After(5 * time.Second):
+			t.Fatal("No frame received")
+			return nil
+		}
+	}
+
+	frame := &cemi.LDataInd{LData: cemi.LData{
+		Control1:    cemi.Control1StdFrame,
+		Control2:    cemi.Control2GroupAddr | cemi.Control2Hops(6),
+		Destination: uint16(cemi.NewGroupAddr3(1, 2, 3)),
+		Data:        &cemi.AppData{Command: cemi.GroupValueWrite, Data: []byte{1}},
+	}}
+
+	if err := spoke.Send(frame); err != nil {
+		t.Fatal(err)
+	}
+
+	if ind, ok := receive(hub).(*cemi.LDataInd); !ok || ind.Destination != frame.Destination {
+		t.Error("Hub did not receive the frame")
+	}
+
+	// The spoke has registered itself with its first packet.
+	if err := hub.Send(frame); err != nil {
+		t.Fatal(err)
+	}
+
+	if ind, ok := receive(spoke).(*cemi.LDataInd); !ok || ind.Destination != frame.Destination {
+		t.Error("Spoke did not receive the frame")
+	}
+}
+
+func TestUnicastRouter_sharedPort(t *testing.T) {
+	urls := []string{
+		"unicast://127.0.0.1:36713?dynamic&post_send_pause=0s",
+		"multicast://224.0.23.12:36713?loopback&post_send_pause=0s",
+	}
+
+	frame := &cemi.LDataInd{LData: cemi.LData{
+		Control1:    cemi.Control1StdFrame,
+		Control2:    cemi.Control2GroupAddr | cemi.Control2Hops(6),
+		Destination: uint16(cemi.NewGroupAddr3(1, 2, 3)),
+		Data:        &cemi.AppData{Command: cemi.GroupValueWrite, Data: []byte{1}},
+	}}
+
+	// Both schemes can be dialed in either order.
+	for _, order := range [][]int{{0, 1}, {1, 0}} {
+		routers := make([]*Router, 2)
+
+		for _, i := range order {
+			transport, err := Dial(urls[i])
+			if err != nil {
+				t.Fatalf("%s: %v", urls[i], err)
+			}
+			defer transport.Close()
+
+			routers[i] = transport.(*Router)
+		}
+
+		unicast, multicast := routers[0], routers[1]
+
+		peer, err := Dial("unicast://127.0.0.1:0?post_send_pause=0s&peer=127.0.0.1:36713")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer peer.Close()
+
+		if err := peer.Send(frame); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case <-unicast.Inbound():
+		case <-time.After(5 * time.Second):
+			t.Fatal("Unicast router did not receive the frame")
+		}
+
+		// Multicast packets are not picked up by the unicast router.
+		if err := multicast.Send(frame); err != nil {
+			t.Skip(err)
+		}
+
+		select {
+		case msg := <-unicast.Inbound():
+			t.Errorf("Unicast router received multicast frame %v", msg)
+
+		case <-time.After(200 * time.Millisecond):
+		}
+
+		peer.Close()
+		unicast.Close()
+		multicast.Close()
+	}
+}
+
+func TestRouter_interfaces(t *testing.T) {
+	ifis, err := multicastInterfaces()
+	if err != nil || len(ifis) == 0 {
+		t.Skip("No multicast-capable interface")
+	}
+
+	ifi := &ifis[0]
+
+	config := DefaultRouterConfig
+	config.Interfaces = []*net.Interface{ifi}
+	config.MulticastLoopbackEnabled = true
+	config.PostSendPauseDuration = 0
+
+	router, err := NewRouter("224.0.23.12:36712", config)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer router.Close()
+
+	frame := &cemi.LDataInd{LData: cemi.LData{
+		Control1:    cemi.Control1StdFrame,
+		Control2:    cemi.Control2GroupAddr | cemi.Control2Hops(6),
+		Destination: uint16(cemi.NewGroupAddr3(1, 2, 3)),
+		Data:        &cemi.AppData{Command: cemi.GroupValueWrite, Data: []byte{1}},
+	}}
+
+	if err := router.SendOn(frame, ifi); err != nil {
+		t.Skip(err)
+	}
+
+	select {
+	case received := <-router.InboundFrames():
+		if ind, ok := received.Message.(*cemi.LDataInd); !ok || ind.Destination != frame.Destination {
+			t.Errorf("Unexpected frame: %v", received.Message)
+		}
+
+		if received.Interface == nil || received.Interface.Index != ifi.Index {
+			t.Errorf("Frame was attributed to %v instead of %v", received.Interface, ifi)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Skip("Multicast loopback is not available")
+	}
+
+	unicast, err := NewUnicastRouter("127.0.0.1:0", knxnet.UnicastRouterConfig{Dynamic: true}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unicast.Close()
+
+	if err := unicast.SendOn(frame, ifi); err != errNoInterfaceSelection {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
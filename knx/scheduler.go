@@ -0,0 +1,298 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// A SchedulerConfig determines how outbound telegrams are queued and paced.
+type SchedulerConfig struct {
+	// Rate is the number of telegrams per second that may be sent. A negative rate disables rate
+	// limiting. A TP1 line can carry roughly 40 to 50 telegrams per second, so keep this well below.
+	Rate float64
+
+	// Burst is the number of telegrams that may be sent in quick succession, before Rate applies.
+	Burst int
+
+	// QueueSize limits the number of telegrams that may be waiting to be sent.
+	QueueSize int
+}
+
+// DefaultSchedulerConfig sends up to 20 telegrams per second, which leaves room for other devices on
+// a TP1 line, and queues up to 1024 telegrams.
+var DefaultSchedulerConfig = SchedulerConfig{
+	Rate:      20,
+	Burst:     10,
+	QueueSize: 1024,
+}
+
+// checkSchedulerConfig makes sure that the configuration is actually usable.
+func checkSchedulerConfig(config SchedulerConfig) SchedulerConfig {
+	if config.Rate == 0 {
+		config.Rate = DefaultSchedulerConfig.Rate
+	}
+
+	if config.Burst <= 0 {
+		config.Burst = DefaultSchedulerConfig.Burst
+	}
+
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultSchedulerConfig.QueueSize
+	}
+
+	return config
+}
+
+var (
+	errQueueFull       = errors.New("outbound queue is full")
+	errSchedulerClosed = errors.New("outbound queue has been closed")
+)
+
+// A SendFuture is the eventual outcome of an enqueued transmission.
+type SendFuture struct {
+	done     chan struct{}
+	err      error
+	callback func(error)
+}
+
+// newSendFuture creates a future which invokes the callback, if any, upon completion.
+func newSendFuture(callback func(error)) *SendFuture {
+	return &SendFuture{done: make(chan struct{}), callback: callback}
+}
+
+// failedSendFuture creates a future that has already failed.
+func failedSendFuture(err error, callback func(error)) *SendFuture {
+	future := newSendFuture(callback)
+	future.complete(err)
+	return future
+}
+
+// complete resolves the future.
+func (future *SendFuture) complete(err error) {
+	future.err = err
+	close(future.done)
+
+	if future.callback != nil {
+		future.callback(err)
+	}
+}
+
+// Done returns a channel which is closed once the transmission has completed.
+func (future *SendFuture) Done() <-chan struct{} {
+	return future.done
+}
+
+// Wait blocks until the transmission has completed and returns its error.
+func (future *SendFuture) Wait() error {
+	<-future.done
+	return future.err
+}
+
+// priorityOrder lists the priorities in the order in which they are served.
+var priorityOrder = [...]cemi.Priority{cemi.PrioSystem, cemi.PrioUrgent, cemi.PrioNormal, cemi.PrioLow}
+
+// messagePriority determines the priority of a message. Messages without a priority of their own
+// are treated as normal priority. Messages sent on selected interfaces keep their priority when they
+// are resent.
+func messagePriority(msg cemi.Message) cemi.Priority {
+	switch msg := msg.(type) {
+	case *targetedMessage:
+		return messagePriority(msg.Message)
+
+	case *cemi.LDataReq:
+		return msg.Control1.Priority()
+
+	case *cemi.LDataInd:
+		return msg.Control1.Priority()
+
+	case *cemi.LDataCon:
+		return msg.Control1.Priority()
+	}
+
+	return cemi.PrioNormal
+}
+
+// outboundJob is a queued transmission.
+type outboundJob struct {
+	msg    cemi.Message
+	future *SendFuture
+}
+
+// A scheduler queues outbound messages by priority and sends them one at a time, no faster than the
+// configured rate.
+type scheduler struct {
+	send   func(cemi.Message) error
+	config SchedulerConfig
+
+	mu     sync.Mutex
+	queues [4][]outboundJob
+	depth  int
+	closed bool
+	wake   chan struct{}
+
+	done chan struct{}
+	wait sync.WaitGroup
+}
+
+// newScheduler creates a scheduler which transmits messages using the given function.
+func newScheduler(send func(cemi.Message) error, config SchedulerConfig) *scheduler {
+	sched := &scheduler{
+		send:   send,
+		config: checkSchedulerConfig(config),
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	sched.wait.Add(1)
+	go sched.serve()
+
+	return sched
+}
+
+// enqueue adds the message to the queue matching the given priority.
+func (sched *scheduler) enqueue(
+	msg cemi.Message,
+	prio cemi.Priority,
+	callback func(error),
+) *SendFuture {
+	sched.mu.Lock()
+
+	// The callback must not be invoked while holding the lock, it might enqueue something.
+	if sched.closed {
+		sched.mu.Unlock()
+		return failedSendFuture(errSchedulerClosed, callback)
+	}
+
+	if sched.depth >= sched.config.QueueSize {
+		sched.mu.Unlock()
+		return failedSendFuture(errQueueFull, callback)
+	}
+
+	defer sched.mu.Unlock()
+
+	future := newSendFuture(callback)
+
+	sched.queues[prio&3] = append(sched.queues[prio&3], outboundJob{msg, future})
+	sched.depth++
+
+	select {
+	case sched.wake <- struct{}{}:
+	default:
+	}
+
+	return future
+}
+
+// pending determines whether there is anything to send.
+func (sched *scheduler) pending() bool {
+	sched.mu.Lock()
+	defer sched.mu.Unlock()
+
+	return sched.depth > 0
+}
+
+// next removes the job with the highest priority from the queues.
+func (sched *scheduler) next() (outboundJob, bool) {
+	sched.mu.Lock()
+	defer sched.mu.Unlock()
+
+	for _, prio := range priorityOrder {
+		if queue := sched.queues[prio]; len(queue) > 0 {
+			job := queue[0]
+			queue[0] = outboundJob{}
+			sched.queues[prio] = queue[1:]
+			sched.depth--
+
+			return job, true
+		}
+	}
+
+	return outboundJob{}, false
+}
+
+// queueDepth returns the number of queued messages.
+func (sched *scheduler) queueDepth() int {
+	sched.mu.Lock()
+	defer sched.mu.Unlock()
+
+	return sched.depth
+}
+
+// serve sends the queued messages.
+func (sched *scheduler) serve() {
+	defer sched.wait.Done()
+
+	tokens := float64(sched.config.Burst)
+	last := time.Now()
+
+	for {
+		// Wait for something to send.
+		for !sched.pending() {
+			select {
+			case <-sched.done:
+				return
+
+			case <-sched.wake:
+			}
+		}
+
+		// Wait until the rate limit permits sending. A message with a higher priority might arrive
+		// in the meantime, which is why the job is only picked afterwards.
+		if sched.config.Rate > 0 {
+			now := time.Now()
+			tokens += now.Sub(last).Seconds() * sched.config.Rate
+			last = now
+
+			if burst := float64(sched.config.Burst); tokens > burst {
+				tokens = burst
+			}
+
+			if tokens < 1 {
+				delay := time.Duration((1 - tokens) / sched.config.Rate * float64(time.Second))
+
+				select {
+				case <-sched.done:
+					return
+
+				case <-time.After(delay):
+				}
+
+				continue
+			}
+
+			tokens--
+		}
+
+		if job, ok := sched.next(); ok {
+			job.future.complete(sched.send(job.msg))
+		}
+	}
+}
+
+// close stops the scheduler. Messages that are still queued fail.
+func (sched *scheduler) close() {
+	sched.mu.Lock()
+	if sched.closed {
+		sched.mu.Unlock()
+		return
+	}
+	sched.closed = true
+	sched.mu.Unlock()
+
+	close(sched.done)
+	sched.wait.Wait()
+
+	for {
+		job, ok := sched.next()
+		if !ok {
+			break
+		}
+
+		job.future.complete(errSchedulerClosed)
+	}
+}
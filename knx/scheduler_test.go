@@ -0,0 +1,144 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+func makePrioMessage(prio cemi.Priority, dest uint16) cemi.Message {
+	return &cemi.LDataReq{LData: cemi.LData{
+		Control1:    cemi.Control1Prio(prio),
+		Destination: dest,
+		Data:        &cemi.AppData{},
+	}}
+}
+
+func TestScheduler_priority(t *testing.T) {
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	sent := make(chan uint16, 10)
+
+	sched := newScheduler(func(msg cemi.Message) error {
+		started <- struct{}{}
+		<-release
+		sent <- msg.(*cemi.LDataReq).Destination
+		return nil
+	}, SchedulerConfig{Rate: -1})
+	defer sched.close()
+
+	// The first message blocks the scheduler, so that the others queue up.
+	sched.enqueue(makePrioMessage(cemi.PrioLow, 0), cemi.PrioLow, nil)
+	<-started
+
+	prios := []cemi.Priority{cemi.PrioLow, cemi.PrioNormal, cemi.PrioUrgent, cemi.PrioSystem}
+	futures := make([]*SendFuture, len(prios))
+
+	for i, prio := range prios {
+		futures[i] = sched.enqueue(makePrioMessage(prio, uint16(i+1)), prio, nil)
+	}
+
+	if depth := sched.queueDepth(); depth != len(prios) {
+		t.Errorf("Expected queue depth %d, got %d", len(prios), depth)
+	}
+
+	close(release)
+
+	expected := []uint16{0, 4, 3, 2, 1}
+	for _, dest := range expected {
+		if actual := <-sent; actual != dest {
+			t.Errorf("Expected destination %d, got %d", dest, actual)
+		}
+	}
+
+	for _, future := range futures {
+		if err := future.Wait(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestMessagePriority(t *testing.T) {
+	msg := makePrioMessage(cemi.PrioUrgent, 1)
+
+	if prio := messagePriority(msg); prio != cemi.PrioUrgent {
+		t.Errorf("Expected urgent priority, got %v", prio)
+	}
+
+	if prio := messagePriority(&targetedMessage{msg, nil}); prio != cemi.PrioUrgent {
+		t.Errorf("Expected urgent priority for a targeted message, got %v", prio)
+	}
+
+	if prio := messagePriority(&cemi.UnsupportedMessage{}); prio != cemi.PrioNormal {
+		t.Errorf("Expected normal priority, got %v", prio)
+	}
+}
+
+func TestScheduler_rate(t *testing.T) {
+	sched := newScheduler(func(cemi.Message) error {
+		return nil
+	}, SchedulerConfig{Rate: 100, Burst: 1})
+	defer sched.close()
+
+	start := time.Now()
+
+	var future *SendFuture
+	for i := 0; i < 6; i++ {
+		future = sched.enqueue(makePrioMessage(cemi.PrioLow, 0), cemi.PrioLow, nil)
+	}
+
+	if err := future.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first message passes immediately, the other 5 have to wait 10 ms each.
+	if elapsed := time.Since(start); elapsed < 45*time.Millisecond {
+		t.Errorf("Sending took only %v", elapsed)
+	}
+}
+
+func TestScheduler_queueFull(t *testing.T) {
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+
+	sched := newScheduler(func(cemi.Message) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}, SchedulerConfig{QueueSize: 1})
+
+	// The first message blocks the scheduler, so that the next one stays in the queue.
+	sched.enqueue(makePrioMessage(cemi.PrioLow, 0), cemi.PrioLow, nil)
+	<-started
+
+	queued := sched.enqueue(makePrioMessage(cemi.PrioLow, 0), cemi.PrioLow, nil)
+
+	var callbackErr error
+	full := sched.enqueue(makePrioMessage(cemi.PrioLow, 0), cemi.PrioLow, func(err error) {
+		callbackErr = err
+	})
+
+	if err := full.Wait(); err != errQueueFull {
+		t.Errorf("Expected error %v, got %v", errQueueFull, err)
+	}
+
+	if callbackErr != errQueueFull {
+		t.Errorf("Expected callback with error %v, got %v", errQueueFull, callbackErr)
+	}
+
+	close(release)
+
+	if err := queued.Wait(); err != nil {
+		t.Error(err)
+	}
+
+	sched.close()
+
+	closed := sched.enqueue(makePrioMessage(cemi.PrioLow, 0), cemi.PrioLow, nil)
+	if err := closed.Wait(); err != errSchedulerClosed {
+		t.Errorf("Expected error %v, got %v", errSchedulerClosed, err)
+	}
+}
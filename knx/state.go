@@ -0,0 +1,166 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"sync"
+	"time"
+)
+
+// ConnState describes the state of a connection to a KNXnet/IP server.
+type ConnState uint8
+
+// These are the states a connection can be in.
+const (
+	// StateConnecting indicates that the initial connection is being established.
+	StateConnecting ConnState = iota
+
+	// StateConnected indicates that the connection is usable.
+	StateConnected
+
+	// StateReconnecting indicates that the connection has been lost and is being re-established.
+	StateReconnecting
+
+	// StateClosed indicates that the connection has been terminated for good.
+	StateClosed
+)
+
+// String generates a string representation of the state.
+func (state ConnState) String() string {
+	switch state {
+	case StateConnecting:
+		return "Connecting"
+
+	case StateConnected:
+		return "Connected"
+
+	case StateReconnecting:
+		return "Reconnecting"
+
+	case StateClosed:
+		return "Closed"
+	}
+
+	return "Unknown"
+}
+
+// A ConnEvent is emitted when the state of a connection changes. Failed reconnection attempts also
+// emit an event, even though the state remains StateReconnecting.
+type ConnEvent struct {
+	// State is the new state of the connection.
+	State ConnState
+
+	// Previous is the state of the connection before the event occurred.
+	Previous ConnState
+
+	// Attempt is the number of the failed reconnection attempt, if the event reports one.
+	Attempt int
+
+	// Err is the reason for the event, if there is one.
+	Err error
+
+	// Time is the time at which the event occurred.
+	Time time.Time
+}
+
+// stateEventBuffer is the number of events a watcher can lag behind before old events are dropped.
+const stateEventBuffer = 16
+
+// stateWatcher keeps track of a connection state and distributes changes to its watchers.
+type stateWatcher struct {
+	mu       sync.Mutex
+	state    ConnState
+	watchers map[chan ConnEvent]struct{}
+	closed   bool
+}
+
+// get returns the current state.
+func (sw *stateWatcher) get() ConnState {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	return sw.state
+}
+
+// emit sends the event to every watcher without blocking. Slow watchers lose their oldest events
+// so that the most recent event is always delivered.
+func (sw *stateWatcher) emit(event ConnEvent) {
+	for ch := range sw.watchers {
+		select {
+		case ch <- event:
+			continue
+		default:
+		}
+
+		select {
+		case <-ch:
+		default:
+		}
+
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// set changes the state and informs the watchers. Setting StateClosed closes all watcher channels.
+func (sw *stateWatcher) set(state ConnState, attempt int, err error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	if sw.closed {
+		return
+	}
+
+	sw.emit(ConnEvent{
+		State:    state,
+		Previous: sw.state,
+		Attempt:  attempt,
+		Err:      err,
+		Time:     time.Now(),
+	})
+
+	sw.state = state
+
+	if state == StateClosed {
+		sw.closed = true
+
+		for ch := range sw.watchers {
+			close(ch)
+		}
+
+		sw.watchers = nil
+	}
+}
+
+// watch registers a new watcher. The returned function unregisters it again.
+func (sw *stateWatcher) watch() (<-chan ConnEvent, func()) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	ch := make(chan ConnEvent, stateEventBuffer)
+
+	if sw.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	if sw.watchers == nil {
+		sw.watchers = make(map[chan ConnEvent]struct{})
+	}
+
+	sw.watchers[ch] = struct{}{}
+
+	stop := func() {
+		sw.mu.Lock()
+		defer sw.mu.Unlock()
+
+		if _, ok := sw.watchers[ch]; ok {
+			delete(sw.watchers, ch)
+			close(ch)
+		}
+	}
+
+	return ch, stop
+}
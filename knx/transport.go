@@ -0,0 +1,536 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+)
+
+// A Transport exchanges CEMI frames with a KNX network. Tunnel and Router implement it, as do the
+// transports that are registered using RegisterScheme.
+type Transport interface {
+	// Send transmits a frame and blocks until the transport has handed it off.
+	Send(data cemi.Message) error
+
+	// Inbound returns the channel on which incoming frames are delivered. It is closed once the
+	// transport has been closed for good.
+	Inbound() <-chan cemi.Message
+
+	// Close terminates the transport.
+	Close()
+
+	// LocalAddr returns the local address of the transport.
+	LocalAddr() net.Addr
+
+	// State returns the current state of the transport.
+	State() ConnState
+
+	// WatchState returns a channel which receives an event whenever the state changes, and a
+	// function which stops watching.
+	WatchState() (<-chan ConnEvent, func())
+}
+
+// An outboundFramer is a transport which does not take outbound frames as L_Data.req.
+type outboundFramer interface {
+	outboundFrame(ldata cemi.LData) cemi.Message
+}
+
+// OutboundFrame wraps the frame in the message that the transport expects. Routers distribute
+// L_Data.ind, all other transports take L_Data.req like a gateway does.
+func OutboundFrame(transport Transport, ldata cemi.LData) cemi.Message {
+	if framer, ok := transport.(outboundFramer); ok {
+		return framer.outboundFrame(ldata)
+	}
+
+	return &cemi.LDataReq{LData: ldata}
+}
+
+// Make sure that the built-in transports satisfy the interface.
+var (
+	_ Transport = (*Tunnel)(nil)
+	_ Transport = (*Router)(nil)
+)
+
+// DefaultPort is the port used by dialed addresses that do not specify one.
+const DefaultPort = "3671"
+
+// DefaultMulticastAddress is used when dialing a multicast URL without a host.
+const DefaultMulticastAddress = "224.0.23.12:3671"
+
+// A DialConfig contains the base configuration of the transports created by DialConfigured. Query
+// parameters of the dialed URL take precedence.
+type DialConfig struct {
+	// Tunnel configures the udp and tcp schemes.
+	Tunnel TunnelConfig
+
+	// Router configures the multicast and unicast schemes.
+	Router RouterConfig
+}
+
+// A DialFunc creates a transport for the given URL. Implementations should reject query parameters
+// they do not understand.
+type DialFunc func(u *url.URL, config DialConfig) (Transport, error)
+
+var (
+	schemesMu sync.RWMutex
+	schemes   = map[string]DialFunc{
+		"udp":       dialTunnel,
+		"tcp":       dialTunnel,
+		"multicast": dialRouter,
+		"unicast":   dialUnicastRouter,
+		"bus":       dialBus,
+	}
+)
+
+// RegisterScheme makes a transport available under the given URL scheme. It panics if the scheme
+// is already registered or dial is nil.
+func RegisterScheme(scheme string, dial DialFunc) {
+	schemesMu.Lock()
+	defer schemesMu.Unlock()
+
+	scheme = strings.ToLower(scheme)
+
+	if dial == nil {
+		panic("knx: RegisterScheme dial function is nil")
+	}
+
+	if _, dup := schemes[scheme]; dup {
+		panic("knx: RegisterScheme called twice for scheme " + scheme)
+	}
+
+	schemes[scheme] = dial
+}
+
+// Schemes returns the registered URL schemes.
+func Schemes() []string {
+	schemesMu.RLock()
+	defer schemesMu.RUnlock()
+
+	list := make([]string, 0, len(schemes))
+	for scheme := range schemes {
+		list = append(list, scheme)
+	}
+
+	return list
+}
+
+// TransportURL turns an address without scheme into a URL for Dial. Multicast addresses such as
+// "224.0.23.12" are routed, all other addresses such as "10.0.0.7:3671" are tunnels over UDP.
+// URLs are returned unchanged.
+func TransportURL(address string) string {
+	if strings.Contains(address, "://") {
+		return address
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+
+	if ip := net.ParseIP(host); ip != nil && ip.IsMulticast() {
+		return "multicast://" + address
+	}
+
+	return "udp://" + address
+}
+
+// Dial creates a transport from a URL such as "udp://10.0.0.7:3671", "tcp://10.0.0.7" or
+// "multicast://224.0.23.12". The port defaults to 3671. Settings can be given as query
+// parameters, e.g. "udp://10.0.0.7?heartbeat=5s&rate=20". Addresses without scheme, which Dial
+// used to reject, are expanded by TransportURL, so "10.0.0.7:3671" dials a tunnel.
+//
+// The tunnel schemes udp and tcp understand the parameters layer (data, raw or busmon), resend,
+// heartbeat, timeout, local_address and reconnect (the maximum number of attempts). The multicast
+// scheme understands interface (which may be repeated to join several interfaces), loopback,
+// retain and post_send_pause. All of them understand the scheduler parameters rate, burst and
+// queue. The rate defaults to 20 telegrams per second; a negative rate disables the limit.
+//
+// The unicast scheme routes over unicast UDP, e.g. "unicast://:3671?peer=10.1.0.2&peer=10.2.0.2".
+// Its host is the local address to listen on, which must be a specific address if a multicast
+// transport in the same process uses the same port. Besides retain and post_send_pause it
+// understands peer (which may be repeated), dynamic, peer_timeout and reflect; see
+// UnicastRouterConfig. The bus scheme connects to an in-process NamedBus, e.g. "bus://test".
+func Dial(address string) (Transport, error) {
+	return DialConfigured(address, DialConfig{
+		Tunnel: DefaultTunnelConfig,
+		Router: DefaultRouterConfig,
+	})
+}
+
+// DialConfigured works like Dial, but uses the given configuration for the settings which are
+// not given as query parameters.
+func DialConfigured(address string, config DialConfig) (Transport, error) {
+	u, err := url.Parse(TransportURL(address))
+	if err != nil {
+		return nil, err
+	}
+
+	schemesMu.RLock()
+	dial, ok := schemes[strings.ToLower(u.Scheme)]
+	schemesMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown transport scheme %q", u.Scheme)
+	}
+
+	// A dial function may return a typed nil along with its error, which must not reach the caller
+	// as a non-nil Transport.
+	transport, err := dial(u, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return transport, nil
+}
+
+// hostPort determines the address of the URL, adding the default port if necessary.
+func hostPort(u *url.URL) (string, error) {
+	if u.Host == "" {
+		return "", errors.New("address has no host")
+	}
+
+	if u.Port() == "" {
+		return net.JoinHostPort(u.Hostname(), DefaultPort), nil
+	}
+
+	return u.Host, nil
+}
+
+// queryParser applies query parameters to a configuration and collects the first error.
+type queryParser struct {
+	query url.Values
+	err   error
+}
+
+// take removes the parameter from the query and returns its value.
+func (qp *queryParser) take(name string) (string, bool) {
+	values, ok := qp.query[name]
+	if !ok || qp.err != nil {
+		return "", false
+	}
+
+	delete(qp.query, name)
+
+	if len(values) == 0 {
+		return "", true
+	}
+
+	return values[len(values)-1], true
+}
+
+// fail records an invalid parameter.
+func (qp *queryParser) fail(name string, err error) {
+	if qp.err == nil {
+		qp.err = fmt.Errorf("invalid parameter %s: %w", name, err)
+	}
+}
+
+func (qp *queryParser) duration(name string, output *time.Duration) {
+	if value, ok := qp.take(name); ok {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			qp.fail(name, err)
+		}
+
+		*output = d
+	}
+}
+
+func (qp *queryParser) boolean(name string, output *bool) {
+	if value, ok := qp.take(name); ok {
+		if value == "" {
+			*output = true
+			return
+		}
+
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			qp.fail(name, err)
+		}
+
+		*output = b
+	}
+}
+
+func (qp *queryParser) integer(name string, output *int) {
+	if value, ok := qp.take(name); ok {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			qp.fail(name, err)
+		}
+
+		*output = i
+	}
+}
+
+func (qp *queryParser) float(name string, output *float64) {
+	if value, ok := qp.take(name); ok {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			qp.fail(name, err)
+		}
+
+		*output = f
+	}
+}
+
+// list removes the parameter from the query and returns all of its values.
+func (qp *queryParser) list(name string) []string {
+	values := qp.query[name]
+	delete(qp.query, name)
+
+	return values
+}
+
+func (qp *queryParser) scheduler(config *SchedulerConfig) {
+	qp.float("rate", &config.Rate)
+	qp.integer("burst", &config.Burst)
+	qp.integer("queue", &config.QueueSize)
+}
+
+// finish returns the first error, or complains about a parameter that has not been used.
+func (qp *queryParser) finish() error {
+	if qp.err != nil {
+		return qp.err
+	}
+
+	for name := range qp.query {
+		return fmt.Errorf("unknown parameter %s", name)
+	}
+
+	return nil
+}
+
+// parseTunnelURL determines the gateway address, layer and configuration given by the URL.
+func parseTunnelURL(
+	u *url.URL,
+	config TunnelConfig,
+) (string, knxnet.TunnelLayer, TunnelConfig, error) {
+	address, err := hostPort(u)
+	if err != nil {
+		return "", 0, config, err
+	}
+
+	config.UseTCP = strings.EqualFold(u.Scheme, "tcp")
+	layer := knxnet.TunnelLayerData
+
+	qp := queryParser{query: u.Query()}
+
+	if value, ok := qp.take("layer"); ok {
+		switch value {
+		case "data":
+			layer = knxnet.TunnelLayerData
+
+		case "raw":
+			layer = knxnet.TunnelLayerRaw
+
+		case "busmon":
+			layer = knxnet.TunnelLayerBusmon
+
+		default:
+			qp.fail("layer", fmt.Errorf("unknown layer %q", value))
+		}
+	}
+
+	qp.duration("resend", &config.ResendInterval)
+	qp.duration("heartbeat", &config.HeartbeatInterval)
+	qp.duration("timeout", &config.ResponseTimeout)
+	qp.boolean("local_address", &config.SendLocalAddress)
+	qp.integer("reconnect", &config.Reconnect.MaxAttempts)
+	qp.scheduler(&config.Scheduler)
+
+	return address, layer, config, qp.finish()
+}
+
+// dialTunnel implements the udp and tcp schemes.
+func dialTunnel(u *url.URL, config DialConfig) (Transport, error) {
+	address, layer, tunnelConfig, err := parseTunnelURL(u, config.Tunnel)
+	if err != nil {
+		return nil, err
+	}
+
+	tunnel, err := NewTunnel(address, layer, tunnelConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return tunnel, nil
+}
+
+// parseRouterURL determines the multicast address and configuration given by the URL.
+func parseRouterURL(u *url.URL, config RouterConfig) (string, RouterConfig, error) {
+	address := DefaultMulticastAddress
+
+	if u.Host != "" {
+		var err error
+		if address, err = hostPort(u); err != nil {
+			return "", config, err
+		}
+	}
+
+	qp := queryParser{query: u.Query()}
+
+	// The interface may be repeated to join the group on several interfaces.
+	var ifis []*net.Interface
+
+	for _, name := range qp.list("interface") {
+		ifi, err := net.InterfaceByName(name)
+		if err != nil {
+			qp.fail("interface", err)
+		}
+
+		ifis = append(ifis, ifi)
+	}
+
+	if len(ifis) == 1 {
+		config.Interface = ifis[0]
+	} else if len(ifis) > 1 {
+		config.Interfaces = ifis
+	}
+
+	if value, ok := qp.take("retain"); ok {
+		retain, err := strconv.ParseUint(value, 10, 0)
+		if err != nil {
+			qp.fail("retain", err)
+		}
+
+		config.RetainCount = uint(retain)
+	}
+
+	qp.boolean("loopback", &config.MulticastLoopbackEnabled)
+	qp.duration("post_send_pause", &config.PostSendPauseDuration)
+	qp.scheduler(&config.Scheduler)
+
+	return address, config, qp.finish()
+}
+
+// dialRouter implements the multicast scheme.
+func dialRouter(u *url.URL, config DialConfig) (Transport, error) {
+	address, routerConfig, err := parseRouterURL(u, config.Router)
+	if err != nil {
+		return nil, err
+	}
+
+	router, err := NewRouter(address, routerConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return router, nil
+}
+
+// parseUnicastRouterURL determines the local address, peers and configuration given by the URL.
+func parseUnicastRouterURL(
+	u *url.URL,
+	config RouterConfig,
+) (string, knxnet.UnicastRouterConfig, RouterConfig, error) {
+	var unicast knxnet.UnicastRouterConfig
+
+	address, err := hostPort(u)
+	if err != nil {
+		return "", unicast, config, err
+	}
+
+	qp := queryParser{query: u.Query()}
+
+	for _, peer := range qp.list("peer") {
+		if _, _, err := net.SplitHostPort(peer); err != nil {
+			peer = net.JoinHostPort(peer, DefaultPort)
+		}
+
+		unicast.Peers = append(unicast.Peers, peer)
+	}
+
+	if value, ok := qp.take("retain"); ok {
+		retain, err := strconv.ParseUint(value, 10, 0)
+		if err != nil {
+			qp.fail("retain", err)
+		}
+
+		config.RetainCount = uint(retain)
+	}
+
+	qp.boolean("dynamic", &unicast.Dynamic)
+	qp.duration("peer_timeout", &unicast.PeerTimeout)
+	qp.boolean("reflect", &unicast.Reflect)
+	qp.duration("post_send_pause", &config.PostSendPauseDuration)
+	qp.scheduler(&config.Scheduler)
+
+	return address, unicast, config, qp.finish()
+}
+
+// dialUnicastRouter implements the unicast scheme.
+func dialUnicastRouter(u *url.URL, config DialConfig) (Transport, error) {
+	address, unicast, routerConfig, err := parseUnicastRouterURL(u, config.Router)
+	if err != nil {
+		return nil, err
+	}
+
+	router, err := NewUnicastRouter(address, unicast, routerConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return router, nil
+}
+
+// GroupTransport provides group communication over any Transport.
+type GroupTransport struct {
+	Transport
+	inbound chan GroupEvent
+	confs   *confirmations
+}
+
+var _ GroupConfirmer = (*GroupTransport)(nil)
+
+// NewGroupTransport creates a group client for the transport. Group communication is wrapped as
+// determined by OutboundFrame.
+func NewGroupTransport(transport Transport) *GroupTransport {
+	gt := &GroupTransport{Transport: transport, inbound: make(chan GroupEvent), confs: &confirmations{}}
+	go serveGroupInbound(transport.Inbound(), gt.inbound, gt.confs)
+
+	return gt
+}
+
+// DialGroup works like Dial, but returns a group client.
+func DialGroup(address string) (*GroupTransport, error) {
+	transport, err := Dial(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewGroupTransport(transport), nil
+}
+
+// Send a group communication with low priority, which group communications have always had. It
+// blocks as long as the Send method of the transport does.
+func (gt *GroupTransport) Send(event GroupEvent) error {
+	return gt.Transport.Send(OutboundFrame(gt.Transport, buildGroupOutbound(event, cemi.PrioLow)))
+}
+
+// SendConfirmed sends a group communication and waits until the gateway has confirmed its
+// transmission on the bus. Only tunnels confirm frames, over other transports it is the same as
+// Send.
+func (gt *GroupTransport) SendConfirmed(ctx context.Context, event GroupEvent) error {
+	if _, ok := gt.Transport.(*Tunnel); !ok {
+		return gt.Send(event)
+	}
+
+	return gt.confs.send(ctx, event, gt.Send)
+}
+
+// Inbound returns the channel on which group communication can be received.
+func (gt *GroupTransport) Inbound() <-chan GroupEvent {
+	return gt.inbound
+}
@@ -0,0 +1,253 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+)
+
+func TestParseTunnelURL(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		u, _ := url.Parse("udp://10.0.0.7")
+
+		address, layer, config, err := parseTunnelURL(u, DefaultTunnelConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if address != "10.0.0.7:3671" || layer != knxnet.TunnelLayerData || config.UseTCP {
+			t.Errorf("Unexpected result: %s, %v, %+v", address, layer, config)
+		}
+	})
+
+	t.Run("Parameters", func(t *testing.T) {
+		u, _ := url.Parse("tcp://10.0.0.7:3672?layer=busmon&heartbeat=5s&local_address&rate=20")
+
+		address, layer, config, err := parseTunnelURL(u, DefaultTunnelConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if address != "10.0.0.7:3672" || layer != knxnet.TunnelLayerBusmon {
+			t.Errorf("Unexpected address or layer: %s, %v", address, layer)
+		}
+
+		if !config.UseTCP || !config.SendLocalAddress || config.HeartbeatInterval != 5*time.Second ||
+			config.Scheduler.Rate != 20 {
+			t.Errorf("Unexpected config: %+v", config)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, raw := range []string{
+			"udp://",
+			"udp://10.0.0.7?heartbeat=soon",
+			"udp://10.0.0.7?layer=link",
+			"udp://10.0.0.7?hartbeat=5s",
+		} {
+			u, _ := url.Parse(raw)
+
+			if _, _, _, err := parseTunnelURL(u, DefaultTunnelConfig); err == nil {
+				t.Errorf("%s was accepted", raw)
+			}
+		}
+	})
+}
+
+func TestParseRouterURL(t *testing.T) {
+	u, _ := url.Parse("multicast://?loopback=true&retain=8")
+
+	address, config, err := parseRouterURL(u, DefaultRouterConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if address != DefaultMulticastAddress || !config.MulticastLoopbackEnabled ||
+		config.RetainCount != 8 {
+		t.Errorf("Unexpected result: %s, %+v", address, config)
+	}
+
+	u, _ = url.Parse("multicast://224.0.23.13?retain=-1")
+	if _, _, err := parseRouterURL(u, DefaultRouterConfig); err == nil {
+		t.Error("Negative retain count was accepted")
+	}
+
+	ifis, err := net.Interfaces()
+	if err != nil || len(ifis) == 0 {
+		t.Skip("No interfaces")
+	}
+
+	name := url.QueryEscape(ifis[0].Name)
+
+	u, _ = url.Parse("multicast://?interface=" + name)
+	if _, config, err := parseRouterURL(u, DefaultRouterConfig); err != nil ||
+		config.Interface == nil || config.Interfaces != nil {
+		t.Errorf("Unexpected result for a single interface: %+v, %v", config, err)
+	}
+
+	u, _ = url.Parse("multicast://?interface=" + name + "&interface=" + name)
+	if _, config, err := parseRouterURL(u, DefaultRouterConfig); err != nil || len(config.Interfaces) != 2 {
+		t.Errorf("Unexpected result for several interfaces: %+v, %v", config, err)
+	}
+}
+
+func TestParseUnicastRouterURL(t *testing.T) {
+	u, _ := url.Parse("unicast://:3671?peer=10.1.0.2&peer=10.2.0.2:3672&dynamic&peer_timeout=5m&retain=8")
+
+	address, unicast, config, err := parseUnicastRouterURL(u, DefaultRouterConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if address != ":3671" || len(unicast.Peers) != 2 || unicast.Peers[0] != "10.1.0.2:3671" ||
+		unicast.Peers[1] != "10.2.0.2:3672" || !unicast.Dynamic || unicast.Reflect ||
+		unicast.PeerTimeout != 5*time.Minute || config.RetainCount != 8 {
+		t.Errorf("Unexpected result: %s, %+v, %+v", address, unicast, config)
+	}
+
+	for _, address := range []string{"unicast://?peer=10.1.0.2", "unicast://:3671?interface=eth0"} {
+		u, _ = url.Parse(address)
+		if _, _, _, err := parseUnicastRouterURL(u, DefaultRouterConfig); err == nil {
+			t.Errorf("%s was accepted", address)
+		}
+	}
+}
+
+type dummyTransport struct {
+	address string
+}
+
+func (dummyTransport) Send(cemi.Message) error                { return nil }
+func (dummyTransport) Inbound() <-chan cemi.Message           { return nil }
+func (dummyTransport) Close()                                 {}
+func (dummyTransport) LocalAddr() net.Addr                    { return nil }
+func (dummyTransport) State() ConnState                       { return StateConnected }
+func (dummyTransport) WatchState() (<-chan ConnEvent, func()) { return nil, func() {} }
+
+func TestTransportURL(t *testing.T) {
+	for address, expected := range map[string]string{
+		"10.0.0.7":              "udp://10.0.0.7",
+		"10.0.0.7:3671":         "udp://10.0.0.7:3671",
+		"gateway.local":         "udp://gateway.local",
+		"224.0.23.12":           "multicast://224.0.23.12",
+		"224.0.23.12:3671":      "multicast://224.0.23.12:3671",
+		"tcp://10.0.0.7":        "tcp://10.0.0.7",
+		"unicast://:3671?peer=": "unicast://:3671?peer=",
+	} {
+		if actual := TransportURL(address); actual != expected {
+			t.Errorf("%s: expected %s, got %s", address, expected, actual)
+		}
+	}
+}
+
+func TestDial(t *testing.T) {
+	RegisterScheme("Dummy", func(u *url.URL, config DialConfig) (Transport, error) {
+		return dummyTransport{u.Host}, nil
+	})
+
+	transport, err := Dial("dummy://ttyUSB0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dummy, ok := transport.(dummyTransport); !ok || dummy.address != "ttyUSB0" {
+		t.Errorf("Unexpected transport: %+v", transport)
+	}
+
+	// Addresses without scheme are no longer rejected, but expanded into tunnel URLs. The invalid
+	// parameter is reported by the tunnel scheme before anything is dialed.
+	if _, err := Dial("10.0.0.7:3671?heartbeat=soon"); err == nil ||
+		!strings.Contains(err.Error(), "heartbeat") {
+		t.Errorf("Address without scheme was not dialed as tunnel: %v", err)
+	}
+
+	if _, err := Dial("serial://ttyUSB0"); err == nil {
+		t.Error("Unknown scheme was accepted")
+	}
+
+	// Failing dial functions must not leave a typed nil behind.
+	RegisterScheme("failing", func(u *url.URL, config DialConfig) (Transport, error) {
+		var router *Router
+		return router, errors.New("cannot dial")
+	})
+
+	if transport, err := Dial("failing://10.0.0.7"); err == nil || transport != nil {
+		t.Errorf("Expected nil transport and an error, got %v and %v", transport, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Registering a scheme twice did not panic")
+		}
+	}()
+
+	RegisterScheme("udp", dialTunnel)
+}
+
+type recordingTransport struct {
+	dummyTransport
+	inbound chan cemi.Message
+	sent    []cemi.Message
+}
+
+func (transport *recordingTransport) Send(msg cemi.Message) error {
+	transport.sent = append(transport.sent, msg)
+	return nil
+}
+
+func (transport *recordingTransport) Inbound() <-chan cemi.Message {
+	return transport.inbound
+}
+
+func TestGroupTransport(t *testing.T) {
+	transport := &recordingTransport{inbound: make(chan cemi.Message)}
+	client := NewGroupTransport(transport)
+
+	event := GroupEvent{Command: GroupWrite, Destination: cemi.NewGroupAddr3(1, 2, 3), Data: []byte{1}}
+	if err := client.Send(event); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transport.sent) != 1 {
+		t.Fatalf("Unexpected frames: %v", transport.sent)
+	} else if req, ok := transport.sent[0].(*cemi.LDataReq); !ok ||
+		cemi.GroupAddr(req.Destination) != event.Destination {
+		t.Errorf("Unexpected frame: %+v", transport.sent[0])
+	}
+
+	transport.inbound <- &cemi.LDataInd{LData: buildGroupOutbound(event, cemi.PrioLow)}
+
+	if received := <-client.Inbound(); received.Destination != event.Destination ||
+		received.Command != GroupWrite {
+		t.Errorf("Unexpected event: %+v", received)
+	}
+
+	close(transport.inbound)
+
+	if _, open := <-client.Inbound(); open {
+		t.Error("Inbound channel is still open")
+	}
+}
+
+func TestOutboundFrame(t *testing.T) {
+	ldata := buildGroupOutbound(GroupEvent{Destination: cemi.NewGroupAddr3(1, 2, 3)}, cemi.PrioLow)
+
+	for transport, expected := range map[Transport]cemi.MessageCode{
+		&Router{}:             cemi.LDataIndCode,
+		&Tunnel{}:             cemi.LDataReqCode,
+		&BusConn{}:            cemi.LDataReqCode,
+		&recordingTransport{}: cemi.LDataReqCode,
+	} {
+		if msg := OutboundFrame(transport, ldata); msg.MessageCode() != expected {
+			t.Errorf("Expected %v for %T, got %v", expected, transport, msg.MessageCode())
+		}
+	}
+}
@@ -4,8 +4,10 @@
 package knx
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net"
 	"sync"
 	"time"
 
@@ -30,6 +32,19 @@ type TunnelConfig struct {
 
 	// UseTCP configures whether to connect to the gateway using TCP.
 	UseTCP bool
+
+	// FailWhenBusy makes connection requests fail as soon as the gateway reports that it has no
+	// free connections, instead of retrying until the response timeout is reached.
+	FailWhenBusy bool
+
+	// Reconnect determines how a lost connection is re-established.
+	Reconnect ReconnectPolicy
+
+	// Scheduler determines how outbound telegrams are queued and paced.
+	Scheduler SchedulerConfig
+
+	// Hooks are notified about frames, packets, resends, heartbeats and reconnects.
+	Hooks Hooks
 }
 
 // DefaultTunnelConfig is a good default configuration for a Tunnel client.
@@ -39,6 +54,8 @@ var DefaultTunnelConfig = TunnelConfig{
 	ResponseTimeout:   10 * time.Second,
 	SendLocalAddress:  false,
 	UseTCP:            false,
+	Reconnect:         DefaultReconnectPolicy,
+	Scheduler:         DefaultSchedulerConfig,
 }
 
 // checkTunnelConfig makes sure that the configuration is actually usable.
@@ -55,25 +72,36 @@ func checkTunnelConfig(config TunnelConfig) TunnelConfig {
 		config.ResponseTimeout = DefaultTunnelConfig.ResponseTimeout
 	}
 
+	config.Reconnect = checkReconnectPolicy(config.Reconnect)
+	config.Scheduler = checkSchedulerConfig(config.Scheduler)
+
 	return config
 }
 
 var (
 	errResponseTimeout = errors.New("response timeout reached")
+	errNotConnected    = errors.New("tunnel is not connected")
+	errTunnelClosed    = errors.New("tunnel has been closed")
 )
 
 // A Tunnel provides methods to communicate with a KNXnet/IP gateway.
 type Tunnel struct {
 	// Communication methods
+	sockMu sync.Mutex
 	sock   knxnet.Socket
+	dial   func() (knxnet.Socket, error)
 	config TunnelConfig
 
+	// Connection state
+	state stateWatcher
+
 	// Connection information
 	layer   knxnet.TunnelLayer
 	channel uint8
 	control knxnet.HostInfo
 
 	// For outgoing requests
+	sched     *scheduler
 	seqMu     sync.Mutex
 	seqNumber uint8
 	ack       chan *knxnet.TunnelRes
@@ -87,8 +115,46 @@ type Tunnel struct {
 	wait sync.WaitGroup
 }
 
+// socket returns the socket which is currently in use.
+func (conn *Tunnel) socket() knxnet.Socket {
+	conn.sockMu.Lock()
+	defer conn.sockMu.Unlock()
+
+	return conn.sock
+}
+
+// redial replaces the socket with a freshly dialed one and closes the previous socket. It does
+// nothing if the tunnel has no means to dial a socket.
+func (conn *Tunnel) redial() error {
+	if conn.dial == nil {
+		return nil
+	}
+
+	sock, err := conn.dial()
+	if err != nil {
+		return err
+	}
+
+	sock = conn.config.Hooks.socket(sock)
+
+	conn.sockMu.Lock()
+	prev := conn.sock
+	conn.sock = sock
+	conn.sockMu.Unlock()
+
+	prev.Close()
+
+	// Nobody reads from the previous socket anymore. Drain it, so that its worker can terminate.
+	go func() {
+		for range prev.Inbound() {
+		}
+	}()
+
+	return nil
+}
+
 func (conn *Tunnel) hostInfo() (knxnet.HostInfo, error) {
-	addr := conn.sock.LocalAddr()
+	addr := conn.socket().LocalAddr()
 	if conn.config.SendLocalAddress && !conn.config.UseTCP {
 		return knxnet.HostInfoFromAddress(addr)
 	} else {
@@ -105,8 +171,10 @@ func (conn *Tunnel) hostInfo() (knxnet.HostInfo, error) {
 
 // requestConn repeatedly sends a connection request through the socket until the configured
 // reponse timeout is reached or a response is received. A response that renders the gateway as busy
-// will not stop requestConn.
+// will not stop requestConn, unless FailWhenBusy is set. But if the gateway remains busy until the
+// timeout is reached, the busy status is returned instead of a timeout error.
 func (conn *Tunnel) requestConn() (err error) {
+	sock := conn.socket()
 
 	hostInfo, err := conn.hostInfo()
 	if err != nil {
@@ -122,7 +190,7 @@ func (conn *Tunnel) requestConn() (err error) {
 	}
 
 	// Send the initial request.
-	err = conn.sock.Send(req)
+	err = sock.Send(req)
 	if err != nil {
 		return
 	}
@@ -134,22 +202,33 @@ func (conn *Tunnel) requestConn() (err error) {
 	// Setup timeout.
 	timeout := time.After(conn.config.ResponseTimeout)
 
+	// Remembers whether the gateway told us it is busy.
+	var busy knxnet.ErrCode
+
 	// Cycle until a request gets a response.
 	for {
 		select {
+		// Termination has been requested.
+		case <-conn.done:
+			return errTunnelClosed
+
 		// Timeout reached.
 		case <-timeout:
+			if busy != knxnet.NoError {
+				return busy
+			}
+
 			return errResponseTimeout
 
 		// Resend timer triggered.
 		case <-ticker.C:
-			err = conn.sock.Send(req)
+			err = sock.Send(req)
 			if err != nil {
 				return
 			}
 
 		// A message has been received or the channel has been closed.
-		case msg, open := <-conn.sock.Inbound():
+		case msg, open := <-sock.Inbound():
 			if !open {
 				return errors.New("socket's inbound channel has been closed")
 			}
@@ -169,6 +248,11 @@ func (conn *Tunnel) requestConn() (err error) {
 
 				// The gateway is busy, but we don't stop yet.
 				case knxnet.ErrNoMoreConnections, knxnet.ErrNoMoreUniqueConnections:
+					if conn.config.FailWhenBusy {
+						return res.Status
+					}
+
+					busy = res.Status
 					continue
 
 				// Connection request has been denied.
@@ -185,10 +269,11 @@ func (conn *Tunnel) requestConn() (err error) {
 func (conn *Tunnel) requestConnState(
 	heartbeat <-chan knxnet.ErrCode,
 ) (knxnet.ErrCode, error) {
+	sock := conn.socket()
 	req := &knxnet.ConnStateReq{Channel: conn.channel, Status: 0, Control: conn.control}
 
 	// Send first connection state request
-	err := conn.sock.Send(req)
+	err := sock.Send(req)
 	if err != nil {
 		return knxnet.ErrConnectionID, err
 	}
@@ -208,7 +293,7 @@ func (conn *Tunnel) requestConnState(
 
 		// Resend timer fired.
 		case <-ticker.C:
-			err := conn.sock.Send(req)
+			err := sock.Send(req)
 			if err != nil {
 				return knxnet.ErrConnectionID, err
 			}
@@ -226,19 +311,34 @@ func (conn *Tunnel) requestConnState(
 
 // requestDisc sends a disconnect request to the gateway.
 func (conn *Tunnel) requestDisc() error {
-	return conn.sock.Send(&knxnet.DiscReq{
+	return conn.socket().Send(&knxnet.DiscReq{
 		Channel: conn.channel,
 		Status:  0,
 		Control: conn.control,
 	})
 }
 
+// send transmits the frame using requestTunnel and informs the hooks about the outcome.
+func (conn *Tunnel) send(data cemi.Message) error {
+	err := conn.requestTunnel(data)
+	conn.config.Hooks.frameOut(data, err)
+
+	return err
+}
+
 // requestTunnel sends a tunnel request to the gateway and waits for an appropriate acknowledgement.
 func (conn *Tunnel) requestTunnel(data cemi.Message) error {
 	// Sequence numbers cannot be reused, therefore we must protect against that.
 	conn.seqMu.Lock()
 	defer conn.seqMu.Unlock()
 
+	// Don't bother sending while the connection is being re-established.
+	if conn.state.get() != StateConnected {
+		return errNotConnected
+	}
+
+	sock := conn.socket()
+
 	var seqNumber uint8
 
 	if !conn.config.UseTCP {
@@ -253,7 +353,9 @@ func (conn *Tunnel) requestTunnel(data cemi.Message) error {
 	}
 
 	// Send initial request.
-	err := conn.sock.Send(req)
+	start := time.Now()
+
+	err := sock.Send(req)
 	if err != nil {
 		return err
 	}
@@ -279,7 +381,9 @@ func (conn *Tunnel) requestTunnel(data cemi.Message) error {
 
 		// Resend timer fired.
 		case <-ticker.C:
-			err := conn.sock.Send(req)
+			conn.config.Hooks.resend()
+
+			err := sock.Send(req)
 			if err != nil {
 				return err
 			}
@@ -297,6 +401,7 @@ func (conn *Tunnel) requestTunnel(data cemi.Message) error {
 
 			// Gateway has received the request, therefore we can increase on our side.
 			conn.seqNumber++
+			conn.config.Hooks.roundTrip(time.Since(start))
 
 			// Check if the response confirms the tunnel request.
 			if res.Status == 0 {
@@ -320,8 +425,11 @@ func (conn *Tunnel) performHeartbeat(
 			util.Log(conn, "Error while requesting connection state: %v", err)
 		} else {
 			util.Log(conn, "Bad connection state: %v", state)
+			err = state
 		}
 
+		conn.config.Hooks.heartbeatFailed(err)
+
 		// Write to timeout as an indication that the heartbeat has failed.
 		select {
 		case <-conn.done:
@@ -338,7 +446,7 @@ func (conn *Tunnel) handleDiscReq(req *knxnet.DiscReq) error {
 	}
 
 	// We don't need to check if this errors or not. It doesn't matter.
-	conn.sock.Send(&knxnet.DiscRes{Channel: req.Channel, Status: 0})
+	conn.socket().Send(&knxnet.DiscRes{Channel: req.Channel, Status: 0})
 
 	return nil
 }
@@ -356,6 +464,8 @@ func (conn *Tunnel) handleDiscRes(res *knxnet.DiscRes) error {
 // pushInbound sends the message through the inbound channel. If the sending blocks, it will launch
 // a goroutine which will do the sending.
 func (conn *Tunnel) pushInbound(msg cemi.Message) {
+	conn.config.Hooks.frameIn(msg)
+
 	select {
 	case conn.inbound <- msg:
 
@@ -401,7 +511,7 @@ func (conn *Tunnel) handleTunnelReq(req *knxnet.TunnelReq, seqNumber *uint8) err
 	}
 
 	// Send the acknowledgement.
-	return conn.sock.Send(&knxnet.TunnelRes{
+	return conn.socket().Send(&knxnet.TunnelRes{
 		Channel:   conn.channel,
 		SeqNumber: req.SeqNumber,
 		Status:    0,
@@ -467,6 +577,8 @@ var (
 
 // process incoming packets.
 func (conn *Tunnel) process() error {
+	sock := conn.socket()
+
 	heartbeat := make(chan knxnet.ErrCode)
 	defer close(heartbeat)
 
@@ -492,7 +604,7 @@ func (conn *Tunnel) process() error {
 			go conn.performHeartbeat(heartbeat, timeout)
 
 		// A message has been received or the channel is closed.
-		case msg, open := <-conn.sock.Inbound():
+		case msg, open := <-sock.Inbound():
 			if !open {
 				return errInboundClosed
 			}
@@ -540,6 +652,59 @@ func (conn *Tunnel) process() error {
 	}
 }
 
+// reconnect re-establishes the connection according to the configured ReconnectPolicy. Every
+// attempt dials a fresh socket, so a gateway that has been restarted or that has changed its address
+// is picked up again.
+func (conn *Tunnel) reconnect(cause error) error {
+	policy := conn.config.Reconnect
+
+	if !policy.enabled() {
+		return cause
+	}
+
+	// Without a fresh socket there is no way to recover from a closed one.
+	if conn.dial == nil && errors.Is(cause, errInboundClosed) {
+		return cause
+	}
+
+	conn.state.set(StateReconnecting, 0, cause)
+
+	// The gateway might still consider our channel to be open. Release it on a best-effort basis.
+	if errors.Is(cause, errHeartbeatFailed) {
+		conn.requestDisc()
+	}
+
+	for attempts := 0; ; {
+		err := conn.redial()
+		if err == nil {
+			err = conn.requestConn()
+		}
+
+		if err == nil {
+			conn.config.Hooks.reconnect(attempts+1, nil)
+			conn.state.set(StateConnected, 0, nil)
+			return nil
+		}
+
+		attempts++
+		conn.config.Hooks.reconnect(attempts, err)
+		util.Log(conn, "Reconnect attempt %d failed: %v", attempts, err)
+
+		if policy.exhausted(attempts) {
+			return fmt.Errorf("giving up after %d reconnect attempts: %w", attempts, err)
+		}
+
+		conn.state.set(StateReconnecting, attempts, err)
+
+		select {
+		case <-conn.done:
+			return errTunnelClosed
+
+		case <-time.After(policy.delay(attempts)):
+		}
+	}
+}
+
 // serve serves the tunnel connection. It can sustain certain failures. This method will try to
 // reconnect in case of a heartbeat failure or disconnect.
 func (conn *Tunnel) serve() {
@@ -551,7 +716,6 @@ func (conn *Tunnel) serve() {
 	defer conn.wait.Done()
 
 	for {
-		util.Log(conn, "")
 		err := conn.process()
 
 		if err != nil {
@@ -559,34 +723,26 @@ func (conn *Tunnel) serve() {
 		}
 
 		// Check if we can try again.
-		if errors.Is(err, errDisconnected) || errors.Is(err, errHeartbeatFailed) {
+		if errors.Is(err, errDisconnected) || errors.Is(err, errHeartbeatFailed) ||
+			errors.Is(err, errInboundClosed) {
 			util.Log(conn, "Attempting reconnect")
 
-			//reconnErr := conn.requestConn()
-			//
-			//if reconnErr == nil {
-			//	util.Log(conn, "Reconnect succeeded")
-			//	continue
-			//}
+			err = conn.reconnect(err)
+			if err == nil {
+				util.Log(conn, "Reconnect succeeded")
+				continue
+			}
 
-			//util.Log(conn, "Reconnect failed: %v", reconnErr)
-			reConn(conn)
-			continue
+			util.Log(conn, "Reconnect failed: %v", err)
 		}
 
-		return
-	}
-}
-func reConn(conn *Tunnel) {
-	reconnErr := conn.requestConn()
+		if errors.Is(err, errTunnelClosed) {
+			err = nil
+		}
+
+		conn.state.set(StateClosed, 0, err)
 
-	if reconnErr == nil {
-		util.Log(conn, "Reconnect succeeded")
 		return
-	} else {
-		util.Log(conn, "Reconnect failed: %v", reconnErr)
-		time.Sleep(conn.config.HeartbeatInterval)
-		reConn(conn)
 	}
 }
 
@@ -597,23 +753,28 @@ func NewTunnel(
 	layer knxnet.TunnelLayer,
 	config TunnelConfig,
 ) (tunnel *Tunnel, err error) {
-	var sock knxnet.Socket
+	// Sockets are dialed again when reconnecting, as the gateway might have changed.
+	dial := func() (knxnet.Socket, error) {
+		if config.UseTCP {
+			return knxnet.DialTunnelTCP(gatewayAddr)
+		}
 
-	// Create socket which will be used for communication.
-	if config.UseTCP {
-		sock, err = knxnet.DialTunnelTCP(gatewayAddr)
-	} else {
-		sock, err = knxnet.DialTunnelUDP(gatewayAddr)
+		return knxnet.DialTunnelUDP(gatewayAddr)
 	}
 
+	// Create socket which will be used for communication.
+	sock, err := dial()
 	if err != nil {
 		return nil, err
 	}
 
+	config = checkTunnelConfig(config)
+
 	// Initialize the Client structure.
 	client := &Tunnel{
-		sock:    sock,
-		config:  checkTunnelConfig(config),
+		sock:    config.Hooks.socket(sock),
+		dial:    dial,
+		config:  config,
 		layer:   layer,
 		ack:     make(chan *knxnet.TunnelRes),
 		inbound: make(chan cemi.Message),
@@ -627,6 +788,9 @@ func NewTunnel(
 		return nil, err
 	}
 
+	client.state.set(StateConnected, 0, nil)
+	client.sched = newScheduler(client.send, client.config.Scheduler)
+
 	client.wait.Add(1)
 	go client.serve()
 
@@ -642,42 +806,101 @@ func (conn *Tunnel) Close() {
 		close(conn.done)
 		conn.wait.Wait()
 
-		conn.sock.Close()
+		// Telegrams which are still queued will fail, since the server routine has exited.
+		conn.sched.close()
+
+		conn.socket().Close()
+		conn.state.set(StateClosed, 0, nil)
 	})
 }
 
+// State returns the current state of the connection.
+func (conn *Tunnel) State() ConnState {
+	return conn.state.get()
+}
+
+// WatchState returns a channel which receives an event whenever the connection state changes or a
+// reconnection attempt fails. The channel is closed once the tunnel has been closed or the returned
+// stop function has been called. Events are delivered without blocking the tunnel; a watcher that
+// falls behind loses its oldest events.
+func (conn *Tunnel) WatchState() (<-chan ConnEvent, func()) {
+	return conn.state.watch()
+}
+
+// LocalAddr returns the local address of the socket that is currently in use.
+func (conn *Tunnel) LocalAddr() net.Addr {
+	return conn.socket().LocalAddr()
+}
+
 // Inbound retrieves the channel which transmits incoming data. The channel is closed when the
 // underlying Socket closes its inbound channel or when the connection is terminated.
 func (conn *Tunnel) Inbound() <-chan cemi.Message {
 	return conn.inbound
 }
 
-// Send relays a tunnel request to the gateway with the given contents.
+// Send relays a tunnel request to the gateway with the given contents. The request is queued
+// according to its priority and waits for its turn, which includes waiting for the rate limit. Send
+// blocks until the gateway has acknowledged the request; use Enqueue to avoid waiting.
 func (conn *Tunnel) Send(data cemi.Message) error {
-	return conn.requestTunnel(data)
+	return conn.Enqueue(data, nil).Wait()
+}
+
+// Enqueue queues a tunnel request without waiting for it to be sent. Requests are sent in order of
+// their priority, which is taken from the L_Data control field. The callback, if not nil, is invoked
+// once the request has been acknowledged or has failed. It runs on the sending goroutine and must
+// not block.
+func (conn *Tunnel) Enqueue(data cemi.Message, callback func(error)) *SendFuture {
+	return conn.sched.enqueue(data, messagePriority(data), callback)
+}
+
+// QueueDepth returns the number of requests that are waiting to be sent.
+func (conn *Tunnel) QueueDepth() int {
+	return conn.sched.queueDepth()
 }
 
 // GroupTunnel is a Tunnel that provides only a group communication interface.
 type GroupTunnel struct {
 	*Tunnel
 	inbound chan GroupEvent
+	confs   *confirmations
 }
 
+var _ GroupConfirmer = (*GroupTunnel)(nil)
+
 // NewGroupTunnel creates a new Tunnel for group communication.
 func NewGroupTunnel(gatewayAddr string, config TunnelConfig) (gt GroupTunnel, err error) {
 	gt.Tunnel, err = NewTunnel(gatewayAddr, knxnet.TunnelLayerData, config)
 
 	if err == nil {
 		gt.inbound = make(chan GroupEvent)
-		go serveGroupInbound(gt.Tunnel.Inbound(), gt.inbound)
+		gt.confs = &confirmations{}
+		go serveGroupInbound(gt.Tunnel.Inbound(), gt.inbound, gt.confs)
 	}
 
 	return
 }
 
-// Send a group communication.
+// Send a group communication with low priority, which group communications have always had. It
+// waits behind queued telegrams of higher priority and for the rate limit, and blocks until the
+// gateway has acknowledged it. Use Enqueue to choose another priority or to avoid waiting.
 func (gt *GroupTunnel) Send(event GroupEvent) error {
-	return gt.Tunnel.Send(&cemi.LDataReq{LData: buildGroupOutbound(event)})
+	return gt.Tunnel.Send(&cemi.LDataReq{LData: buildGroupOutbound(event, cemi.PrioLow)})
+}
+
+// SendConfirmed sends a group communication with low priority and waits until the gateway has
+// confirmed its transmission on the bus.
+func (gt *GroupTunnel) SendConfirmed(ctx context.Context, event GroupEvent) error {
+	return gt.confs.send(ctx, event, gt.Send)
+}
+
+// Enqueue queues a group communication with the given priority without waiting for it to be sent.
+// See Tunnel.Enqueue for details.
+func (gt *GroupTunnel) Enqueue(
+	event GroupEvent,
+	prio cemi.Priority,
+	callback func(error),
+) *SendFuture {
+	return gt.Tunnel.Enqueue(&cemi.LDataReq{LData: buildGroupOutbound(event, prio)}, callback)
 }
 
 // Inbound returns the channel on which group communication can be received.
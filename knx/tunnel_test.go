@@ -4,6 +4,7 @@
 package knx
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -20,6 +21,7 @@ func makeTunnelConn(
 		sock:    sock,
 		config:  config,
 		channel: channel,
+		state:   stateWatcher{state: StateConnected},
 		ack:     make(chan *knxnet.TunnelRes),
 		inbound: make(chan cemi.Message, 100),
 	}
@@ -310,6 +312,94 @@ func TestTunnelConn_requestConn(t *testing.T) {
 		})
 	})
 
+	// The gateway remains busy until the timeout is reached.
+	t.Run("BusyTimeout", func(t *testing.T) {
+		client, gateway := newDummySockets()
+
+		t.Run("Gateway", func(t *testing.T) {
+			t.Parallel()
+
+			defer gateway.Close()
+
+			msg := <-gateway.Inbound()
+			if req, ok := msg.(*knxnet.ConnReq); ok {
+				gateway.sendAny(&knxnet.ConnRes{
+					Channel: 0,
+					Status:  knxnet.ErrNoMoreConnections,
+					Control: req.Control,
+				})
+			} else {
+				t.Fatalf("Unexpected incoming message type: %T", msg)
+			}
+		})
+
+		t.Run("Client", func(t *testing.T) {
+			t.Parallel()
+
+			defer client.Close()
+
+			config := DefaultTunnelConfig
+			config.ResponseTimeout = 100 * time.Millisecond
+
+			conn := Tunnel{
+				sock:   client,
+				config: config,
+			}
+
+			err := conn.requestConn()
+			if err != knxnet.ErrCode(knxnet.ErrNoMoreConnections) {
+				t.Fatalf("Expected error %v, got %v", knxnet.ErrNoMoreConnections, err)
+			}
+		})
+	})
+
+	// The busy status is returned straight away if the tunnel should not wait.
+	t.Run("FailWhenBusy", func(t *testing.T) {
+		client, gateway := newDummySockets()
+
+		t.Run("Gateway", func(t *testing.T) {
+			t.Parallel()
+
+			defer gateway.Close()
+
+			msg := <-gateway.Inbound()
+			if req, ok := msg.(*knxnet.ConnReq); ok {
+				gateway.sendAny(&knxnet.ConnRes{
+					Channel: 0,
+					Status:  knxnet.ErrNoMoreUniqueConnections,
+					Control: req.Control,
+				})
+			} else {
+				t.Fatalf("Unexpected incoming message type: %T", msg)
+			}
+		})
+
+		t.Run("Client", func(t *testing.T) {
+			t.Parallel()
+
+			defer client.Close()
+
+			config := DefaultTunnelConfig
+			config.FailWhenBusy = true
+
+			conn := Tunnel{
+				sock:   client,
+				config: config,
+			}
+
+			start := time.Now()
+
+			err := conn.requestConn()
+			if err != knxnet.ErrCode(knxnet.ErrNoMoreUniqueConnections) {
+				t.Fatalf("Expected error %v, got %v", knxnet.ErrNoMoreUniqueConnections, err)
+			}
+
+			if elapsed := time.Since(start); elapsed >= config.ResponseTimeout {
+				t.Errorf("Busy status was reported after %v", elapsed)
+			}
+		})
+	})
+
 	// The gateway doesn't supported the requested connection type.
 	t.Run("Unsupported", func(t *testing.T) {
 		client, gateway := newDummySockets()
@@ -984,3 +1074,158 @@ func TestTunnelConn_handleTunnelRes(t *testing.T) {
 		})
 	})
 }
+
+func TestTunnelConn_reconnect(t *testing.T) {
+	config := DefaultTunnelConfig
+	config.Reconnect = ReconnectPolicy{
+		InitialInterval: time.Millisecond,
+		Jitter:          -1,
+		MaxAttempts:     3,
+	}
+	config = checkTunnelConfig(config)
+
+	t.Run("Ok", func(t *testing.T) {
+		client, gateway := newDummySockets()
+		defer gateway.Close()
+
+		conn := makeTunnelConn(client, config, 1)
+		conn.done = make(chan struct{})
+
+		events, stop := conn.WatchState()
+		defer stop()
+
+		fresh, freshGateway := newDummySockets()
+		defer freshGateway.Close()
+
+		dials := 0
+		conn.dial = func() (knxnet.Socket, error) {
+			dials++
+			if dials < 3 {
+				return nil, errors.New("gateway unreachable")
+			}
+
+			return fresh, nil
+		}
+
+		go func() {
+			msg := <-freshGateway.Inbound()
+			if req, ok := msg.(*knxnet.ConnReq); ok {
+				freshGateway.sendAny(&knxnet.ConnRes{
+					Channel: 2,
+					Status:  knxnet.NoError,
+					Control: req.Control,
+				})
+			}
+		}()
+
+		err := conn.reconnect(errDisconnected)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if conn.socket() != fresh {
+			t.Error("Tunnel did not switch to the freshly dialed socket")
+		}
+
+		if conn.channel != 2 {
+			t.Errorf("Expected channel 2, got %d", conn.channel)
+		}
+
+		expected := []ConnEvent{
+			{State: StateReconnecting, Previous: StateConnected, Attempt: 0},
+			{State: StateReconnecting, Previous: StateReconnecting, Attempt: 1},
+			{State: StateReconnecting, Previous: StateReconnecting, Attempt: 2},
+			{State: StateConnected, Previous: StateReconnecting, Attempt: 0},
+		}
+
+		for _, want := range expected {
+			event := <-events
+			if event.State != want.State || event.Previous != want.Previous ||
+				event.Attempt != want.Attempt {
+				t.Errorf("Expected event %+v, got %+v", want, event)
+			}
+		}
+
+		if conn.State() != StateConnected {
+			t.Errorf("Expected state %v, got %v", StateConnected, conn.State())
+		}
+	})
+
+	t.Run("GiveUp", func(t *testing.T) {
+		client, gateway := newDummySockets()
+		defer client.Close()
+		defer gateway.Close()
+
+		conn := makeTunnelConn(client, config, 1)
+		conn.done = make(chan struct{})
+
+		dials := 0
+		conn.dial = func() (knxnet.Socket, error) {
+			dials++
+			return nil, errors.New("gateway unreachable")
+		}
+
+		err := conn.reconnect(errHeartbeatFailed)
+		if err == nil {
+			t.Fatal("Should not succeed")
+		}
+
+		if dials != config.Reconnect.MaxAttempts {
+			t.Errorf("Expected %d attempts, got %d", config.Reconnect.MaxAttempts, dials)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		client, gateway := newDummySockets()
+		defer client.Close()
+		defer gateway.Close()
+
+		disabled := config
+		disabled.Reconnect.MaxAttempts = -1
+
+		conn := makeTunnelConn(client, disabled, 1)
+		conn.done = make(chan struct{})
+		conn.dial = func() (knxnet.Socket, error) {
+			t.Fatal("Should not dial")
+			return nil, nil
+		}
+
+		err := conn.reconnect(errDisconnected)
+		if err != errDisconnected {
+			t.Fatalf("Expected error %v, got %v", errDisconnected, err)
+		}
+
+		if conn.State() != StateConnected {
+			t.Errorf("State should not have changed, got %v", conn.State())
+		}
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		client, gateway := newDummySockets()
+		defer client.Close()
+		defer gateway.Close()
+
+		// Make sure the termination is noticed while waiting for the next attempt.
+		slow := config
+		slow.Reconnect.InitialInterval = time.Minute
+
+		conn := makeTunnelConn(client, slow, 1)
+		conn.done = make(chan struct{})
+		conn.dial = func() (knxnet.Socket, error) {
+			return nil, errors.New("gateway unreachable")
+		}
+
+		events, _ := conn.WatchState()
+		close(conn.done)
+
+		err := conn.reconnect(errDisconnected)
+		if err != errTunnelClosed {
+			t.Fatalf("Expected error %v, got %v", errTunnelClosed, err)
+		}
+
+		conn.state.set(StateClosed, 0, nil)
+
+		for range events {
+		}
+	})
+}